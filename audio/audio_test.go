@@ -0,0 +1,119 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package audio
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapturePipeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+		wantErr bool
+	}{
+		{"PulseDefault", Options{Source: SourcePulse}, `pulsesrc device="@DEFAULT_MONITOR@" ! `, false},
+		{"PulseDevice", Options{Source: SourcePulse, Device: "alsa_output.pci.monitor"}, `pulsesrc device="alsa_output.pci.monitor" ! `, false},
+		{"PipewireDefault", Options{Source: SourcePipewire}, `pipewiresrc stream-properties="props,stream.capture.sink=true" ! `, false},
+		{"PipewireDevice", Options{Source: SourcePipewire, Device: "42"}, `pipewiresrc target-object="42" ! `, false},
+		{"Test", Options{Source: SourceTest, Bitrate: 64000}, "opusenc bitrate=64000 ", false},
+		{"DefaultBitrate", Options{Source: SourceTest}, "opusenc bitrate=96000 ", false},
+		{"Quote", Options{Source: SourcePulse, Device: `a" ! filesink location="x`}, "", true},
+		{"Bitrate", Options{Source: SourceTest, Bitrate: 1000}, "", true},
+		{"Source", Options{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CapturePipeline(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CapturePipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !strings.Contains(got, tt.want) || !strings.HasSuffix(got, "appsink name="+SinkName+" sync=false") {
+				t.Errorf("CapturePipeline() = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSync_Due(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := &Sync{Latency: 100 * time.Millisecond, MaxLate: 50 * time.Millisecond}
+
+	if due, ok := s.Due(time.Second, start); !ok || !due.Equal(start) {
+		t.Errorf("Due() before video = %v, %v, want now", due, ok)
+	}
+
+	s.Video(10*time.Second, start)
+	if due, ok := s.Due(70*time.Second, start); !ok || !due.Equal(start) {
+		t.Errorf("Due() before anchoring = %v, %v, want now", due, ok)
+	}
+	// the audio capture started a minute before the video
+	s.Audio(70*time.Second, start)
+	tests := []struct {
+		name   string
+		ts     time.Duration
+		now    time.Time
+		want   time.Time
+		wantOk bool
+	}{
+		{"WithVideo", 70 * time.Second, start, start.Add(100 * time.Millisecond), true},
+		{"Ahead", 70*time.Second + 500*time.Millisecond, start, start.Add(600 * time.Millisecond), true},
+		{"SlightlyLate", 70 * time.Second, start.Add(140 * time.Millisecond), start.Add(100 * time.Millisecond), true},
+		{"Late", 69 * time.Second, start, start.Add(-900 * time.Millisecond), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.Due(tt.ts, tt.now)
+			if !got.Equal(tt.want) || ok != tt.wantOk {
+				t.Errorf("Due() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	s.Reset()
+	if due, ok := s.Due(0, start); !ok || !due.Equal(start) {
+		t.Errorf("Due() after Reset = %v, %v, want now", due, ok)
+	}
+}
+
+func TestSync_VideoRestart(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := &Sync{Latency: 100 * time.Millisecond, MaxLate: 50 * time.Millisecond}
+	s.Video(10*time.Second, start)
+	s.Audio(70*time.Second, start)
+
+	// a new video starts its timeline at 0 while the audio capture goes on
+	restart := start.Add(5 * time.Second)
+	s.Reset()
+	s.Video(0, restart)
+	s.Audio(75*time.Second, restart)
+	if due, ok := s.Due(75*time.Second, restart); !ok || !due.Equal(restart.Add(100*time.Millisecond)) {
+		t.Errorf("Due() after restart = %v, %v, want %v", due, ok, restart.Add(100*time.Millisecond))
+	}
+	s.Video(time.Second, restart.Add(time.Second))
+	if due, ok := s.Due(76*time.Second, restart.Add(time.Second)); !ok || !due.Equal(restart.Add(1100*time.Millisecond)) {
+		t.Errorf("Due() with the new video = %v, %v, want %v", due, ok, restart.Add(1100*time.Millisecond))
+	}
+}
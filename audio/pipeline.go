@@ -0,0 +1,108 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package audio builds the GStreamer pipelines of the audio track and syncs its playback to the video.
+package audio
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Audio format of the stream, Opus always runs at 48 kHz.
+const (
+	SampleRate = 48000
+	Channels   = 2
+	// DefaultBitrate is the Opus bitrate in bits per second.
+	DefaultBitrate = 96000
+)
+
+// Element names the pipelines are driven through.
+const (
+	SinkName   = "sink"
+	SourceName = "src"
+)
+
+// Options configure the capture pipeline.
+type Options struct {
+	Source Source
+	// Device is the PulseAudio source or PipeWire node to capture, "" captures the default sink.
+	Device string
+	// Bitrate is the Opus bitrate in bits per second, 0 uses DefaultBitrate.
+	Bitrate int
+}
+
+// quote quotes a property value for the pipeline description.
+func quote(value string) (string, error) {
+	if strings.ContainsAny(value, "\"\\") {
+		return "", errors.Errorf("invalid character in %q", value)
+	}
+	return `"` + value + `"`, nil
+}
+
+// CapturePipeline returns the description of the pipeline capturing audio as Opus packets into an appsink named
+// SinkName.
+func CapturePipeline(o Options) (string, error) {
+	var src string
+	switch o.Source {
+	case SourcePulse:
+		device := o.Device
+		if device == "" {
+			device = "@DEFAULT_MONITOR@"
+		}
+		quoted, err := quote(device)
+		if err != nil {
+			return "", errors.Wrap(err, "device")
+		}
+		src = "pulsesrc device=" + quoted
+	case SourcePipewire:
+		if o.Device == "" {
+			src = `pipewiresrc stream-properties="props,stream.capture.sink=true"`
+			break
+		}
+		quoted, err := quote(o.Device)
+		if err != nil {
+			return "", errors.Wrap(err, "device")
+		}
+		src = "pipewiresrc target-object=" + quoted
+	case SourceTest:
+		src = "audiotestsrc is-live=true wave=sine"
+	default:
+		return "", errors.Errorf("invalid audio source %q", o.Source)
+	}
+	bitrate := o.Bitrate
+	if bitrate == 0 {
+		bitrate = DefaultBitrate
+	}
+	if bitrate < 6000 || bitrate > 510000 {
+		return "", errors.Errorf("bitrate %d outside of the Opus range 6000 to 510000", bitrate)
+	}
+	return fmt.Sprintf(
+		"%s ! audioconvert ! audioresample ! audio/x-raw,rate=%d,channels=%d ! opusenc bitrate=%d frame-size=20 ! appsink name=%s sync=false",
+		src, SampleRate, Channels, bitrate, SinkName,
+	), nil
+}
+
+// PlaybackPipeline is the description of the pipeline playing the Opus packets pushed into an appsrc named
+// SourceName. The packets are timestamped on arrival, Sync decides when they are pushed.
+var PlaybackPipeline = fmt.Sprintf(
+	"appsrc name=%s is-live=true do-timestamp=true format=time caps=audio/x-opus,rate=%d,channels=%d,channel-mapping-family=0 ! opusdec ! audioconvert ! audioresample ! autoaudiosink",
+	SourceName, SampleRate, Channels,
+)
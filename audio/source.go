@@ -0,0 +1,30 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+//go:generate go-enum --marshal --names --values
+package audio
+
+// Source selects where the server captures audio from.
+/*
+ ENUM(
+ pulse // Capture a PulseAudio source, by default the monitor of the default sink. Also works with pipewire-pulse.
+ pipewire // Capture a PipeWire node, by default the default sink.
+ test // Generate a sine tone with audiotestsrc.
+)
+*/
+type Source string
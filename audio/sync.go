@@ -0,0 +1,100 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package audio
+
+import (
+	"sync"
+	"time"
+)
+
+// Default timing of Sync.
+const (
+	// DefaultLatency is how long the client takes from receiving a video fragment to showing it.
+	DefaultLatency = 100 * time.Millisecond
+	// DefaultMaxLate is how late audio may be played before it is dropped.
+	DefaultMaxLate = 50 * time.Millisecond
+)
+
+// Sync schedules audio against the video. The streams have timelines of their own, audio timestamps run from the
+// start of the capture and video timestamps from the start of the current video. The first audio frame received after
+// the video (re)started anchors the audio timeline to the video received at the same time, from then on audio is
+// played when the video of its moment is shown.
+type Sync struct {
+	// Latency delays audio by the time the video takes to be decoded and shown.
+	Latency time.Duration
+	// MaxLate is how late audio may be played before it is dropped.
+	MaxLate time.Duration
+
+	mu    sync.Mutex
+	video time.Duration
+	at    time.Time
+	ok    bool
+	// offset is the audio timestamp minus the video timestamp of the same moment.
+	offset   time.Duration
+	anchored bool
+}
+
+// NewSync creates a Sync with the default timing.
+func NewSync() *Sync {
+	return &Sync{Latency: DefaultLatency, MaxLate: DefaultMaxLate}
+}
+
+// Video tells that the video fragment at timestamp ts was received at now.
+func (s *Sync) Video(ts time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.video = ts
+	s.at = now
+	s.ok = true
+}
+
+// Audio tells that the audio frame at timestamp ts was received at now. The first one after the video (re)started
+// anchors the audio timeline.
+func (s *Sync) Audio(ts time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ok || s.anchored {
+		return
+	}
+	s.offset = ts - s.video - now.Sub(s.at)
+	s.anchored = true
+}
+
+// Reset forgets the video position and the anchor of the audio, e.g. after a new video restarted the video timeline.
+func (s *Sync) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ok = false
+	s.anchored = false
+}
+
+// Due returns when the audio at timestamp ts is to be played, and false if it is too late to be played at now.
+// Until the audio is anchored to the video it is played right away.
+func (s *Sync) Due(ts time.Duration, now time.Time) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ok || !s.anchored {
+		return now, true
+	}
+	due := s.at.Add(ts - s.offset - s.video + s.Latency)
+	if now.Sub(due) > s.MaxLate {
+		return due, false
+	}
+	return due, true
+}
@@ -25,6 +25,7 @@ import (
 	"image"
 	"image/color"
 	"io"
+	"math/bits"
 
 	"github.com/pkg/errors"
 )
@@ -33,6 +34,10 @@ import (
 // feature.
 var ErrUnsupported = errors.New("bmp: unsupported BMP image")
 
+// ErrShortBuffer means that the input BMP image holds less pixel data than its
+// header declares.
+var ErrShortBuffer = errors.New("bmp: pixel data shorter than declared by the header")
+
 func readUint16(b []byte) uint16 {
 	return uint16(b[0]) | uint16(b[1])<<8
 }
@@ -55,11 +60,30 @@ func NewNBGRA(r image.Rectangle) *NBGRA {
 	return &NBGRA{buf, 4 * w, r}
 }
 
-// BGRADecoder is used to decode an BGRA BMP image of the same size as the one
-// used for construction.
+// RGBA returns a copy of the image as an *image.RGBA, e.g. to encode it with image/png.
+func (p *NBGRA) RGBA() *image.RGBA {
+	dst := image.NewRGBA(p.Rect)
+	w := 4 * p.Rect.Dx()
+	for y := 0; y < p.Rect.Dy(); y++ {
+		src := p.Pix[y*p.Stride : y*p.Stride+w]
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+w]
+		for x := 0; x < w; x += 4 {
+			row[x+0] = src[x+2]
+			row[x+1] = src[x+1]
+			row[x+2] = src[x+0]
+			row[x+3] = src[x+3]
+		}
+	}
+	return dst
+}
+
+// BGRADecoder is used to decode a sequence of BMP images into BGRA, e.g. the
+// snapshots of a video. The header of the last image is cached and only parsed
+// again when it changes.
 type BGRADecoder struct {
 	cfg *Config
-	hdr int64
+	hdr []byte
+	row func(dst, src []byte)
 }
 
 // NewBGRADecoder returns a new BGRADecoder.
@@ -67,52 +91,49 @@ func NewBGRADecoder() *BGRADecoder {
 	return &BGRADecoder{}
 }
 
-// Decode reads an BGRA BMP image from r and write it to dst (if not nil). If
-// dst is nil, then a new one is allocated. If topDown is false, the image rows
-// will be read bottom-up.
+// Decode reads a 24 or 32 bits per pixel BMP image from src and writes it to
+// dst. If dst is nil or its size differs from the image, a new one is
+// allocated. Both top-down and bottom-up images are supported, rows are
+// expected to be padded to 4 bytes.
 func (dec *BGRADecoder) Decode(src []byte, dst *NBGRA) (*NBGRA, error) {
-	var c Config
-
-	if dec.cfg != nil {
-		c = *dec.cfg
-		src = src[dec.hdr:]
-	} else {
-		buf := bytes.NewBuffer(src)
-
-		var err error
-		c, err = DecodeConfig(buf)
+	if dec.cfg == nil || !bytes.HasPrefix(src, dec.hdr) {
+		dec.cfg = nil
+		c, err := DecodeConfig(bytes.NewReader(src))
 		if err != nil {
 			return nil, fmt.Errorf("bmp: failed to decode config: %w", err)
 		}
-
+		row, err := c.rowDecoder()
+		if err != nil {
+			return nil, err
+		}
 		dec.cfg = &c
-		dec.hdr = int64(len(src) - buf.Len())
-		src = buf.Bytes() // swap to unread bytes
+		dec.hdr = append(dec.hdr[:0], src[:c.HeaderLen]...)
+		dec.row = row
 	}
+	c := *dec.cfg
 
-	if dst != nil {
-		if c.Width != dst.Rect.Dx() || c.Height != dst.Rect.Dy() {
-			return nil, fmt.Errorf(
-				"bmp: image size mismatch: %dx%d != %dx%d",
-				c.Width, c.Height, dst.Rect.Dx(), dst.Rect.Dy())
-		}
-	} else {
+	stride := c.Stride()
+	if c.Offset > len(src) || c.Height > 0 && stride > (len(src)-c.Offset)/c.Height {
+		return nil, fmt.Errorf(
+			"bmp: %d bytes of pixel data for %dx%d at %d bits per pixel: %w",
+			len(src)-c.Offset, c.Width, c.Height, c.BPP, ErrShortBuffer)
+	}
+	src = src[c.Offset:]
+
+	if dst == nil || c.Width != dst.Rect.Dx() || c.Height != dst.Rect.Dy() {
 		dst = NewNBGRA(image.Rect(0, 0, c.Width, c.Height))
 	}
 
-	if c.TopDown {
-		// This is rarely the case.
-		copy(dst.Pix, src)
-	} else {
-		y1 := 0
-		y2 := c.Height - 1
-		for y1 < c.Height {
-			b := src[y1*dst.Stride : y1*dst.Stride+c.Width*4]
-			p := dst.Pix[y2*dst.Stride : y2*dst.Stride+c.Width*4]
-			copy(p, b)
-			y1++
-			y2--
+	if c.Width == 0 {
+		// Nothing to copy, and a huge height would only waste time.
+		return dst, nil
+	}
+	for y := 0; y < c.Height; y++ {
+		sy := y
+		if !c.TopDown {
+			sy = c.Height - 1 - y
 		}
+		dec.row(dst.Pix[y*dst.Stride:y*dst.Stride+4*c.Width], src[sy*stride:sy*stride+stride])
 	}
 
 	return dst, nil
@@ -124,17 +145,104 @@ type Config struct {
 	BPP        int
 	TopDown    bool
 	AllowAlpha bool
+	// HeaderLen is the length of the file and info headers, including the
+	// bitfield masks following a BITMAPINFOHEADER.
+	HeaderLen int
+	// Offset is where the pixel data starts.
+	Offset int
+	// Masks are the red, green, blue and alpha masks of a BI_BITFIELDS image,
+	// or all zero if the pixels are stored as plain BGR(A).
+	Masks [4]uint32
+}
+
+// Stride returns the length of a row of pixel data, including padding.
+func (c Config) Stride() int {
+	return (c.BPP*c.Width + 31) / 32 * 4
+}
+
+// rowDecoder returns a function converting a row of pixel data to BGRA.
+func (c Config) rowDecoder() (func(dst, src []byte), error) {
+	switch {
+	case c.Masks != [4]uint32{}:
+		return bitfieldRow(c.BPP, c.Masks), nil
+	case c.BPP == 32:
+		return func(dst, src []byte) {
+			copy(dst, src)
+		}, nil
+	case c.BPP == 24:
+		return func(dst, src []byte) {
+			for x, s := 0, 0; x < len(dst); x, s = x+4, s+3 {
+				dst[x+0] = src[s+0]
+				dst[x+1] = src[s+1]
+				dst[x+2] = src[s+2]
+				dst[x+3] = 0xFF
+			}
+		}, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// bitfield extracts a channel described by a contiguous mask from a pixel.
+type bitfield struct {
+	mask  uint32
+	shift int
+	bits  int
+}
+
+func newBitfield(mask uint32) bitfield {
+	return bitfield{mask, bits.TrailingZeros32(mask), bits.OnesCount32(mask)}
+}
+
+// value returns the channel scaled to 8 bits, or def if the mask is empty.
+func (f bitfield) value(px uint32, def uint8) uint8 {
+	if f.mask == 0 {
+		return def
+	}
+	v := (px & f.mask) >> f.shift
+	if f.bits >= 8 {
+		return uint8(v >> (f.bits - 8))
+	}
+	return uint8(v * 0xFF / (1<<f.bits - 1))
+}
+
+// bitfieldRow returns a function converting a row of 16 or 32 bits per pixel
+// BI_BITFIELDS pixels to BGRA.
+func bitfieldRow(bpp int, masks [4]uint32) func(dst, src []byte) {
+	r, g, b, a := newBitfield(masks[0]), newBitfield(masks[1]), newBitfield(masks[2]), newBitfield(masks[3])
+	return func(dst, src []byte) {
+		for x, s := 0, 0; x < len(dst); x, s = x+4, s+bpp/8 {
+			var px uint32
+			if bpp == 16 {
+				px = uint32(readUint16(src[s:]))
+			} else {
+				px = readUint32(src[s:])
+			}
+			dst[x+0] = b.value(px, 0)
+			dst[x+1] = g.value(px, 0)
+			dst[x+2] = r.value(px, 0)
+			dst[x+3] = a.value(px, 0xFF)
+		}
+	}
+}
+
+// validMask reports whether mask is a contiguous run of bits fitting in bpp bits.
+func validMask(mask uint32, bpp int) bool {
+	if bpp < 32 && mask>>bpp != 0 {
+		return false
+	}
+	v := mask >> bits.TrailingZeros32(mask)
+	return v&(v+1) == 0
 }
 
 // DecodeConfig returns the color model and dimensions of a BMP image without
 // decoding the entire image.
-// Limitation: The file must be 8, 24 or 32 bits per pixel.
+// Limitation: The file must be 8, 24 or 32 bits per pixel, or 16 or 32 bits
+// per pixel with BI_BITFIELDS masks.
 func DecodeConfig(r io.Reader) (Config, error) {
-	config, bpp, topDown, allowAlpha, err := decodeConfig(r)
-	return Config{config, bpp, topDown, allowAlpha}, err
+	return decodeConfig(r)
 }
 
-func decodeConfig(r io.Reader) (config image.Config, bitsPerPixel int, topDown bool, allowAlpha bool, err error) {
+func decodeConfig(r io.Reader) (Config, error) {
 	// We only support those BMP images with one of the following DIB headers:
 	// - BITMAPINFOHEADER (40 bytes)
 	// - BITMAPV4HEADER (108 bytes)
@@ -144,57 +252,93 @@ func decodeConfig(r io.Reader) (config image.Config, bitsPerPixel int, topDown b
 		infoHeaderLen   = 40
 		v4InfoHeaderLen = 108
 		v5InfoHeaderLen = 124
+		masksLen        = 12
+	)
+	const (
+		biRGB       = 0
+		biBitfields = 3
 	)
 	var b [1024]byte
 	if _, err := io.ReadFull(r, b[:fileHeaderLen+4]); err != nil {
 		if err == io.EOF {
 			err = io.ErrUnexpectedEOF
 		}
-		return image.Config{}, 0, false, false, err
+		return Config{}, err
 	}
 	if string(b[:2]) != "BM" {
-		return image.Config{}, 0, false, false, errors.New("bmp: invalid format")
+		return Config{}, errors.New("bmp: invalid format")
 	}
 	offset := readUint32(b[10:14])
 	infoLen := readUint32(b[14:18])
 	if infoLen != infoHeaderLen && infoLen != v4InfoHeaderLen && infoLen != v5InfoHeaderLen {
-		return image.Config{}, 0, false, false, ErrUnsupported
+		return Config{}, ErrUnsupported
 	}
 	if _, err := io.ReadFull(r, b[fileHeaderLen+4:fileHeaderLen+infoLen]); err != nil {
 		if err == io.EOF {
 			err = io.ErrUnexpectedEOF
 		}
-		return image.Config{}, 0, false, false, err
+		return Config{}, err
 	}
+	c := Config{HeaderLen: int(fileHeaderLen + infoLen)}
 	width := int(int32(readUint32(b[18:22])))
 	height := int(int32(readUint32(b[22:26])))
 	if height < 0 {
-		height, topDown = -height, true
+		height, c.TopDown = -height, true
 	}
 	if width < 0 || height < 0 {
-		return image.Config{}, 0, false, false, ErrUnsupported
+		return Config{}, ErrUnsupported
 	}
-	// We only support 1 plane and 8, 24 or 32 bits per pixel and no
-	// compression.
+	c.Width, c.Height = width, height
+	// We only support 1 plane and 8, 24 or 32 bits per pixel without
+	// compression, or 16 or 32 bits per pixel with BI_BITFIELDS.
 	planes, bpp, compression := readUint16(b[26:28]), readUint16(b[28:30]), readUint32(b[30:34])
-	// if compression is set to BI_BITFIELDS, but the bitmask is set to the default bitmask
-	// that would be used if compression was set to 0, we can continue as if compression was 0
-	if compression == 3 && infoLen > infoHeaderLen &&
-		readUint32(b[54:58]) == 0xff0000 && readUint32(b[58:62]) == 0xff00 &&
-		readUint32(b[62:66]) == 0xff && readUint32(b[66:70]) == 0xff000000 {
-		compression = 0
+	c.BPP = int(bpp)
+	if compression == biBitfields {
+		if bpp != 16 && bpp != 32 {
+			return Config{}, ErrUnsupported
+		}
+		// The masks of a BITMAPINFOHEADER follow it, later headers include
+		// them and an alpha mask.
+		if infoLen == infoHeaderLen {
+			if _, err := io.ReadFull(r, b[c.HeaderLen:c.HeaderLen+masksLen]); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return Config{}, err
+			}
+			c.HeaderLen += masksLen
+		}
+		c.Masks = [4]uint32{readUint32(b[54:58]), readUint32(b[58:62]), readUint32(b[62:66])}
+		if infoLen > infoHeaderLen {
+			c.Masks[3] = readUint32(b[66:70])
+		}
+		for _, m := range c.Masks {
+			if !validMask(m, c.BPP) {
+				return Config{}, ErrUnsupported
+			}
+		}
+		// if the bitmask is the default bitmask that would be used if
+		// compression was set to 0, we can continue as if compression was 0
+		if bpp == 32 && c.Masks == [4]uint32{0xff0000, 0xff00, 0xff, 0xff000000} {
+			c.Masks = [4]uint32{}
+		}
+		compression = biRGB
+	}
+	if planes != 1 || compression != biRGB {
+		return Config{}, ErrUnsupported
 	}
-	if planes != 1 || compression != 0 {
-		return image.Config{}, 0, false, false, ErrUnsupported
+	if int(offset) < c.HeaderLen {
+		return Config{}, ErrUnsupported
 	}
+	c.Offset = int(offset)
 	switch bpp {
 	case 8:
 		if offset != fileHeaderLen+infoLen+256*4 {
-			return image.Config{}, 0, false, false, ErrUnsupported
+			return Config{}, ErrUnsupported
 		}
-		_, err = io.ReadFull(r, b[:256*4])
+		_, err := io.ReadFull(r, b[:256*4])
 		if err != nil {
-			return image.Config{}, 0, false, false, err
+			return Config{}, err
 		}
 		pcm := make(color.Palette, 256)
 		for i := range pcm {
@@ -202,16 +346,19 @@ func decodeConfig(r io.Reader) (config image.Config, bitsPerPixel int, topDown b
 			// Every 4th byte is padding.
 			pcm[i] = color.RGBA{b[4*i+2], b[4*i+1], b[4*i+0], 0xFF}
 		}
-		return image.Config{ColorModel: pcm, Width: width, Height: height}, 8, topDown, false, nil
-	case 24:
-		if offset != fileHeaderLen+infoLen {
-			return image.Config{}, 0, false, false, ErrUnsupported
+		c.ColorModel = pcm
+		return c, nil
+	case 16:
+		if c.Masks == [4]uint32{} {
+			return Config{}, ErrUnsupported
 		}
-		return image.Config{ColorModel: color.RGBAModel, Width: width, Height: height}, 24, topDown, false, nil
+		c.ColorModel = color.RGBAModel
+		c.AllowAlpha = c.Masks[3] != 0
+		return c, nil
+	case 24:
+		c.ColorModel = color.RGBAModel
+		return c, nil
 	case 32:
-		if offset != fileHeaderLen+infoLen {
-			return image.Config{}, 0, false, false, ErrUnsupported
-		}
 		// 32 bits per pixel is possibly RGBX (X is padding) or RGBA (A is
 		// alpha transparency). However, for BMP images, "Alpha is a
 		// poorly-documented and inconsistently-used feature" says
@@ -232,8 +379,9 @@ func decodeConfig(r io.Reader) (config image.Config, bitsPerPixel int, topDown b
 		// This Go package does not support ICO files and the (infoLen >
 		// infoHeaderLen) condition distinguishes BITMAPINFOHEADER (40 bytes)
 		// vs later (larger) headers.
-		allowAlpha = infoLen > infoHeaderLen
-		return image.Config{ColorModel: color.RGBAModel, Width: width, Height: height}, 32, topDown, allowAlpha, nil
+		c.ColorModel = color.RGBAModel
+		c.AllowAlpha = infoLen > infoHeaderLen
+		return c, nil
 	}
-	return image.Config{}, 0, false, false, ErrUnsupported
+	return Config{}, ErrUnsupported
 }
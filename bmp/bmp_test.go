@@ -0,0 +1,240 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNBGRA_RGBA(t *testing.T) {
+	img := NewNBGRA(image.Rect(0, 0, 2, 2))
+	copy(img.Pix, []uint8{
+		1, 2, 3, 255, 4, 5, 6, 255,
+		7, 8, 9, 128, 10, 11, 12, 0,
+	})
+	rgba := img.RGBA()
+	var tests = []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, color.RGBA{R: 3, G: 2, B: 1, A: 255}},
+		{1, 0, color.RGBA{R: 6, G: 5, B: 4, A: 255}},
+		{0, 1, color.RGBA{R: 9, G: 8, B: 7, A: 128}},
+		{1, 1, color.RGBA{R: 12, G: 11, B: 10, A: 0}},
+	}
+	for _, tt := range tests {
+		if got := rgba.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("pixel %d,%d: got %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+// header describes a BMP image built by encodeBMP.
+type header struct {
+	infoLen     int
+	width       int
+	height      int
+	bpp         int
+	compression uint32
+	masks       []uint32
+}
+
+// encodeBMP returns a BMP image with h, followed by the pixel data pix as stored in the file.
+func encodeBMP(h header, pix []byte) []byte {
+	var buf bytes.Buffer
+	offset := 14 + h.infoLen
+	if h.infoLen == 40 {
+		offset += 4 * len(h.masks)
+	}
+	le := binary.LittleEndian
+	buf.WriteString("BM")
+	_ = binary.Write(&buf, le, uint32(offset+len(pix)))
+	_ = binary.Write(&buf, le, uint32(0))
+	_ = binary.Write(&buf, le, uint32(offset))
+	info := make([]byte, h.infoLen)
+	le.PutUint32(info[0:], uint32(h.infoLen))
+	le.PutUint32(info[4:], uint32(int32(h.width)))
+	le.PutUint32(info[8:], uint32(int32(h.height)))
+	le.PutUint16(info[12:], 1)
+	le.PutUint16(info[14:], uint16(h.bpp))
+	le.PutUint32(info[16:], h.compression)
+	le.PutUint32(info[20:], uint32(len(pix)))
+	if h.infoLen > 40 {
+		for i, m := range h.masks {
+			le.PutUint32(info[40+4*i:], m)
+		}
+	}
+	buf.Write(info)
+	if h.infoLen == 40 {
+		for _, m := range h.masks {
+			_ = binary.Write(&buf, le, m)
+		}
+	}
+	buf.Write(pix)
+	return buf.Bytes()
+}
+
+func TestBGRADecoder_Decode(t *testing.T) {
+	var tests = []struct {
+		name string
+		h    header
+		pix  []byte
+		want []byte
+		err  error
+	}{
+		{
+			name: "32bpp bottom-up",
+			h:    header{infoLen: 40, width: 1, height: 2, bpp: 32},
+			pix:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			want: []byte{5, 6, 7, 8, 1, 2, 3, 4},
+		},
+		{
+			name: "32bpp top-down",
+			h:    header{infoLen: 40, width: 1, height: -2, bpp: 32},
+			pix:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			want: []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		{
+			name: "24bpp padded rows",
+			h:    header{infoLen: 40, width: 1, height: -2, bpp: 24},
+			pix:  []byte{1, 2, 3, 0, 4, 5, 6, 0},
+			want: []byte{1, 2, 3, 0xFF, 4, 5, 6, 0xFF},
+		},
+		{
+			name: "24bpp bottom-up",
+			h:    header{infoLen: 40, width: 2, height: 2, bpp: 24},
+			pix:  []byte{1, 2, 3, 4, 5, 6, 0, 0, 7, 8, 9, 10, 11, 12, 0, 0},
+			want: []byte{7, 8, 9, 0xFF, 10, 11, 12, 0xFF, 1, 2, 3, 0xFF, 4, 5, 6, 0xFF},
+		},
+		{
+			name: "V4 default masks",
+			h:    header{infoLen: 108, width: 1, height: -1, bpp: 32, compression: 3, masks: []uint32{0xff0000, 0xff00, 0xff, 0xff000000}},
+			pix:  []byte{1, 2, 3, 4},
+			want: []byte{1, 2, 3, 4},
+		},
+		{
+			name: "V4 RGBA masks",
+			h:    header{infoLen: 108, width: 1, height: -1, bpp: 32, compression: 3, masks: []uint32{0xff, 0xff00, 0xff0000, 0xff000000}},
+			pix:  []byte{1, 2, 3, 4},
+			want: []byte{3, 2, 1, 4},
+		},
+		{
+			name: "V5 RGBX masks",
+			h:    header{infoLen: 124, width: 1, height: -1, bpp: 32, compression: 3, masks: []uint32{0xff, 0xff00, 0xff0000, 0}},
+			pix:  []byte{1, 2, 3, 4},
+			want: []byte{3, 2, 1, 0xFF},
+		},
+		{
+			name: "info header RGB565 masks",
+			h:    header{infoLen: 40, width: 2, height: -1, bpp: 16, compression: 3, masks: []uint32{0xf800, 0x07e0, 0x001f}},
+			pix:  []byte{0x00, 0xf8, 0x1f, 0x00},
+			want: []byte{0, 0, 0xFF, 0xFF, 0xFF, 0, 0, 0xFF},
+		},
+		{
+			name: "non-contiguous mask",
+			h:    header{infoLen: 108, width: 1, height: -1, bpp: 32, compression: 3, masks: []uint32{0xf0f, 0xff00, 0xff0000, 0}},
+			pix:  []byte{1, 2, 3, 4},
+			err:  ErrUnsupported,
+		},
+		{
+			name: "8bpp",
+			h:    header{infoLen: 40, width: 4, height: 1, bpp: 8},
+			pix:  []byte{1, 2, 3, 4},
+			err:  ErrUnsupported,
+		},
+		{
+			name: "short pixel data",
+			h:    header{infoLen: 40, width: 2, height: 2, bpp: 32},
+			pix:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			err:  ErrShortBuffer,
+		},
+		{
+			name: "short padding",
+			h:    header{infoLen: 40, width: 1, height: 2, bpp: 24},
+			pix:  []byte{1, 2, 3, 0, 4, 5, 6},
+			err:  ErrShortBuffer,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := NewBGRADecoder().Decode(encodeBMP(tt.h, tt.pix), nil)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(img.Pix, tt.want) {
+				t.Errorf("got %v, want %v", img.Pix, tt.want)
+			}
+		})
+	}
+}
+
+func TestBGRADecoder_Decode_HeaderChange(t *testing.T) {
+	dec := NewBGRADecoder()
+	small := encodeBMP(header{infoLen: 40, width: 1, height: -1, bpp: 32}, []byte{1, 2, 3, 4})
+	large := encodeBMP(header{infoLen: 40, width: 2, height: -1, bpp: 24}, []byte{1, 2, 3, 4, 5, 6, 0, 0})
+
+	img, err := dec.Decode(small, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = dec.Decode(large, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 2, 3, 0xFF, 4, 5, 6, 0xFF}; !bytes.Equal(img.Pix, want) {
+		t.Errorf("got %v, want %v", img.Pix, want)
+	}
+	reused, err := dec.Decode(large, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused != img {
+		t.Error("image of the same size was not reused")
+	}
+	if _, err := dec.Decode(large[:len(large)-1], img); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("got error %v, want %v", err, ErrShortBuffer)
+	}
+}
+
+func FuzzBGRADecoder_Decode(f *testing.F) {
+	f.Add(encodeBMP(header{infoLen: 40, width: 1, height: 2, bpp: 32}, []byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	f.Add(encodeBMP(header{infoLen: 40, width: 2, height: 2, bpp: 24}, make([]byte, 16)))
+	f.Add(encodeBMP(header{infoLen: 108, width: 1, height: -1, bpp: 32, compression: 3, masks: []uint32{0xff, 0xff00, 0xff0000, 0xff000000}}, make([]byte, 4)))
+	f.Add(encodeBMP(header{infoLen: 40, width: 2, height: -1, bpp: 16, compression: 3, masks: []uint32{0xf800, 0x07e0, 0x001f}}, make([]byte, 4)))
+	f.Fuzz(func(t *testing.T, in []byte) {
+		img, err := NewBGRADecoder().Decode(in, nil)
+		if err != nil {
+			return
+		}
+		if len(img.Pix) != 4*img.Rect.Dx()*img.Rect.Dy() {
+			t.Errorf("got %d bytes for %v", len(img.Pix), img.Rect)
+		}
+	})
+}
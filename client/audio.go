@@ -0,0 +1,76 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/rs/zerolog/log"
+)
+
+// audioQueueSize is how many audio frames wait for their time to be played before new ones are dropped.
+const audioQueueSize = 64
+
+// AudioSink plays Opus packets right away, gstreamer.AudioPlayer implements it.
+type AudioSink interface {
+	PlayAudio(packet []byte) error
+}
+
+// queueAudio queues the audio frame of a binary message to be played by RunAudio.
+func (w *WeylusClient) queueAudio(data []byte) {
+	var frame protocol.AudioFrame
+	if err := frame.UnmarshalBinary(data); err != nil {
+		log.Ctx(w.ctx).Err(err).Msg("error on audio frame")
+		return
+	}
+	w.AVSync.Audio(frame.Timestamp, time.Now())
+	select {
+	case w.audioFrames <- frame:
+	default:
+		log.Ctx(w.ctx).Debug().Dur("timestamp", frame.Timestamp).Msg("audio queue full, dropping frame")
+	}
+}
+
+// RunAudio plays the audio frames of the server on sink, each when the video at its timestamp is shown.
+// Frames too late to be played in sync are dropped.
+func (w *WeylusClient) RunAudio(sink AudioSink) {
+	for {
+		var frame protocol.AudioFrame
+		select {
+		case <-w.ctx.Done():
+			return
+		case frame = <-w.audioFrames:
+		}
+		due, ok := w.AVSync.Due(frame.Timestamp, time.Now())
+		if !ok {
+			continue
+		}
+		if wait := time.Until(due); wait > 0 {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+		if err := sink.PlayAudio(frame.Data); err != nil {
+			log.Ctx(w.ctx).Err(err).Msg("error on play audio")
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+)
+
+type fakeAudioSink struct {
+	packets chan []byte
+}
+
+func (s *fakeAudioSink) PlayAudio(packet []byte) error {
+	s.packets <- packet
+	return nil
+}
+
+func TestWeylusClient_RunAudio(t *testing.T) {
+	srv := newTestServer(t)
+	w := newTestClient(t, srv)
+	sink := &fakeAudioSink{packets: make(chan []byte, 4)}
+	w.AVSync.Latency = 0
+	go w.RunAudio(sink)
+
+	now := time.Now()
+	w.AVSync.Video(10*time.Second, now)
+	for _, f := range []protocol.AudioFrame{
+		// anchors the audio timeline to the video
+		{Timestamp: 60 * time.Second, Data: []byte{1}},
+		// too late
+		{Timestamp: 55 * time.Second, Data: []byte{2}},
+		{Timestamp: 60*time.Second + 50*time.Millisecond, Data: []byte{3}},
+	} {
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.queueAudio(data)
+	}
+
+	ctx := testContext(t)
+	for _, want := range []byte{1, 3} {
+		select {
+		case got := <-sink.packets:
+			if len(got) != 1 || got[0] != want {
+				t.Errorf("played %v, want [%d]", got, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("packet %d not played", want)
+		}
+	}
+	if elapsed := time.Since(now); elapsed < 50*time.Millisecond {
+		t.Errorf("played the packet at +50ms after %v", elapsed)
+	}
+}
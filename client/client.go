@@ -22,12 +22,17 @@ package client
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"io"
 	"reflect"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/OmegaRogue/weylus-desktop/audio"
+	"github.com/OmegaRogue/weylus-desktop/internal/logger"
+	"github.com/OmegaRogue/weylus-desktop/macro"
 	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/OmegaRogue/weylus-desktop/recorder"
 	"github.com/OmegaRogue/weylus-desktop/utils"
 	"github.com/kr/pretty"
 	"github.com/pkg/errors"
@@ -37,85 +42,206 @@ import (
 )
 
 var WebsocketNotStartedError = errors.New("Websocket not initialized")
+var NotConfiguredError = errors.New("Client not configured")
 
 type Callback func(msg utils.Msg)
 type WeylusClient struct {
-	ws                    *websocket.Conn
-	msgs                  chan utils.Msg
-	callbacks             map[protocol.WeylusResponse][]Callback
-	callbackMutex         sync.Mutex
-	ctx                   context.Context
-	cancel                context.CancelFunc
-	Framerate             uint
-	frameTimer            *time.Ticker
-	BufPipe               *bufio.ReadWriter
-	receivedVideoResponse bool
-	requestedFirstFrame   bool
+	ws            *websocket.Conn
+	msgs          chan utils.Msg
+	callbacks     map[protocol.WeylusResponse]map[int]Callback
+	callbackID    int
+	callbackMutex sync.Mutex
+	ctx           context.Context
+	cancel        context.CancelFunc
+	Framerate     uint
+	frameTimer    *time.Ticker
+	BufPipe       *bufio.ReadWriter
+	Recorder      *recorder.Recorder
+	inputRecorder *macro.Writer
+	inputMutex    sync.Mutex
+	state         State
+	stateChanged  chan struct{}
+	stateMutex    sync.Mutex
+	// AVSync schedules the audio frames against the video.
+	AVSync      *audio.Sync
+	audioFrames chan protocol.AudioFrame
+	videoClock  videoClock
 }
 
+// AddCallback registers callback for event and returns an id that can be passed to RemoveCallback.
 func (w *WeylusClient) AddCallback(event protocol.WeylusResponse, callback Callback) int {
 	w.callbackMutex.Lock()
 	defer w.callbackMutex.Unlock()
-	n := len(w.callbacks[event])
-	w.callbacks[event] = append(w.callbacks[event], callback)
-	return n
+	if w.callbacks[event] == nil {
+		w.callbacks[event] = make(map[int]Callback)
+	}
+	w.callbackID++
+	w.callbacks[event][w.callbackID] = callback
+	return w.callbackID
 }
-func (w *WeylusClient) RemoveCallback(event protocol.WeylusResponse, i int) int {
+
+// RemoveCallback removes the callback with id from event and returns the number of callbacks registered before.
+func (w *WeylusClient) RemoveCallback(event protocol.WeylusResponse, id int) int {
 	w.callbackMutex.Lock()
 	defer w.callbackMutex.Unlock()
 	n := len(w.callbacks[event])
-
-	w.callbacks[event] = utils.Remove(w.callbacks[event], i)
+	delete(w.callbacks[event], id)
 	return n
 }
 
-func (w *WeylusClient) AddCallbackNext(event protocol.WeylusResponse, callback Callback) {
-	var i int
-	i = w.AddCallback(event, func(msg utils.Msg) {
-		defer w.RemoveCallback(event, i)
-		callback(msg)
+// AddCallbackNext registers callback for event and removes it after it ran once.
+func (w *WeylusClient) AddCallbackNext(event protocol.WeylusResponse, callback Callback) int {
+	var once sync.Once
+	var id int
+	id = w.AddCallback(event, func(msg utils.Msg) {
+		once.Do(func() {
+			w.RemoveCallback(event, id)
+			callback(msg)
+		})
 	})
+	return id
 }
 
 func NewWeylusClient(ctx context.Context, fps uint) *WeylusClient {
 	w := new(WeylusClient)
 	w.msgs = make(chan utils.Msg)
-	w.callbacks = make(map[protocol.WeylusResponse][]Callback)
-	ctx = log.With().Str("component", "client").Logger().WithContext(ctx)
+	w.callbacks = make(map[protocol.WeylusResponse]map[int]Callback)
+	w.stateChanged = make(chan struct{})
+	clientLogger := logger.Component("client")
+	ctx = clientLogger.WithContext(ctx)
 	w.ctx, w.cancel = context.WithCancel(ctx)
 	w.Framerate = fps
 	log.Ctx(ctx).Debug().Dur("frame_time", time.Second/time.Duration(w.Framerate)).Uint("fps", fps).Msg("video times")
 	w.frameTimer = time.NewTicker(time.Second / time.Duration(w.Framerate))
+	w.Recorder = recorder.NewRecorder()
+	w.AVSync = audio.NewSync()
+	w.audioFrames = make(chan protocol.AudioFrame, audioQueueSize)
+	w.AddCallback(protocol.WeylusResponseNewVideo, func(msg utils.Msg) {
+		if w.advanceState(StateConfigured, StateVideoStarted) {
+			log.Ctx(w.ctx).Info().Msg("video")
+		}
+		if err := w.Recorder.NewSegment(); err != nil {
+			log.Ctx(w.ctx).Err(err).Msg("error on new recording segment")
+		}
+		// the new video starts a new timeline
+		w.AVSync.Reset()
+	})
 
 	return w
 }
 
+// State returns the current State of the client.
+func (w *WeylusClient) State() State {
+	w.stateMutex.Lock()
+	defer w.stateMutex.Unlock()
+	return w.state
+}
+
+func (w *WeylusClient) setState(state State) {
+	w.stateMutex.Lock()
+	defer w.stateMutex.Unlock()
+	w.setStateLocked(state)
+}
+
+// advanceState switches from state from to state to and returns false if the client is in another state.
+func (w *WeylusClient) advanceState(from, to State) bool {
+	w.stateMutex.Lock()
+	defer w.stateMutex.Unlock()
+	if w.state != from {
+		return false
+	}
+	w.setStateLocked(to)
+	return true
+}
+
+// setStateLocked switches to state, the caller holds stateMutex.
+func (w *WeylusClient) setStateLocked(state State) {
+	if w.state == state {
+		return
+	}
+	log.Ctx(w.ctx).Debug().Stringer("from", w.state).Stringer("to", state).Msg("state changed")
+	w.state = state
+	close(w.stateChanged)
+	w.stateChanged = make(chan struct{})
+}
+
+// stateSnapshot returns the current State and a channel that is closed on the next state change.
+func (w *WeylusClient) stateSnapshot() (State, <-chan struct{}) {
+	w.stateMutex.Lock()
+	defer w.stateMutex.Unlock()
+	return w.state, w.stateChanged
+}
+
+// WaitForState blocks until the client reached at least state or ctx is done.
+func (w *WeylusClient) WaitForState(ctx context.Context, state State) error {
+	for {
+		current, changed := w.stateSnapshot()
+		if current >= state {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "wait for state %s", state)
+		case <-changed:
+		}
+	}
+}
+
+func (w *WeylusClient) conn() *websocket.Conn {
+	w.stateMutex.Lock()
+	defer w.stateMutex.Unlock()
+	return w.ws
+}
+
 func commandWithReceive[T protocol.MessageInbound, V protocol.MessageOutboundContent](w *WeylusClient, command V) (a T, err error) {
-	if w.ws == nil {
+	ws := w.conn()
+	if ws == nil {
 		return a, errors.Wrap(WebsocketNotStartedError, "commandWithReceive failed")
 	}
-	var wg sync.WaitGroup
-	wg.Add(1)
-	resp := protocol.ResponseFromOutboundContent(command)
-	w.AddCallbackNext(resp, func(msg utils.Msg) {
-		var r any
-		r, err = protocol.ParseMessage(msg.Data)
-		if b, ok := r.(T); ok {
-			a = b
-		} else {
-			err = errors.Errorf("wrong type returned by ParseMessage: %v\n %v", reflect.TypeOf(r), pretty.Sprint(b))
+	type result struct {
+		a   T
+		err error
+	}
+	results := make(chan result, 1)
+	receive := func(msg utils.Msg) {
+		var res result
+		r, err := protocol.ParseMessage(msg.Data)
+		switch b := r.(type) {
+		case T:
+			res.a = b
+		case error:
+			res.err = b
+		default:
+			if err == nil {
+				err = errors.Errorf("wrong type returned by ParseMessage: %v\n %v", reflect.TypeOf(r), pretty.Sprint(b))
+			}
+			res.err = err
 		}
-		wg.Done()
-	})
-	if err := wsjson.Write(w.ctx, w.ws, protocol.WrapMessage(command)); err != nil {
+		select {
+		case results <- res:
+		default:
+		}
+	}
+	// errors can be returned for any command, so listen for them as well
+	resp := protocol.ResponseFromOutboundContent(command)
+	for _, response := range []protocol.WeylusResponse{resp, protocol.WeylusResponseError, protocol.WeylusResponseConfigError} {
+		id := w.AddCallbackNext(response, receive)
+		defer w.RemoveCallback(response, id)
+	}
+
+	if err := wsjson.Write(w.ctx, ws, protocol.WrapMessage(command)); err != nil {
 		cmd := protocol.CommandFromOutboundContent(command)
 		return a, errors.Wrap(err, cmd.String())
 	}
-	wg.Wait()
-	if err != nil {
-		return a, errors.Wrap(err, "parsing message")
+	select {
+	case <-w.ctx.Done():
+		return a, errors.Wrap(w.ctx.Err(), "waiting for response")
+	case res := <-results:
+		if res.err != nil {
+			return a, errors.Wrap(res.err, "parsing message")
+		}
+		return res.a, nil
 	}
-	return a, nil
 }
 
 func (w *WeylusClient) GetCapturableList() (protocol.CapturableList, error) {
@@ -124,24 +250,27 @@ func (w *WeylusClient) GetCapturableList() (protocol.CapturableList, error) {
 func (w *WeylusClient) Config(config protocol.Config) (protocol.WeylusResponse, error) {
 	resp, err := commandWithReceive[string](w, config)
 	if err != nil {
+		var configErr *protocol.WeylusConfigError
+		if errors.As(err, &configErr) {
+			return protocol.WeylusResponseConfigError, errors.Wrap(err, "error on receive")
+		}
 		return protocol.WeylusResponseError, errors.Wrap(err, "error on receive")
 	}
 	res, err := protocol.ParseWeylusResponse(resp)
 	if err != nil {
 		return "", errors.Wrap(err, "parse response")
 	}
+	if res == protocol.WeylusResponseConfigOk {
+		w.setState(StateConfigured)
+	}
 	return res, nil
 }
 
+// StartVideo requests the first frame. The client switches to StateVideoStarted once the server answered with NewVideo.
 func (w *WeylusClient) StartVideo() error {
-	var wg sync.WaitGroup
-	w.requestedFirstFrame = true
-	wg.Add(1)
-	w.AddCallbackNext(protocol.WeylusResponseNewVideo, func(msg utils.Msg) {
-		w.receivedVideoResponse = true
-		log.Ctx(w.ctx).Info().Msg("video")
-		wg.Done()
-	})
+	if w.State() < StateConfigured {
+		return errors.Wrap(NotConfiguredError, "start video")
+	}
 	err := w.TryGetFrame()
 	if err != nil {
 		return errors.Wrap(err, "start video")
@@ -150,90 +279,252 @@ func (w *WeylusClient) StartVideo() error {
 }
 
 func (w *WeylusClient) TryGetFrame() error {
-	if w.ws == nil {
+	ws := w.conn()
+	if ws == nil {
 		return errors.Wrap(WebsocketNotStartedError, "TryGetFrame failed")
 	}
-	if err := wsjson.Write(w.ctx, w.ws, protocol.WeylusCommandTryGetFrame); err != nil {
+	if err := wsjson.Write(w.ctx, ws, protocol.WeylusCommandTryGetFrame); err != nil {
 		return errors.Wrap(err, string(protocol.WeylusCommandTryGetFrame))
 	}
 	return nil
 }
 
+// RecordInput records all input events sent from now on as macro to out. Passing nil stops the recording.
+func (w *WeylusClient) RecordInput(out io.Writer) {
+	w.inputMutex.Lock()
+	defer w.inputMutex.Unlock()
+	if out == nil {
+		w.inputRecorder = nil
+		return
+	}
+	w.inputRecorder = macro.NewWriter(out)
+}
+
+func recordInput[T macro.Event](w *WeylusClient, e T) {
+	w.inputMutex.Lock()
+	defer w.inputMutex.Unlock()
+	if w.inputRecorder == nil {
+		return
+	}
+	if err := macro.Record(w.inputRecorder, e); err != nil {
+		log.Ctx(w.ctx).Err(err).Msg("error on record input")
+	}
+}
+
 //nolint:gocritic // PointerEvent might be heavy, but it should be like this
 func (w *WeylusClient) SendPointerEvent(e protocol.PointerEvent) error {
-	if w.ws == nil {
+	ws := w.conn()
+	if ws == nil {
 		return errors.Wrap(WebsocketNotStartedError, "SendPointerEvent failed")
 	}
-	if err := wsjson.Write(w.ctx, w.ws, e); err != nil {
+	if err := wsjson.Write(w.ctx, ws, protocol.WrapMessage(e)); err != nil {
 		return errors.Wrap(err, string(protocol.WeylusCommandPointerEvent))
 	}
+	recordInput(w, e)
 	return nil
 }
 func (w *WeylusClient) SendWheelEvent(e protocol.WheelEvent) error {
-	if w.ws == nil {
+	ws := w.conn()
+	if ws == nil {
 		return errors.Wrap(WebsocketNotStartedError, "SendWheelEvent failed")
 	}
-	if err := wsjson.Write(w.ctx, w.ws, e); err != nil {
+	if err := wsjson.Write(w.ctx, ws, protocol.WrapMessage(e)); err != nil {
 		return errors.Wrap(err, string(protocol.WeylusCommandWheelEvent))
 	}
+	recordInput(w, e)
 	return nil
 }
 func (w *WeylusClient) SendKeyboardEvent(e protocol.KeyboardEvent) error {
-	if w.ws == nil {
+	ws := w.conn()
+	if ws == nil {
 		return errors.Wrap(WebsocketNotStartedError, "SendKeyboardEvent failed")
 	}
-	if err := wsjson.Write(w.ctx, w.ws, e); err != nil {
+	if err := wsjson.Write(w.ctx, ws, protocol.WrapMessage(e)); err != nil {
 		return errors.Wrap(err, string(protocol.WeylusCommandKeyboardEvent))
 	}
+	recordInput(w, e)
+	return nil
+}
+
+// SendGamepadEvent sends the state of a gamepad. Gamepad events are not recorded, macros only replay pointer, wheel
+// and keyboard input.
+//
+//nolint:gocritic // GamepadEvent might be heavy, but it should be like this
+func (w *WeylusClient) SendGamepadEvent(e protocol.GamepadEvent) error {
+	ws := w.conn()
+	if ws == nil {
+		return errors.Wrap(WebsocketNotStartedError, "SendGamepadEvent failed")
+	}
+	if err := wsjson.Write(w.ctx, ws, protocol.WrapMessage(e)); err != nil {
+		return errors.Wrap(err, string(protocol.WeylusCommandGamepadEvent))
+	}
+	return nil
+}
+
+// SendClipboardOffer announces new content of the local clipboard.
+func (w *WeylusClient) SendClipboardOffer(e protocol.ClipboardOffer) error {
+	ws := w.conn()
+	if ws == nil {
+		return errors.Wrap(WebsocketNotStartedError, "SendClipboardOffer failed")
+	}
+	if err := wsjson.Write(w.ctx, ws, protocol.WrapMessage(e)); err != nil {
+		return errors.Wrap(err, string(protocol.WeylusCommandClipboardOffer))
+	}
+	return nil
+}
+
+// SendClipboardRequest asks for the content of a clipboard offer of the server.
+func (w *WeylusClient) SendClipboardRequest(e protocol.ClipboardRequest) error {
+	ws := w.conn()
+	if ws == nil {
+		return errors.Wrap(WebsocketNotStartedError, "SendClipboardRequest failed")
+	}
+	if err := wsjson.Write(w.ctx, ws, protocol.WrapMessage(e)); err != nil {
+		return errors.Wrap(err, string(protocol.WeylusCommandClipboardRequest))
+	}
+	return nil
+}
+
+// SendClipboardContent sends the content of a clipboard offer requested by the server.
+func (w *WeylusClient) SendClipboardContent(e protocol.ClipboardContent) error {
+	ws := w.conn()
+	if ws == nil {
+		return errors.Wrap(WebsocketNotStartedError, "SendClipboardContent failed")
+	}
+	if err := wsjson.Write(w.ctx, ws, protocol.WrapMessage(e)); err != nil {
+		return errors.Wrap(err, string(protocol.WeylusCommandClipboardContent))
+	}
 	return nil
 }
 
+// ClipboardHandler handles the clipboard messages of the server, clipboard.Sync implements it.
+type ClipboardHandler interface {
+	HandleOffer(o protocol.ClipboardOffer) error
+	HandleRequest(r protocol.ClipboardRequest) error
+	HandleContent(ctx context.Context, c protocol.ClipboardContent) error
+}
+
+// HandleClipboard passes the clipboard messages of the server to h.
+func (w *WeylusClient) HandleClipboard(h ClipboardHandler) {
+	w.AddCallback(protocol.WeylusResponseClipboardOffer, func(msg utils.Msg) {
+		handleClipboardMessage(w, msg, protocol.WeylusResponseClipboardOffer, h.HandleOffer)
+	})
+	w.AddCallback(protocol.WeylusResponseClipboardRequest, func(msg utils.Msg) {
+		handleClipboardMessage(w, msg, protocol.WeylusResponseClipboardRequest, h.HandleRequest)
+	})
+	w.AddCallback(protocol.WeylusResponseClipboardContent, func(msg utils.Msg) {
+		handleClipboardMessage(w, msg, protocol.WeylusResponseClipboardContent, func(c protocol.ClipboardContent) error {
+			return h.HandleContent(w.ctx, c)
+		})
+	})
+}
+
+// handleClipboardMessage unwraps the response of msg and passes it to handle.
+func handleClipboardMessage[T any](w *WeylusClient, msg utils.Msg, response protocol.WeylusResponse, handle func(T) error) {
+	var wrapper map[protocol.WeylusResponse]T
+	if err := json.Unmarshal(msg.Data, &wrapper); err != nil {
+		log.Ctx(w.ctx).Warn().Err(err).Stringer("response", response).Msg("unmarshal clipboard message")
+		return
+	}
+	content, ok := wrapper[response]
+	if !ok {
+		return
+	}
+	if err := handle(content); err != nil {
+		log.Ctx(w.ctx).Warn().Err(err).Stringer("response", response).Msg("handle clipboard message")
+	}
+}
+
 func (w *WeylusClient) Dial(address string) error {
 	c, _, err := websocket.Dial(w.ctx, address, nil)
 	if err != nil {
 		return errors.Wrap(err, "dial weylusClient")
 	}
 	c.SetReadLimit(32769 * 16)
+	w.stateMutex.Lock()
 	w.ws = c
+	w.stateMutex.Unlock()
+	w.setState(StateDialed)
 	return nil
 }
 
 func (w *WeylusClient) Listen() {
-	if w.ws == nil {
+	ws := w.conn()
+	if ws == nil {
 		log.Fatal().Msg("Listen failed")
 	}
 	for {
+		t, d, err := ws.Read(w.ctx)
+		if err != nil {
+			if w.ctx.Err() != nil {
+				log.Ctx(w.ctx).Err(errors.Wrap(w.ctx.Err(), "closed context")).Msg("closed context")
+			} else {
+				log.Ctx(w.ctx).Err(err).Msg("error on listen")
+			}
+			w.setState(StateDisconnected)
+			return
+		}
 		select {
 		case <-w.ctx.Done():
 			log.Ctx(w.ctx).Err(errors.Wrap(w.ctx.Err(), "closed context")).Msg("closed context")
 			return
-		default:
-			t, d, err := w.ws.Read(w.ctx)
-			if err != nil {
-				_ = w.Close()
-				log.Ctx(w.ctx).Fatal().Err(err).Msg("error on listen")
-				return
-			}
-			w.msgs <- utils.Msg{
-				Type: t,
-				Data: d,
-			}
+		case w.msgs <- utils.Msg{
+			Type: t,
+			Data: d,
+		}:
 		}
 	}
 }
 
 func (w *WeylusClient) Close() error {
-	w.cancel()
-	close(w.msgs)
-	if err := w.ws.Close(websocket.StatusNormalClosure, "closing"); err != nil {
-		return errors.Wrap(err, "close websocket and channel")
+	defer w.cancel()
+	defer w.setState(StateDisconnected)
+	if err := w.Recorder.Close(); err != nil {
+		log.Ctx(w.ctx).Err(err).Msg("error on stop recording")
+	}
+	if ws := w.conn(); ws != nil {
+		if err := ws.Close(websocket.StatusNormalClosure, "closing"); err != nil {
+			return errors.Wrap(err, "close websocket")
+		}
 	}
 	return nil
 }
 
+// responseOf returns the response of a text message of the server, the message is either the name of the response
+// as a string or an object with the response as its only key.
+func responseOf(data []byte) (protocol.WeylusResponse, bool) {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		return protocol.WeylusResponse(name), true
+	}
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(data, &wrapper); err != nil || len(wrapper) != 1 {
+		return "", false
+	}
+	for response := range wrapper {
+		name = response
+	}
+	return protocol.WeylusResponse(name), true
+}
+
+// dispatch runs the callbacks registered for the response of msg.
+func (w *WeylusClient) dispatch(msg utils.Msg) {
+	response, ok := responseOf(msg.Data)
+	if !ok {
+		return
+	}
+	w.callbackMutex.Lock()
+	matched := make([]Callback, 0, len(w.callbacks[response]))
+	for _, callback := range w.callbacks[response] {
+		matched = append(matched, callback)
+	}
+	w.callbackMutex.Unlock()
+	for _, callback := range matched {
+		callback(msg)
+	}
+}
+
 func (w *WeylusClient) Run() {
-	// avoid racecondition
-	time.Sleep(time.Second)
 	for {
 		select {
 		case <-w.ctx.Done():
@@ -242,15 +533,22 @@ func (w *WeylusClient) Run() {
 		case msg := <-w.msgs:
 			switch msg.Type {
 			case websocket.MessageText:
-				log.Ctx(w.ctx).Info().RawJSON("data", msg.Data).Msg("received data")
-				for response, callbacks := range w.callbacks {
-					if strings.Contains(string(msg.Data), string(response)) {
-						for _, callback := range callbacks {
-							callback(msg)
-						}
-					}
-				}
+				w.logMessage(msg.Data)
+				w.dispatch(msg)
 			case websocket.MessageBinary:
+				if protocol.IsAudioFrame(msg.Data) {
+					w.queueAudio(msg.Data)
+					continue
+				}
+				if ts, ok := w.videoClock.Fragment(msg.Data); ok {
+					w.AVSync.Video(ts, time.Now())
+				}
+				if _, err := w.Recorder.Write(msg.Data); err != nil {
+					log.Ctx(w.ctx).Err(err).Msg("error on record data")
+				}
+				if w.BufPipe == nil {
+					continue
+				}
 				if _, err := w.BufPipe.Write(msg.Data); err != nil {
 					log.Ctx(w.ctx).Err(err).Msg("error on write data")
 				}
@@ -259,25 +557,47 @@ func (w *WeylusClient) Run() {
 	}
 }
 
+// RunVideo waits until the client is configured, starts the video and then requests a frame on every tick.
+// If the connection is lost, it waits for the client to be configured again.
 func (w *WeylusClient) RunVideo() {
-	w.receivedVideoResponse = false
-	w.requestedFirstFrame = false
-	time.Sleep(time.Second * 2)
+	defer w.frameTimer.Stop()
 	for {
+		if err := w.WaitForState(w.ctx, StateConfigured); err != nil {
+			log.Ctx(w.ctx).Err(err).Msg("closed context")
+			return
+		}
+		_, changed := w.stateSnapshot()
+		if err := w.StartVideo(); err != nil {
+			log.Ctx(w.ctx).Err(err).Msg("send TryGetFrame for first frame")
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-w.frameTimer.C:
+				continue
+			}
+		}
 		select {
 		case <-w.ctx.Done():
-			w.frameTimer.Stop()
 			log.Ctx(w.ctx).Err(errors.Wrap(w.ctx.Err(), "closed context")).Msg("closed context")
 			return
+		case <-changed:
+		}
+		if w.State() == StateVideoStarted && !w.requestFrames() {
+			return
+		}
+	}
+}
+
+// requestFrames sends TryGetFrame on every tick until the video stops. It returns false if the context is done.
+func (w *WeylusClient) requestFrames() bool {
+	for {
+		select {
+		case <-w.ctx.Done():
+			log.Ctx(w.ctx).Err(errors.Wrap(w.ctx.Err(), "closed context")).Msg("closed context")
+			return false
 		case <-w.frameTimer.C:
-			if !w.requestedFirstFrame {
-				if err := w.StartVideo(); err != nil {
-					log.Ctx(w.ctx).Err(err).Msg("send TryGetFrame for first frame, dropped frame")
-				}
-				log.Ctx(w.ctx).Trace().Msg("tick")
-				continue
-			} else if !w.receivedVideoResponse {
-				continue
+			if w.State() < StateVideoStarted {
+				return true
 			}
 			if err := w.TryGetFrame(); err != nil {
 				log.Ctx(w.ctx).Err(err).Msg("send TryGetFrame, dropped frame")
@@ -0,0 +1,477 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/client/weylustest"
+	"github.com/OmegaRogue/weylus-desktop/macro"
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/OmegaRogue/weylus-desktop/recorder"
+	"github.com/OmegaRogue/weylus-desktop/utils"
+	"nhooyr.io/websocket"
+)
+
+func newTestClient(t *testing.T, srv *weylustest.Server) *WeylusClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	w := NewWeylusClient(ctx, 100)
+	if err := w.Dial(srv.WebsocketURL()); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	go w.Listen()
+	go w.Run()
+	return w
+}
+
+func newTestServer(t *testing.T) *weylustest.Server {
+	t.Helper()
+	srv := weylustest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestWeylusClient_GetCapturableList(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle(protocol.WeylusCommandGetCapturableList, weylustest.Reply(protocol.CapturableList{
+		CapturableList: []string{"Desktop", "Monitor: DP-4"},
+	}))
+	w := newTestClient(t, srv)
+
+	list, err := w.GetCapturableList()
+	if err != nil {
+		t.Fatalf("GetCapturableList: %v", err)
+	}
+	if len(list.CapturableList) != 2 || list.CapturableList[1] != "Monitor: DP-4" {
+		t.Errorf("unexpected capturables: %v", list.CapturableList)
+	}
+	if n := srv.Count(protocol.WeylusCommandGetCapturableList); n != 1 {
+		t.Errorf("server received %d GetCapturableList, want 1", n)
+	}
+}
+
+func TestWeylusClient_GetCapturableListError(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle(protocol.WeylusCommandGetCapturableList, weylustest.Error("no capturables"))
+	w := newTestClient(t, srv)
+
+	if _, err := w.GetCapturableList(); err == nil {
+		t.Fatal("GetCapturableList did not return an error")
+	}
+}
+
+func TestWeylusClient_GetCapturableListDelayed(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle(protocol.WeylusCommandGetCapturableList, weylustest.Response{
+		Messages: []any{protocol.CapturableList{CapturableList: []string{"Desktop"}}},
+		Delay:    100 * time.Millisecond,
+	})
+	w := newTestClient(t, srv)
+
+	if _, err := w.GetCapturableList(); err != nil {
+		t.Fatalf("GetCapturableList: %v", err)
+	}
+}
+
+func TestWeylusClient_ConcurrentCommands(t *testing.T) {
+	srv := newTestServer(t)
+	w := newTestClient(t, srv)
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := w.GetCapturableList()
+			errs <- err
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("GetCapturableList: %v", err)
+		}
+	}
+}
+
+func TestWeylusClient_Config(t *testing.T) {
+	srv := newTestServer(t)
+	w := newTestClient(t, srv)
+
+	if w.State() != StateDialed {
+		t.Fatalf("state after Dial is %s, want %s", w.State(), StateDialed)
+	}
+	res, err := w.Config(protocol.Config{ClientName: "test"})
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	if res != protocol.WeylusResponseConfigOk {
+		t.Errorf("Config returned %s, want %s", res, protocol.WeylusResponseConfigOk)
+	}
+	if w.State() != StateConfigured {
+		t.Errorf("state is %s, want %s", w.State(), StateConfigured)
+	}
+	received := srv.Received()
+	if len(received) != 1 || !bytes.Contains(received[0].Data, []byte(`"client_name":"test"`)) {
+		t.Errorf("server received %s", received)
+	}
+}
+
+func TestWeylusClient_ConfigError(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle(protocol.WeylusCommandConfig, weylustest.ConfigError("nope"))
+	w := newTestClient(t, srv)
+
+	res, err := w.Config(protocol.Config{})
+	if err == nil {
+		t.Fatal("Config did not return an error")
+	}
+	if res != protocol.WeylusResponseConfigError {
+		t.Errorf("Config returned %s, want %s", res, protocol.WeylusResponseConfigError)
+	}
+	if w.State() != StateDialed {
+		t.Errorf("state is %s, want %s", w.State(), StateDialed)
+	}
+}
+
+func TestWeylusClient_StartVideo(t *testing.T) {
+	srv := newTestServer(t)
+	w := newTestClient(t, srv)
+	w.BufPipe = utils.NewBufPipe()
+	go w.RunVideo()
+
+	if _, err := w.Config(protocol.Config{}); err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	ctx := testContext(t)
+	if err := w.WaitForState(ctx, StateVideoStarted); err != nil {
+		t.Fatalf("video did not start: %v", err)
+	}
+
+	// the BufPipe only flushes once its buffer is full, so stream enough fragments to fill it
+	fragments := [][]byte{weylustest.InitSegment(), bytes.Repeat([]byte{0xff}, 4096)}
+	srv.SetFragments(fragments)
+	want := append(append([]byte(nil), fragments[0]...), fragments[1][:4096-len(fragments[0])]...)
+	got := make([]byte, len(want))
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(w.BufPipe, got)
+		done <- err
+	}()
+	select {
+	case <-ctx.Done():
+		t.Fatal("no video data received")
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("read video: %v", err)
+		}
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("video data does not match the streamed fragments")
+	}
+}
+
+func TestWeylusClient_Record(t *testing.T) {
+	srv := newTestServer(t)
+	fragments := weylustest.Fragments(3)
+	srv.SetFragments(fragments)
+	w := newTestClient(t, srv)
+	path := filepath.Join(t.TempDir(), "rec.mp4")
+	if err := w.Recorder.Start(recorder.Options{Path: path}); err != nil {
+		t.Fatalf("start recording: %v", err)
+	}
+	go w.RunVideo()
+
+	if _, err := w.Config(protocol.Config{}); err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	// NewVideo, the initialization segment, the media segments and one more to make sure all were received
+	if !srv.WaitFor(testContext(t), protocol.WeylusCommandTryGetFrame, len(fragments)+2) {
+		t.Fatal("not enough frames requested")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(filepath.Dir(path), "rec-001.mp4"))
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	if want := bytes.Join(fragments, nil); !bytes.Equal(got, want) {
+		t.Errorf("recording contains %x, want %x", got, want)
+	}
+}
+
+func TestWeylusClient_RecordInput(t *testing.T) {
+	srv := newTestServer(t)
+	w := newTestClient(t, srv)
+	var buf bytes.Buffer
+	w.RecordInput(&buf)
+
+	if err := w.SendPointerEvent(protocol.PointerEvent{EventType: protocol.PointerEventTypeDown, X: 0.25}); err != nil {
+		t.Fatalf("SendPointerEvent: %v", err)
+	}
+	if err := w.SendKeyboardEvent(protocol.KeyboardEvent{EventType: protocol.KeyboardEventTypeDown, Key: "a"}); err != nil {
+		t.Fatalf("SendKeyboardEvent: %v", err)
+	}
+	w.RecordInput(nil)
+	if err := w.SendWheelEvent(protocol.WheelEvent{Dy: 10}); err != nil {
+		t.Fatalf("SendWheelEvent: %v", err)
+	}
+
+	// replay the recording on a second connection
+	srv2 := newTestServer(t)
+	w2 := newTestClient(t, srv2)
+	if err := macro.Replay(testContext(t), &buf, w2, 1); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	ctx := testContext(t)
+	if !srv2.WaitFor(ctx, protocol.WeylusCommandPointerEvent, 1) || !srv2.WaitFor(ctx, protocol.WeylusCommandKeyboardEvent, 1) {
+		t.Fatalf("replayed events not received: %v", srv2.Received())
+	}
+	if n := srv2.Count(protocol.WeylusCommandWheelEvent); n != 0 {
+		t.Errorf("received %d WheelEvent sent after the recording stopped", n)
+	}
+	if received := srv2.Received(); !bytes.Contains(received[0].Data, []byte(`"x":0.25`)) {
+		t.Errorf("unexpected replayed event %s", received[0].Data)
+	}
+}
+
+func TestWeylusClient_SendInputEvents(t *testing.T) {
+	srv := newTestServer(t)
+	w := newTestClient(t, srv)
+
+	if err := w.SendPointerEvent(protocol.PointerEvent{EventType: protocol.PointerEventTypeMove, X: 0.5}); err != nil {
+		t.Fatalf("SendPointerEvent: %v", err)
+	}
+	if err := w.SendWheelEvent(protocol.WheelEvent{Dy: -120}); err != nil {
+		t.Fatalf("SendWheelEvent: %v", err)
+	}
+	if err := w.SendKeyboardEvent(protocol.KeyboardEvent{EventType: protocol.KeyboardEventTypeDown, Key: "a"}); err != nil {
+		t.Fatalf("SendKeyboardEvent: %v", err)
+	}
+	if !srv.WaitFor(testContext(t), protocol.WeylusCommandKeyboardEvent, 1) {
+		t.Fatalf("events not received: %v", srv.Received())
+	}
+
+	var tests = []struct {
+		command protocol.WeylusCommand
+		prefix  string
+	}{
+		{protocol.WeylusCommandPointerEvent, `{"PointerEvent":{"event_type":"pointermove",`},
+		{protocol.WeylusCommandWheelEvent, `{"WheelEvent":{`},
+		{protocol.WeylusCommandKeyboardEvent, `{"KeyboardEvent":{"event_type":"down",`},
+	}
+	received := srv.Received()
+	if len(received) != len(tests) {
+		t.Fatalf("received %d messages, want %d", len(received), len(tests))
+	}
+	for i, tt := range tests {
+		if received[i].Command != tt.command || !bytes.HasPrefix(received[i].Data, []byte(tt.prefix)) {
+			t.Errorf("message %d = %s, want %s wrapped as %s...", i, received[i].Data, tt.command, tt.prefix)
+		}
+	}
+}
+
+func TestWeylusClient_StartVideoRetry(t *testing.T) {
+	srv := newTestServer(t)
+	w := newTestClient(t, srv)
+	if _, err := w.Config(protocol.Config{}); err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	w.callbackMutex.Lock()
+	before := len(w.callbacks[protocol.WeylusResponseNewVideo])
+	w.callbackMutex.Unlock()
+	for i := 0; i < 3; i++ {
+		if err := w.StartVideo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.callbackMutex.Lock()
+	defer w.callbackMutex.Unlock()
+	if n := len(w.callbacks[protocol.WeylusResponseNewVideo]); n != before {
+		t.Errorf("%d NewVideo callbacks after retrying StartVideo, want %d", n, before)
+	}
+}
+
+func TestWeylusClient_dispatch(t *testing.T) {
+	w := NewWeylusClient(context.Background(), 30)
+	defer w.Close()
+	var got []protocol.WeylusResponse
+	for _, response := range []protocol.WeylusResponse{
+		protocol.WeylusResponseError, protocol.WeylusResponseConfigError, protocol.WeylusResponseClipboardContent,
+	} {
+		response := response
+		w.AddCallback(response, func(utils.Msg) { got = append(got, response) })
+	}
+	for _, data := range []string{
+		`{"ConfigError":"invalid capturable"}`,
+		`{"CapturableList":["ClipboardContent","Error"]}`,
+		`"Error"`,
+		`not json`,
+	} {
+		w.dispatch(utils.Msg{Type: websocket.MessageText, Data: []byte(data)})
+	}
+	want := []protocol.WeylusResponse{protocol.WeylusResponseConfigError, protocol.WeylusResponseError}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched to %v, want %v", got, want)
+	}
+}
+
+func TestWeylusClient_StartVideoNotConfigured(t *testing.T) {
+	srv := newTestServer(t)
+	w := newTestClient(t, srv)
+
+	if err := w.StartVideo(); err == nil {
+		t.Fatal("StartVideo succeeded before Config")
+	}
+	if n := srv.Count(protocol.WeylusCommandTryGetFrame); n != 0 {
+		t.Errorf("server received %d TryGetFrame, want 0", n)
+	}
+}
+
+func TestWeylusClient_NotDialed(t *testing.T) {
+	w := NewWeylusClient(context.Background(), 30)
+	defer w.Close()
+	if _, err := w.GetCapturableList(); err == nil {
+		t.Error("GetCapturableList succeeded without Dial")
+	}
+	if err := w.TryGetFrame(); err == nil {
+		t.Error("TryGetFrame succeeded without Dial")
+	}
+	if w.State() != StateDisconnected {
+		t.Errorf("state is %s, want %s", w.State(), StateDisconnected)
+	}
+}
+
+func TestWeylusClient_Reconnect(t *testing.T) {
+	srv := newTestServer(t)
+	w := newTestClient(t, srv)
+	go w.RunVideo()
+	ctx := testContext(t)
+
+	if _, err := w.Config(protocol.Config{}); err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	if err := w.WaitForState(ctx, StateVideoStarted); err != nil {
+		t.Fatalf("video did not start: %v", err)
+	}
+
+	srv.DropConnections()
+	for w.State() != StateDisconnected {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("state is %s after the connection dropped", w.State())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if err := w.Dial(srv.WebsocketURL()); err != nil {
+		t.Fatalf("redial: %v", err)
+	}
+	go w.Listen()
+	if _, err := w.GetCapturableList(); err != nil {
+		t.Fatalf("GetCapturableList after reconnect: %v", err)
+	}
+	if _, err := w.Config(protocol.Config{}); err != nil {
+		t.Fatalf("Config after reconnect: %v", err)
+	}
+	if err := w.WaitForState(ctx, StateVideoStarted); err != nil {
+		t.Fatalf("video did not restart: %v", err)
+	}
+	tries := srv.Count(protocol.WeylusCommandTryGetFrame)
+	if !srv.WaitFor(ctx, protocol.WeylusCommandTryGetFrame, tries+3) {
+		t.Error("no frames requested after reconnect")
+	}
+}
+
+// clipboardHandler records the clipboard messages of the server.
+type clipboardHandler struct {
+	requests chan protocol.ClipboardRequest
+	contents chan protocol.ClipboardContent
+}
+
+func (h *clipboardHandler) HandleOffer(protocol.ClipboardOffer) error {
+	return nil
+}
+
+func (h *clipboardHandler) HandleRequest(r protocol.ClipboardRequest) error {
+	h.requests <- r
+	return nil
+}
+
+func (h *clipboardHandler) HandleContent(_ context.Context, c protocol.ClipboardContent) error {
+	h.contents <- c
+	return nil
+}
+
+func TestWeylusClient_HandleClipboard(t *testing.T) {
+	srv := newTestServer(t)
+	request := protocol.ClipboardRequest{Serial: 3, MimeType: protocol.ClipboardMimeText}
+	content := protocol.ClipboardContent{Serial: 7, MimeType: protocol.ClipboardMimeText, Data: []byte("hello")}
+	srv.Handle(protocol.WeylusCommandClipboardOffer, weylustest.Reply(
+		map[protocol.WeylusResponse]protocol.ClipboardRequest{protocol.WeylusResponseClipboardRequest: request},
+	))
+	srv.Handle(protocol.WeylusCommandClipboardRequest, weylustest.Reply(
+		map[protocol.WeylusResponse]protocol.ClipboardContent{protocol.WeylusResponseClipboardContent: content},
+	))
+	w := newTestClient(t, srv)
+	h := &clipboardHandler{
+		requests: make(chan protocol.ClipboardRequest, 1),
+		contents: make(chan protocol.ClipboardContent, 1),
+	}
+	w.HandleClipboard(h)
+	ctx := testContext(t)
+
+	if err := w.SendClipboardOffer(protocol.ClipboardOffer{Serial: 3, MimeTypes: []string{protocol.ClipboardMimeText}, Size: 5}); err != nil {
+		t.Fatalf("SendClipboardOffer: %v", err)
+	}
+	select {
+	case got := <-h.requests:
+		if got != request {
+			t.Errorf("request = %+v, want %+v", got, request)
+		}
+	case <-ctx.Done():
+		t.Fatal("no ClipboardRequest handled")
+	}
+
+	if err := w.SendClipboardRequest(protocol.ClipboardRequest{Serial: 7, MimeType: protocol.ClipboardMimeText}); err != nil {
+		t.Fatalf("SendClipboardRequest: %v", err)
+	}
+	select {
+	case got := <-h.contents:
+		if got.Serial != content.Serial || !bytes.Equal(got.Data, content.Data) {
+			t.Errorf("content = %+v, want %+v", got, content)
+		}
+	case <-ctx.Done():
+		t.Fatal("no ClipboardContent handled")
+	}
+}
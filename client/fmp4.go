@@ -0,0 +1,64 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/internal/mp4"
+)
+
+// videoClock tracks the timeline of the fMP4 video stream.
+type videoClock struct {
+	// timescale of the video track in ticks per second, from the init segment
+	timescale uint32
+}
+
+// Fragment reads the timescale of init segments and returns the decode time of media segments.
+// ok is false for anything else and before the first init segment.
+func (c *videoClock) Fragment(data []byte) (time.Duration, bool) {
+	if mdhd, ok := mp4.Find(data, "moov", "trak", "mdia", "mdhd"); ok {
+		// version and flags, then creation and modification time of 4 or 8 bytes each
+		offset := 12
+		if len(mdhd) > 0 && mdhd[0] == 1 {
+			offset = 20
+		}
+		if len(mdhd) >= offset+4 {
+			c.timescale = binary.BigEndian.Uint32(mdhd[offset:])
+		}
+		return 0, false
+	}
+	tfdt, ok := mp4.Find(data, "moof", "traf", "tfdt")
+	if !ok || c.timescale == 0 || len(tfdt) < 8 {
+		return 0, false
+	}
+	var decodeTime uint64
+	if tfdt[0] == 1 {
+		if len(tfdt) < 12 {
+			return 0, false
+		}
+		decodeTime = binary.BigEndian.Uint64(tfdt[4:])
+	} else {
+		decodeTime = uint64(binary.BigEndian.Uint32(tfdt[4:]))
+	}
+	seconds := decodeTime / uint64(c.timescale)
+	rest := decodeTime % uint64(c.timescale)
+	return time.Duration(seconds)*time.Second + time.Duration(rest)*time.Second/time.Duration(c.timescale), true
+}
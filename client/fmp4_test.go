@@ -0,0 +1,81 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/client/weylustest"
+)
+
+func initSegment(version byte, timescale uint32) []byte {
+	mdhd := make([]byte, 32)
+	mdhd[0] = version
+	offset := 12
+	if version == 1 {
+		offset = 20
+	}
+	binary.BigEndian.PutUint32(mdhd[offset:], timescale)
+	return append(weylustest.Box("ftyp", []byte("isom")),
+		weylustest.Box("moov", weylustest.Box("trak", weylustest.Box("mdia", weylustest.Box("mdhd", mdhd))))...)
+}
+
+func mediaSegment(version byte, decodeTime uint64) []byte {
+	tfdt := make([]byte, 8)
+	if version == 1 {
+		tfdt = make([]byte, 12)
+		binary.BigEndian.PutUint64(tfdt[4:], decodeTime)
+	} else {
+		binary.BigEndian.PutUint32(tfdt[4:], uint32(decodeTime))
+	}
+	tfdt[0] = version
+	moof := weylustest.Box("moof", weylustest.Box("mfhd", make([]byte, 8)), weylustest.Box("traf", weylustest.Box("tfdt", tfdt)))
+	return append(moof, weylustest.Box("mdat", []byte{1, 2})...)
+}
+
+func TestVideoClock_Fragment(t *testing.T) {
+	tests := []struct {
+		name      string
+		fragments [][]byte
+		want      time.Duration
+		wantOk    bool
+	}{
+		{"BeforeInit", [][]byte{mediaSegment(0, 90000)}, 0, false},
+		{"Init", [][]byte{initSegment(0, 90000)}, 0, false},
+		{"Version0", [][]byte{initSegment(0, 90000), mediaSegment(0, 135000)}, 1500 * time.Millisecond, true},
+		{"Version1", [][]byte{initSegment(1, 1000), mediaSegment(1, 1<<33)}, (1 << 33) * time.Millisecond, true},
+		{"NoTfdt", [][]byte{weylustest.InitSegment(), weylustest.MediaSegment(1)}, 0, false},
+		{"Truncated", [][]byte{initSegment(0, 90000), mediaSegment(0, 90000)[:20]}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c videoClock
+			var got time.Duration
+			var ok bool
+			for _, f := range tt.fragments {
+				got, ok = c.Fragment(f)
+			}
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Fragment() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
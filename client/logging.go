@@ -0,0 +1,46 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/rs/zerolog/log"
+)
+
+// redacted replaces values that must not end up in logs.
+const redacted = "REDACTED"
+
+// redactedResponses carry clipboard contents, they are logged without content.
+var redactedResponses = map[protocol.WeylusResponse]bool{
+	protocol.WeylusResponseClipboardContent: true,
+}
+
+// logMessage logs a text message received from the server at debug level.
+func (w *WeylusClient) logMessage(data []byte) {
+	l := log.Ctx(w.ctx)
+	response, ok := responseOf(data)
+	switch {
+	case !ok:
+		l.Debug().Int("size", len(data)).Msg("received invalid data")
+	case redactedResponses[response]:
+		l.Debug().Stringer("response", response).Str("data", redacted).Int("size", len(data)).Msg("received data")
+	default:
+		l.Debug().Stringer("response", response).RawJSON("data", data).Msg("received data")
+	}
+}
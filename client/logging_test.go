@@ -0,0 +1,61 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestWeylusClient_logMessage(t *testing.T) {
+	var tests = []struct {
+		name     string
+		data     string
+		contains string
+		excludes string
+	}{
+		{"clipboard", `{"ClipboardContent":{"data":"c2VjcmV0"}}`, `"data":"REDACTED"`, "c2VjcmV0"},
+		{"capturables", `{"CapturableList":["Desktop"]}`, `"data":{"CapturableList":["Desktop"]}`, "REDACTED"},
+		{"plain response", `"ConfigOk"`, `"response":"ConfigOk"`, ""},
+		{"invalid", `{"ClipboardContent"`, "received invalid data", "ClipboardContent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := zerolog.New(&buf).Level(zerolog.InfoLevel)
+			w := &WeylusClient{ctx: l.WithContext(context.Background())}
+			if w.logMessage([]byte(tt.data)); buf.Len() != 0 {
+				t.Errorf("logged %s above debug level", buf.String())
+			}
+			l = zerolog.New(&buf)
+			w.ctx = l.WithContext(context.Background())
+			w.logMessage([]byte(tt.data))
+			if !strings.Contains(buf.String(), tt.contains) {
+				t.Errorf("log %s doesn't contain %s", buf.String(), tt.contains)
+			}
+			if tt.excludes != "" && strings.Contains(buf.String(), tt.excludes) {
+				t.Errorf("log %s contains %s", buf.String(), tt.excludes)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+//go:generate go-enum --marshal --names --values
+package client
+
+// State describes how far the WeylusClient has progressed through the connection handshake.
+/*
+ENUM(
+ disconnected // The websocket is not connected.
+ dialed // The websocket is connected, but no Config has been accepted yet.
+ configured // The server accepted a Config.
+ videoStarted // The server announced a NewVideo and frames can be requested.
+)
+*/
+type State int
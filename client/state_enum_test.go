@@ -0,0 +1,144 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+func TestStateNames(t *testing.T) {
+	names := StateNames()
+	for _, name := range names {
+		if i := lo.IndexOf(_StateNames, name); i < 0 {
+			t.Fatalf("value %v not in list _StateNames", name)
+		}
+	}
+	for _, name := range _StateNames {
+		if i := lo.IndexOf(names, name); i < 0 {
+			t.Fatalf("value %v not returned", name)
+		}
+	}
+}
+
+func TestStateValues(t *testing.T) {
+	values := StateValues()
+	for _, value := range values {
+		if _, ok := lo.FindKey(_StateValue, value); !ok {
+			t.Fatalf("value %v not in map _StateValue", value)
+		}
+	}
+	for _, value := range _StateValue {
+		if i := lo.IndexOf(values, value); i < 0 {
+			t.Fatalf("value %v not returned", value)
+		}
+	}
+}
+
+func TestState_String(t *testing.T) {
+	for s, command := range _StateValue {
+		if command.String() != s {
+			t.Fatalf("String returned invalid result %s for value %v", command.String(), s)
+		}
+	}
+}
+
+func FuzzState_String(f *testing.F) {
+	for _, seed := range append(StateValues(), 4, -1) {
+		f.Add(int(seed))
+	}
+	f.Fuzz(func(t *testing.T, in int) {
+		val := State(in)
+		out := val.String()
+
+		if lo.Contains(StateValues(), val) {
+			if !lo.Contains(StateNames(), out) {
+				t.Errorf("invalid string %s on valid value %v", out, _StateMap[val])
+			}
+		} else {
+			if s := fmt.Sprintf("State(%d)", in); out != s {
+				t.Errorf("invalid string %s on unknown value %d, should be %s", out, in, s)
+			}
+		}
+	})
+}
+
+func TestState_MarshalText(t *testing.T) {
+	for s, command := range _StateValue {
+		if b, _ := command.MarshalText(); string(b) != s {
+			t.Fatalf("Marshal %v returned invalid value %s", command, string(b))
+		}
+	}
+}
+
+func TestState_UnmarshalText(t *testing.T) {
+	var foo State
+	for s, command := range _StateValue {
+		if err := foo.UnmarshalText([]byte(s)); err != nil {
+			t.Fatalf("Unmarshal %s returned error %v", s, err)
+		} else if foo != command {
+			t.Fatalf("Unmarshal %s returned invalid value %s", s, foo)
+		}
+	}
+}
+
+func FuzzParseState(f *testing.F) {
+	for _, seed := range append(StateNames(), "fdsaghjkfcgkjhsdgvf") {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		res, err := ParseState(in)
+		if lo.Contains(_StateNames, in) {
+			if err != nil {
+				t.Errorf("Got error on correct value %s: %v", in, err)
+			}
+		} else {
+			if err == nil {
+				t.Errorf("Got no error on invalid value %s, result: %v", in, res)
+			}
+		}
+	})
+}
+
+func FuzzState_UnmarshalText(f *testing.F) {
+	for _, seed := range StateValues() {
+		f.Log(int(seed))
+		b, _ := seed.MarshalText()
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		var res State
+		err := res.UnmarshalText(in)
+		t.Logf("in: %s %v out: %v", string(in), in, res)
+		if err != nil {
+			t.Log(err)
+			if res2, err2 := res.MarshalText(); err2 != nil {
+				t.Fatalf("error on remarshal %v: %v", res, err2)
+			} else if string(in) != string(res2) && string(in) != strconv.Itoa(int(res)) && res != StateDisconnected {
+				t.Errorf("Values dont match after remarshal: %v != %v", string(in), string(res2))
+			}
+			if err.Error() != fmt.Errorf("%s is %w", string(in), ErrInvalidState).Error() {
+				t.Fatalf("invalid error on unmarshal %s: %v", string(in), err)
+			}
+		}
+	})
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package weylustest
+
+import "encoding/binary"
+
+// Box returns an ISO BMFF box of type typ containing payload.
+func Box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(b, uint32(size))
+	copy(b[4:], typ)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// InitSegment returns a minimal fMP4 initialization segment (ftyp and moov).
+func InitSegment() []byte {
+	ftyp := Box("ftyp", []byte("isom"), []byte{0, 0, 2, 0}, []byte("isomiso6mp41"))
+	moov := Box("moov", Box("mvhd", make([]byte, 100)), Box("mvex", Box("trex", make([]byte, 24))))
+	return append(ftyp, moov...)
+}
+
+// MediaSegment returns a minimal fMP4 media segment (moof and mdat) with sequence number seq.
+func MediaSegment(seq uint32) []byte {
+	mfhd := make([]byte, 8)
+	binary.BigEndian.PutUint32(mfhd[4:], seq)
+	moof := Box("moof", Box("mfhd", mfhd))
+	mdat := Box("mdat", []byte{byte(seq), byte(seq >> 8)})
+	return append(moof, mdat...)
+}
+
+// TrackSegment returns a fMP4 media segment like MediaSegment with a track fragment whose first sample is a keyframe
+// or not, as told by the first sample flags of its trun box.
+func TrackSegment(seq uint32, keyframe bool) []byte {
+	mfhd := make([]byte, 8)
+	binary.BigEndian.PutUint32(mfhd[4:], seq)
+	tfhd := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	// version 0, first sample flags present, one sample
+	trun := []byte{0, 0, 0, 0x04, 0, 0, 0, 1, 0, 0, 0, 0}
+	if keyframe {
+		trun[8] = 0x02 // depends on no other sample
+	} else {
+		trun[8], trun[9] = 0x01, 0x01 // depends on other samples, not a sync sample
+	}
+	moof := Box("moof", Box("mfhd", mfhd), Box("traf", Box("tfhd", tfhd), Box("trun", trun)))
+	mdat := Box("mdat", []byte{byte(seq), byte(seq >> 8)})
+	return append(moof, mdat...)
+}
+
+// Fragments returns an initialization segment followed by n media segments, the way weylus streams them.
+func Fragments(n int) [][]byte {
+	fragments := [][]byte{InitSegment()}
+	for i := 1; i <= n; i++ {
+		fragments = append(fragments, MediaSegment(uint32(i)))
+	}
+	return fragments
+}
@@ -0,0 +1,266 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package weylustest provides a scriptable in-memory weylus server for testing clients.
+package weylustest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// Response describes how the Server answers a command.
+type Response struct {
+	// Messages are written as JSON text messages in order.
+	Messages []any
+	// Delay is waited before the Messages are written.
+	Delay time.Duration
+	// Drop closes the connection instead of answering.
+	Drop bool
+}
+
+// Reply returns a Response writing the given messages.
+func Reply(messages ...any) Response {
+	return Response{Messages: messages}
+}
+
+// Error returns a Response writing a protocol.WeylusError with message.
+func Error(message string) Response {
+	return Reply(protocol.WeylusError{ErrorMessage: message})
+}
+
+// ConfigError returns a Response writing a protocol.WeylusConfigError with message.
+func ConfigError(message string) Response {
+	return Reply(protocol.WeylusConfigError{ErrorMessage: message})
+}
+
+// Received is a command received by the Server.
+type Received struct {
+	Command protocol.WeylusCommand
+	Data    json.RawMessage
+}
+
+// Server is a fake weylus server. Without further configuration it answers like a weylus instance with a single
+// capturable and streams the canned fragments returned by Fragments on TryGetFrame.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	received  []Received
+	responses map[protocol.WeylusCommand]Response
+	fragments [][]byte
+	conns     map[*websocket.Conn]context.CancelFunc
+	notify    chan struct{}
+}
+
+// NewServer starts a new Server. Call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		responses: map[protocol.WeylusCommand]Response{
+			protocol.WeylusCommandGetCapturableList: Reply(protocol.CapturableList{CapturableList: []string{"Desktop"}}),
+			protocol.WeylusCommandConfig:            Reply(protocol.WeylusResponseConfigOk),
+		},
+		fragments: Fragments(4),
+		conns:     make(map[*websocket.Conn]context.CancelFunc),
+		notify:    make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// WebsocketURL returns the ws:// address of the Server.
+func (s *Server) WebsocketURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// Handle sets the Response for command.
+func (s *Server) Handle(command protocol.WeylusCommand, response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[command] = response
+}
+
+// SetFragments sets the binary fragments streamed on TryGetFrame. The first TryGetFrame of a connection is answered
+// with NewVideo, every following one with the next fragment until all fragments have been sent.
+func (s *Server) SetFragments(fragments [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fragments = fragments
+}
+
+// Received returns all commands received so far.
+func (s *Server) Received() []Received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Received(nil), s.received...)
+}
+
+// Count returns how often command was received.
+func (s *Server) Count(command protocol.WeylusCommand) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.received {
+		if r.Command == command {
+			n++
+		}
+	}
+	return n
+}
+
+// WaitFor blocks until command was received at least n times or ctx is done.
+func (s *Server) WaitFor(ctx context.Context, command protocol.WeylusCommand, n int) bool {
+	for {
+		s.mu.Lock()
+		notify := s.notify
+		s.mu.Unlock()
+		if s.Count(command) >= n {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-notify:
+		}
+	}
+}
+
+// DropConnections aborts all open websocket connections without a close handshake.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	cancels := make([]context.CancelFunc, 0, len(s.conns))
+	for _, cancel := range s.conns {
+		cancels = append(cancels, cancel)
+	}
+	s.mu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
+
+// Close drops all connections and shuts the Server down.
+func (s *Server) Close() {
+	s.DropConnections()
+	s.Server.Close()
+}
+
+func (s *Server) record(command protocol.WeylusCommand, data []byte) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, Received{Command: command, Data: append(json.RawMessage(nil), data...)})
+	close(s.notify)
+	s.notify = make(chan struct{})
+	return s.responses[command]
+}
+
+func (s *Server) fragment(i int) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i >= len(s.fragments) {
+		return nil
+	}
+	return s.fragments[i]
+}
+
+func (s *Server) handle(writer http.ResponseWriter, request *http.Request) {
+	c, err := websocket.Accept(writer, request, nil)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(request.Context())
+	s.mu.Lock()
+	s.conns[c] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		cancel()
+		_ = c.Close(websocket.StatusNormalClosure, "")
+	}()
+
+	frames := 0
+	for {
+		typ, data, err := c.Read(ctx)
+		if err != nil {
+			return
+		}
+		if typ != websocket.MessageText {
+			continue
+		}
+		command, ok := parseCommand(data)
+		if !ok {
+			continue
+		}
+		response := s.record(command, data)
+		if response.Delay > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(response.Delay):
+			}
+		}
+		if response.Drop {
+			return
+		}
+		for _, msg := range response.Messages {
+			if err := wsjson.Write(ctx, c, msg); err != nil {
+				return
+			}
+		}
+		if command != protocol.WeylusCommandTryGetFrame || len(response.Messages) > 0 {
+			continue
+		}
+		if frames == 0 {
+			err = wsjson.Write(ctx, c, protocol.WeylusResponseNewVideo)
+		} else if f := s.fragment(frames - 1); f != nil {
+			err = c.Write(ctx, websocket.MessageBinary, f)
+		}
+		if err != nil {
+			return
+		}
+		frames++
+	}
+}
+
+// parseCommand extracts the command from either a bare string or a single-key object.
+func parseCommand(data []byte) (protocol.WeylusCommand, bool) {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		cmd, err := protocol.ParseWeylusCommand(str)
+		return cmd, err == nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return "", false
+	}
+	for key := range obj {
+		cmd, err := protocol.ParseWeylusCommand(key)
+		return cmd, err == nil
+	}
+	return "", false
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package weylustest
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, _, err := websocket.Dial(ctx, srv.WebsocketURL(), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	if err := wsjson.Write(ctx, c, protocol.WrapMessage(protocol.Config{ClientName: "test"})); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var res string
+	if err := wsjson.Read(ctx, c, &res); err != nil || res != string(protocol.WeylusResponseConfigOk) {
+		t.Fatalf("read ConfigOk: %q %v", res, err)
+	}
+
+	if err := wsjson.Write(ctx, c, protocol.WeylusCommandTryGetFrame); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := wsjson.Read(ctx, c, &res); err != nil || res != string(protocol.WeylusResponseNewVideo) {
+		t.Fatalf("read NewVideo: %q %v", res, err)
+	}
+	if err := wsjson.Write(ctx, c, protocol.WeylusCommandTryGetFrame); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	typ, data, err := c.Read(ctx)
+	if err != nil || typ != websocket.MessageBinary || string(data[4:8]) != "ftyp" {
+		t.Fatalf("read init segment: %v %v", typ, err)
+	}
+
+	received := srv.Received()
+	if len(received) != 3 || received[0].Command != protocol.WeylusCommandConfig {
+		t.Errorf("unexpected received commands: %v", received)
+	}
+}
+
+func TestBox(t *testing.T) {
+	b := Box("mdat", []byte{1, 2}, []byte{3})
+	if size := binary.BigEndian.Uint32(b); size != 11 || int(size) != len(b) {
+		t.Errorf("invalid box size %d for %d bytes", size, len(b))
+	}
+	if string(b[4:8]) != "mdat" {
+		t.Errorf("invalid box type %s", b[4:8])
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package clipboard synchronizes the clipboards of client and server.
+package clipboard
+
+import (
+	"context"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxSize is the size limit of clipboard content in bytes if a profile doesn't set one.
+const DefaultMaxSize = 8 << 20
+
+// DefaultProfileName is the name of the profile used for peers without a profile of their own.
+const DefaultProfileName = "default"
+
+var (
+	// ErrTooLarge is returned for clipboard content larger than the size limit.
+	ErrTooLarge = errors.New("clipboard content too large")
+	// ErrUnsupportedType is returned for clipboard content of a type that isn't synchronized.
+	ErrUnsupportedType = errors.New("unsupported clipboard type")
+)
+
+// mimeTypes are the synchronized types by preference, images before text as copied images often come with a
+// text description.
+var mimeTypes = []string{protocol.ClipboardMimePNG, protocol.ClipboardMimeText}
+
+// Provider is a clipboard.
+type Provider interface {
+	// Types returns the synchronized types the clipboard content is available as.
+	Types(ctx context.Context) ([]string, error)
+	// Read reads the clipboard content as mimeType, failing with ErrTooLarge if it is larger than limit bytes.
+	Read(ctx context.Context, mimeType string, limit int) ([]byte, error)
+	// Write replaces the clipboard content.
+	Write(ctx context.Context, mimeType string, data []byte) error
+}
+
+// Watcher is a Provider telling about clipboard changes, Providers that aren't are polled.
+type Watcher interface {
+	// Changed is signaled when the clipboard content changes.
+	Changed() <-chan struct{}
+}
+
+// preferredType returns the preferred synchronized type of types, "" if there is none.
+func preferredType(types []string) string {
+	for _, mimeType := range mimeTypes {
+		for _, t := range types {
+			if t == mimeType {
+				return mimeType
+			}
+		}
+	}
+	return ""
+}
+
+func supported(mimeType string) bool {
+	return preferredType([]string{mimeType}) != ""
+}
+
+// Profile configures clipboard synchronization with a peer. Synchronization is opt-in, the zero Profile disables it.
+type Profile struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxSize is the size limit of clipboard content in bytes, 0 uses DefaultMaxSize.
+	MaxSize int `mapstructure:"max-size"`
+	// Images also synchronizes images, otherwise only text is.
+	Images bool `mapstructure:"images"`
+}
+
+// Validate checks the profile for invalid values.
+func (p Profile) Validate() error {
+	if p.MaxSize < 0 {
+		return errors.Errorf("max-size %d is negative", p.MaxSize)
+	}
+	return nil
+}
+
+// Limit returns the size limit of clipboard content in bytes.
+func (p Profile) Limit() int {
+	if p.MaxSize == 0 {
+		return DefaultMaxSize
+	}
+	return p.MaxSize
+}
+
+// Profiles are clipboard profiles by peer, the server hostname on the client and the client name on the server.
+type Profiles map[string]Profile
+
+// Validate checks all profiles for invalid values.
+func (p Profiles) Validate() error {
+	for name, profile := range p {
+		if err := profile.Validate(); err != nil {
+			return errors.Wrapf(err, "clipboard profile %s", name)
+		}
+	}
+	return nil
+}
+
+// For returns the profile of peer, falling back to the default profile.
+func (p Profiles) For(peer string) Profile {
+	if profile, ok := p[peer]; ok {
+		return profile
+	}
+	return p[DefaultProfileName]
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package clipboard
+
+import (
+	"context"
+	"io"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/core/gioutil"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/pkg/errors"
+)
+
+// GDK is a gdk.Clipboard. The clipboard is only touched on the main loop, the methods can be called from any
+// goroutine but block while the main loop isn't running.
+type GDK struct {
+	clipboard *gdk.Clipboard
+	changed   chan struct{}
+}
+
+// NewGDK creates a GDK for clipboard, it has to be called on the main loop.
+func NewGDK(clipboard *gdk.Clipboard) *GDK {
+	c := &GDK{clipboard: clipboard, changed: make(chan struct{}, 1)}
+	clipboard.ConnectChanged(func() {
+		select {
+		case c.changed <- struct{}{}:
+		default:
+		}
+	})
+	return c
+}
+
+// onMainLoop runs f on the main loop and waits for it to return.
+func onMainLoop[T any](ctx context.Context, f func() T) (T, error) {
+	result := make(chan T, 1)
+	glib.IdleAdd(func() {
+		result <- f()
+	})
+	select {
+	case r := <-result:
+		return r, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, errors.Wrap(ctx.Err(), "wait for main loop")
+	}
+}
+
+// Types implements Provider. Types the content can be converted to, like PNG for textures, are included.
+func (c *GDK) Types(ctx context.Context) ([]string, error) {
+	formats, err := onMainLoop(ctx, func() []string {
+		return c.clipboard.Formats().UnionSerializeMIMETypes().MIMETypes()
+	})
+	if err != nil {
+		return nil, err
+	}
+	var types []string
+	for _, mimeType := range mimeTypes {
+		for _, format := range formats {
+			if format == mimeType || mimeType == protocol.ClipboardMimeText && format == "text/plain" {
+				types = append(types, mimeType)
+				break
+			}
+		}
+	}
+	return types, nil
+}
+
+type readResult struct {
+	text   string
+	stream gio.InputStreamer
+	err    error
+}
+
+// Read implements Provider.
+func (c *GDK) Read(ctx context.Context, mimeType string, limit int) ([]byte, error) {
+	if !supported(mimeType) {
+		return nil, errors.Wrap(ErrUnsupportedType, mimeType)
+	}
+	done := make(chan readResult, 1)
+	if _, err := onMainLoop(ctx, func() struct{} {
+		if mimeType == protocol.ClipboardMimeText {
+			c.clipboard.ReadTextAsync(ctx, func(res gio.AsyncResulter) {
+				text, err := c.clipboard.ReadTextFinish(res)
+				done <- readResult{text: text, err: err}
+			})
+		} else {
+			c.clipboard.ReadAsync(ctx, []string{mimeType}, int(glib.PriorityDefault), func(res gio.AsyncResulter) {
+				_, stream, err := c.clipboard.ReadFinish(res)
+				done <- readResult{stream: stream, err: err}
+			})
+		}
+		return struct{}{}
+	}); err != nil {
+		return nil, err
+	}
+
+	var r readResult
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "read clipboard")
+	}
+	if r.err != nil {
+		return nil, errors.Wrap(r.err, "read clipboard")
+	}
+	if r.stream == nil {
+		if len(r.text) > limit {
+			return nil, ErrTooLarge
+		}
+		return []byte(r.text), nil
+	}
+
+	reader := gioutil.Reader(ctx, r.stream)
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "read clipboard stream")
+	}
+	if len(data) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// Write implements Provider.
+func (c *GDK) Write(ctx context.Context, mimeType string, data []byte) error {
+	if !supported(mimeType) {
+		return errors.Wrap(ErrUnsupportedType, mimeType)
+	}
+	_, err := onMainLoop(ctx, func() struct{} {
+		if mimeType == protocol.ClipboardMimeText {
+			c.clipboard.SetText(string(data))
+		} else {
+			c.clipboard.SetContent(gdk.NewContentProviderForBytes(mimeType, glib.NewBytesWithGo(data)))
+		}
+		return struct{}{}
+	})
+	return err
+}
+
+// Changed implements Watcher.
+func (c *GDK) Changed() <-chan struct{} {
+	return c.changed
+}
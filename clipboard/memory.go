@@ -0,0 +1,78 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package clipboard
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a clipboard in memory, for tests and headless servers.
+type Memory struct {
+	mu       sync.Mutex
+	mimeType string
+	data     []byte
+	changed  chan struct{}
+}
+
+// NewMemory creates an empty Memory clipboard.
+func NewMemory() *Memory {
+	return &Memory{changed: make(chan struct{}, 1)}
+}
+
+// Types implements Provider.
+func (m *Memory) Types(context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.mimeType == "" {
+		return nil, nil
+	}
+	return []string{m.mimeType}, nil
+}
+
+// Read implements Provider.
+func (m *Memory) Read(_ context.Context, mimeType string, limit int) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mimeType != m.mimeType {
+		return nil, ErrUnsupportedType
+	}
+	if len(m.data) > limit {
+		return nil, ErrTooLarge
+	}
+	return append([]byte(nil), m.data...), nil
+}
+
+// Write implements Provider.
+func (m *Memory) Write(_ context.Context, mimeType string, data []byte) error {
+	m.mu.Lock()
+	m.mimeType = mimeType
+	m.data = append([]byte(nil), data...)
+	m.mu.Unlock()
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Changed implements Watcher.
+func (m *Memory) Changed() <-chan struct{} {
+	return m.changed
+}
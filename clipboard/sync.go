@@ -0,0 +1,190 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package clipboard
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Sender sends clipboard messages to the peer.
+type Sender interface {
+	SendClipboardOffer(o protocol.ClipboardOffer) error
+	SendClipboardRequest(r protocol.ClipboardRequest) error
+	SendClipboardContent(c protocol.ClipboardContent) error
+}
+
+// Sync synchronizes a clipboard with the clipboard of a peer.
+// A local change is offered to the peer, which requests the content if it accepts the type and size.
+type Sync struct {
+	provider Provider
+	sender   Sender
+	profile  Profile
+
+	mu sync.Mutex
+	// serial of the last local offer and its content
+	serial  uint64
+	offered *protocol.ClipboardContent
+	// serial of the last accepted remote offer
+	remote uint64
+	// hash of the content last offered or received, so content received from the peer isn't offered back
+	last [sha256.Size]byte
+}
+
+// NewSync creates a Sync of provider with the peer sender sends to.
+func NewSync(provider Provider, sender Sender, profile Profile) *Sync {
+	return &Sync{provider: provider, sender: sender, profile: profile}
+}
+
+// preferredType returns the preferred type of types that is synchronized with the profile, "" if there is none.
+func (s *Sync) preferredType(types []string) string {
+	mimeType := preferredType(types)
+	if mimeType == protocol.ClipboardMimePNG && !s.profile.Images {
+		var text []string
+		for _, t := range types {
+			if t != protocol.ClipboardMimePNG {
+				text = append(text, t)
+			}
+		}
+		return preferredType(text)
+	}
+	return mimeType
+}
+
+func hash(mimeType string, data []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(mimeType))
+	h.Write([]byte{0})
+	h.Write(data)
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// Changed offers the local clipboard content to the peer, unless it didn't change since the last offer or came from
+// the peer.
+func (s *Sync) Changed(ctx context.Context) error {
+	types, err := s.provider.Types(ctx)
+	if err != nil {
+		return errors.Wrap(err, "read clipboard types")
+	}
+	mimeType := s.preferredType(types)
+	if mimeType == "" {
+		return nil
+	}
+	data, err := s.provider.Read(ctx, mimeType, s.profile.Limit())
+	if err != nil {
+		return errors.Wrapf(err, "read clipboard as %s", mimeType)
+	}
+
+	s.mu.Lock()
+	sum := hash(mimeType, data)
+	if sum == s.last {
+		s.mu.Unlock()
+		return nil
+	}
+	s.last = sum
+	s.serial++
+	s.offered = &protocol.ClipboardContent{Serial: s.serial, MimeType: mimeType, Data: data}
+	offer := protocol.ClipboardOffer{Serial: s.serial, MimeTypes: []string{mimeType}, Size: len(data)}
+	s.mu.Unlock()
+
+	return errors.Wrap(s.sender.SendClipboardOffer(offer), "send clipboard offer")
+}
+
+// HandleOffer requests the content of an offer of the peer, if its type and size are accepted.
+func (s *Sync) HandleOffer(o protocol.ClipboardOffer) error {
+	if o.Size > s.profile.Limit() {
+		return errors.Wrapf(ErrTooLarge, "offer of %d bytes", o.Size)
+	}
+	mimeType := s.preferredType(o.MimeTypes)
+	if mimeType == "" {
+		return nil
+	}
+	s.mu.Lock()
+	s.remote = o.Serial
+	s.mu.Unlock()
+	return errors.Wrap(s.sender.SendClipboardRequest(protocol.ClipboardRequest{Serial: o.Serial, MimeType: mimeType}),
+		"send clipboard request")
+}
+
+// HandleRequest sends the content of the last local offer.
+func (s *Sync) HandleRequest(r protocol.ClipboardRequest) error {
+	s.mu.Lock()
+	offered := s.offered
+	s.mu.Unlock()
+	if offered == nil || offered.Serial != r.Serial {
+		return errors.Errorf("request for outdated clipboard offer %d", r.Serial)
+	}
+	if offered.MimeType != r.MimeType {
+		return errors.Wrapf(ErrUnsupportedType, "request for %s", r.MimeType)
+	}
+	return errors.Wrap(s.sender.SendClipboardContent(*offered), "send clipboard content")
+}
+
+// HandleContent writes the content of the last requested offer to the local clipboard.
+// Content of older offers is dropped.
+func (s *Sync) HandleContent(ctx context.Context, c protocol.ClipboardContent) error {
+	if len(c.Data) > s.profile.Limit() {
+		return errors.Wrapf(ErrTooLarge, "content of %d bytes", len(c.Data))
+	}
+	if s.preferredType([]string{c.MimeType}) == "" {
+		return errors.Wrapf(ErrUnsupportedType, "content of type %s", c.MimeType)
+	}
+	s.mu.Lock()
+	if c.Serial != s.remote {
+		s.mu.Unlock()
+		return nil
+	}
+	s.last = hash(c.MimeType, c.Data)
+	s.mu.Unlock()
+	return errors.Wrap(s.provider.Write(ctx, c.MimeType, c.Data), "write clipboard")
+}
+
+// Run offers the local clipboard content on every change until ctx is done.
+// Providers that aren't Watchers are polled every interval.
+func (s *Sync) Run(ctx context.Context, interval time.Duration) {
+	// nil channels never fire
+	var changed <-chan struct{}
+	var tick <-chan time.Time
+	if w, ok := s.provider.(Watcher); ok {
+		changed = w.Changed()
+	} else {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		if err := s.Changed(ctx); err != nil {
+			log.Ctx(ctx).Debug().Err(err).Msg("offer clipboard")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-tick:
+		}
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+)
+
+// loopback delivers clipboard messages to the Sync of the peer and counts the offers.
+type loopback struct {
+	ctx    context.Context
+	peer   *Sync
+	offers int
+}
+
+func (l *loopback) SendClipboardOffer(o protocol.ClipboardOffer) error {
+	l.offers++
+	return l.peer.HandleOffer(o)
+}
+
+func (l *loopback) SendClipboardRequest(r protocol.ClipboardRequest) error {
+	return l.peer.HandleRequest(r)
+}
+
+func (l *loopback) SendClipboardContent(c protocol.ClipboardContent) error {
+	return l.peer.HandleContent(l.ctx, c)
+}
+
+type peer struct {
+	memory *Memory
+	sender *loopback
+	sync   *Sync
+}
+
+func newPeers(ctx context.Context, profile Profile) (a, b *peer) {
+	a = &peer{memory: NewMemory(), sender: &loopback{ctx: ctx}}
+	b = &peer{memory: NewMemory(), sender: &loopback{ctx: ctx}}
+	a.sync = NewSync(a.memory, a.sender, profile)
+	b.sync = NewSync(b.memory, b.sender, profile)
+	a.sender.peer = b.sync
+	b.sender.peer = a.sync
+	return a, b
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	png := []byte("\x89PNG\r\n\x1a\n")
+	tests := []struct {
+		name     string
+		profile  Profile
+		mimeType string
+		data     []byte
+		wantErr  error
+		want     []byte
+	}{
+		{"Text", Profile{Enabled: true}, protocol.ClipboardMimeText, []byte("hello"), nil, []byte("hello")},
+		{"Image", Profile{Enabled: true, Images: true}, protocol.ClipboardMimePNG, png, nil, png},
+		{"ImagesDisabled", Profile{Enabled: true}, protocol.ClipboardMimePNG, png, nil, nil},
+		{"Unsupported", Profile{Enabled: true}, "text/html", []byte("<b>hello</b>"), nil, nil},
+		{"TooLarge", Profile{Enabled: true, MaxSize: 4}, protocol.ClipboardMimeText, []byte("hello"), ErrTooLarge, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newPeers(ctx, tt.profile)
+			if err := a.memory.Write(ctx, tt.mimeType, tt.data); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.sync.Changed(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Changed() error = %v, want %v", err, tt.wantErr)
+			}
+			got, err := b.memory.Read(ctx, tt.mimeType, DefaultMaxSize)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("peer clipboard = %q, want it empty", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("peer Read() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("peer clipboard = %q, want %q", got, tt.want)
+			}
+
+			// the content came from a, b must not offer it back
+			if err := b.sync.Changed(ctx); err != nil {
+				t.Fatalf("peer Changed() error = %v", err)
+			}
+			if b.sender.offers != 0 {
+				t.Errorf("peer offered the received content back")
+			}
+			// unchanged content isn't offered again
+			if err := a.sync.Changed(ctx); err != nil {
+				t.Fatalf("Changed() error = %v", err)
+			}
+			if a.sender.offers != 1 {
+				t.Errorf("offers = %d, want 1", a.sender.offers)
+			}
+		})
+	}
+}
+
+func TestSync_Stale(t *testing.T) {
+	ctx := context.Background()
+	a, b := newPeers(ctx, Profile{Enabled: true})
+	if err := a.memory.Write(ctx, protocol.ClipboardMimeText, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sync.Changed(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.sync.HandleRequest(protocol.ClipboardRequest{Serial: 0, MimeType: protocol.ClipboardMimeText}); err == nil {
+		t.Error("HandleRequest(outdated serial) error = nil, want error")
+	}
+	if err := a.sync.HandleRequest(protocol.ClipboardRequest{Serial: 1, MimeType: protocol.ClipboardMimePNG}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("HandleRequest(other type) error = %v, want %v", err, ErrUnsupportedType)
+	}
+	// content of an older offer arriving late doesn't overwrite the clipboard
+	if err := b.sync.HandleContent(ctx, protocol.ClipboardContent{Serial: 0, MimeType: protocol.ClipboardMimeText, Data: []byte("old")}); err != nil {
+		t.Fatalf("HandleContent(outdated serial) error = %v", err)
+	}
+	got, err := b.memory.Read(ctx, protocol.ClipboardMimeText, DefaultMaxSize)
+	if err != nil || string(got) != "first" {
+		t.Errorf("peer clipboard = %q, %v, want %q", got, err, "first")
+	}
+}
+
+func TestProfiles_For(t *testing.T) {
+	profiles := Profiles{
+		DefaultProfileName: {Enabled: true},
+		"tablet":           {Enabled: true, Images: true, MaxSize: 1024},
+	}
+	if got := profiles.For("tablet"); got.Limit() != 1024 || !got.Images {
+		t.Errorf("For(tablet) = %+v", got)
+	}
+	if got := profiles.For("phone"); !got.Enabled || got.Limit() != DefaultMaxSize {
+		t.Errorf("For(phone) = %+v, want the default profile", got)
+	}
+	if got := (Profiles{}).For("phone"); got.Enabled {
+		t.Errorf("For() without profiles = %+v, want synchronization disabled", got)
+	}
+	if err := (Profiles{"tablet": {MaxSize: -1}}).Validate(); err == nil {
+		t.Error("Validate() with negative max-size error = nil, want error")
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+)
+
+// System is the clipboard of the desktop session, accessed through wl-clipboard on Wayland and xclip on X11.
+type System struct {
+	// list lists the native types of the clipboard content
+	list func() []string
+	// read reads the clipboard content as a native type
+	read func(nativeType string) []string
+	// write replaces the clipboard content with content of a native type
+	write func(nativeType string) []string
+	// aliases are the native types of the synchronized types by preference
+	aliases map[string][]string
+}
+
+// NewSystem creates the System clipboard of the running Wayland or X11 session.
+func NewSystem() (*System, error) {
+	switch {
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		return &System{
+			list:  func() []string { return []string{"wl-paste", "--list-types"} },
+			read:  func(t string) []string { return []string{"wl-paste", "--no-newline", "--type", t} },
+			write: func(t string) []string { return []string{"wl-copy", "--type", t} },
+			aliases: map[string][]string{
+				protocol.ClipboardMimeText: {protocol.ClipboardMimeText, "UTF8_STRING", "text/plain"},
+				protocol.ClipboardMimePNG:  {protocol.ClipboardMimePNG},
+			},
+		}, nil
+	case os.Getenv("DISPLAY") != "":
+		return &System{
+			list:  func() []string { return []string{"xclip", "-selection", "clipboard", "-out", "-target", "TARGETS"} },
+			read:  func(t string) []string { return []string{"xclip", "-selection", "clipboard", "-out", "-target", t} },
+			write: func(t string) []string { return []string{"xclip", "-selection", "clipboard", "-in", "-target", t} },
+			aliases: map[string][]string{
+				protocol.ClipboardMimeText: {"UTF8_STRING", protocol.ClipboardMimeText, "text/plain"},
+				protocol.ClipboardMimePNG:  {protocol.ClipboardMimePNG},
+			},
+		}, nil
+	}
+	return nil, errors.New("no Wayland or X11 session")
+}
+
+func (s *System) run(ctx context.Context, args []string, stdout io.Writer) error {
+	//nolint:gosec // the arguments are fixed apart from the types read from the clipboard
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "%s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// nativeTypes lists the native types of the clipboard content. An empty clipboard has no types.
+func (s *System) nativeTypes(ctx context.Context) map[string]bool {
+	var out bytes.Buffer
+	if err := s.run(ctx, s.list(), &out); err != nil {
+		// both tools fail on an empty clipboard
+		return nil
+	}
+	types := make(map[string]bool)
+	for _, t := range strings.Fields(out.String()) {
+		types[t] = true
+	}
+	return types
+}
+
+// nativeType returns the native type mimeType is read as, "" if the content isn't available as mimeType.
+func (s *System) nativeType(native map[string]bool, mimeType string) string {
+	for _, alias := range s.aliases[mimeType] {
+		if native[alias] {
+			return alias
+		}
+	}
+	return ""
+}
+
+// Types implements Provider.
+func (s *System) Types(ctx context.Context) ([]string, error) {
+	native := s.nativeTypes(ctx)
+	var types []string
+	for _, mimeType := range mimeTypes {
+		if s.nativeType(native, mimeType) != "" {
+			types = append(types, mimeType)
+		}
+	}
+	return types, nil
+}
+
+// limitWriter fails with ErrTooLarge once more than limit bytes were written.
+type limitWriter struct {
+	bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > w.limit {
+		w.exceeded = true
+		return 0, ErrTooLarge
+	}
+	return w.Buffer.Write(p)
+}
+
+// Read implements Provider.
+func (s *System) Read(ctx context.Context, mimeType string, limit int) ([]byte, error) {
+	nativeType := s.nativeType(s.nativeTypes(ctx), mimeType)
+	if nativeType == "" {
+		return nil, errors.Wrap(ErrUnsupportedType, mimeType)
+	}
+	out := &limitWriter{limit: limit}
+	if err := s.run(ctx, s.read(nativeType), out); err != nil {
+		if out.exceeded {
+			return nil, ErrTooLarge
+		}
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Write implements Provider.
+func (s *System) Write(ctx context.Context, mimeType string, data []byte) error {
+	if !supported(mimeType) {
+		return errors.Wrap(ErrUnsupportedType, mimeType)
+	}
+	args := s.write(s.aliases[mimeType][0])
+	//nolint:gosec // the arguments are fixed
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	// both tools keep serving the clipboard in the background after reading the content, capturing their output would
+	// wait for the background process
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, args[0])
+	}
+	return nil
+}
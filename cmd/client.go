@@ -30,23 +30,32 @@ import (
 	"path/filepath"
 	"strconv"
 	"sync"
-	"sync/atomic"
 	"time"
 
+	"github.com/OmegaRogue/weylus-desktop/audio"
 	"github.com/OmegaRogue/weylus-desktop/bmp"
 	"github.com/OmegaRogue/weylus-desktop/client"
+	"github.com/OmegaRogue/weylus-desktop/clipboard"
+	"github.com/OmegaRogue/weylus-desktop/frame"
+	"github.com/OmegaRogue/weylus-desktop/gstreamer"
+	"github.com/OmegaRogue/weylus-desktop/input"
+	"github.com/OmegaRogue/weylus-desktop/internal/config"
 	"github.com/OmegaRogue/weylus-desktop/internal/event"
 	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/OmegaRogue/weylus-desktop/recorder"
+	"github.com/OmegaRogue/weylus-desktop/screenshot"
 	"github.com/OmegaRogue/weylus-desktop/utils"
 	"github.com/diamondburned/gotk4/pkg/cairo"
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
-	// coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/edsrzf/mmap-go"
+	"github.com/holoplot/go-evdev"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -63,6 +72,11 @@ func NewClientCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			app := gtk.NewApplication("codes.omegavoid.weylus-desktop", gio.ApplicationHandlesCommandLine)
 			app.ConnectCommandLine(func(commandLine *gio.ApplicationCommandLine) (gint int) {
+				// a second instance started with --screenshot triggers a screenshot in the running one
+				if lo.Contains(commandLine.Arguments(), "--screenshot") && app.LookupAction("screenshot") != nil {
+					app.ActivateAction("screenshot", nil)
+					return 0
+				}
 				app.Activate()
 				return 0
 			})
@@ -83,6 +97,34 @@ func NewClientCmd() *cobra.Command {
 	clientCmd.Flags().StringP("hostname", "", "localhost", "Hostname to connect to")
 	clientCmd.Flags().Uint16P("websocket-port", "", 9001, "Websocket port")
 	clientCmd.Flags().StringP("access-code", "", "", "Access code")
+	clientCmd.Flags().BoolP("screenshot", "", false, "Take a screenshot in the already running client")
+	clientCmd.Flags().StringP("screenshot-dir", "", "", "Directory screenshots are saved to (default is the pictures directory)")
+	clientCmd.Flags().BoolP("screenshot-clipboard", "", false, "Also copy screenshots to the clipboard")
+	clientCmd.Flags().StringP("record-input", "", "", "Record the input events sent to the server as JSON Lines to PATH, see the replay command")
+	clientCmd.Flags().BoolP("capture-keyboard", "", false, "Capture the keyboard on start, so system shortcuts like Alt+Tab are sent to the server")
+	clientCmd.Flags().StringP("capture-keyboard-release", "", "<Control><Alt>Escape", "Key chord releasing the captured keyboard, in GTK accelerator format")
+	clientCmd.Flags().StringP("record", "", "", "Record the video stream, segments are written to PATH-001.mp4, PATH-002.mp4, ...")
+	clientCmd.Flags().Uint64P("record-max-size", "", 0, "Start a new recording segment after this many MiB, 0 disables rotation by size")
+	clientCmd.Flags().DurationP("record-max-duration", "", 0, "Start a new recording segment after this duration, 0 disables rotation by time")
+	clientCmd.Flags().StringP("relative-mouse-toggle", "", "<Control><Alt>m", "Key chord toggling relative mouse input, in GTK accelerator format. The cursor is hidden but not confined to the window")
+	clientCmd.Flags().BoolP("forward-gamepads", "", false, "Forward the gamepads connected to this machine to the server")
+	clientCmd.Flags().BoolP("audio", "", false, "Play the audio of the server")
+	clientCmd.Flags().DurationP("audio-latency", "", audio.DefaultLatency, "Time the video takes from arriving to being shown, audio is delayed by it to stay in sync")
+	clientCmd.Flags().UintP("fps", "", 30, "Frames per second requested from the server")
+	clientCmd.Flags().StringToStringP("device-type", "", nil, "Report the events of a device as mouse, pen or touch, by device name, for devices that misreport themselves")
+
+	if err := config.MarkNoSetting(clientCmd.Flags(), "screenshot"); err != nil {
+		log.Fatal().Err(err).Msg("failed marking flag screenshot")
+	}
+	if err := clientCmd.MarkFlagDirname("screenshot-dir"); err != nil {
+		log.Fatal().Err(err).Msg("failed mark flag screenshot-dir as dirname")
+	}
+	if err := clientCmd.MarkFlagFilename("record-input", "jsonl"); err != nil {
+		log.Fatal().Err(err).Msg("failed mark flag record-input as filename")
+	}
+	if err := clientCmd.MarkFlagFilename("record", "mp4"); err != nil {
+		log.Fatal().Err(err).Msg("failed mark flag record as filename")
+	}
 
 	if err := viper.BindPFlag("websocket-port", clientCmd.Flags().Lookup("websocket-port")); err != nil {
 		log.Fatal().Err(err).Msg("failed binding flag websocket-port")
@@ -93,6 +135,48 @@ func NewClientCmd() *cobra.Command {
 	if err := viper.BindPFlag("hostname", clientCmd.Flags().Lookup("hostname")); err != nil {
 		log.Fatal().Err(err).Msg("failed binding flag hostname")
 	}
+	if err := viper.BindPFlag("screenshot-dir", clientCmd.Flags().Lookup("screenshot-dir")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag screenshot-dir")
+	}
+	if err := viper.BindPFlag("screenshot-clipboard", clientCmd.Flags().Lookup("screenshot-clipboard")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag screenshot-clipboard")
+	}
+	if err := viper.BindPFlag("record-input", clientCmd.Flags().Lookup("record-input")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag record-input")
+	}
+	if err := viper.BindPFlag("capture-keyboard", clientCmd.Flags().Lookup("capture-keyboard")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag capture-keyboard")
+	}
+	if err := viper.BindPFlag("capture-keyboard-release", clientCmd.Flags().Lookup("capture-keyboard-release")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag capture-keyboard-release")
+	}
+	if err := viper.BindPFlag("record", clientCmd.Flags().Lookup("record")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag record")
+	}
+	if err := viper.BindPFlag("record-max-size", clientCmd.Flags().Lookup("record-max-size")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag record-max-size")
+	}
+	if err := viper.BindPFlag("record-max-duration", clientCmd.Flags().Lookup("record-max-duration")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag record-max-duration")
+	}
+	if err := viper.BindPFlag("relative-mouse-toggle", clientCmd.Flags().Lookup("relative-mouse-toggle")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag relative-mouse-toggle")
+	}
+	if err := viper.BindPFlag("fps", clientCmd.Flags().Lookup("fps")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag fps")
+	}
+	if err := viper.BindPFlag("device-type", clientCmd.Flags().Lookup("device-type")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag device-type")
+	}
+	if err := viper.BindPFlag("forward-gamepads", clientCmd.Flags().Lookup("forward-gamepads")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag forward-gamepads")
+	}
+	if err := viper.BindPFlag("audio", clientCmd.Flags().Lookup("audio")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag audio")
+	}
+	if err := viper.BindPFlag("audio-latency", clientCmd.Flags().Lookup("audio-latency")); err != nil {
+		log.Fatal().Err(err).Msg("failed binding flag audio-latency")
+	}
 	return clientCmd
 }
 
@@ -115,6 +199,21 @@ func activate(app *gtk.Application) {
 
 	window := gtk.NewApplicationWindow(app)
 	window.SetTitle("weylus-client")
+	menu := gio.NewMenu()
+	menu.Append("Screenshot", "app.screenshot")
+	menu.Append("Record", "win.record")
+	menu.Append("Record input", "win.record-input")
+	menu.Append("Capture keyboard", "win.capture-keyboard")
+	menu.Append("Relative mouse", "win.relative-mouse")
+	menuButton := gtk.NewMenuButton()
+	menuButton.SetIconName("open-menu-symbolic")
+	menuButton.SetMenuModel(menu)
+	header := gtk.NewHeaderBar()
+	header.PackEnd(menuButton)
+	captureIndicator := gtk.NewImageFromIconName("input-keyboard-symbolic")
+	captureIndicator.SetVisible(false)
+	header.PackStart(captureIndicator)
+	window.SetTitlebar(header)
 	drawArea := gtk.NewDrawingArea()
 	drawArea.SetVExpand(true)
 	drawArea.SetDrawFunc(func(draw *gtk.DrawingArea, cr *cairo.Context, w, h int) {
@@ -127,26 +226,42 @@ func activate(app *gtk.Application) {
 	stylusLabel.SetHAlign(gtk.AlignStart)
 	clickLabel := gtk.NewLabel("")
 	clickLabel.SetHAlign(gtk.AlignStart)
-	touchLabel := gtk.NewLabel("")
-	touchLabel.SetHAlign(gtk.AlignStart)
-	keyLabel := gtk.NewLabel("")
-	keyLabel.SetHAlign(gtk.AlignStart)
 	scrollLabel := gtk.NewLabel("")
 	scrollLabel.SetHAlign(gtk.AlignStart)
 
 	layout := gtk.NewGrid()
 	// layout.Attach(stylusLabel, 0, 0, 1, 1)
 	// layout.Attach(clickLabel, 0, 1, 1, 1)
-	// layout.Attach(touchLabel, 0, 2, 1, 1)
-	// layout.Attach(keyLabel, 0, 3, 1, 1)
 	// layout.Attach(scrollLabel, 0, 4, 1, 1)
 
 	manager := event.NewControllerManager()
+	overrides, err := event.ParseDeviceOverrides(viper.GetStringMapString("device-type"))
+	if err != nil {
+		log.Warn().Err(err).Msg("ignoring device type overrides")
+	}
+	manager.Classifier.Overrides = overrides
+	var profiles event.InputProfiles
+	if err := viper.UnmarshalKey("input-profiles", &profiles); err != nil {
+		log.Warn().Err(err).Msg("ignoring input profiles")
+	} else if err := profiles.Validate(); err != nil {
+		log.Warn().Err(err).Msg("ignoring input profiles")
+	} else {
+		manager.Profiles = profiles
+	}
+	bindings, err := event.ParseGestureBindings(viper.GetStringMapString("gestures"), event.DisplayKeycode(gdk.DisplayGetDefault()))
+	if err != nil {
+		log.Warn().Err(err).Msg("ignoring gesture bindings")
+	}
+	manager.Gestures.Bindings = bindings
+	if err := viper.UnmarshalKey("active-area", &manager.Mapper.ActiveArea); err != nil {
+		log.Warn().Err(err).Msg("ignoring active area")
+	} else if err := manager.Mapper.ActiveArea.Validate(); err != nil {
+		log.Warn().Err(err).Msg("ignoring active area")
+		manager.Mapper.ActiveArea = event.ActiveArea{}
+	}
 	manager.AddCallback(func(m *event.ControllerManager) {
 		stylusLabel.SetMarkup(fmt.Sprintf("<span font_desc=\"mono\">%v</span>", m.StylusState))
 		clickLabel.SetMarkup(fmt.Sprintf("<span font_desc=\"mono\">%v</span>", m.MouseState))
-		touchLabel.SetMarkup(fmt.Sprintf("<span font_desc=\"mono\">%v</span>", m.TouchState))
-		keyLabel.SetMarkup(fmt.Sprintf("<span font_desc=\"mono\">%v</span>", m.KeyState))
 		scrollLabel.SetMarkup(fmt.Sprintf("<span font_desc=\"mono\">%v</span>", m.ScrollState))
 	})
 
@@ -156,7 +271,22 @@ func activate(app *gtk.Application) {
 
 	manager.ConnectControllers(overlay)
 	window.AddController(manager.Key)
+	window.NotifyProperty("is-active", func() {
+		if !window.IsActive() {
+			manager.FocusOutHandler()
+		}
+	})
+	captureAction, captureEscape := newCaptureKeyboardAction(window, captureIndicator)
+	window.AddAction(captureAction)
+	window.AddController(captureEscape)
 	window.AddController(manager.Scroll)
+	window.AddAction(newRelativeMouseAction(manager, overlay))
+	toggle := viper.GetString("relative-mouse-toggle")
+	if _, err := event.ParseChord(toggle); err != nil {
+		log.Warn().Err(err).Msg("invalid relative-mouse-toggle, using <Control><Alt>m")
+		toggle = "<Control><Alt>m"
+	}
+	app.SetAccelsForAction("win.relative-mouse", []string{toggle})
 	overlay.SetChild(layout)
 	overlay.AddOverlay(drawArea)
 	window.SetChild(overlay)
@@ -183,10 +313,22 @@ func activate(app *gtk.Application) {
 
 	errCh := make(chan error, 1)
 
-	weylusClient := client.NewWeylusClient(ctx, 30)
+	weylusClient := client.NewWeylusClient(ctx, viper.GetUint("fps"))
 
 	weylusClient.BufPipe = utils.NewBufPipe()
 
+	recordAction := newRecordAction(weylusClient)
+	window.AddAction(recordAction)
+	app.SetAccelsForAction("win.record", []string{"<Control><Shift>r"})
+	if viper.GetString("record") != "" {
+		recordAction.ChangeState(glib.NewVariantBoolean(true))
+	}
+	recordInputAction := newRecordInputAction(weylusClient)
+	window.AddAction(recordInputAction)
+	if viper.GetString("record-input") != "" {
+		recordInputAction.ChangeState(glib.NewVariantBoolean(true))
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -247,35 +389,64 @@ func activate(app *gtk.Application) {
 		}
 	}()
 
+	video := frame.NewPaintable()
 	screen := gtk.NewPicture()
 	screen.SetKeepAspectRatio(true)
 	screen.SetHExpand(true)
+	screen.SetPaintable(video.Paintable())
 	screen.AddTickCallback(func(_ gtk.Widgetter, clock gdk.FrameClocker) bool {
-		bmpr.acquire(func(txt *gdk.MemoryTexture) {
-			screen.SetSizeRequest(txt.Width(), txt.Height())
-			screen.SetPaintable(txt)
-		})
+		scale := screen.ScaleFactor()
+		if f := bmpr.pool.Take(); f != nil {
+			width, height := f.Image.Rect.Dx(), f.Image.Rect.Dy()
+			// request the frame size in device pixels, so the video is not upscaled on HiDPI screens
+			screen.SetSizeRequest(width/scale, height/scale)
+			manager.Mapper.SetVideoSize(width, height)
+			video.SetFrame(f)
+		}
+		if bounds, ok := screen.ComputeBounds(overlay); ok {
+			manager.Mapper.SetView(event.Area{
+				X:      float64(bounds.X()),
+				Y:      float64(bounds.Y()),
+				Width:  float64(bounds.Width()),
+				Height: float64(bounds.Height()),
+			}, scale)
+		}
 		return true
 	})
 
-	glib.TimeoutAdd(1000/weylusClient.Framerate, func() bool {
-		screen.QueueDraw()
-		return ctx.Err() == nil
-	})
-
 	layout.Attach(screen, 0, 0, 1, 1)
 
-	address := url.URL{
-		Scheme: "ws",
-		Host:   net.JoinHostPort(viper.GetString("hostname"), strconv.FormatUint(uint64(viper.GetUint16("websocket-port")), 10)),
-	}
-
+	address := websocketAddress()
 	if err := weylusClient.Dial(address.String()); err != nil {
 		log.Err(err).Msg("dial weylusClient")
 	}
 	go weylusClient.Listen()
 	go weylusClient.Run()
 	go weylusClient.RunVideo()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sendEvents(ctx, manager.KeyEvents, weylusClient.SendKeyboardEvent)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sendEvents(ctx, manager.PointerEvents, weylusClient.SendPointerEvent)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sendEvents(ctx, manager.Actions, func(a event.Action) error {
+			return a.Send(weylusClient)
+		})
+	}()
+	if viper.GetBool("forward-gamepads") {
+		forwardGamepads(ctx, &wg, weylusClient.SendGamepadEvent)
+	}
+	startClipboard(ctx, &wg, weylusClient, viper.GetString("hostname"))
+	if viper.GetBool("audio") {
+		startAudio(ctx, &wg, weylusClient)
+	}
 
 	capturables, err := weylusClient.GetCapturableList()
 	if err != nil {
@@ -284,6 +455,17 @@ func activate(app *gtk.Application) {
 		log.Debug().Strs("capturables", capturables.CapturableList).Msg("get capturables")
 	}
 
+	capturable := ""
+	if len(capturables.CapturableList) > 0 {
+		capturable = capturables.CapturableList[0]
+	}
+	screenshotAction := gio.NewSimpleAction("screenshot", nil)
+	screenshotAction.ConnectActivate(func(_ *glib.Variant) {
+		takeScreenshot(&window.Widget, video, viper.GetString("hostname"), capturable)
+	})
+	app.AddAction(screenshotAction)
+	app.SetAccelsForAction("app.screenshot", []string{"Print", "<Control><Shift>s"})
+
 	if _, err := weylusClient.Config(protocol.Config{
 		UInputSupport: true,
 		CapturableID:  0,
@@ -291,19 +473,334 @@ func activate(app *gtk.Application) {
 		MaxWidth:      5120,
 		MaxHeight:     1440,
 		ClientName:    "weylus-desktop",
+		Audio:         viper.GetBool("audio"),
 	}); err != nil {
 		log.Err(err).Msg("send Config")
 	}
 	window.Show()
 }
 
+// sendEvents forwards queued input events to the server in order until ctx is done.
+func sendEvents[T any](ctx context.Context, queue *event.Queue[T], send func(T) error) {
+	for {
+		e, err := queue.Pop(ctx)
+		if err != nil {
+			return
+		}
+		if err := send(e); err != nil {
+			log.Err(err).Interface("event", e).Msg("send input event")
+		}
+	}
+}
+
+// startAudio plays the audio track of the server until ctx is done.
+func startAudio(ctx context.Context, wg *sync.WaitGroup, weylusClient *client.WeylusClient) {
+	player, err := gstreamer.NewAudioPlayer()
+	if err != nil {
+		log.Err(err).Msg("create audio player")
+		return
+	}
+	weylusClient.AVSync.Latency = viper.GetDuration("audio-latency")
+	go weylusClient.RunAudio(player)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		if err := player.Close(); err != nil {
+			log.Err(err).Msg("close audio player")
+		}
+	}()
+}
+
+// startClipboard synchronizes the clipboard with the server if the clipboard profile of hostname enables it.
+func startClipboard(ctx context.Context, wg *sync.WaitGroup, weylusClient *client.WeylusClient, hostname string) {
+	var profiles clipboard.Profiles
+	if err := viper.UnmarshalKey("clipboard-profiles", &profiles); err != nil {
+		log.Warn().Err(err).Msg("ignoring clipboard profiles")
+		return
+	}
+	if err := profiles.Validate(); err != nil {
+		log.Warn().Err(err).Msg("ignoring clipboard profiles")
+		return
+	}
+	profile := profiles.For(hostname)
+	if !profile.Enabled {
+		return
+	}
+	clipboardSync := clipboard.NewSync(clipboard.NewGDK(gdk.DisplayGetDefault().Clipboard()), weylusClient, profile)
+	weylusClient.HandleClipboard(clipboardSync)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		clipboardSync.Run(ctx, time.Second)
+	}()
+}
+
+// forwardGamepads sends the state of the evdev gamepads to the server until ctx is done or the gamepad is unplugged.
+func forwardGamepads(ctx context.Context, wg *sync.WaitGroup, send func(protocol.GamepadEvent) error) {
+	devices, err := input.FindGamepads()
+	if err != nil {
+		log.Err(err).Msg("find gamepads")
+		return
+	}
+	if len(devices) == 0 {
+		log.Warn().Msg("no gamepads found, is the user allowed to read /dev/input?")
+	}
+	for i, dev := range devices {
+		name, _ := dev.Name()
+		abs, err := dev.AbsInfos()
+		if err != nil {
+			log.Warn().Err(err).Str("gamepad", name).Msg("read gamepad axis ranges, using the ranges of an Xbox 360 controller")
+		}
+		reader := input.NewGamepadReader(i, dev, abs)
+		log.Info().Str("gamepad", name).Int("index", i).Msg("forwarding gamepad")
+
+		wg.Add(1)
+		go func(dev *evdev.InputDevice) {
+			defer wg.Done()
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				// closing the device unblocks the reader
+				select {
+				case <-ctx.Done():
+					_ = dev.Close()
+				case <-done:
+				}
+			}()
+			for {
+				e, err := reader.Next()
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Err(err).Str("gamepad", name).Msg("gamepad disconnected")
+						_ = dev.Close()
+					}
+					if err := send(reader.Disconnected()); err != nil {
+						log.Err(err).Str("gamepad", name).Msg("send gamepad event")
+					}
+					return
+				}
+				if err := send(e); err != nil {
+					log.Err(err).Str("gamepad", name).Msg("send gamepad event")
+				}
+			}
+		}(dev)
+	}
+}
+
+// newCaptureKeyboardAction creates the stateful win.capture-keyboard action inhibiting the system shortcuts of
+// window, and the controller releasing the keyboard again on the capture-keyboard-release chord.
+// indicator is shown while the compositor actually inhibits the shortcuts.
+func newCaptureKeyboardAction(window *gtk.ApplicationWindow, indicator *gtk.Image) (*gio.SimpleAction, *gtk.EventControllerKey) {
+	chord, err := event.ParseChord(viper.GetString("capture-keyboard-release"))
+	if err != nil {
+		log.Warn().Err(err).Msg("invalid capture-keyboard-release, using <Control><Alt>Escape")
+		chord = event.Chord{KeyVal: gdk.KEY_Escape, Mods: gdk.ControlMask | gdk.AltMask}
+	}
+	indicator.SetTooltipText(fmt.Sprintf("Keyboard captured, press %s to release", chord.Label()))
+
+	toplevel := func() gdk.Topleveller {
+		native := window.Widget.Native()
+		if native == nil {
+			return nil
+		}
+		surface := native.Surface()
+		if surface == nil {
+			return nil
+		}
+		t, _ := coreglib.InternObject(surface).CastType(gdk.GTypeToplevel).(gdk.Topleveller)
+		return t
+	}
+
+	action := gio.NewSimpleActionStateful("capture-keyboard", nil, glib.NewVariantBoolean(false))
+	action.ConnectActivate(func(_ *glib.Variant) {
+		action.ChangeState(glib.NewVariantBoolean(!action.State().Boolean()))
+	})
+	action.ConnectChangeState(func(value *glib.Variant) {
+		t := toplevel()
+		if t == nil {
+			log.Warn().Msg("window has no toplevel surface, can't capture keyboard")
+			return
+		}
+		if value.Boolean() {
+			t.InhibitSystemShortcuts(nil)
+			log.Info().Str("release", chord.Label()).Msg("capturing keyboard")
+		} else {
+			t.RestoreSystemShortcuts()
+			log.Info().Msg("released keyboard")
+		}
+		action.SetState(value)
+	})
+
+	window.ConnectRealize(func() {
+		t := toplevel()
+		if t == nil {
+			return
+		}
+		// the compositor decides whether shortcuts are inhibited, e.g. after asking the user
+		coreglib.BaseObject(t).NotifyProperty("shortcuts-inhibited", func() {
+			inhibited, _ := coreglib.BaseObject(t).ObjectProperty("shortcuts-inhibited").(bool)
+			indicator.SetVisible(inhibited)
+			if inhibited != action.State().Boolean() {
+				action.SetState(glib.NewVariantBoolean(inhibited))
+			}
+		})
+		if viper.GetBool("capture-keyboard") {
+			action.ChangeState(glib.NewVariantBoolean(true))
+		}
+	})
+
+	escape := gtk.NewEventControllerKey()
+	escape.SetPropagationPhase(gtk.PhaseCapture)
+	escape.ConnectKeyPressed(func(keyVal, _ uint, state gdk.ModifierType) bool {
+		if !action.State().Boolean() || !chord.Matches(keyVal, state) {
+			return false
+		}
+		action.ChangeState(glib.NewVariantBoolean(false))
+		return true
+	})
+	return action, escape
+}
+
+// newRelativeMouseAction creates the action switching to relative mouse input. While on the cursor is hidden over the
+// video and mouse motion is sent as relative movement.
+// This is no pointer lock: GTK 4 has no API to confine or warp the pointer, and locking it with the pointer
+// constraints protocols of the compositor would need Wayland client code GDK doesn't expose. The motion stops at the
+// window border, keeping the window fullscreen makes that the screen border.
+func newRelativeMouseAction(manager *event.ControllerManager, target gtk.Widgetter) *gio.SimpleAction {
+	widget := gtk.BaseWidget(target)
+	action := gio.NewSimpleActionStateful("relative-mouse", nil, glib.NewVariantBoolean(false))
+	action.ConnectActivate(func(_ *glib.Variant) {
+		action.ChangeState(glib.NewVariantBoolean(!action.State().Boolean()))
+	})
+	action.ConnectChangeState(func(value *glib.Variant) {
+		relative := value.Boolean()
+		manager.SetRelativeMouse(relative)
+		if relative {
+			widget.SetCursorFromName("none")
+			log.Info().Msg("relative mouse on, the hidden cursor still stops at the window border")
+		} else {
+			widget.SetCursor(nil)
+			log.Info().Msg("relative mouse off")
+		}
+		action.SetState(value)
+	})
+	return action
+}
+
+// newRecordAction creates the stateful win.record action toggling the recording of the video stream.
+func newRecordAction(weylusClient *client.WeylusClient) *gio.SimpleAction {
+	action := gio.NewSimpleActionStateful("record", nil, glib.NewVariantBoolean(false))
+	action.ConnectActivate(func(_ *glib.Variant) {
+		action.ChangeState(glib.NewVariantBoolean(!action.State().Boolean()))
+	})
+	action.ConnectChangeState(func(value *glib.Variant) {
+		if !value.Boolean() {
+			if err := weylusClient.Recorder.Stop(); err != nil {
+				log.Err(err).Msg("stop recording")
+			}
+			action.SetState(value)
+			return
+		}
+		path := viper.GetString("record")
+		if path == "" {
+			path = filepath.Join(
+				glib.GetUserSpecialDir(glib.UserDirectoryVideos),
+				fmt.Sprintf("weylus-desktop-%s.mp4", time.Now().Format("20060102-150405")),
+			)
+		}
+		if err := weylusClient.Recorder.Start(recorder.Options{
+			Path:        path,
+			MaxSize:     int64(viper.GetUint64("record-max-size")) << 20,
+			MaxDuration: viper.GetDuration("record-max-duration"),
+		}); err != nil {
+			log.Err(err).Str("path", path).Msg("start recording")
+			return
+		}
+		log.Info().Str("path", path).Msg("started recording")
+		action.SetState(value)
+	})
+	return action
+}
+
+// newRecordInputAction creates the stateful win.record-input action toggling the recording of input events.
+func newRecordInputAction(weylusClient *client.WeylusClient) *gio.SimpleAction {
+	var file *os.File
+	action := gio.NewSimpleActionStateful("record-input", nil, glib.NewVariantBoolean(false))
+	action.ConnectActivate(func(_ *glib.Variant) {
+		action.ChangeState(glib.NewVariantBoolean(!action.State().Boolean()))
+	})
+	action.ConnectChangeState(func(value *glib.Variant) {
+		if !value.Boolean() {
+			weylusClient.RecordInput(nil)
+			if file != nil {
+				if err := file.Close(); err != nil {
+					log.Err(err).Msg("failed closing file")
+				}
+				file = nil
+			}
+			action.SetState(value)
+			return
+		}
+		path := viper.GetString("record-input")
+		if path == "" {
+			path = filepath.Join(
+				glib.GetUserDataDir(),
+				"weylus-desktop",
+				fmt.Sprintf("input-%s.jsonl", time.Now().Format("20060102-150405")),
+			)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			log.Err(err).Str("path", path).Msg("create input recording directory")
+			return
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			log.Err(err).Str("path", path).Msg("start recording input")
+			return
+		}
+		file = f
+		weylusClient.RecordInput(file)
+		log.Info().Str("path", path).Msg("started recording input")
+		action.SetState(value)
+	})
+	return action
+}
+
+// takeScreenshot saves the most recent frame as PNG and optionally copies it to the clipboard of widget.
+func takeScreenshot(widget *gtk.Widget, video *frame.Paintable, server, capturable string) {
+	img := video.Image()
+	if img == nil {
+		log.Warn().Msg("no frame received yet, can't take screenshot")
+		return
+	}
+	dir := viper.GetString("screenshot-dir")
+	if dir == "" {
+		dir = glib.GetUserSpecialDir(glib.UserDirectoryPictures)
+	}
+	path, err := screenshot.Save(dir, img, server, capturable, time.Now())
+	if err != nil {
+		log.Err(err).Msg("save screenshot")
+		return
+	}
+	log.Info().Str("path", path).Msg("saved screenshot")
+
+	if viper.GetBool("screenshot-clipboard") {
+		widget.Clipboard().SetTexture(gdk.NewMemoryTexture(
+			img.Rect.Dx(),
+			img.Rect.Dy(),
+			gdk.MemoryR8G8B8A8Premultiplied,
+			glib.NewBytesWithGo(img.Pix),
+			uint(img.Stride),
+		))
+	}
+}
+
 type bmpReader struct {
 	path string
 	freq time.Duration
 	dec  *bmp.BGRADecoder
-
-	bmp  *bmp.NBGRA
-	txtv atomic.Value // *gdk.MemoryTexture
+	pool *frame.Pool
 }
 
 func newBMPReader(path string, fps int) *bmpReader {
@@ -311,6 +808,7 @@ func newBMPReader(path string, fps int) *bmpReader {
 		path: path,
 		freq: time.Second / time.Duration(fps),
 		dec:  bmp.NewBGRADecoder(),
+		pool: frame.NewPool(frame.DefaultBuffers, frame.CAllocator),
 	}
 }
 
@@ -354,36 +852,15 @@ func (r *bmpReader) update(ctx context.Context) error {
 		}
 	}(&buf)
 
-	// TODO: figure out double buffering to avoid locking for too long.
-	r.bmp, err = r.dec.Decode(buf, r.bmp)
-	if err != nil {
+	fr := r.pool.Get()
+	if err := fr.Decode(r.dec, buf); err != nil {
+		fr.Release()
 		return errors.Wrap(err, "failed to decode bmp snapshot")
 	}
-
-	// This unfortunately makes a newly-allocated copy of the image every call.
-	// It's probably the slowest part of this code and why you should write your
-	// own Paintable.
-	newTexture := gdk.NewMemoryTexture(
-		r.bmp.Rect.Dx(),
-		r.bmp.Rect.Dy(),
-		// I'm not sure if this is the format that GTK uses. They might be
-		// swizzling this on their own in the code which adds cost.
-		gdk.MemoryB8G8R8A8Premultiplied,
-		glib.NewBytesWithGo(r.bmp.Pix),
-		uint(r.bmp.Stride),
-	)
-
-	r.txtv.Store(newTexture)
+	r.pool.Publish(fr)
 	return nil
 }
 
-func (r *bmpReader) acquire(f func(*gdk.MemoryTexture)) {
-	txt, _ := r.txtv.Swap((*gdk.MemoryTexture)(nil)).(*gdk.MemoryTexture)
-	if txt != nil {
-		f(txt)
-	}
-}
-
 func sh(ctx context.Context, shcmd string, reader io.Reader) error {
 	cmd := exec.CommandContext(ctx, "sh", "-c", shcmd)
 	cmd.Stdout = nil
@@ -413,3 +890,15 @@ func sh(ctx context.Context, shcmd string, reader io.Reader) error {
 
 	return nil
 }
+
+// websocketAddress returns the address of the websocket of the server, with the access code if one is set.
+func websocketAddress() url.URL {
+	address := url.URL{
+		Scheme: "ws",
+		Host:   net.JoinHostPort(viper.GetString("hostname"), strconv.FormatUint(uint64(viper.GetUint16("websocket-port")), 10)),
+	}
+	if code := viper.GetString("access-code"); code != "" {
+		address.RawQuery = url.Values{"access_code": {code}}.Encode()
+	}
+	return address
+}
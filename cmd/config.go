@@ -0,0 +1,262 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/OmegaRogue/weylus-desktop/audio"
+	"github.com/OmegaRogue/weylus-desktop/clipboard"
+	"github.com/OmegaRogue/weylus-desktop/input"
+	"github.com/OmegaRogue/weylus-desktop/internal/config"
+	"github.com/OmegaRogue/weylus-desktop/internal/event"
+	"github.com/OmegaRogue/weylus-desktop/internal/logger"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// configCmd represents the config command
+var configCmd = NewConfigCmd()
+
+// secretSettings are replaced when the config is shown.
+var secretSettings = []string{"access-code"}
+
+// extraSettings are settings of the config file without a flag.
+var extraSettings = []config.Setting{
+	{
+		Key:      "clipboard-profiles",
+		Type:     "map",
+		Usage:    "Clipboard synchronization profiles by client or host name, each with enabled, max-size and images; the default profile applies to everyone else",
+		Commands: []string{"client", "server"},
+	},
+}
+
+// settingRules validate the settings of the config file, flags and environment.
+var settingRules = map[string]config.Rule{
+	"web-port":             config.Port(),
+	"websocket-port":       config.Port(),
+	"custom-access-html":   config.File(".html"),
+	"custom-index-html":    config.File(".html", ".gohtml"),
+	"custom-lib-js":        config.File(".js"),
+	"custom-style-css":     config.File(".css"),
+	"fps":                  config.IntRange(1, 240),
+	"record-max-size":      config.IntRange(0, math.MaxInt32),
+	"audio-bitrate":        config.IntRange(6000, 510000),
+	"audio-latency":        config.MinDuration(0),
+	"audio-source":         config.Parse(audio.ParseSource),
+	"keyboard-injection":   config.Parse(input.ParseKeyboardInjection),
+	"log-level":            config.Parse(logger.ParseLevel),
+	"log-format":           config.Parse(logger.ParseFormat),
+	"log-file-max-size":    config.IntRange(0, math.MaxInt32),
+	"log-file-max-backups": config.IntRange(0, 1000),
+	"log-component": func(v *viper.Viper, key string) error {
+		_, err := logger.ParseComponentLevels(v.GetStringSlice(key))
+		return err
+	},
+	"device-type": func(v *viper.Viper, key string) error {
+		_, err := event.ParseDeviceOverrides(v.GetStringMapString(key))
+		return err
+	},
+	"clipboard-profiles": func(v *viper.Viper, key string) error {
+		var profiles clipboard.Profiles
+		if err := v.UnmarshalKey(key, &profiles); err != nil {
+			return errors.Wrap(err, "unmarshal clipboard profiles")
+		}
+		return profiles.Validate()
+	},
+}
+
+// NewConfigCmd creates a new config command
+func NewConfigCmd() *cobra.Command {
+	var configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Show, validate and create the config file",
+		Long: `Show, validate and create the config file.
+The config file is read from $XDG_CONFIG_HOME/weylus-desktop/config.yaml or ~/.weylus-desktop.yaml, unless --config is
+given. Every flag can also be set in the config file, by its name.`,
+		// the config may be invalid, that's what validate is for
+		PersistentPreRun: func(*cobra.Command, []string) {},
+	}
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Print the effective settings",
+		Long:  `Print the effective settings, combined from the defaults, the config file, the environment and the flags.`,
+		Args:  cobra.NoArgs,
+		RunE:  runConfigShowCommand,
+	})
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "validate [FILE]",
+		Short: "Check the config file for invalid and unknown settings",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runConfigValidateCommand,
+	})
+	initCmd := &cobra.Command{
+		Use:   "init [FILE]",
+		Short: "Create a config file documenting every setting",
+		Long: `Create a config file documenting every setting, by default in $XDG_CONFIG_HOME/weylus-desktop/config.yaml.
+All settings are commented out, so the file doesn't change anything until edited.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runConfigInitCommand,
+	}
+	initCmd.Flags().BoolP("force", "f", false, "Overwrite an existing file")
+	if err := config.MarkNoSetting(initCmd.Flags(), "force"); err != nil {
+		log.Fatal().Err(err).Msg("failed marking flag force")
+	}
+	configCmd.AddCommand(initCmd)
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "path",
+		Short: "Print the path of the config file in use",
+		Args:  cobra.NoArgs,
+		RunE:  runConfigPathCommand,
+	})
+	return configCmd
+}
+
+// ConfigSchema returns the settings of the config file.
+func ConfigSchema() []config.Setting {
+	return config.Schema(rootCmd, extraSettings...)
+}
+
+// validateConfig checks the settings used by cmd.
+func validateConfig(cmd *cobra.Command, _ []string) error {
+	rules := make(map[string]config.Rule)
+	for _, s := range config.Schema(cmd.Root(), extraSettings...) {
+		rule, ok := settingRules[s.Key]
+		if ok && (len(s.Commands) == 0 || lo.Contains(s.Commands, cmd.Name())) {
+			rules[s.Key] = rule
+		}
+	}
+	return errors.Wrap(config.Validate(viper.GetViper(), rules), "invalid config")
+}
+
+func runConfigShowCommand(cmd *cobra.Command, _ []string) error {
+	settings := make(map[string]any)
+	for _, s := range config.Schema(cmd.Root(), extraSettings...) {
+		v := viper.Get(s.Key)
+		if lo.Contains(secretSettings, s.Key) && v != "" {
+			v = "REDACTED"
+		}
+		settings[s.Key] = v
+	}
+	if path := viper.ConfigFileUsed(); path != "" {
+		if _, err := fmt.Fprintf(cmd.OutOrStdout(), "# config file: %s\n", path); err != nil {
+			return errors.Wrap(err, "print config")
+		}
+	}
+	enc := yaml.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent(2)
+	if err := enc.Encode(settings); err != nil {
+		return errors.Wrap(err, "print config")
+	}
+	return errors.Wrap(enc.Close(), "print config")
+}
+
+func runConfigValidateCommand(cmd *cobra.Command, args []string) error {
+	path := viper.ConfigFileUsed()
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path == "" {
+		return errors.New("no config file found, see weylus-desktop config path")
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return errors.Wrap(err, "read config")
+	}
+	var errs config.Errors
+	for _, key := range config.Unknown(v, config.Schema(cmd.Root(), extraSettings...)) {
+		errs = append(errs, config.Error{Key: key, Err: errors.New("unknown setting")})
+	}
+	if err := config.Validate(v, settingRules); err != nil {
+		errs = append(errs, err.(config.Errors)...)
+	}
+	if len(errs) > 0 {
+		return errors.Wrapf(errs, "invalid config %s", path)
+	}
+	_, err := fmt.Fprintf(cmd.OutOrStdout(), "%s is valid\n", path)
+	return errors.Wrap(err, "print result")
+}
+
+func runConfigInitCommand(cmd *cobra.Command, args []string) error {
+	path, err := config.DefaultPath()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		path = args[0]
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return errors.Wrap(err, "get flag force")
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrap(err, "create config directory")
+	}
+	// the config may hold the access code
+	f, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "create config file")
+	}
+	defer func(f *os.File) {
+		if err := f.Close(); err != nil {
+			log.Err(err).Msg("failed closing file")
+		}
+	}(f)
+	if err := config.WriteDefault(f, config.Schema(cmd.Root(), extraSettings...)); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "created %s\n", path)
+	return errors.Wrap(err, "print result")
+}
+
+func runConfigPathCommand(cmd *cobra.Command, _ []string) error {
+	if path := viper.ConfigFileUsed(); path != "" {
+		_, err := fmt.Fprintln(cmd.OutOrStdout(), path)
+		return errors.Wrap(err, "print path")
+	}
+	paths, err := config.Paths()
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(cmd.ErrOrStderr(), "no config file found, searched:"); err != nil {
+		return errors.Wrap(err, "print paths")
+	}
+	for _, path := range paths {
+		if _, err := fmt.Fprintf(cmd.ErrOrStderr(), "  %s\n", path); err != nil {
+			return errors.Wrap(err, "print paths")
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
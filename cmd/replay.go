@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/OmegaRogue/weylus-desktop/client"
+	"github.com/OmegaRogue/weylus-desktop/macro"
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// replayCmd represents the replay command
+var replayCmd = NewReplayCmd()
+
+// NewReplayCmd creates a new replay command
+func NewReplayCmd() *cobra.Command {
+	var replayCmd = &cobra.Command{
+		Use:   "replay FILE",
+		Short: "Replay recorded input events on a weylus server",
+		Long: `Replay input events recorded by the weylus-desktop client with --record-input on a weylus server.
+The relative timing of the events is kept, scaled by --speed.`,
+		Args: cobra.ExactArgs(1),
+		// the flags are shared with the client command, so only bind them if this command is run
+		PreRun: func(cmd *cobra.Command, _ []string) {
+			cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+				if err := viper.BindPFlag(flag.Name, flag); err != nil {
+					log.Fatal().Err(err).Msgf("failed binding flag %s", flag.Name)
+				}
+			})
+		},
+		RunE: runReplayCommand,
+	}
+	replayCmd.Flags().StringP("hostname", "", "localhost", "Hostname to connect to")
+	replayCmd.Flags().Uint16P("websocket-port", "", 9001, "Websocket port")
+	replayCmd.Flags().StringP("access-code", "", "", "Access code")
+	replayCmd.Flags().UintP("capturable-id", "", 0, "Capturable the events are sent to")
+	replayCmd.Flags().Float64P("speed", "", 1, "Replay speed, 2 replays twice as fast")
+
+	return replayCmd
+}
+
+func runReplayCommand(_ *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrap(err, "open macro")
+	}
+	defer func(f *os.File) {
+		if err := f.Close(); err != nil {
+			log.Err(err).Msg("failed closing file")
+		}
+	}(f)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	weylusClient := client.NewWeylusClient(ctx, 30)
+	address := websocketAddress()
+	if err := weylusClient.Dial(address.String()); err != nil {
+		return errors.Wrap(err, "dial weylusClient")
+	}
+	defer func(weylusClient *client.WeylusClient) {
+		if err := weylusClient.Close(); err != nil {
+			log.Err(err).Msg("close weylusClient")
+		}
+	}(weylusClient)
+	go weylusClient.Listen()
+	go weylusClient.Run()
+
+	if _, err := weylusClient.Config(protocol.Config{
+		UInputSupport: true,
+		CapturableID:  viper.GetUint("capturable-id"),
+		ClientName:    "weylus-desktop-replay",
+	}); err != nil {
+		return errors.Wrap(err, "send Config")
+	}
+	if err := macro.Replay(ctx, f, weylusClient, viper.GetFloat64("speed")); err != nil {
+		return errors.Wrap(err, "replay")
+	}
+	log.Info().Str("file", args[0]).Msg("replay finished")
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+}
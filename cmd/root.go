@@ -21,9 +21,15 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/OmegaRogue/weylus-desktop/internal/config"
+	"github.com/OmegaRogue/weylus-desktop/internal/logger"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -45,13 +51,43 @@ func NewRootCmd() *cobra.Command {
 		Short: "weylus-desktop is a remote-desktop program for stylus/touch input and gaming.",
 		Long:  `weylus-desktop is a remote-desktop program for stylus/touch input and gaming.`,
 		//Run: func(cmd *cobra.Command, args []string) { },
+		PersistentPreRunE: validateConfig,
 	}
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.weylus-desktop.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/weylus-desktop/config.yaml, then $HOME/.weylus-desktop.yaml)")
+	rootCmd.PersistentFlags().StringP("log-level", "", zerolog.InfoLevel.String(), "Minimum level of log messages, one of [trace, debug, info, warn, error, fatal, panic]")
+	rootCmd.PersistentFlags().StringSliceP("log-component", "", nil, "Override the log level of a component as COMPONENT:LEVEL, e.g. client:debug")
+	rootCmd.PersistentFlags().StringP("log-format", "", string(logger.FormatConsole), fmt.Sprintf("Format of log messages, one of [%s]", strings.Join(logger.FormatNames(), ", ")))
+	rootCmd.PersistentFlags().StringP("log-file", "", "", "Also write log messages to this file")
+	rootCmd.PersistentFlags().Int64P("log-file-max-size", "", 10, "Rotate the log file after this many MiB, 0 disables rotation")
+	rootCmd.PersistentFlags().IntP("log-file-max-backups", "", 3, "Number of rotated log files to keep")
+	rootCmd.PersistentFlags().BoolP("log-journald", "", true, "Also send log messages to the journal, if it is available")
+
+	if err := rootCmd.RegisterFlagCompletionFunc("log-level", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return []string{"trace", "debug", "info", "warn", "error", "fatal", "panic"}, cobra.ShellCompDirectiveNoFileComp
+	}); err != nil {
+		log.Fatal().Err(err).Msg("failed register completion for flag log-level")
+	}
+	if err := rootCmd.RegisterFlagCompletionFunc("log-format", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return logger.FormatNames(), cobra.ShellCompDirectiveNoFileComp
+	}); err != nil {
+		log.Fatal().Err(err).Msg("failed register completion for flag log-format")
+	}
+	if err := config.MarkNoSetting(rootCmd.PersistentFlags(), "config"); err != nil {
+		log.Fatal().Err(err).Msg("failed marking flag config")
+	}
+	rootCmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "config" {
+			return
+		}
+		if err := viper.BindPFlag(flag.Name, flag); err != nil {
+			log.Fatal().Err(err).Msgf("failed binding flag %s", flag.Name)
+		}
+	})
 
 	return rootCmd
 }
@@ -75,14 +111,13 @@ func initConfig() {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
 	} else {
-		// Find home directory.
-		home, err := os.UserHomeDir()
+		// Search config in the XDG config directory, then the home directory.
+		path, err := config.Find()
 		cobra.CheckErr(err)
-
-		// Search config in home directory with name ".weylus-desktop" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".weylus-desktop")
+		if path != "" {
+			viper.SetConfigFile(path)
+		}
+		viper.SetConfigType(config.Type)
 	}
 
 	viper.AutomaticEnv() // read in environment variables that match
@@ -94,4 +129,36 @@ func initConfig() {
 			log.Err(err).Msg("print config file location")
 		}
 	}
+
+	options, err := loggerOptions()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid log options")
+	}
+	if err := logger.Setup(options); err != nil {
+		log.Fatal().Err(err).Msg("setup logger")
+	}
+}
+
+// loggerOptions returns the logger options set by flags and the config file.
+func loggerOptions() (logger.Options, error) {
+	level, err := logger.ParseLevel(viper.GetString("log-level"))
+	if err != nil {
+		return logger.Options{}, errors.Wrap(err, "log-level")
+	}
+	components, err := logger.ParseComponentLevels(viper.GetStringSlice("log-component"))
+	if err != nil {
+		return logger.Options{}, errors.Wrap(err, "log-component")
+	}
+	format, err := logger.ParseFormat(viper.GetString("log-format"))
+	if err != nil {
+		return logger.Options{}, errors.Wrap(err, "log-format")
+	}
+	return logger.Options{
+		Levels:         logger.Levels{Default: level, Components: components},
+		Format:         format,
+		File:           viper.GetString("log-file"),
+		FileMaxSize:    viper.GetInt64("log-file-max-size") << 20,
+		FileMaxBackups: viper.GetInt("log-file-max-backups"),
+		Journald:       viper.GetBool("log-journald"),
+	}, nil
 }
@@ -19,11 +19,28 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"github.com/OmegaRogue/weylus-desktop/audio"
+	"github.com/OmegaRogue/weylus-desktop/clipboard"
+	"github.com/OmegaRogue/weylus-desktop/gstreamer"
+	"github.com/OmegaRogue/weylus-desktop/input"
+	"github.com/OmegaRogue/weylus-desktop/internal/config"
+	"github.com/OmegaRogue/weylus-desktop/internal/logger"
+	"github.com/OmegaRogue/weylus-desktop/internal/watch"
+	"github.com/OmegaRogue/weylus-desktop/server"
 	"github.com/OmegaRogue/weylus-desktop/web"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -55,7 +72,15 @@ func NewServerCmd() *cobra.Command {
 	serverCmd.Flags().StringP("custom-lib-js", "", "", "Use custom lib.js to be served by Weylus.")
 	serverCmd.Flags().StringP("custom-style-css", "", "", "Use custom style.css to be served by Weylus.")
 	serverCmd.Flags().Uint16P("web-port", "", 1701, "Web port")
+	serverCmd.Flags().StringP("keyboard-injection", "", string(input.KeyboardInjectionCode), fmt.Sprintf("How keyboard events are injected, one of [%s]. code presses the physical key, key types the character in the server's layout.", strings.Join(input.KeyboardInjectionNames(), ", ")))
+	serverCmd.Flags().StringP("audio-source", "", string(audio.SourcePulse), fmt.Sprintf("Where the audio sent to clients asking for it is captured, one of [%s]. test plays a tone.", strings.Join(audio.SourceNames(), ", ")))
+	serverCmd.Flags().StringP("audio-device", "", "", "Capture audio from this device instead of the monitor of the default output")
+	serverCmd.Flags().BoolP("log-unredacted", "", false, "Log keystrokes and clipboard contents received from clients, for debugging")
+	serverCmd.Flags().IntP("audio-bitrate", "", audio.DefaultBitrate, "Bitrate of the Opus audio track in bit/s")
 
+	if err := config.MarkNoSetting(serverCmd.Flags(), "print-access-html", "print-index-html", "print-lib-js", "print-style-css"); err != nil {
+		log.Fatal().Err(err).Msg("failed marking print flags")
+	}
 	if err := serverCmd.MarkFlagFilename("custom-access-html", "html"); err != nil {
 		log.Fatal().Err(err).Msg("failed mark flag custom-access-html as filename")
 	}
@@ -68,6 +93,16 @@ func NewServerCmd() *cobra.Command {
 	if err := serverCmd.MarkFlagFilename("custom-style-css", "css"); err != nil {
 		log.Fatal().Err(err).Msg("failed mark flag custom-style-css as filename")
 	}
+	if err := serverCmd.RegisterFlagCompletionFunc("keyboard-injection", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return input.KeyboardInjectionNames(), cobra.ShellCompDirectiveNoFileComp
+	}); err != nil {
+		log.Fatal().Err(err).Msg("failed register completion for flag keyboard-injection")
+	}
+	if err := serverCmd.RegisterFlagCompletionFunc("audio-source", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return audio.SourceNames(), cobra.ShellCompDirectiveNoFileComp
+	}); err != nil {
+		log.Fatal().Err(err).Msg("failed register completion for flag audio-source")
+	}
 	serverFlagsOSSpecific(serverCmd)
 	serverCmd.Flags().VisitAll(func(flag *pflag.Flag) {
 		if err := viper.BindPFlag(flag.Name, flag); err != nil {
@@ -78,52 +113,158 @@ func NewServerCmd() *cobra.Command {
 	return serverCmd
 }
 
-func runServerCommand(_ *cobra.Command, _ []string) {
-	if accessPath := viper.GetString("custom-access-html"); accessPath != "" {
-		data, err := os.ReadFile(accessPath)
-		if err != nil {
-			log.Fatal().Err(err).Str("path", accessPath).Msg("failed reading file")
-		}
-		web.AccessHTML = string(data)
+func runServerCommand(cmd *cobra.Command, _ []string) {
+	serverLogger := logger.Component("server")
+	l := &serverLogger
+	assets, err := web.NewAssets()
+	if err != nil {
+		l.Fatal().Err(err).Msg("failed loading embedded assets")
 	}
-	if indexPath := viper.GetString("custom-index-html"); indexPath != "" {
-		data, err := os.ReadFile(indexPath)
-		if err != nil {
-			log.Fatal().Err(err).Str("path", indexPath).Msg("failed reading file")
-		}
-		web.IndexHTML = string(data)
+	watcher, err := watch.New(watch.DefaultDelay)
+	if err != nil {
+		l.Fatal().Err(err).Msg("failed watching files")
 	}
-	if libPath := viper.GetString("custom-lib-js"); libPath != "" {
-		data, err := os.ReadFile(libPath)
-		if err != nil {
-			log.Fatal().Err(err).Str("path", libPath).Msg("failed reading file")
-		}
-		web.LibJS = string(data)
-	}
-	if stylePath := viper.GetString("custom-style-css"); stylePath != "" {
-		data, err := os.ReadFile(stylePath)
-		if err != nil {
-			log.Fatal().Err(err).Str("path", stylePath).Msg("failed reading file")
+	defer func(watcher *watch.Watcher) {
+		if err := watcher.Close(); err != nil {
+			l.Err(err).Msg("failed closing file watcher")
 		}
-		web.StyleCSS = string(data)
+	}(watcher)
+	reloader := newServerReloader(l, cmd, assets, watcher)
+	if err := reloader.loadAssets(); err != nil {
+		l.Fatal().Err(err).Msg("failed loading custom assets")
 	}
 	switch {
 	case viper.GetBool("print-access-html"):
-		fmt.Println(web.AccessHTML)
+		fmt.Println(assets.Get(web.AccessHTMLName))
 		return
 	case viper.GetBool("print-index-html"):
-		fmt.Println(web.IndexHTML)
+		fmt.Println(assets.Get(web.IndexHTMLName))
 		return
 	case viper.GetBool("print-lib-js"):
-		fmt.Println(web.LibJS)
+		fmt.Println(assets.Get(web.LibJSName))
 		return
 	case viper.GetBool("print-style-css"):
-		fmt.Println(web.StyleCSS)
+		fmt.Println(assets.Get(web.StyleCSSName))
+		return
+	}
+	keyboardInjection, err := input.ParseKeyboardInjection(viper.GetString("keyboard-injection"))
+	if err != nil {
+		l.Fatal().Err(err).Msg("invalid keyboard-injection")
+	}
+	l.Debug().Stringer("keyboard-injection", keyboardInjection).Msg("keyboard injection mode")
+	audioSource, err := audio.ParseSource(viper.GetString("audio-source"))
+	if err != nil {
+		l.Fatal().Err(err).Msg("invalid audio-source")
+	}
+	audioOptions := audio.Options{Source: audioSource, Device: viper.GetString("audio-device"), Bitrate: viper.GetInt("audio-bitrate")}
+	if _, err := audio.CapturePipeline(audioOptions); err != nil {
+		l.Fatal().Err(err).Msg("invalid audio options")
+	}
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go func() {
+		if err := watcher.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			l.Err(err).Msg("failed watching files")
+		}
+	}()
+
+	weylusServer := server.NewWeylusServer(ctx, assets, viper.GetString("bind-address"), viper.GetUint16("web-port"), viper.GetUint16("websocket-port"))
+	weylusServer.SetAccessCode(viper.GetString("access-code"))
+	if keyboardDevice, err := input.NewUInputKeyboard("weylus-desktop keyboard"); err != nil {
+		l.Err(err).Msg("failed creating virtual keyboard, keyboard events are dropped")
+	} else {
+		defer func(keyboardDevice *input.UInputKeyboard) {
+			if err := keyboardDevice.Close(); err != nil {
+				l.Err(err).Msg("failed removing virtual keyboard")
+			}
+		}(keyboardDevice)
+		weylusServer.SetKeyboard(keyboardDevice, keyboardInjection, serverKeyLookup(l, keyboardInjection))
+	}
+	if mouseDevice, err := input.NewUInputMouse("weylus-desktop mouse"); err != nil {
+		l.Err(err).Msg("failed creating virtual mouse, relative pointer events are dropped")
+	} else {
+		defer func(mouseDevice *input.UInputMouse) {
+			if err := mouseDevice.Close(); err != nil {
+				l.Err(err).Msg("failed removing virtual mouse")
+			}
+		}(mouseDevice)
+		weylusServer.SetRelativeMouse(mouseDevice)
+	}
+	if pointerDevice, err := input.NewUInputPointer("weylus-desktop pointer"); err != nil {
+		l.Err(err).Msg("failed creating virtual pointer, absolute pointer events are dropped")
+	} else {
+		defer func(pointerDevice *input.UInputPointer) {
+			if err := pointerDevice.Close(); err != nil {
+				l.Err(err).Msg("failed removing virtual pointer")
+			}
+		}(pointerDevice)
+		weylusServer.SetAbsolutePointer(pointerDevice)
+	}
+	weylusServer.SetGamepads(func(index int) (input.GamepadDevice, error) {
+		pad, err := input.NewUInputGamepad(fmt.Sprintf("weylus-desktop gamepad %d", index+1))
+		if err != nil {
+			return nil, err
+		}
+		return pad, nil
+	})
+	weylusServer.SetAudio(func() (server.AudioCapture, error) {
+		capture, err := gstreamer.NewAudioCapture(audioOptions)
+		if err != nil {
+			return nil, err
+		}
+		return capture, nil
+	})
+	setServerClipboard(l, weylusServer)
+	if viper.GetBool("log-unredacted") {
+		l.Warn().Msg("logging keystrokes and clipboard contents of clients")
+	}
+	weylusServer.SetLogUnredacted(viper.GetBool("log-unredacted"))
+
+	// set before watching, reloadConfig reads it from the watcher goroutine
+	reloader.onAccessCode = weylusServer.SetAccessCode
+	if viper.ConfigFileUsed() != "" {
+		viper.OnConfigChange(func(fsnotify.Event) {
+			reloader.reloadConfig()
+		})
+		viper.WatchConfig()
+	}
+
+	l.Info().Str("bind-address", viper.GetString("bind-address")).Uint16("web-port", viper.GetUint16("web-port")).Uint16("websocket-port", viper.GetUint16("websocket-port")).Msg("serving")
+	if err := weylusServer.Run(ctx); err != nil {
+		l.Fatal().Err(err).Msg("server failed")
+	}
+}
+
+// setServerClipboard synchronizes the system clipboard with the clients whose clipboard profile enables it.
+func setServerClipboard(l *zerolog.Logger, weylusServer *server.WeylusServer) {
+	var profiles clipboard.Profiles
+	if err := viper.UnmarshalKey("clipboard-profiles", &profiles); err != nil {
+		l.Warn().Err(err).Msg("ignoring clipboard profiles")
+		return
+	}
+	if err := profiles.Validate(); err != nil {
+		l.Warn().Err(err).Msg("ignoring clipboard profiles")
+		return
+	}
+	system, err := clipboard.NewSystem()
+	if err != nil {
+		l.Warn().Err(err).Msg("clipboard synchronization disabled")
 		return
 	}
-	// TODO serverLogger := log.With().Str("component", "server").Logger()
-	// TODO server.NewWeylusServer()
-	// server.WeylusWeb(serverLogger)
+	weylusServer.SetClipboard(system, profiles)
+}
+
+// serverKeyLookup returns the layout characters are looked up in with mode input.KeyboardInjectionKey, the one of the
+// display if there is one.
+func serverKeyLookup(l *zerolog.Logger, mode input.KeyboardInjection) input.KeyLookup {
+	if mode != input.KeyboardInjectionKey {
+		return nil
+	}
+	if !gtk.InitCheck() {
+		l.Warn().Msg("no display found, looking characters up in a US layout")
+		return nil
+	}
+	return input.GDKLookup{Display: gdk.DisplayGetDefault()}
 }
 
 func init() {
@@ -0,0 +1,133 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"sync"
+
+	"github.com/OmegaRogue/weylus-desktop/internal/logger"
+	"github.com/OmegaRogue/weylus-desktop/internal/watch"
+	"github.com/OmegaRogue/weylus-desktop/web"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// customAssets are the settings replacing assets of the website, by asset name.
+var customAssets = map[string]string{
+	"custom-access-html": web.AccessHTMLName,
+	"custom-index-html":  web.IndexHTMLName,
+	"custom-lib-js":      web.LibJSName,
+	"custom-style-css":   web.StyleCSSName,
+}
+
+// serverReloader applies changes of the config file and the custom assets while the server runs.
+type serverReloader struct {
+	log     *zerolog.Logger
+	cmd     *cobra.Command
+	assets  *web.Assets
+	watcher *watch.Watcher
+
+	mu sync.Mutex
+	// paths are the custom assets loaded, by setting
+	paths      map[string]string
+	accessCode string
+	// onAccessCode is called when the access code changes
+	onAccessCode func(code string)
+}
+
+func newServerReloader(l *zerolog.Logger, cmd *cobra.Command, assets *web.Assets, watcher *watch.Watcher) *serverReloader {
+	return &serverReloader{
+		log:        l,
+		cmd:        cmd,
+		assets:     assets,
+		watcher:    watcher,
+		paths:      make(map[string]string),
+		accessCode: viper.GetString("access-code"),
+	}
+}
+
+// loadAssets loads the custom assets whose path changed and watches them.
+func (r *serverReloader) loadAssets() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, name := range customAssets {
+		path := viper.GetString(key)
+		old, loaded := r.paths[key]
+		if loaded && path == old {
+			continue
+		}
+		if old != "" {
+			if err := r.watcher.Unwatch(old); err != nil {
+				return errors.Wrapf(err, "unwatch %s", key)
+			}
+		}
+		if err := r.assets.Load(name, path); err != nil {
+			return errors.Wrap(err, key)
+		}
+		r.paths[key] = path
+		if path == "" {
+			continue
+		}
+		name, path := name, path
+		if err := r.watcher.Watch(path, func() { r.reloadAsset(name, path) }); err != nil {
+			return errors.Wrapf(err, "watch %s", key)
+		}
+	}
+	return nil
+}
+
+// reloadAsset loads the changed custom asset name from path. If it can't be loaded, e.g. while an editor replaces it,
+// the previous content is kept.
+func (r *serverReloader) reloadAsset(name, path string) {
+	if err := r.assets.Load(name, path); err != nil {
+		r.log.Err(err).Str("path", path).Msg("failed reloading asset, keeping the previous one")
+		return
+	}
+	r.log.Info().Str("asset", name).Str("path", path).Msg("reloaded asset")
+}
+
+// reloadConfig applies the changed config file. Settings needing a restart, like the ports, are only applied by one.
+func (r *serverReloader) reloadConfig() {
+	if err := validateConfig(r.cmd, nil); err != nil {
+		r.log.Err(err).Msg("ignoring config change")
+		return
+	}
+	options, err := loggerOptions()
+	if err != nil {
+		r.log.Err(err).Msg("ignoring config change")
+		return
+	}
+	logger.SetLevels(options.Levels)
+	if err := r.loadAssets(); err != nil {
+		r.log.Err(err).Msg("failed reloading custom assets")
+	}
+
+	code := viper.GetString("access-code")
+	r.mu.Lock()
+	changed := r.accessCode != code
+	r.accessCode = code
+	onAccessCode := r.onAccessCode
+	r.mu.Unlock()
+	if changed && onAccessCode != nil {
+		onAccessCode(code)
+	}
+	r.log.Info().Str("path", viper.ConfigFileUsed()).Msg("reloaded config")
+}
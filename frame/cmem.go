@@ -0,0 +1,49 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package frame
+
+// #include <stdlib.h>
+import "C"
+
+import "unsafe"
+
+// CAllocator allocates pixel buffers with malloc. C code such as a Paintable
+// may keep them after the Go frame was dropped, which Go memory must not be.
+var CAllocator Allocator = cAllocator{}
+
+type cAllocator struct{}
+
+// Alloc implements Allocator.
+func (cAllocator) Alloc(n int) []byte {
+	if n == 0 {
+		return nil
+	}
+	p := C.malloc(C.size_t(n))
+	if p == nil {
+		panic("frame: out of memory")
+	}
+	return unsafe.Slice((*byte)(p), n)
+}
+
+// Free implements Allocator.
+func (cAllocator) Free(b []byte) {
+	if len(b) > 0 {
+		C.free(unsafe.Pointer(&b[0]))
+	}
+}
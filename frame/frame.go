@@ -0,0 +1,147 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package frame hands decoded video frames from the decoder to the UI without
+// copying or allocating pixel buffers per frame.
+package frame
+
+import (
+	"sync"
+
+	"github.com/OmegaRogue/weylus-desktop/bmp"
+)
+
+// DefaultBuffers is the number of pixel buffers kept for reuse: one being
+// decoded, one waiting to be shown and one being shown.
+const DefaultBuffers = 3
+
+// Allocator provides the pixel buffers of a Pool.
+type Allocator interface {
+	// Alloc returns a buffer of n bytes.
+	Alloc(n int) []byte
+	// Free frees a buffer returned by Alloc.
+	Free(b []byte)
+}
+
+// Frame is a video frame in a pixel buffer borrowed from a Pool.
+type Frame struct {
+	// Image holds the pixels, it is nil for a new buffer and replaced by
+	// Decode when the size of the video changes.
+	Image *bmp.NBGRA
+
+	pool *Pool
+}
+
+// Decode decodes the BMP image src into f with dec. The pixel buffer of f is
+// reused if the size of the image didn't change, otherwise it is replaced by
+// one from the Allocator of the pool.
+func (f *Frame) Decode(dec *bmp.BGRADecoder, src []byte) error {
+	img, err := dec.Decode(src, f.Image)
+	if err != nil {
+		return err
+	}
+	if img == f.Image || f.pool.alloc == nil {
+		f.Image = img
+		return nil
+	}
+	f.free()
+	pix := f.pool.alloc.Alloc(len(img.Pix))
+	copy(pix, img.Pix)
+	img.Pix = pix
+	f.Image = img
+	return nil
+}
+
+// Release returns the pixel buffer of f to its pool, f must not be used afterwards.
+func (f *Frame) Release() {
+	f.pool.put(f)
+}
+
+// free returns the pixel buffer of f to the Allocator of the pool.
+func (f *Frame) free() {
+	if f.Image != nil && f.pool.alloc != nil {
+		f.pool.alloc.Free(f.Image.Pix)
+	}
+	f.Image = nil
+}
+
+// Pool passes frames from a decoder to the UI, reusing their pixel buffers.
+// Only the latest frame is kept, frames published before the UI took them are
+// dropped.
+type Pool struct {
+	mu      sync.Mutex
+	free    []*Frame
+	latest  *Frame
+	buffers int
+	alloc   Allocator
+}
+
+// NewPool returns a Pool keeping up to buffers pixel buffers for reuse, 2 for
+// double and DefaultBuffers for triple buffering. The buffers are allocated by
+// alloc, nil keeps them on the Go heap.
+func NewPool(buffers int, alloc Allocator) *Pool {
+	return &Pool{free: make([]*Frame, 0, buffers), buffers: buffers, alloc: alloc}
+}
+
+// Get returns an unused frame to decode into. It never blocks, if all buffers
+// are in use a new one is allocated.
+func (p *Pool) Get() *Frame {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := len(p.free); n > 0 {
+		f := p.free[n-1]
+		p.free[n-1] = nil
+		p.free = p.free[:n-1]
+		return f
+	}
+	return &Frame{pool: p}
+}
+
+// Publish makes f the latest frame, a previous frame that was not taken yet is
+// released.
+func (p *Pool) Publish(f *Frame) {
+	p.mu.Lock()
+	prev := p.latest
+	p.latest = f
+	p.mu.Unlock()
+	if prev != nil {
+		prev.Release()
+	}
+}
+
+// Take returns the latest frame, or nil if none was published since the last
+// call. The caller releases the frame when done with it.
+func (p *Pool) Take() *Frame {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f := p.latest
+	p.latest = nil
+	return f
+}
+
+// put keeps the buffer of f for reuse unless enough buffers are kept already,
+// then it is freed.
+func (p *Pool) put(f *Frame) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.free) < p.buffers {
+		p.free = append(p.free, f)
+		return
+	}
+	f.free()
+}
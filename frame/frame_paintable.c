@@ -0,0 +1,101 @@
+// Copyright © 2023 omegarogue
+// SPDX-License-Identifier: AGPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//
+
+#include "frame_paintable.h"
+#include "_cgo_export.h"
+
+// WeylusFramePaintable shows the texture of the latest frame, the pixels of
+// the frame are released when GTK drops the texture.
+struct _WeylusFramePaintable {
+    GObject parent_instance;
+    GdkTexture *texture;
+};
+
+static void weylus_frame_paintable_snapshot(GdkPaintable *paintable, GdkSnapshot *snapshot, double width, double height) {
+    WeylusFramePaintable *self = WEYLUS_FRAME_PAINTABLE(paintable);
+    if (self->texture != NULL) {
+        gdk_paintable_snapshot(GDK_PAINTABLE(self->texture), snapshot, width, height);
+    }
+}
+
+static GdkPaintable *weylus_frame_paintable_get_current_image(GdkPaintable *paintable) {
+    WeylusFramePaintable *self = WEYLUS_FRAME_PAINTABLE(paintable);
+    if (self->texture != NULL) {
+        return GDK_PAINTABLE(g_object_ref(self->texture));
+    }
+    return gdk_paintable_new_empty(0, 0);
+}
+
+static int weylus_frame_paintable_get_intrinsic_width(GdkPaintable *paintable) {
+    WeylusFramePaintable *self = WEYLUS_FRAME_PAINTABLE(paintable);
+    return self->texture != NULL ? gdk_texture_get_width(self->texture) : 0;
+}
+
+static int weylus_frame_paintable_get_intrinsic_height(GdkPaintable *paintable) {
+    WeylusFramePaintable *self = WEYLUS_FRAME_PAINTABLE(paintable);
+    return self->texture != NULL ? gdk_texture_get_height(self->texture) : 0;
+}
+
+static void weylus_frame_paintable_init_interface(GdkPaintableInterface *iface) {
+    iface->snapshot = weylus_frame_paintable_snapshot;
+    iface->get_current_image = weylus_frame_paintable_get_current_image;
+    iface->get_intrinsic_width = weylus_frame_paintable_get_intrinsic_width;
+    iface->get_intrinsic_height = weylus_frame_paintable_get_intrinsic_height;
+}
+
+G_DEFINE_TYPE_WITH_CODE(WeylusFramePaintable, weylus_frame_paintable, G_TYPE_OBJECT,
+                        G_IMPLEMENT_INTERFACE(GDK_TYPE_PAINTABLE, weylus_frame_paintable_init_interface))
+
+static void weylus_frame_paintable_dispose(GObject *object) {
+    WeylusFramePaintable *self = WEYLUS_FRAME_PAINTABLE(object);
+    g_clear_object(&self->texture);
+    G_OBJECT_CLASS(weylus_frame_paintable_parent_class)->dispose(object);
+}
+
+static void weylus_frame_paintable_class_init(WeylusFramePaintableClass *klass) {
+    G_OBJECT_CLASS(klass)->dispose = weylus_frame_paintable_dispose;
+}
+
+static void weylus_frame_paintable_init(WeylusFramePaintable *self) {
+    self->texture = NULL;
+}
+
+WeylusFramePaintable *weylus_frame_paintable_new(void) {
+    return g_object_new(WEYLUS_TYPE_FRAME_PAINTABLE, NULL);
+}
+
+static void weylus_frame_release(gpointer frame) {
+    goFrameRelease((guintptr) frame);
+}
+
+void weylus_frame_paintable_set_frame(WeylusFramePaintable *self, guchar *pix, int width, int height, int stride, guintptr frame) {
+    GBytes *bytes = g_bytes_new_with_free_func(pix, (gsize) stride * height, weylus_frame_release, (gpointer) frame);
+    GdkTexture *texture = gdk_memory_texture_new(width, height, GDK_MEMORY_B8G8R8A8_PREMULTIPLIED, bytes, stride);
+    g_bytes_unref(bytes);
+
+    gboolean resized = self->texture == NULL ||
+                       gdk_texture_get_width(self->texture) != width ||
+                       gdk_texture_get_height(self->texture) != height;
+    g_clear_object(&self->texture);
+    self->texture = texture;
+
+    if (resized) {
+        gdk_paintable_invalidate_size(GDK_PAINTABLE(self));
+    }
+    gdk_paintable_invalidate_contents(GDK_PAINTABLE(self));
+}
@@ -0,0 +1,31 @@
+// Copyright © 2023 omegarogue
+// SPDX-License-Identifier: AGPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//
+
+#pragma once
+
+#include <gtk/gtk.h>
+
+G_BEGIN_DECLS
+
+#define WEYLUS_TYPE_FRAME_PAINTABLE (weylus_frame_paintable_get_type())
+G_DECLARE_FINAL_TYPE(WeylusFramePaintable, weylus_frame_paintable, WEYLUS, FRAME_PAINTABLE, GObject)
+
+WeylusFramePaintable *weylus_frame_paintable_new(void);
+void weylus_frame_paintable_set_frame(WeylusFramePaintable *self, guchar *pix, int width, int height, int stride, guintptr frame);
+
+G_END_DECLS
@@ -0,0 +1,190 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package frame
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"sync"
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/bmp"
+)
+
+func TestPool(t *testing.T) {
+	p := NewPool(2, nil)
+	if f := p.Take(); f != nil {
+		t.Fatal("took a frame before one was published")
+	}
+
+	a := p.Get()
+	a.Image = bmp.NewNBGRA(image.Rect(0, 0, 1, 1))
+	p.Publish(a)
+	b := p.Get()
+	if b == a {
+		t.Fatal("got the published frame")
+	}
+	p.Publish(b)
+	if got := p.Get(); got != a {
+		t.Error("frame replaced before being taken was not reused")
+	}
+
+	if got := p.Take(); got != b {
+		t.Fatalf("took %p, want latest frame %p", got, b)
+	}
+	if got := p.Take(); got != nil {
+		t.Error("took the same frame twice")
+	}
+	b.Release()
+	if got := p.Get(); got != b {
+		t.Error("released frame was not reused")
+	}
+}
+
+func TestPool_Buffers(t *testing.T) {
+	p := NewPool(1, nil)
+	a, b := p.Get(), p.Get()
+	a.Release()
+	b.Release()
+	if got := p.Get(); got != a {
+		t.Error("first released frame was not kept")
+	}
+	if got := p.Get(); got == b {
+		t.Error("kept more frames than buffers")
+	}
+}
+
+// encodeFrame returns a top-down 32 bits per pixel BMP image of the given size.
+func encodeFrame(width, height int) []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	pix := 4 * width * height
+	buf.WriteString("BM")
+	_ = binary.Write(&buf, le, []uint32{uint32(54 + pix), 0, 54, 40, uint32(width), uint32(-int32(height))})
+	_ = binary.Write(&buf, le, []uint16{1, 32})
+	_ = binary.Write(&buf, le, []uint32{0, uint32(pix), 0, 0, 0, 0})
+	buf.Write(make([]byte, pix))
+	return buf.Bytes()
+}
+
+// benchmarkFrames decodes b.N frames on one goroutine and consumes them on another, like bmpReader and the UI.
+func benchmarkFrames(b *testing.B, decode func(src []byte) *Frame, consume func(*Frame)) {
+	src := encodeFrame(1920, 1080)
+	frames := make(chan *Frame, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for f := range frames {
+			consume(f)
+		}
+	}()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frames <- decode(src)
+	}
+	close(frames)
+	wg.Wait()
+}
+
+func BenchmarkPool(b *testing.B) {
+	p := NewPool(DefaultBuffers, nil)
+	dec := bmp.NewBGRADecoder()
+	benchmarkFrames(b, func(src []byte) *Frame {
+		f := p.Get()
+		if err := f.Decode(dec, src); err != nil {
+			b.Fatal(err)
+		}
+		p.Publish(f)
+		return nil
+	}, func(*Frame) {
+		if f := p.Take(); f != nil {
+			f.Release()
+		}
+	})
+}
+
+func BenchmarkAllocPerFrame(b *testing.B) {
+	dec := bmp.NewBGRADecoder()
+	benchmarkFrames(b, func(src []byte) *Frame {
+		img, err := dec.Decode(src, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return &Frame{Image: img}
+	}, func(*Frame) {})
+}
+
+// countingAllocator tracks the buffers allocated on the Go heap.
+type countingAllocator struct {
+	live map[*byte]bool
+}
+
+func (a *countingAllocator) Alloc(n int) []byte {
+	b := make([]byte, n)
+	a.live[&b[0]] = true
+	return b
+}
+
+func (a *countingAllocator) Free(b []byte) {
+	if !a.live[&b[0]] {
+		panic("freed a buffer not allocated by the allocator")
+	}
+	delete(a.live, &b[0])
+}
+
+func TestFrame_Decode(t *testing.T) {
+	alloc := &countingAllocator{live: make(map[*byte]bool)}
+	p := NewPool(1, alloc)
+	dec := bmp.NewBGRADecoder()
+
+	f := p.Get()
+	if err := f.Decode(dec, encodeFrame(2, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if !alloc.live[&f.Image.Pix[0]] {
+		t.Fatal("pixels of a new frame were not allocated by the pool")
+	}
+	pix := &f.Image.Pix[0]
+	if err := f.Decode(dec, encodeFrame(2, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if &f.Image.Pix[0] != pix {
+		t.Error("pixels of a frame of the same size were not reused")
+	}
+	if err := f.Decode(dec, encodeFrame(3, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if alloc.live[pix] || !alloc.live[&f.Image.Pix[0]] || len(alloc.live) != 1 {
+		t.Errorf("resizing kept %d buffers, want the new one", len(alloc.live))
+	}
+
+	g := p.Get()
+	if err := g.Decode(dec, encodeFrame(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	f.Release()
+	g.Release()
+	if len(alloc.live) != 1 {
+		t.Errorf("%d buffers left after releasing more frames than kept, want 1", len(alloc.live))
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package frame
+
+// #cgo pkg-config: gtk4
+// #include "frame_paintable.h"
+import "C"
+
+import (
+	"image"
+	"runtime/cgo"
+	"unsafe"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+// Paintable is a gdk.Paintable showing the frames of a Pool. Its contents are
+// only invalidated when a new frame is set, and the pixels of a frame are
+// handed to GTK without copying and released once GTK no longer uses them, so
+// the pool has to allocate them with CAllocator.
+type Paintable struct {
+	obj     *coreglib.Object
+	current *Frame
+}
+
+// NewPaintable returns a Paintable showing nothing until the first frame is set.
+func NewPaintable() *Paintable {
+	return &Paintable{obj: coreglib.AssumeOwnership(unsafe.Pointer(C.weylus_frame_paintable_new()))}
+}
+
+// Paintable returns the gdk.Paintable to show, e.g. with gtk.Picture.SetPaintable.
+func (p *Paintable) Paintable() gdk.Paintabler {
+	return p.obj.Cast().(gdk.Paintabler)
+}
+
+// SetFrame shows f, which is released once GTK drops its texture. The pixels
+// of f must be allocated by CAllocator, GTK keeps them past the call. Empty
+// frames are released right away. It must be called on the main loop.
+func (p *Paintable) SetFrame(f *Frame) {
+	img := f.Image
+	if img == nil || len(img.Pix) == 0 {
+		f.Release()
+		return
+	}
+	C.weylus_frame_paintable_set_frame(
+		(*C.WeylusFramePaintable)(unsafe.Pointer(p.obj.Native())),
+		(*C.guchar)(unsafe.Pointer(&img.Pix[0])),
+		C.int(img.Rect.Dx()),
+		C.int(img.Rect.Dy()),
+		C.int(img.Stride),
+		C.guintptr(cgo.NewHandle(f)),
+	)
+	p.current = f
+}
+
+// Image returns a copy of the frame shown, or nil if no frame was set yet.
+// It must be called on the main loop.
+func (p *Paintable) Image() *image.RGBA {
+	if p.current == nil {
+		return nil
+	}
+	return p.current.Image.RGBA()
+}
+
+// goFrameRelease releases the frame behind the handle once GTK freed the texture of it.
+//
+//export goFrameRelease
+func goFrameRelease(handle C.guintptr) {
+	h := cgo.Handle(handle)
+	f := h.Value().(*Frame)
+	h.Delete()
+	f.Release()
+}
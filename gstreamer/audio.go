@@ -0,0 +1,120 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package gstreamer
+
+import (
+	"context"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/audio"
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+)
+
+// pullTimeout is how long a pull waits for a buffer before checking whether the capture was stopped.
+const pullTimeout = 100 * time.Millisecond
+
+// AudioCapture captures the audio track as Opus packets.
+type AudioCapture struct {
+	pipeline *GstPipeline
+	sink     *GstElement
+}
+
+// NewAudioCapture creates the capture pipeline of o.
+func NewAudioCapture(o audio.Options) (*AudioCapture, error) {
+	description, err := audio.CapturePipeline(o)
+	if err != nil {
+		return nil, errors.Wrap(err, "audio capture pipeline")
+	}
+	Init()
+	pipeline, err := ParseLaunch(description)
+	if err != nil {
+		return nil, errors.Wrap(err, "audio capture pipeline")
+	}
+	sink := pipeline.ElementByName(audio.SinkName)
+	if sink == nil {
+		return nil, errors.New("audio capture pipeline has no appsink")
+	}
+	return &AudioCapture{pipeline: pipeline, sink: sink}, nil
+}
+
+// Run captures until ctx is done or the stream ends and sends the packets to frames. The timestamps are the running
+// time of the pipeline, which starts at 0 with Run.
+func (c *AudioCapture) Run(ctx context.Context, frames chan<- protocol.AudioFrame) error {
+	if _, err := c.pipeline.SetState(GstStatePlaying); err != nil {
+		return errors.Wrap(err, "start audio capture")
+	}
+	defer func() {
+		_, _ = c.pipeline.SetState(GstStateNull)
+	}()
+	var last time.Duration
+	for ctx.Err() == nil {
+		data, pts, ok := c.sink.AppSinkPull(pullTimeout)
+		if !ok {
+			if c.sink.AppSinkIsEOS() {
+				return errors.New("audio capture ended")
+			}
+			continue
+		}
+		if pts < 0 {
+			pts = last
+		}
+		last = pts
+		select {
+		case frames <- protocol.AudioFrame{Timestamp: pts, Data: data}:
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
+// AudioPlayer plays Opus packets.
+type AudioPlayer struct {
+	pipeline *GstPipeline
+	src      *GstElement
+}
+
+// NewAudioPlayer creates and starts the playback pipeline.
+func NewAudioPlayer() (*AudioPlayer, error) {
+	Init()
+	pipeline, err := ParseLaunch(audio.PlaybackPipeline)
+	if err != nil {
+		return nil, errors.Wrap(err, "audio playback pipeline")
+	}
+	src := pipeline.ElementByName(audio.SourceName)
+	if src == nil {
+		return nil, errors.New("audio playback pipeline has no appsrc")
+	}
+	if _, err := pipeline.SetState(GstStatePlaying); err != nil {
+		return nil, errors.Wrap(err, "start audio playback")
+	}
+	return &AudioPlayer{pipeline: pipeline, src: src}, nil
+}
+
+// PlayAudio plays an Opus packet right away.
+func (p *AudioPlayer) PlayAudio(packet []byte) error {
+	return p.src.AppSrcPush(packet)
+}
+
+// Close stops the playback.
+func (p *AudioPlayer) Close() error {
+	p.src.AppSrcEndOfStream()
+	_, err := p.pipeline.SetState(GstStateNull)
+	return err
+}
@@ -139,4 +139,53 @@ GstElement *gstreamer_pipeline_new(const char *name) {
 //            gst_object_unref (pipeline);
 //            exit(-1);
 //        }
-//}
\ No newline at end of file
+//}
+GstElement *gstreamer_parse_launch(const char *description, char **error_message) {
+    GError *error = NULL;
+    GstElement *pipeline = gst_parse_launch(description, &error);
+    if (error != NULL) {
+        *error_message = g_strdup(error->message);
+        g_error_free(error);
+        if (pipeline != NULL) {
+            gst_object_unref(pipeline);
+        }
+        return NULL;
+    }
+    return pipeline;
+}
+
+GstElement *gstreamer_bin_get_by_name(GstElement *bin, const char *name) {
+    return gst_bin_get_by_name(GST_BIN(bin), name);
+}
+
+void *gstreamer_app_sink_pull(GstElement *appsink, guint64 timeout, size_t *size, guint64 *pts) {
+    GstSample *sample = gst_app_sink_try_pull_sample(GST_APP_SINK(appsink), timeout);
+    if (sample == NULL) {
+        return NULL;
+    }
+    GstBuffer *buffer = gst_sample_get_buffer(sample);
+    GstMapInfo map;
+    if (buffer == NULL || !gst_buffer_map(buffer, &map, GST_MAP_READ)) {
+        gst_sample_unref(sample);
+        return NULL;
+    }
+    void *data = malloc(map.size);
+    if (data != NULL) {
+        memcpy(data, map.data, map.size);
+        *size = map.size;
+        *pts = GST_BUFFER_PTS(buffer);
+    }
+    gst_buffer_unmap(buffer, &map);
+    gst_sample_unref(sample);
+    return data;
+}
+
+int gstreamer_app_sink_is_eos(GstElement *appsink) {
+    return gst_app_sink_is_eos(GST_APP_SINK(appsink));
+}
+
+int gstreamer_app_src_push(GstElement *appsrc, const void *data, size_t size) {
+    GstBuffer *buffer = gst_buffer_new_allocate(NULL, size, NULL);
+    gst_buffer_fill(buffer, 0, data, size);
+    return gst_app_src_push_buffer(GST_APP_SRC(appsrc), buffer);
+}
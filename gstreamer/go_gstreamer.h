@@ -23,6 +23,8 @@
 #include <gst/gst.h>
 #include <gtk/gtk.h>
 #include <gst/app/gstappsrc.h>
+#include <gst/app/gstappsink.h>
+#include <string.h>
 #include <glib-object.h>
 
 
@@ -42,4 +44,9 @@ GstBuffer *gstreamer_new_buffer(size_t size);
 size_t gstreamer_buffer_fill(GstBuffer *buffer, size_t offset, const void* data, size_t size);
 GstCaps *gstreamer_caps_example();
 void gstreamer_set_caps(GstElement *element, GstCaps *caps);
-void gstreamer_set_caps_example(GstElement *element);
\ No newline at end of file
+void gstreamer_set_caps_example(GstElement *element);
+GstElement *gstreamer_parse_launch(const char *description, char **error_message);
+GstElement *gstreamer_bin_get_by_name(GstElement *bin, const char *name);
+void *gstreamer_app_sink_pull(GstElement *appsink, guint64 timeout, size_t *size, guint64 *pts);
+int gstreamer_app_sink_is_eos(GstElement *appsink);
+int gstreamer_app_src_push(GstElement *appsrc, const void *data, size_t size);
@@ -0,0 +1,97 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package gstreamer
+
+// #include "go_gstreamer.h"
+import "C"
+
+import (
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// Init initializes GStreamer, it can be called more than once.
+func Init() {
+	C.gstreamer_init()
+}
+
+// ParseLaunch creates the pipeline of a gst-launch description.
+func ParseLaunch(description string) (*GstPipeline, error) {
+	_description := C.CString(description)
+	defer C.free(unsafe.Pointer(_description))
+	var _error *C.char
+	native := C.gstreamer_parse_launch(_description, &_error)
+	if native == nil {
+		defer C.g_free(C.gpointer(unsafe.Pointer(_error)))
+		return nil, errors.Errorf("parse pipeline: %s", C.GoString(_error))
+	}
+	return &GstPipeline{native: native}, nil
+}
+
+// ElementByName returns the element of the pipeline named name, nil if there is none.
+func (p *GstPipeline) ElementByName(name string) *GstElement {
+	_name := C.CString(name)
+	defer C.free(unsafe.Pointer(_name))
+	native := C.gstreamer_bin_get_by_name(p.native, _name)
+	if native == nil {
+		return nil
+	}
+	return &GstElement{native: native}
+}
+
+// AppSinkPull waits up to timeout for the next buffer of an appsink and returns a copy of it with its presentation
+// timestamp, -1 if the buffer has none. ok is false on timeout and at the end of the stream.
+func (e *GstElement) AppSinkPull(timeout time.Duration) (data []byte, pts time.Duration, ok bool) {
+	var size C.size_t
+	var _pts C.guint64
+	native := C.gstreamer_app_sink_pull(e.native, C.guint64(timeout.Nanoseconds()), &size, &_pts)
+	if native == nil {
+		return nil, 0, false
+	}
+	defer C.free(native)
+	data = C.GoBytes(native, C.int(size))
+	if uint64(_pts) == math.MaxUint64 {
+		return data, -1, true
+	}
+	return data, time.Duration(_pts), true
+}
+
+// AppSinkIsEOS reports whether an appsink reached the end of the stream.
+func (e *GstElement) AppSinkIsEOS() bool {
+	return C.gstreamer_app_sink_is_eos(e.native) != 0
+}
+
+// AppSrcPush pushes a copy of data into an appsrc.
+func (e *GstElement) AppSrcPush(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if ret := C.gstreamer_app_src_push(e.native, unsafe.Pointer(&data[0]), C.size_t(len(data))); int(ret) != int(C.GST_FLOW_OK) {
+		return errors.Errorf("push buffer: flow return %d", int(ret))
+	}
+	return nil
+}
+
+// AppSrcEndOfStream ends the stream of an appsrc.
+func (e *GstElement) AppSrcEndOfStream() {
+	C.gstreamer_app_src_end_of_stream(e.native)
+}
@@ -0,0 +1,396 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package input
+
+import (
+	"math"
+	"path/filepath"
+	"sync"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/holoplot/go-evdev"
+	"github.com/pkg/errors"
+)
+
+// MaxGamepads is the number of gamepads a client can forward, the same limit as XInput.
+const MaxGamepads = 4
+
+// Axis ranges of an Xbox 360 controller, used for the virtual gamepad and for devices that do not report them.
+const (
+	stickMin   = math.MinInt16
+	stickMax   = math.MaxInt16
+	triggerMax = math.MaxUint8
+)
+
+// gamepadButtons maps protocol buttons to the evdev buttons of an Xbox controller.
+var gamepadButtons = []struct {
+	button protocol.GamepadButtons
+	code   evdev.EvCode
+}{
+	{protocol.GamepadA, evdev.BTN_A},
+	{protocol.GamepadB, evdev.BTN_B},
+	{protocol.GamepadX, evdev.BTN_X},
+	{protocol.GamepadY, evdev.BTN_Y},
+	{protocol.GamepadLeftBumper, evdev.BTN_TL},
+	{protocol.GamepadRightBumper, evdev.BTN_TR},
+	{protocol.GamepadBack, evdev.BTN_SELECT},
+	{protocol.GamepadStart, evdev.BTN_START},
+	{protocol.GamepadGuide, evdev.BTN_MODE},
+	{protocol.GamepadLeftStick, evdev.BTN_THUMBL},
+	{protocol.GamepadRightStick, evdev.BTN_THUMBR},
+}
+
+// dpadButtons maps protocol buttons to the d-pad buttons some drivers report instead of a hat.
+var dpadButtons = []struct {
+	button protocol.GamepadButtons
+	code   evdev.EvCode
+}{
+	{protocol.GamepadDPadUp, evdev.BTN_DPAD_UP},
+	{protocol.GamepadDPadDown, evdev.BTN_DPAD_DOWN},
+	{protocol.GamepadDPadLeft, evdev.BTN_DPAD_LEFT},
+	{protocol.GamepadDPadRight, evdev.BTN_DPAD_RIGHT},
+}
+
+// defaultAbsInfos are the axis ranges of an Xbox 360 controller.
+var defaultAbsInfos = map[evdev.EvCode]evdev.AbsInfo{
+	evdev.ABS_X:     {Minimum: stickMin, Maximum: stickMax, Fuzz: 16, Flat: 128},
+	evdev.ABS_Y:     {Minimum: stickMin, Maximum: stickMax, Fuzz: 16, Flat: 128},
+	evdev.ABS_RX:    {Minimum: stickMin, Maximum: stickMax, Fuzz: 16, Flat: 128},
+	evdev.ABS_RY:    {Minimum: stickMin, Maximum: stickMax, Fuzz: 16, Flat: 128},
+	evdev.ABS_Z:     {Minimum: 0, Maximum: triggerMax},
+	evdev.ABS_RZ:    {Minimum: 0, Maximum: triggerMax},
+	evdev.ABS_HAT0X: {Minimum: -1, Maximum: 1},
+	evdev.ABS_HAT0Y: {Minimum: -1, Maximum: 1},
+}
+
+// EventReader reads the events of an input device, *evdev.InputDevice implements it.
+type EventReader interface {
+	ReadOne() (*evdev.InputEvent, error)
+}
+
+// GamepadReader reads the state of a gamepad from its evdev device.
+type GamepadReader struct {
+	source EventReader
+	abs    map[evdev.EvCode]evdev.AbsInfo
+	state  protocol.GamepadEvent
+}
+
+// NewGamepadReader creates a GamepadReader reporting the gamepad read from source as index.
+// abs are the axis ranges of the device, missing axes use the ranges of an Xbox 360 controller.
+func NewGamepadReader(index int, source EventReader, abs map[evdev.EvCode]evdev.AbsInfo) *GamepadReader {
+	ranges := make(map[evdev.EvCode]evdev.AbsInfo, len(defaultAbsInfos))
+	for code, info := range defaultAbsInfos {
+		ranges[code] = info
+	}
+	for code, info := range abs {
+		if info.Maximum > info.Minimum {
+			ranges[code] = info
+		}
+	}
+	return &GamepadReader{
+		source: source,
+		abs:    ranges,
+		state:  protocol.GamepadEvent{Index: index, Connected: true},
+	}
+}
+
+// Next reads events until the next sync report and returns the state of the gamepad.
+// After an error the gamepad is gone, Disconnected returns the event telling the server.
+func (r *GamepadReader) Next() (protocol.GamepadEvent, error) {
+	for {
+		ev, err := r.source.ReadOne()
+		if err != nil {
+			return r.state, errors.Wrap(err, "read gamepad event")
+		}
+		switch ev.Type {
+		case evdev.EV_SYN:
+			if ev.Code != evdev.SYN_REPORT {
+				continue
+			}
+			r.state.Timestamp = uint64(ev.Time.Sec)*1000 + uint64(ev.Time.Usec)/1000
+			return r.state, nil
+		case evdev.EV_KEY:
+			r.key(ev.Code, ev.Value != KeyReleased)
+		case evdev.EV_ABS:
+			r.axis(ev.Code, ev.Value)
+		}
+	}
+}
+
+// Disconnected returns the event removing the gamepad from the server.
+func (r *GamepadReader) Disconnected() protocol.GamepadEvent {
+	return protocol.GamepadEvent{Index: r.state.Index}
+}
+
+func (r *GamepadReader) key(code evdev.EvCode, pressed bool) {
+	for _, b := range gamepadButtons {
+		if b.code == code {
+			r.setButton(b.button, pressed)
+			return
+		}
+	}
+	for _, b := range dpadButtons {
+		if b.code == code {
+			r.setButton(b.button, pressed)
+			return
+		}
+	}
+	// digital triggers
+	switch code {
+	case evdev.BTN_TL2:
+		r.state.LeftTrigger = digital(pressed)
+	case evdev.BTN_TR2:
+		r.state.RightTrigger = digital(pressed)
+	}
+}
+
+func (r *GamepadReader) setButton(button protocol.GamepadButtons, pressed bool) {
+	if pressed {
+		r.state.Buttons |= button
+	} else {
+		r.state.Buttons &^= button
+	}
+}
+
+func (r *GamepadReader) axis(code evdev.EvCode, value int32) {
+	info := r.abs[code]
+	switch code {
+	case evdev.ABS_X:
+		r.state.LeftX = normalizeStick(value, info)
+	case evdev.ABS_Y:
+		r.state.LeftY = normalizeStick(value, info)
+	case evdev.ABS_RX:
+		r.state.RightX = normalizeStick(value, info)
+	case evdev.ABS_RY:
+		r.state.RightY = normalizeStick(value, info)
+	case evdev.ABS_Z:
+		r.state.LeftTrigger = normalizeTrigger(value, info)
+	case evdev.ABS_RZ:
+		r.state.RightTrigger = normalizeTrigger(value, info)
+	case evdev.ABS_HAT0X:
+		r.setButton(protocol.GamepadDPadLeft, value < 0)
+		r.setButton(protocol.GamepadDPadRight, value > 0)
+	case evdev.ABS_HAT0Y:
+		r.setButton(protocol.GamepadDPadUp, value < 0)
+		r.setButton(protocol.GamepadDPadDown, value > 0)
+	}
+}
+
+func digital(pressed bool) float64 {
+	if pressed {
+		return 1
+	}
+	return 0
+}
+
+// normalizeStick maps value in the range of info to [-1, 1].
+func normalizeStick(value int32, info evdev.AbsInfo) float64 {
+	if info.Maximum <= info.Minimum {
+		return 0
+	}
+	v := 2*float64(value-info.Minimum)/float64(info.Maximum-info.Minimum) - 1
+	return math.Max(-1, math.Min(1, v))
+}
+
+// normalizeTrigger maps value in the range of info to [0, 1].
+func normalizeTrigger(value int32, info evdev.AbsInfo) float64 {
+	if info.Maximum <= info.Minimum {
+		return 0
+	}
+	v := float64(value-info.Minimum) / float64(info.Maximum-info.Minimum)
+	return math.Max(0, math.Min(1, v))
+}
+
+// Capabilities are the events an input device supports, *evdev.InputDevice implements it.
+type Capabilities interface {
+	CapableEvents(t evdev.EvType) []evdev.EvCode
+}
+
+// IsGamepad reports whether dev is a gamepad, a device with the gamepad buttons and absolute axes.
+// Joysticks and wheels without BTN_GAMEPAD are not gamepads.
+func IsGamepad(dev Capabilities) bool {
+	if len(dev.CapableEvents(evdev.EV_ABS)) == 0 {
+		return false
+	}
+	for _, code := range dev.CapableEvents(evdev.EV_KEY) {
+		if code == evdev.BTN_GAMEPAD {
+			return true
+		}
+	}
+	return false
+}
+
+// FindGamepads opens the evdev gamepads, at most MaxGamepads.
+// Devices that can't be opened, usually for lack of permissions, are skipped.
+func FindGamepads() ([]*evdev.InputDevice, error) {
+	paths, err := filepath.Glob("/dev/input/event*")
+	if err != nil {
+		return nil, errors.Wrap(err, "list input devices")
+	}
+	var gamepads []*evdev.InputDevice
+	for _, path := range paths {
+		if len(gamepads) == MaxGamepads {
+			break
+		}
+		dev, err := evdev.Open(path)
+		if err != nil {
+			continue
+		}
+		if !IsGamepad(dev) {
+			_ = dev.Close()
+			continue
+		}
+		gamepads = append(gamepads, dev)
+	}
+	return gamepads, nil
+}
+
+// GamepadDevice is a virtual gamepad events are written to.
+type GamepadDevice interface {
+	EventWriter
+	Close() error
+}
+
+// virtualGamepad is a virtual gamepad and the values last written to it.
+type virtualGamepad struct {
+	dev  GamepadDevice
+	keys map[evdev.EvCode]int32
+	axes map[evdev.EvCode]int32
+}
+
+// Gamepads injects the gamepad events of a client into virtual gamepads, created on the first event of each gamepad
+// index and removed when the gamepad disconnects.
+type Gamepads struct {
+	create func(index int) (GamepadDevice, error)
+	mu     sync.Mutex
+	pads   map[int]*virtualGamepad
+}
+
+// NewGamepads creates Gamepads creating the virtual gamepads with create, e.g. NewUInputGamepad.
+func NewGamepads(create func(index int) (GamepadDevice, error)) *Gamepads {
+	return &Gamepads{create: create, pads: make(map[int]*virtualGamepad)}
+}
+
+// Inject sets the state of the virtual gamepad of e.Index, writing the buttons and axes that changed.
+//
+//nolint:gocritic // GamepadEvent might be heavy, but it should be like this
+func (g *Gamepads) Inject(e protocol.GamepadEvent) error {
+	if e.Index < 0 || e.Index >= MaxGamepads {
+		return errors.Errorf("gamepad index %d out of range", e.Index)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pad, ok := g.pads[e.Index]
+	if !e.Connected {
+		if !ok {
+			return nil
+		}
+		delete(g.pads, e.Index)
+		return errors.Wrap(pad.dev.Close(), "remove virtual gamepad")
+	}
+	if !ok {
+		dev, err := g.create(e.Index)
+		if err != nil {
+			return errors.Wrap(err, "create virtual gamepad")
+		}
+		pad = &virtualGamepad{dev: dev, keys: make(map[evdev.EvCode]int32), axes: make(map[evdev.EvCode]int32)}
+		g.pads[e.Index] = pad
+	}
+
+	var events []evdev.InputEvent
+	for _, b := range gamepadButtons {
+		value := KeyReleased
+		if e.Buttons&b.button != 0 {
+			value = KeyPressed
+		}
+		if pad.keys[b.code] != value {
+			events = append(events, evdev.InputEvent{Type: evdev.EV_KEY, Code: b.code, Value: value})
+		}
+	}
+	axes := []struct {
+		code  evdev.EvCode
+		value int32
+	}{
+		{evdev.ABS_X, stickValue(e.LeftX)},
+		{evdev.ABS_Y, stickValue(e.LeftY)},
+		{evdev.ABS_RX, stickValue(e.RightX)},
+		{evdev.ABS_RY, stickValue(e.RightY)},
+		{evdev.ABS_Z, triggerValue(e.LeftTrigger)},
+		{evdev.ABS_RZ, triggerValue(e.RightTrigger)},
+		{evdev.ABS_HAT0X, hatValue(e.Buttons, protocol.GamepadDPadLeft, protocol.GamepadDPadRight)},
+		{evdev.ABS_HAT0Y, hatValue(e.Buttons, protocol.GamepadDPadUp, protocol.GamepadDPadDown)},
+	}
+	for _, a := range axes {
+		if pad.axes[a.code] != a.value {
+			events = append(events, evdev.InputEvent{Type: evdev.EV_ABS, Code: a.code, Value: a.value})
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := pad.dev.WriteFrame(events...); err != nil {
+		return errors.Wrap(err, "inject gamepad event")
+	}
+	for _, ev := range events {
+		if ev.Type == evdev.EV_KEY {
+			pad.keys[ev.Code] = ev.Value
+		} else {
+			pad.axes[ev.Code] = ev.Value
+		}
+	}
+	return nil
+}
+
+// Close removes all virtual gamepads, e.g. when the client disconnects.
+func (g *Gamepads) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var err error
+	for index, pad := range g.pads {
+		if closeErr := pad.dev.Close(); closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "remove virtual gamepad")
+		}
+		delete(g.pads, index)
+	}
+	return err
+}
+
+func stickValue(v float64) int32 {
+	v = math.Max(-1, math.Min(1, v))
+	if v < 0 {
+		return int32(math.Round(-v * stickMin))
+	}
+	return int32(math.Round(v * stickMax))
+}
+
+func triggerValue(v float64) int32 {
+	return int32(math.Round(math.Max(0, math.Min(1, v)) * triggerMax))
+}
+
+func hatValue(buttons, negative, positive protocol.GamepadButtons) int32 {
+	var v int32
+	if buttons&negative != 0 {
+		v--
+	}
+	if buttons&positive != 0 {
+		v++
+	}
+	return v
+}
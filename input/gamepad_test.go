@@ -0,0 +1,188 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package input
+
+import (
+	"io"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/holoplot/go-evdev"
+)
+
+// fakeEventSource replays events like an evdev device and fails with io.EOF once they ran out.
+type fakeEventSource struct {
+	events []evdev.InputEvent
+}
+
+func (s *fakeEventSource) ReadOne() (*evdev.InputEvent, error) {
+	if len(s.events) == 0 {
+		return nil, io.EOF
+	}
+	ev := s.events[0]
+	s.events = s.events[1:]
+	return &ev, nil
+}
+
+type fakeCapabilities map[evdev.EvType][]evdev.EvCode
+
+func (c fakeCapabilities) CapableEvents(t evdev.EvType) []evdev.EvCode {
+	return c[t]
+}
+
+type fakeGamepadDevice struct {
+	fakeEventWriter
+	closed bool
+}
+
+func (d *fakeGamepadDevice) Close() error {
+	d.closed = true
+	return nil
+}
+
+func abs(code evdev.EvCode, value int32) evdev.InputEvent {
+	return evdev.InputEvent{Type: evdev.EV_ABS, Code: code, Value: value}
+}
+
+func syn(ms int64) evdev.InputEvent {
+	return evdev.InputEvent{Time: syscall.NsecToTimeval(ms * 1e6), Type: evdev.EV_SYN, Code: evdev.SYN_REPORT}
+}
+
+func TestGamepadReader_Next(t *testing.T) {
+	source := &fakeEventSource{events: []evdev.InputEvent{
+		btn(evdev.BTN_A, KeyPressed),
+		abs(evdev.ABS_X, 100),
+		abs(evdev.ABS_Y, stickMin),
+		abs(evdev.ABS_Z, triggerMax),
+		abs(evdev.ABS_HAT0X, -1),
+		syn(10),
+		btn(evdev.BTN_A, KeyReleased),
+		btn(evdev.BTN_START, KeyPressed),
+		abs(evdev.ABS_X, 50),
+		abs(evdev.ABS_HAT0X, 0),
+		abs(evdev.ABS_HAT0Y, 1),
+		abs(evdev.ABS_RX, 255),
+		btn(evdev.BTN_TR2, KeyPressed),
+		{Type: evdev.EV_SYN, Code: evdev.SYN_DROPPED},
+		syn(20),
+	}}
+	r := NewGamepadReader(1, source, map[evdev.EvCode]evdev.AbsInfo{
+		evdev.ABS_X:  {Minimum: -100, Maximum: 100},
+		evdev.ABS_RX: {Minimum: 0, Maximum: 255},
+		// invalid ranges keep the default
+		evdev.ABS_Y: {},
+	})
+
+	want := []protocol.GamepadEvent{
+		{
+			Index: 1, Connected: true, Buttons: protocol.GamepadA | protocol.GamepadDPadLeft,
+			LeftX: 1, LeftY: -1, LeftTrigger: 1, Timestamp: 10,
+		},
+		{
+			Index: 1, Connected: true, Buttons: protocol.GamepadStart | protocol.GamepadDPadDown,
+			LeftX: 0.5, LeftY: -1, RightX: 1, LeftTrigger: 1, RightTrigger: 1, Timestamp: 20,
+		},
+	}
+	for i, w := range want {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next() #%d error = %v", i, err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("Next() #%d = %+v, want %+v", i, got, w)
+		}
+	}
+	if _, err := r.Next(); err == nil {
+		t.Error("Next() after the last event error = nil, want error")
+	}
+	if got := r.Disconnected(); got != (protocol.GamepadEvent{Index: 1}) {
+		t.Errorf("Disconnected() = %+v", got)
+	}
+}
+
+func TestIsGamepad(t *testing.T) {
+	tests := []struct {
+		name string
+		caps fakeCapabilities
+		want bool
+	}{
+		{"Gamepad", fakeCapabilities{
+			evdev.EV_KEY: {evdev.BTN_A, evdev.BTN_B},
+			evdev.EV_ABS: {evdev.ABS_X, evdev.ABS_Y},
+		}, true},
+		{"NoAxes", fakeCapabilities{evdev.EV_KEY: {evdev.BTN_A}}, false},
+		{"Joystick", fakeCapabilities{
+			evdev.EV_KEY: {evdev.BTN_TRIGGER},
+			evdev.EV_ABS: {evdev.ABS_X, evdev.ABS_Y},
+		}, false},
+		{"Keyboard", fakeCapabilities{evdev.EV_KEY: {evdev.KEY_A}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsGamepad(tt.caps); got != tt.want {
+				t.Errorf("IsGamepad() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGamepads_Inject(t *testing.T) {
+	devices := make(map[int]*fakeGamepadDevice)
+	pads := NewGamepads(func(index int) (GamepadDevice, error) {
+		dev := &fakeGamepadDevice{}
+		devices[index] = dev
+		return dev, nil
+	})
+
+	events := []protocol.GamepadEvent{
+		{Index: 0, Connected: true, Buttons: protocol.GamepadA | protocol.GamepadDPadUp, LeftX: -1, RightTrigger: 0.5},
+		{Index: 0, Connected: true, Buttons: protocol.GamepadA | protocol.GamepadDPadUp, LeftX: -1, RightTrigger: 0.5},
+		{Index: 0, Connected: true, Buttons: protocol.GamepadDPadLeft | protocol.GamepadDPadRight, LeftY: 1},
+		{Index: 2, Connected: true, Buttons: protocol.GamepadGuide},
+	}
+	for _, e := range events {
+		if err := pads.Inject(e); err != nil {
+			t.Fatalf("Inject(%+v) error = %v", e, err)
+		}
+	}
+	wantFirst := [][]evdev.InputEvent{
+		{btn(evdev.BTN_A, KeyPressed), abs(evdev.ABS_X, stickMin), abs(evdev.ABS_RZ, 128), abs(evdev.ABS_HAT0Y, -1)},
+		{btn(evdev.BTN_A, KeyReleased), abs(evdev.ABS_X, 0), abs(evdev.ABS_Y, stickMax), abs(evdev.ABS_RZ, 0), abs(evdev.ABS_HAT0Y, 0)},
+	}
+	assertFrames(t, devices[0].frames, wantFirst)
+	assertFrames(t, devices[2].frames, [][]evdev.InputEvent{{btn(evdev.BTN_MODE, KeyPressed)}})
+
+	if err := pads.Inject(protocol.GamepadEvent{Index: 0}); err != nil {
+		t.Fatalf("Inject(disconnect) error = %v", err)
+	}
+	if !devices[0].closed || devices[2].closed {
+		t.Errorf("after disconnect closed = %v, %v, want true, false", devices[0].closed, devices[2].closed)
+	}
+	if err := pads.Inject(protocol.GamepadEvent{Index: MaxGamepads, Connected: true}); err == nil {
+		t.Error("Inject(index out of range) error = nil, want error")
+	}
+	if err := pads.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !devices[2].closed {
+		t.Error("Close() left a virtual gamepad")
+	}
+}
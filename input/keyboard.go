@@ -0,0 +1,337 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package input
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/holoplot/go-evdev"
+	"github.com/pkg/errors"
+)
+
+// Key values as used by the kernel input subsystem.
+const (
+	KeyReleased int32 = 0
+	KeyPressed  int32 = 1
+	KeyRepeated int32 = 2
+)
+
+// KeyWriter emits key state changes on an input device.
+type KeyWriter interface {
+	WriteKey(code evdev.EvCode, value int32) error
+}
+
+// Stroke is the key and modifiers needed to produce a character.
+type Stroke struct {
+	Code  evdev.EvCode
+	Shift bool
+	AltGr bool
+}
+
+// KeyLookup finds the stroke producing a character in the server's keyboard layout.
+type KeyLookup interface {
+	Lookup(r rune) (Stroke, bool)
+}
+
+// KeyLookupFunc adapts a function to KeyLookup.
+type KeyLookupFunc func(r rune) (Stroke, bool)
+
+// Lookup calls f(r).
+func (f KeyLookupFunc) Lookup(r rune) (Stroke, bool) {
+	return f(r)
+}
+
+// Keyboard injects protocol.KeyboardEvent values into a KeyWriter.
+//
+// With KeyboardInjectionCode the physical key from the event code is pressed and the server's layout decides the
+// character. With KeyboardInjectionKey the character from the event key is looked up in the server's layout,
+// characters the layout cannot produce are typed as unicode with Ctrl+Shift+U.
+// Events without a usable code are always injected by key.
+type Keyboard struct {
+	Mode   KeyboardInjection
+	Lookup KeyLookup
+
+	writer  KeyWriter
+	mu      sync.Mutex
+	pressed map[string][]evdev.EvCode
+}
+
+// NewKeyboard creates a Keyboard writing to w. A nil lookup uses a US layout.
+func NewKeyboard(w KeyWriter, mode KeyboardInjection, lookup KeyLookup) *Keyboard {
+	if lookup == nil {
+		lookup = KeyLookupFunc(LookupUS)
+	}
+	return &Keyboard{
+		Mode:    mode,
+		Lookup:  lookup,
+		writer:  w,
+		pressed: make(map[string][]evdev.EvCode),
+	}
+}
+
+// Inject presses or releases the keys for e.
+func (k *Keyboard) Inject(e protocol.KeyboardEvent) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	id := e.Code
+	if id == "" || id == "Unidentified" {
+		id = "key:" + e.Key
+	}
+
+	switch e.EventType {
+	case protocol.KeyboardEventTypeUp:
+		codes, ok := k.pressed[id]
+		if !ok {
+			return nil
+		}
+		delete(k.pressed, id)
+		for i := len(codes) - 1; i >= 0; i-- {
+			if err := k.writer.WriteKey(codes[i], KeyReleased); err != nil {
+				return errors.Wrapf(err, "release %s", evdev.CodeName(evdev.EV_KEY, codes[i]))
+			}
+		}
+		return nil
+	case protocol.KeyboardEventTypeRepeat:
+		codes, ok := k.pressed[id]
+		if !ok {
+			return nil
+		}
+		if err := k.writer.WriteKey(codes[len(codes)-1], KeyRepeated); err != nil {
+			return errors.Wrapf(err, "repeat %s", evdev.CodeName(evdev.EV_KEY, codes[len(codes)-1]))
+		}
+		return nil
+	case protocol.KeyboardEventTypeDown:
+	default:
+		return errors.Errorf("unknown keyboard event type %q", e.EventType)
+	}
+
+	codes, err := k.strokes(e)
+	if err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	for _, code := range codes {
+		if err := k.writer.WriteKey(code, KeyPressed); err != nil {
+			return errors.Wrapf(err, "press %s", evdev.CodeName(evdev.EV_KEY, code))
+		}
+	}
+	k.pressed[id] = codes
+	return nil
+}
+
+// Release releases all keys held by the Keyboard, e.g. when the client disconnects. Every client has its own
+// Keyboard, so a client only releases its own keys, they can share the KeyWriter.
+func (k *Keyboard) Release() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	ids := make([]string, 0, len(k.pressed))
+	for id := range k.pressed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var err error
+	for _, id := range ids {
+		codes := k.pressed[id]
+		delete(k.pressed, id)
+		for i := len(codes) - 1; i >= 0; i-- {
+			if writeErr := k.writer.WriteKey(codes[i], KeyReleased); writeErr != nil && err == nil {
+				err = errors.Wrapf(writeErr, "release %s", evdev.CodeName(evdev.EV_KEY, codes[i]))
+			}
+		}
+	}
+	return err
+}
+
+// strokes returns the keys to hold down for e, in press order.
+// Unicode input is typed completely and returns no keys to hold.
+func (k *Keyboard) strokes(e protocol.KeyboardEvent) ([]evdev.EvCode, error) {
+	if k.Mode == KeyboardInjectionKey && (e.Key == "Dead" || e.Key == "Process") {
+		// The client composes the text, the press completing the sequence carries it.
+		return nil, nil
+	}
+	code, hasCode := CodeToEvdev(e.Code)
+	if hasCode && (k.Mode == KeyboardInjectionCode || !printable(e.Key)) {
+		return []evdev.EvCode{code}, nil
+	}
+	if code, ok := namedKeys[e.Key]; ok {
+		return []evdev.EvCode{code}, nil
+	}
+	if !printable(e.Key) {
+		return nil, errors.Errorf("no key for code %q key %q", e.Code, e.Key)
+	}
+	runes := []rune(e.Key)
+	if len(runes) == 1 {
+		if stroke, ok := k.Lookup.Lookup(runes[0]); ok {
+			var codes []evdev.EvCode
+			if stroke.Shift && !e.Shift {
+				codes = append(codes, evdev.KEY_LEFTSHIFT)
+			}
+			if stroke.AltGr {
+				codes = append(codes, evdev.KEY_RIGHTALT)
+			}
+			return append(codes, stroke.Code), nil
+		}
+	}
+	return nil, k.typeUnicode(runes)
+}
+
+// typeUnicode types runes with the Ctrl+Shift+U unicode input understood by GTK and IBus.
+func (k *Keyboard) typeUnicode(runes []rune) error {
+	tap := func(codes ...evdev.EvCode) error {
+		for _, code := range codes {
+			if err := k.writer.WriteKey(code, KeyPressed); err != nil {
+				return err
+			}
+		}
+		for i := len(codes) - 1; i >= 0; i-- {
+			if err := k.writer.WriteKey(codes[i], KeyReleased); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, r := range runes {
+		if err := tap(evdev.KEY_LEFTCTRL, evdev.KEY_LEFTSHIFT, evdev.KEY_U); err != nil {
+			return errors.Wrapf(err, "type %q", r)
+		}
+		for _, digit := range fmt.Sprintf("%x", r) {
+			stroke, _ := LookupUS(digit)
+			if err := tap(stroke.Code); err != nil {
+				return errors.Wrapf(err, "type %q", r)
+			}
+		}
+		if err := tap(evdev.KEY_SPACE); err != nil {
+			return errors.Wrapf(err, "type %q", r)
+		}
+	}
+	return nil
+}
+
+// printable reports whether key is text rather than a named key like "Enter" or "Shift".
+func printable(key string) bool {
+	if key == "" || key == "Unidentified" || key == "Dead" || key == "Process" {
+		return false
+	}
+	if _, ok := namedKeys[key]; ok {
+		return false
+	}
+	// Other named keys are ASCII words, text from a compose sequence may be several non-ASCII runes.
+	return utf8.RuneCountInString(key) == 1 || strings.IndexFunc(key, func(r rune) bool { return r > unicode.MaxASCII }) >= 0
+}
+
+var (
+	codeToEvdev     map[string]evdev.EvCode
+	codeToEvdevOnce sync.Once
+)
+
+// CodeToEvdev returns the evdev key code for a KeyboardEvent.code value.
+// protocol.CodeValue is indexed by X11 keycodes, which are evdev codes offset by 8.
+func CodeToEvdev(code string) (evdev.EvCode, bool) {
+	codeToEvdevOnce.Do(func() {
+		codeToEvdev = make(map[string]evdev.EvCode, len(protocol.CodeValue))
+		for keycode, name := range protocol.CodeValue {
+			if name == "Unidentified" || keycode < 8 {
+				continue
+			}
+			codeToEvdev[name] = evdev.EvCode(keycode - 8)
+		}
+	})
+	c, ok := codeToEvdev[code]
+	return c, ok
+}
+
+// namedKeys maps KeyboardEvent.key values that do not depend on the layout to evdev key codes.
+var namedKeys = map[string]evdev.EvCode{
+	"Enter":       evdev.KEY_ENTER,
+	"Tab":         evdev.KEY_TAB,
+	"Backspace":   evdev.KEY_BACKSPACE,
+	"Escape":      evdev.KEY_ESC,
+	"Delete":      evdev.KEY_DELETE,
+	"Insert":      evdev.KEY_INSERT,
+	"Home":        evdev.KEY_HOME,
+	"End":         evdev.KEY_END,
+	"PageUp":      evdev.KEY_PAGEUP,
+	"PageDown":    evdev.KEY_PAGEDOWN,
+	"ArrowUp":     evdev.KEY_UP,
+	"ArrowDown":   evdev.KEY_DOWN,
+	"ArrowLeft":   evdev.KEY_LEFT,
+	"ArrowRight":  evdev.KEY_RIGHT,
+	"Shift":       evdev.KEY_LEFTSHIFT,
+	"Control":     evdev.KEY_LEFTCTRL,
+	"Alt":         evdev.KEY_LEFTALT,
+	"AltGraph":    evdev.KEY_RIGHTALT,
+	"Meta":        evdev.KEY_LEFTMETA,
+	"Super":       evdev.KEY_LEFTMETA,
+	"CapsLock":    evdev.KEY_CAPSLOCK,
+	"NumLock":     evdev.KEY_NUMLOCK,
+	"ScrollLock":  evdev.KEY_SCROLLLOCK,
+	"ContextMenu": evdev.KEY_COMPOSE,
+	"PrintScreen": evdev.KEY_SYSRQ,
+	"Pause":       evdev.KEY_PAUSE,
+	"F1":          evdev.KEY_F1,
+	"F2":          evdev.KEY_F2,
+	"F3":          evdev.KEY_F3,
+	"F4":          evdev.KEY_F4,
+	"F5":          evdev.KEY_F5,
+	"F6":          evdev.KEY_F6,
+	"F7":          evdev.KEY_F7,
+	"F8":          evdev.KEY_F8,
+	"F9":          evdev.KEY_F9,
+	"F10":         evdev.KEY_F10,
+	"F11":         evdev.KEY_F11,
+	"F12":         evdev.KEY_F12,
+}
+
+// usLayout maps the characters of a US layout to their strokes.
+var usLayout = func() map[rune]Stroke {
+	m := make(map[rune]Stroke)
+	rows := []struct {
+		plain, shifted string
+		codes          []evdev.EvCode
+	}{
+		{"`1234567890-=", "~!@#$%^&*()_+", []evdev.EvCode{evdev.KEY_GRAVE, evdev.KEY_1, evdev.KEY_2, evdev.KEY_3, evdev.KEY_4, evdev.KEY_5, evdev.KEY_6, evdev.KEY_7, evdev.KEY_8, evdev.KEY_9, evdev.KEY_0, evdev.KEY_MINUS, evdev.KEY_EQUAL}},
+		{"qwertyuiop[]\\", "QWERTYUIOP{}|", []evdev.EvCode{evdev.KEY_Q, evdev.KEY_W, evdev.KEY_E, evdev.KEY_R, evdev.KEY_T, evdev.KEY_Y, evdev.KEY_U, evdev.KEY_I, evdev.KEY_O, evdev.KEY_P, evdev.KEY_LEFTBRACE, evdev.KEY_RIGHTBRACE, evdev.KEY_BACKSLASH}},
+		{"asdfghjkl;'", "ASDFGHJKL:\"", []evdev.EvCode{evdev.KEY_A, evdev.KEY_S, evdev.KEY_D, evdev.KEY_F, evdev.KEY_G, evdev.KEY_H, evdev.KEY_J, evdev.KEY_K, evdev.KEY_L, evdev.KEY_SEMICOLON, evdev.KEY_APOSTROPHE}},
+		{"zxcvbnm,./", "ZXCVBNM<>?", []evdev.EvCode{evdev.KEY_Z, evdev.KEY_X, evdev.KEY_C, evdev.KEY_V, evdev.KEY_B, evdev.KEY_N, evdev.KEY_M, evdev.KEY_COMMA, evdev.KEY_DOT, evdev.KEY_SLASH}},
+	}
+	for _, row := range rows {
+		plain, shifted := []rune(row.plain), []rune(row.shifted)
+		for i, code := range row.codes {
+			m[plain[i]] = Stroke{Code: code}
+			m[shifted[i]] = Stroke{Code: code, Shift: true}
+		}
+	}
+	m[' '] = Stroke{Code: evdev.KEY_SPACE}
+	return m
+}()
+
+// LookupUS finds the stroke for r in a US layout.
+func LookupUS(r rune) (Stroke, bool) {
+	s, ok := usLayout[r]
+	return s, ok
+}
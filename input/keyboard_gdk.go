@@ -0,0 +1,54 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package input
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/holoplot/go-evdev"
+)
+
+// GDKLookup finds strokes in the active keyboard layout of a GDK display.
+type GDKLookup struct {
+	Display *gdk.Display
+}
+
+// Lookup implements KeyLookup. The stroke on the lowest level of the lowest group wins,
+// levels above the second one need AltGr.
+func (l GDKLookup) Lookup(r rune) (Stroke, bool) {
+	keyVal := gdk.UnicodeToKeyval(uint32(r))
+	keys, ok := l.Display.MapKeyval(keyVal)
+	if !ok || len(keys) == 0 {
+		return Stroke{}, false
+	}
+	best := keys[0]
+	for _, key := range keys[1:] {
+		if key.Group() < best.Group() || key.Group() == best.Group() && key.Level() < best.Level() {
+			best = key
+		}
+	}
+	// X11 keycodes are evdev codes offset by 8.
+	if best.Keycode() < 8 {
+		return Stroke{}, false
+	}
+	return Stroke{
+		Code:  evdev.EvCode(best.Keycode() - 8),
+		Shift: best.Level()%2 == 1,
+		AltGr: best.Level() >= 2,
+	}, true
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package input
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/holoplot/go-evdev"
+)
+
+type keyWrite struct {
+	code  evdev.EvCode
+	value int32
+}
+
+type fakeKeyWriter struct {
+	writes []keyWrite
+}
+
+func (w *fakeKeyWriter) WriteKey(code evdev.EvCode, value int32) error {
+	w.writes = append(w.writes, keyWrite{code, value})
+	return nil
+}
+
+func press(code evdev.EvCode) keyWrite   { return keyWrite{code, KeyPressed} }
+func release(code evdev.EvCode) keyWrite { return keyWrite{code, KeyReleased} }
+
+// germanLookup knows just enough of a German layout to tell it apart from a US one.
+func germanLookup(r rune) (Stroke, bool) {
+	switch r {
+	case 'z':
+		return Stroke{Code: evdev.KEY_Y}, true
+	case 'y':
+		return Stroke{Code: evdev.KEY_Z}, true
+	case 'ü':
+		return Stroke{Code: evdev.KEY_LEFTBRACE}, true
+	case '@':
+		return Stroke{Code: evdev.KEY_Q, AltGr: true}, true
+	}
+	return LookupUS(r)
+}
+
+func TestCodeToEvdev(t *testing.T) {
+	tests := []struct {
+		code string
+		want evdev.EvCode
+	}{
+		{"KeyA", evdev.KEY_A},
+		{"Escape", evdev.KEY_ESC},
+		{"ShiftLeft", evdev.KEY_LEFTSHIFT},
+		{"ShiftRight", evdev.KEY_RIGHTSHIFT},
+		{"AltRight", evdev.KEY_RIGHTALT},
+		{"Numpad1", evdev.KEY_KP1},
+		{"NumpadEnter", evdev.KEY_KPENTER},
+		{"ArrowUp", evdev.KEY_UP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, ok := CodeToEvdev(tt.code)
+			if !ok || got != tt.want {
+				t.Errorf("CodeToEvdev(%q) = %v, %v, want %v", tt.code, got, ok, tt.want)
+			}
+		})
+	}
+	if _, ok := CodeToEvdev("Unidentified"); ok {
+		t.Error("CodeToEvdev(Unidentified) returned a code")
+	}
+}
+
+func TestKeyboard_Inject(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   KeyboardInjection
+		events []protocol.KeyboardEvent
+		want   []keyWrite
+	}{
+		{
+			name: "CodeUsesPhysicalKey",
+			mode: KeyboardInjectionCode,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "KeyY", Key: "z"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "KeyY", Key: "z"},
+			},
+			want: []keyWrite{press(evdev.KEY_Y), release(evdev.KEY_Y)},
+		},
+		{
+			name: "CodeRepeat",
+			mode: KeyboardInjectionCode,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "KeyA", Key: "a"},
+				{EventType: protocol.KeyboardEventTypeRepeat, Code: "KeyA", Key: "a"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "KeyA", Key: "a"},
+			},
+			want: []keyWrite{press(evdev.KEY_A), {evdev.KEY_A, KeyRepeated}, release(evdev.KEY_A)},
+		},
+		{
+			name: "CodeDeadKey",
+			mode: KeyboardInjectionCode,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "Equal", Key: "Dead"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "Equal", Key: "Dead"},
+				{EventType: protocol.KeyboardEventTypeDown, Code: "KeyE", Key: "é"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "KeyE", Key: "é"},
+			},
+			want: []keyWrite{press(evdev.KEY_EQUAL), release(evdev.KEY_EQUAL), press(evdev.KEY_E), release(evdev.KEY_E)},
+		},
+		{
+			name: "KeyUsesServerLayout",
+			mode: KeyboardInjectionKey,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "KeyY", Key: "z"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "KeyY", Key: "z"},
+			},
+			want: []keyWrite{press(evdev.KEY_Y), release(evdev.KEY_Y)},
+		},
+		{
+			name: "KeyAddsShift",
+			mode: KeyboardInjectionKey,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "Digit1", Key: "!"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "Digit1", Key: "!"},
+			},
+			want: []keyWrite{press(evdev.KEY_LEFTSHIFT), press(evdev.KEY_1), release(evdev.KEY_1), release(evdev.KEY_LEFTSHIFT)},
+		},
+		{
+			name: "KeyAddsAltGr",
+			mode: KeyboardInjectionKey,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "Digit2", Key: "@", Shift: true},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "Digit2", Key: "@", Shift: true},
+			},
+			want: []keyWrite{press(evdev.KEY_RIGHTALT), press(evdev.KEY_Q), release(evdev.KEY_Q), release(evdev.KEY_RIGHTALT)},
+		},
+		{
+			name: "KeyNamedKeyUsesCode",
+			mode: KeyboardInjectionKey,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "ShiftRight", Key: "Shift"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "ShiftRight", Key: "Shift"},
+			},
+			want: []keyWrite{press(evdev.KEY_RIGHTSHIFT), release(evdev.KEY_RIGHTSHIFT)},
+		},
+		{
+			name: "KeyComposed",
+			mode: KeyboardInjectionKey,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "BracketLeft", Key: "Dead"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "BracketLeft", Key: "Dead"},
+				{EventType: protocol.KeyboardEventTypeDown, Code: "KeyU", Key: "ü"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "KeyU", Key: "ü"},
+			},
+			want: []keyWrite{press(evdev.KEY_LEFTBRACE), release(evdev.KEY_LEFTBRACE)},
+		},
+		{
+			name: "KeyUnicodeFallback",
+			mode: KeyboardInjectionKey,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "Unidentified", Key: "ß"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "Unidentified", Key: "ß"},
+			},
+			want: []keyWrite{
+				press(evdev.KEY_LEFTCTRL), press(evdev.KEY_LEFTSHIFT), press(evdev.KEY_U),
+				release(evdev.KEY_U), release(evdev.KEY_LEFTSHIFT), release(evdev.KEY_LEFTCTRL),
+				press(evdev.KEY_D), release(evdev.KEY_D),
+				press(evdev.KEY_F), release(evdev.KEY_F),
+				press(evdev.KEY_SPACE), release(evdev.KEY_SPACE),
+			},
+		},
+		{
+			name: "UpWithoutDown",
+			mode: KeyboardInjectionCode,
+			events: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeUp, Code: "KeyA", Key: "a"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := new(fakeKeyWriter)
+			k := NewKeyboard(w, tt.mode, KeyLookupFunc(germanLookup))
+			for _, e := range tt.events {
+				if err := k.Inject(e); err != nil {
+					t.Fatalf("Inject(%v) error = %v", e, err)
+				}
+			}
+			if !reflect.DeepEqual(w.writes, tt.want) {
+				t.Errorf("writes = %v, want %v", w.writes, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyboard_InjectInvalid(t *testing.T) {
+	k := NewKeyboard(new(fakeKeyWriter), KeyboardInjectionCode, nil)
+	if err := k.Inject(protocol.KeyboardEvent{EventType: "sideways", Code: "KeyA"}); err == nil {
+		t.Error("Inject with invalid event type returned no error")
+	}
+	if err := k.Inject(protocol.KeyboardEvent{EventType: protocol.KeyboardEventTypeDown, Code: "Unidentified", Key: "VolumeUp"}); err == nil {
+		t.Error("Inject of unknown named key returned no error")
+	}
+}
+
+func TestKeyboard_Release(t *testing.T) {
+	w := new(fakeKeyWriter)
+	k := NewKeyboard(w, KeyboardInjectionCode, nil)
+	for _, code := range []string{"ShiftLeft", "KeyA"} {
+		if err := k.Inject(protocol.KeyboardEvent{EventType: protocol.KeyboardEventTypeDown, Code: code}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.writes = nil
+	if err := k.Release(); err != nil {
+		t.Fatal(err)
+	}
+	want := []keyWrite{release(evdev.KEY_A), release(evdev.KEY_LEFTSHIFT)}
+	if !reflect.DeepEqual(w.writes, want) {
+		t.Errorf("writes = %v, want %v", w.writes, want)
+	}
+	w.writes = nil
+	if err := k.Release(); err != nil || len(w.writes) != 0 {
+		t.Errorf("second Release wrote %v, error = %v", w.writes, err)
+	}
+}
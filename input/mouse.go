@@ -0,0 +1,237 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package input
+
+import (
+	"math"
+	"sync"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/holoplot/go-evdev"
+	"github.com/pkg/errors"
+)
+
+// wheelDetent is the high resolution wheel value of one wheel detent.
+const wheelDetent = 120
+
+// EventWriter emits a frame of events on an input device, followed by a sync event.
+type EventWriter interface {
+	WriteFrame(events ...evdev.InputEvent) error
+}
+
+// mouseButtons maps protocol buttons to evdev buttons.
+var mouseButtons = []struct {
+	button protocol.ButtonFlags
+	code   evdev.EvCode
+}{
+	{protocol.ButtonPrimary, evdev.BTN_LEFT},
+	{protocol.ButtonSecondary, evdev.BTN_RIGHT},
+	{protocol.ButtonAuxiliary, evdev.BTN_MIDDLE},
+	{protocol.ButtonFourth, evdev.BTN_SIDE},
+	{protocol.ButtonFifth, evdev.BTN_EXTRA},
+}
+
+// buttonEvents returns the events pressing and releasing the buttons that differ between held and buttons.
+func buttonEvents(held, buttons protocol.ButtonFlags) []evdev.InputEvent {
+	var events []evdev.InputEvent
+	for _, b := range mouseButtons {
+		switch {
+		case buttons&b.button != 0 && held&b.button == 0:
+			events = append(events, evdev.InputEvent{Type: evdev.EV_KEY, Code: b.code, Value: KeyPressed})
+		case buttons&b.button == 0 && held&b.button != 0:
+			events = append(events, evdev.InputEvent{Type: evdev.EV_KEY, Code: b.code, Value: KeyReleased})
+		}
+	}
+	return events
+}
+
+// wheel turns wheel events into high resolution wheel events. The detent axes are sent once the deltas add up to a
+// whole detent, for applications that do not read high resolution scrolling.
+type wheel struct {
+	x int32
+	y int32
+}
+
+// events returns the events scrolling by e.
+func (w *wheel) events(e protocol.WheelEvent) []evdev.InputEvent {
+	var events []evdev.InputEvent
+	// wheel events follow the browser, positive dy scrolls down, evdev scrolls down with negative values
+	if e.Dy != 0 {
+		events = append(events, evdev.InputEvent{Type: evdev.EV_REL, Code: evdev.REL_WHEEL_HI_RES, Value: -e.Dy})
+		w.y -= e.Dy
+	}
+	if e.Dx != 0 {
+		events = append(events, evdev.InputEvent{Type: evdev.EV_REL, Code: evdev.REL_HWHEEL_HI_RES, Value: e.Dx})
+		w.x += e.Dx
+	}
+	if detents := w.y / wheelDetent; detents != 0 {
+		events = append(events, evdev.InputEvent{Type: evdev.EV_REL, Code: evdev.REL_WHEEL, Value: detents})
+		w.y -= detents * wheelDetent
+	}
+	if detents := w.x / wheelDetent; detents != 0 {
+		events = append(events, evdev.InputEvent{Type: evdev.EV_REL, Code: evdev.REL_HWHEEL, Value: detents})
+		w.x -= detents * wheelDetent
+	}
+	return events
+}
+
+// RelativeMouse injects the relative motion and buttons of relative mouse input and wheel events into an EventWriter.
+// Every client has its own RelativeMouse, so a client only releases its own buttons, they can share the EventWriter.
+type RelativeMouse struct {
+	writer  EventWriter
+	mu      sync.Mutex
+	buttons protocol.ButtonFlags
+	wheel   wheel
+}
+
+// NewRelativeMouse creates a RelativeMouse writing to w.
+func NewRelativeMouse(w EventWriter) *RelativeMouse {
+	return &RelativeMouse{writer: w}
+}
+
+// Inject moves the mouse by the movement of a relative pointer event and presses or releases the buttons that
+// changed. A cancel releases all buttons.
+//
+//nolint:gocritic // PointerEvent might be heavy, but it should be like this
+func (m *RelativeMouse) Inject(e protocol.PointerEvent) error {
+	if !e.Relative {
+		return errors.New("pointer event is not relative")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buttons := e.Buttons
+	if e.EventType == protocol.PointerEventTypeCancel {
+		buttons = protocol.ButtonNone
+	}
+	var events []evdev.InputEvent
+	if e.MovementX != 0 {
+		events = append(events, evdev.InputEvent{Type: evdev.EV_REL, Code: evdev.REL_X, Value: int32(e.MovementX)})
+	}
+	if e.MovementY != 0 {
+		events = append(events, evdev.InputEvent{Type: evdev.EV_REL, Code: evdev.REL_Y, Value: int32(e.MovementY)})
+	}
+	events = append(events, buttonEvents(m.buttons, buttons)...)
+	if len(events) == 0 {
+		return nil
+	}
+	if err := m.writer.WriteFrame(events...); err != nil {
+		return errors.Wrap(err, "inject relative pointer event")
+	}
+	m.buttons = buttons
+	return nil
+}
+
+// Wheel scrolls by a wheel event.
+func (m *RelativeMouse) Wheel(e protocol.WheelEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := m.wheel.events(e)
+	if len(events) == 0 {
+		return nil
+	}
+	return errors.Wrap(m.writer.WriteFrame(events...), "inject wheel event")
+}
+
+// Release releases all held buttons, e.g. when the client disconnects.
+func (m *RelativeMouse) Release() error {
+	return m.Inject(protocol.PointerEvent{EventType: protocol.PointerEventTypeCancel, Relative: true})
+}
+
+// absMax is the maximum of the absolute axes of AbsolutePointer devices, positions in [0, 1] are scaled to it.
+const absMax = 1<<16 - 1
+
+// PointerAxes are the absolute axes of the devices AbsolutePointer writes to.
+var PointerAxes = map[evdev.EvCode]evdev.AbsInfo{
+	evdev.ABS_X: {Maximum: absMax},
+	evdev.ABS_Y: {Maximum: absMax},
+}
+
+// AbsolutePointer injects absolute pointer events and wheel events into an EventWriter with the PointerAxes, moving
+// the pointer to the position of the event on the screen. Like RelativeMouse, every client has its own.
+type AbsolutePointer struct {
+	writer  EventWriter
+	mu      sync.Mutex
+	buttons protocol.ButtonFlags
+	wheel   wheel
+}
+
+// NewAbsolutePointer creates an AbsolutePointer writing to w.
+func NewAbsolutePointer(w EventWriter) *AbsolutePointer {
+	return &AbsolutePointer{writer: w}
+}
+
+// Inject moves the pointer to the position of an absolute pointer event and presses or releases the buttons that
+// changed. Only the primary pointer moves it, the other contacts of multi-touch are ignored. A cancel releases all
+// buttons without moving the pointer.
+//
+//nolint:gocritic // PointerEvent might be heavy, but it should be like this
+func (p *AbsolutePointer) Inject(e protocol.PointerEvent) error {
+	if e.Relative {
+		return errors.New("pointer event is relative")
+	}
+	if !e.IsPrimary {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	buttons := e.Buttons
+	var events []evdev.InputEvent
+	if e.EventType == protocol.PointerEventTypeCancel {
+		buttons = protocol.ButtonNone
+	} else {
+		events = append(events,
+			evdev.InputEvent{Type: evdev.EV_ABS, Code: evdev.ABS_X, Value: absValue(e.X)},
+			evdev.InputEvent{Type: evdev.EV_ABS, Code: evdev.ABS_Y, Value: absValue(e.Y)},
+		)
+	}
+	events = append(events, buttonEvents(p.buttons, buttons)...)
+	if len(events) == 0 {
+		return nil
+	}
+	if err := p.writer.WriteFrame(events...); err != nil {
+		return errors.Wrap(err, "inject absolute pointer event")
+	}
+	p.buttons = buttons
+	return nil
+}
+
+// Wheel scrolls by a wheel event at the position of the pointer.
+func (p *AbsolutePointer) Wheel(e protocol.WheelEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	events := p.wheel.events(e)
+	if len(events) == 0 {
+		return nil
+	}
+	return errors.Wrap(p.writer.WriteFrame(events...), "inject wheel event")
+}
+
+// Release releases all held buttons, e.g. when the client disconnects.
+func (p *AbsolutePointer) Release() error {
+	return p.Inject(protocol.PointerEvent{EventType: protocol.PointerEventTypeCancel, IsPrimary: true})
+}
+
+// absValue scales a normalized position to the PointerAxes.
+func absValue(v float64) int32 {
+	return int32(math.Round(math.Max(0, math.Min(1, v)) * absMax))
+}
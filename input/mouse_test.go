@@ -0,0 +1,201 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package input
+
+import (
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/holoplot/go-evdev"
+)
+
+type fakeEventWriter struct {
+	frames [][]evdev.InputEvent
+}
+
+func (w *fakeEventWriter) WriteFrame(events ...evdev.InputEvent) error {
+	w.frames = append(w.frames, events)
+	return nil
+}
+
+func rel(code evdev.EvCode, value int32) evdev.InputEvent {
+	return evdev.InputEvent{Type: evdev.EV_REL, Code: code, Value: value}
+}
+
+func btn(code evdev.EvCode, value int32) evdev.InputEvent {
+	return evdev.InputEvent{Type: evdev.EV_KEY, Code: code, Value: value}
+}
+
+func TestRelativeMouse_Inject(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []protocol.PointerEvent
+		want   [][]evdev.InputEvent
+	}{
+		{
+			name: "Move",
+			events: []protocol.PointerEvent{
+				{EventType: protocol.PointerEventTypeMove, MovementX: 5, MovementY: -3, Relative: true},
+				{EventType: protocol.PointerEventTypeMove, MovementX: 2, Relative: true},
+				{EventType: protocol.PointerEventTypeMove, Relative: true},
+			},
+			want: [][]evdev.InputEvent{
+				{rel(evdev.REL_X, 5), rel(evdev.REL_Y, -3)},
+				{rel(evdev.REL_X, 2)},
+			},
+		},
+		{
+			name: "Buttons",
+			events: []protocol.PointerEvent{
+				{EventType: protocol.PointerEventTypeDown, Button: protocol.ButtonPrimary, Buttons: protocol.ButtonPrimary, Relative: true},
+				{EventType: protocol.PointerEventTypeMove, MovementY: 4, Buttons: protocol.ButtonPrimary, Relative: true},
+				{EventType: protocol.PointerEventTypeDown, Button: protocol.ButtonFourth, Buttons: protocol.ButtonPrimary | protocol.ButtonFourth, Relative: true},
+				{EventType: protocol.PointerEventTypeUp, Button: protocol.ButtonPrimary, Buttons: protocol.ButtonFourth, Relative: true},
+			},
+			want: [][]evdev.InputEvent{
+				{btn(evdev.BTN_LEFT, KeyPressed)},
+				{rel(evdev.REL_Y, 4)},
+				{btn(evdev.BTN_SIDE, KeyPressed)},
+				{btn(evdev.BTN_LEFT, KeyReleased)},
+			},
+		},
+		{
+			name: "Cancel",
+			events: []protocol.PointerEvent{
+				{EventType: protocol.PointerEventTypeDown, Buttons: protocol.ButtonSecondary | protocol.ButtonAuxiliary, Relative: true},
+				{EventType: protocol.PointerEventTypeCancel, Buttons: protocol.ButtonSecondary, Relative: true},
+			},
+			want: [][]evdev.InputEvent{
+				{btn(evdev.BTN_RIGHT, KeyPressed), btn(evdev.BTN_MIDDLE, KeyPressed)},
+				{btn(evdev.BTN_RIGHT, KeyReleased), btn(evdev.BTN_MIDDLE, KeyReleased)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := new(fakeEventWriter)
+			mouse := NewRelativeMouse(w)
+			for _, e := range tt.events {
+				if err := mouse.Inject(e); err != nil {
+					t.Fatalf("Inject() error = %v", err)
+				}
+			}
+			assertFrames(t, w.frames, tt.want)
+		})
+	}
+}
+
+func TestRelativeMouse_InjectAbsolute(t *testing.T) {
+	mouse := NewRelativeMouse(new(fakeEventWriter))
+	if err := mouse.Inject(protocol.PointerEvent{EventType: protocol.PointerEventTypeMove, X: 0.5, Y: 0.5}); err == nil {
+		t.Error("Inject with absolute event returned no error")
+	}
+}
+
+func TestRelativeMouse_Release(t *testing.T) {
+	w := new(fakeEventWriter)
+	mouse := NewRelativeMouse(w)
+	if err := mouse.Inject(protocol.PointerEvent{EventType: protocol.PointerEventTypeDown, Buttons: protocol.ButtonPrimary, Relative: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mouse.Release(); err != nil {
+		t.Fatal(err)
+	}
+	assertFrames(t, w.frames, [][]evdev.InputEvent{
+		{btn(evdev.BTN_LEFT, KeyPressed)},
+		{btn(evdev.BTN_LEFT, KeyReleased)},
+	})
+}
+
+func TestRelativeMouse_Wheel(t *testing.T) {
+	w := new(fakeEventWriter)
+	mouse := NewRelativeMouse(w)
+	for _, e := range []protocol.WheelEvent{{Dy: 60}, {Dy: 60}, {Dx: -240}, {}} {
+		if err := mouse.Wheel(e); err != nil {
+			t.Fatalf("Wheel() error = %v", err)
+		}
+	}
+	assertFrames(t, w.frames, [][]evdev.InputEvent{
+		{rel(evdev.REL_WHEEL_HI_RES, -60)},
+		{rel(evdev.REL_WHEEL_HI_RES, -60), rel(evdev.REL_WHEEL, -1)},
+		{rel(evdev.REL_HWHEEL_HI_RES, -240), rel(evdev.REL_HWHEEL, -2)},
+	})
+}
+
+func TestAbsolutePointer_Inject(t *testing.T) {
+	w := new(fakeEventWriter)
+	pointer := NewAbsolutePointer(w)
+	for _, e := range []protocol.PointerEvent{
+		{EventType: protocol.PointerEventTypeMove, X: 0.5, Y: 0.25, IsPrimary: true},
+		{EventType: protocol.PointerEventTypeDown, X: 1.5, Y: -1, Buttons: protocol.ButtonPrimary, IsPrimary: true},
+		// a second touch contact
+		{EventType: protocol.PointerEventTypeDown, X: 0.1, Y: 0.1, Buttons: protocol.ButtonPrimary},
+		{EventType: protocol.PointerEventTypeCancel, X: 0, Y: 0, IsPrimary: true},
+	} {
+		if err := pointer.Inject(e); err != nil {
+			t.Fatalf("Inject() error = %v", err)
+		}
+	}
+	assertFrames(t, w.frames, [][]evdev.InputEvent{
+		{abs(evdev.ABS_X, 32768), abs(evdev.ABS_Y, 16384)},
+		{abs(evdev.ABS_X, absMax), abs(evdev.ABS_Y, 0), btn(evdev.BTN_LEFT, KeyPressed)},
+		{btn(evdev.BTN_LEFT, KeyReleased)},
+	})
+
+	if err := pointer.Inject(protocol.PointerEvent{Relative: true, IsPrimary: true}); err == nil {
+		t.Error("Inject with relative event returned no error")
+	}
+}
+
+func TestAbsolutePointer_Release(t *testing.T) {
+	w := new(fakeEventWriter)
+	pointer := NewAbsolutePointer(w)
+	if err := pointer.Inject(protocol.PointerEvent{EventType: protocol.PointerEventTypeDown, Buttons: protocol.ButtonSecondary, IsPrimary: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pointer.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pointer.Wheel(protocol.WheelEvent{Dy: -120}); err != nil {
+		t.Fatal(err)
+	}
+	assertFrames(t, w.frames, [][]evdev.InputEvent{
+		{abs(evdev.ABS_X, 0), abs(evdev.ABS_Y, 0), btn(evdev.BTN_RIGHT, KeyPressed)},
+		{btn(evdev.BTN_RIGHT, KeyReleased)},
+		{rel(evdev.REL_WHEEL_HI_RES, 120), rel(evdev.REL_WHEEL, 1)},
+	})
+}
+
+func assertFrames(t *testing.T, got, want [][]evdev.InputEvent) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Errorf("frame %d = %v, want %v", i, got[i], want[i])
+			continue
+		}
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				t.Errorf("frame %d event %d = %v, want %v", i, j, got[i][j], want[i][j])
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package input
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/holoplot/go-evdev"
+	"github.com/pkg/errors"
+)
+
+// uinput ioctls from linux/uinput.h, go-evdev doesn't export them.
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+	uiSetAbsBit  = 0x40045567
+)
+
+// uinputAbsDevice is a uinput device with absolute axes.
+// evdev.CreateDevice can't set the ranges of absolute axes, they would all be 0..0.
+type uinputAbsDevice struct {
+	file *os.File
+	// mu keeps frames of clients sharing the device apart
+	mu sync.Mutex
+}
+
+// createUinputAbsDevice creates a uinput device with the keys, the relative axes rel and the absolute axes with
+// their ranges.
+func createUinputAbsDevice(name string, id evdev.InputID, keys, rel []evdev.EvCode, abs map[evdev.EvCode]evdev.AbsInfo) (*uinputAbsDevice, error) {
+	file, err := os.OpenFile("/dev/uinput", syscall.O_WRONLY|syscall.O_NONBLOCK, 0o660)
+	if err != nil {
+		return nil, errors.Wrap(err, "open uinput")
+	}
+	dev := &uinputAbsDevice{file: file}
+	if err := dev.setup(name, id, keys, rel, abs); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return dev, nil
+}
+
+func (d *uinputAbsDevice) setup(name string, id evdev.InputID, keys, rel []evdev.EvCode, abs map[evdev.EvCode]evdev.AbsInfo) error {
+	if err := d.ioctl(uiSetEvBit, uintptr(evdev.EV_KEY)); err != nil {
+		return errors.Wrap(err, "set key event bit")
+	}
+	for _, code := range keys {
+		if err := d.ioctl(uiSetKeyBit, uintptr(code)); err != nil {
+			return errors.Wrapf(err, "set key bit %s", evdev.CodeName(evdev.EV_KEY, code))
+		}
+	}
+	if len(rel) > 0 {
+		if err := d.ioctl(uiSetEvBit, uintptr(evdev.EV_REL)); err != nil {
+			return errors.Wrap(err, "set rel event bit")
+		}
+	}
+	for _, code := range rel {
+		if err := d.ioctl(uiSetRelBit, uintptr(code)); err != nil {
+			return errors.Wrapf(err, "set rel bit %s", evdev.CodeName(evdev.EV_REL, code))
+		}
+	}
+	if err := d.ioctl(uiSetEvBit, uintptr(evdev.EV_ABS)); err != nil {
+		return errors.Wrap(err, "set abs event bit")
+	}
+
+	user := evdev.UinputUserDevice{ID: id}
+	copy(user.Name[:len(user.Name)-1], name)
+	codes := make([]evdev.EvCode, 0, len(abs))
+	for code := range abs {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	for _, code := range codes {
+		if code > evdev.ABS_MAX {
+			return errors.Errorf("invalid abs axis %d", code)
+		}
+		if err := d.ioctl(uiSetAbsBit, uintptr(code)); err != nil {
+			return errors.Wrapf(err, "set abs bit %s", evdev.CodeName(evdev.EV_ABS, code))
+		}
+		info := abs[code]
+		user.Absmin[code] = info.Minimum
+		user.Absmax[code] = info.Maximum
+		user.Absfuzz[code] = info.Fuzz
+		user.Absflat[code] = info.Flat
+	}
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, user); err != nil {
+		return errors.Wrap(err, "encode uinput device")
+	}
+	if _, err := d.file.Write(buf.Bytes()); err != nil {
+		return errors.Wrap(err, "write uinput device")
+	}
+	return errors.Wrap(d.ioctl(uiDevCreate, 0), "create uinput device")
+}
+
+func (d *uinputAbsDevice) ioctl(request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, d.file.Fd(), request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// WriteFrame implements EventWriter.
+func (d *uinputAbsDevice) WriteFrame(events ...evdev.InputEvent) error {
+	events = append(events, evdev.InputEvent{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT})
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, events); err != nil {
+		return errors.Wrap(err, "encode events")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.file.Write(buf.Bytes())
+	return errors.Wrap(err, "write events")
+}
+
+// Close removes the device.
+func (d *uinputAbsDevice) Close() error {
+	destroyErr := d.ioctl(uiDevDestroy, 0)
+	if err := d.file.Close(); err != nil {
+		return errors.Wrap(err, "close uinput")
+	}
+	return errors.Wrap(destroyErr, "destroy uinput device")
+}
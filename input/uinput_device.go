@@ -20,8 +20,10 @@ package input
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/holoplot/go-evdev"
+	"github.com/pkg/errors"
 )
 
 // TODO extend and switch to https://github.com/holoplot/go-evdev, doesnt support setting properties yet, i like the api more than this
@@ -60,3 +62,173 @@ func createStylus() {
 		return
 	}
 }
+
+// UInputKeyboard is a virtual keyboard created through uinput.
+type UInputKeyboard struct {
+	dev *evdev.InputDevice
+	// mu keeps the key events of clients sharing the keyboard apart
+	mu sync.Mutex
+}
+
+// NewUInputKeyboard creates a virtual keyboard supporting all standard keys.
+func NewUInputKeyboard(name string) (*UInputKeyboard, error) {
+	keys := make([]evdev.EvCode, 0, evdev.KEY_MICMUTE)
+	for code := evdev.EvCode(evdev.KEY_ESC); code <= evdev.KEY_MICMUTE; code++ {
+		keys = append(keys, code)
+	}
+	dev, err := evdev.CreateDevice(
+		name,
+		evdev.InputID{
+			BusType: 0x06,
+			Vendor:  0x4711,
+			Product: 0x0817,
+			Version: 1,
+		},
+		map[evdev.EvType][]evdev.EvCode{
+			evdev.EV_KEY: keys,
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create uinput keyboard")
+	}
+	return &UInputKeyboard{dev: dev}, nil
+}
+
+// WriteKey implements KeyWriter.
+func (k *UInputKeyboard) WriteKey(code evdev.EvCode, value int32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.dev.WriteOne(&evdev.InputEvent{Type: evdev.EV_KEY, Code: code, Value: value}); err != nil {
+		return errors.Wrap(err, "write key event")
+	}
+	if err := k.dev.WriteOne(&evdev.InputEvent{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT}); err != nil {
+		return errors.Wrap(err, "write sync event")
+	}
+	return nil
+}
+
+// Close removes the virtual keyboard.
+func (k *UInputKeyboard) Close() error {
+	return evdev.DestroyDevice(k.dev)
+}
+
+// UInputMouse is a virtual mouse with relative axes created through uinput.
+type UInputMouse struct {
+	dev *evdev.InputDevice
+	// mu keeps frames of clients sharing the mouse apart
+	mu sync.Mutex
+}
+
+// NewUInputMouse creates a virtual mouse with five buttons, relative motion and high resolution wheels.
+func NewUInputMouse(name string) (*UInputMouse, error) {
+	dev, err := evdev.CreateDevice(
+		name,
+		evdev.InputID{
+			BusType: 0x03,
+			Vendor:  0x4711,
+			Product: 0x0818,
+			Version: 1,
+		},
+		map[evdev.EvType][]evdev.EvCode{
+			evdev.EV_KEY: {
+				evdev.BTN_LEFT,
+				evdev.BTN_RIGHT,
+				evdev.BTN_MIDDLE,
+				evdev.BTN_SIDE,
+				evdev.BTN_EXTRA,
+			},
+			evdev.EV_REL: {
+				evdev.REL_X,
+				evdev.REL_Y,
+				evdev.REL_WHEEL,
+				evdev.REL_HWHEEL,
+				evdev.REL_WHEEL_HI_RES,
+				evdev.REL_HWHEEL_HI_RES,
+			},
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create uinput mouse")
+	}
+	return &UInputMouse{dev: dev}, nil
+}
+
+// WriteFrame implements EventWriter.
+func (m *UInputMouse) WriteFrame(events ...evdev.InputEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range events {
+		if err := m.dev.WriteOne(&events[i]); err != nil {
+			return errors.Wrap(err, "write mouse event")
+		}
+	}
+	if err := m.dev.WriteOne(&evdev.InputEvent{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT}); err != nil {
+		return errors.Wrap(err, "write sync event")
+	}
+	return nil
+}
+
+// Close removes the virtual mouse.
+func (m *UInputMouse) Close() error {
+	return evdev.DestroyDevice(m.dev)
+}
+
+// UInputPointer is a virtual pointing device with absolute axes created through uinput, like the tablets of virtual
+// machines. The pointer jumps to the position written, anywhere on the screen.
+type UInputPointer struct {
+	*uinputAbsDevice
+}
+
+// NewUInputPointer creates a virtual absolute pointer with the PointerAxes, five buttons and high resolution wheels.
+func NewUInputPointer(name string) (*UInputPointer, error) {
+	keys := make([]evdev.EvCode, 0, len(mouseButtons))
+	for _, b := range mouseButtons {
+		keys = append(keys, b.code)
+	}
+	dev, err := createUinputAbsDevice(
+		name,
+		evdev.InputID{
+			BusType: 0x03,
+			Vendor:  0x4711,
+			Product: 0x0819,
+			Version: 1,
+		},
+		keys,
+		[]evdev.EvCode{evdev.REL_WHEEL, evdev.REL_HWHEEL, evdev.REL_WHEEL_HI_RES, evdev.REL_HWHEEL_HI_RES},
+		PointerAxes,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create uinput pointer")
+	}
+	return &UInputPointer{dev}, nil
+}
+
+// UInputGamepad is a virtual Xbox 360 controller created through uinput.
+type UInputGamepad struct {
+	*uinputAbsDevice
+}
+
+// NewUInputGamepad creates a virtual Xbox 360 controller, with the ids of the real one so games pick their
+// controller mappings for it.
+func NewUInputGamepad(name string) (*UInputGamepad, error) {
+	keys := make([]evdev.EvCode, 0, len(gamepadButtons))
+	for _, b := range gamepadButtons {
+		keys = append(keys, b.code)
+	}
+	dev, err := createUinputAbsDevice(
+		name,
+		evdev.InputID{
+			BusType: 0x03,
+			Vendor:  0x045e,
+			Product: 0x028e,
+			Version: 0x0110,
+		},
+		keys,
+		nil,
+		defaultAbsInfos,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create uinput gamepad")
+	}
+	return &UInputGamepad{dev}, nil
+}
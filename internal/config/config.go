@@ -0,0 +1,82 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package config locates, documents and validates the config file of
+// weylus-desktop.
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Name names the config directory and the dotfile in the home directory.
+	Name = "weylus-desktop"
+	// Type is the format of config files.
+	Type = "yaml"
+)
+
+// Paths returns the config files searched, in order: config.yaml in the
+// weylus-desktop directory of the XDG config directory, then the
+// .weylus-desktop.yaml dotfile in the home directory.
+func Paths() ([]string, error) {
+	var paths []string
+	dir, dirErr := os.UserConfigDir()
+	if dirErr == nil {
+		paths = append(paths, filepath.Join(dir, Name, "config."+Type))
+	}
+	home, homeErr := os.UserHomeDir()
+	if homeErr == nil {
+		paths = append(paths, filepath.Join(home, "."+Name+"."+Type))
+	}
+	if len(paths) == 0 {
+		return nil, errors.Wrap(dirErr, "find config directory")
+	}
+	return paths, nil
+}
+
+// Find returns the first existing config file of Paths, or an empty string if
+// there is none.
+func Find() (string, error) {
+	paths, err := Paths()
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return "", errors.Wrap(err, "stat config file")
+		}
+	}
+	return "", nil
+}
+
+// DefaultPath returns where new config files are created, the first of Paths.
+func DefaultPath() (string, error) {
+	paths, err := Paths()
+	if err != nil {
+		return "", err
+	}
+	return paths[0], nil
+}
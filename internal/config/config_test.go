@@ -0,0 +1,203 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestPaths(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/config")
+	t.Setenv("HOME", "/home/user")
+	paths, err := Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/config/weylus-desktop/config.yaml", "/home/user/.weylus-desktop.yaml"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("Paths() = %v, want %v", paths, want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("HOME", dir)
+
+	if path, err := Find(); err != nil || path != "" {
+		t.Fatalf("Find() = %q, %v, want no file", path, err)
+	}
+	dotfile := filepath.Join(dir, ".weylus-desktop.yaml")
+	if err := os.WriteFile(dotfile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := Find(); err != nil || path != dotfile {
+		t.Fatalf("Find() = %q, %v, want %q", path, err, dotfile)
+	}
+	xdg, err := DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(xdg), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(xdg, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := Find(); err != nil || path != xdg {
+		t.Fatalf("Find() = %q, %v, want %q", path, err, xdg)
+	}
+}
+
+func testCommands(t *testing.T) *cobra.Command {
+	root := &cobra.Command{Use: "root"}
+	root.PersistentFlags().String("config", "", "config file")
+	root.PersistentFlags().String("log-level", "info", "log level")
+	if err := MarkNoSetting(root.PersistentFlags(), "config"); err != nil {
+		t.Fatal(err)
+	}
+	client := &cobra.Command{Use: "client", Run: func(*cobra.Command, []string) {}}
+	client.Flags().Uint16("websocket-port", 9001, "Websocket port")
+	client.Flags().Bool("screenshot", false, "Take a screenshot")
+	if err := MarkNoSetting(client.Flags(), "screenshot"); err != nil {
+		t.Fatal(err)
+	}
+	server := &cobra.Command{Use: "server", Run: func(*cobra.Command, []string) {}}
+	server.Flags().Uint16("websocket-port", 9001, "Websocket port")
+	server.Flags().StringSlice("custom", nil, "custom files")
+	root.AddCommand(client, server)
+	return root
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema(testCommands(t), Setting{Key: "profiles", Type: "map", Commands: []string{"client"}})
+	var keys []string
+	for _, s := range schema {
+		keys = append(keys, s.Key+"="+strings.Join(s.Commands, "+"))
+	}
+	want := "custom=server,log-level=,profiles=client,websocket-port=client+server"
+	if got := strings.Join(keys, ","); got != want {
+		t.Errorf("Schema() = %s, want %s", got, want)
+	}
+}
+
+func TestWriteDefault(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDefault(&buf, Schema(testCommands(t))); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"#custom: []\n",
+		"#log-level: \"info\"\n",
+		"# Type: uint16, used by: client, server\n#websocket-port: 9001\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteDefault() = %s, missing %q", buf.String(), want)
+		}
+	}
+
+	// the commented file must be valid YAML setting nothing
+	v := viper.New()
+	v.SetConfigType(Type)
+	if err := v.ReadConfig(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if keys := v.AllKeys(); len(keys) != 0 {
+		t.Errorf("WriteDefault() sets %v", keys)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	css := filepath.Join(dir, "style.css")
+	txt := filepath.Join(dir, "style.txt")
+	for _, path := range []string{css, txt} {
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rules := map[string]Rule{
+		"port":    Port(),
+		"fps":     IntRange(1, 240),
+		"latency": MinDuration(0),
+		"style":   File(".css"),
+		"unset":   Port(),
+	}
+	var tests = []struct {
+		name     string
+		settings map[string]any
+		want     []string
+	}{
+		{"valid", map[string]any{"port": 1701, "fps": "60", "latency": "50ms", "style": css}, nil},
+		{"empty file", map[string]any{"style": ""}, nil},
+		{"port", map[string]any{"port": 70000}, []string{"port"}},
+		{"not a number", map[string]any{"fps": "fast"}, []string{"fps"}},
+		{"negative duration", map[string]any{"latency": -time.Second}, []string{"latency"}},
+		{"missing file", map[string]any{"style": filepath.Join(dir, "missing.css")}, []string{"style"}},
+		{"directory", map[string]any{"style": dir}, []string{"style"}},
+		{"extension", map[string]any{"style": txt}, []string{"style"}},
+		{"sorted", map[string]any{"port": 0, "fps": 0}, []string{"fps", "port"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			for key, value := range tt.settings {
+				v.Set(key, value)
+			}
+			err := Validate(v, rules)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			errs, ok := err.(Errors)
+			if !ok {
+				t.Fatalf("Validate() = %v, want Errors", err)
+			}
+			var keys []string
+			for _, e := range errs {
+				keys = append(keys, e.Key)
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Validate() = %v, want errors for %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType(Type)
+	if err := v.ReadConfig(strings.NewReader("log-level: debug\nwebsocket-prot: 9001\nprofiles:\n  default:\n    enabled: true\n")); err != nil {
+		t.Fatal(err)
+	}
+	unknown := Unknown(v, Schema(testCommands(t), Setting{Key: "profiles"}))
+	if strings.Join(unknown, ",") != "websocket-prot" {
+		t.Errorf("Unknown() = %v, want [websocket-prot]", unknown)
+	}
+}
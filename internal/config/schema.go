@@ -0,0 +1,142 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// noSettingAnnotation marks flags that aren't settings of the config file.
+const noSettingAnnotation = "weylus_no_setting"
+
+// MarkNoSetting excludes flags triggering one-off actions, like printing a
+// file, from the schema.
+func MarkNoSetting(flags *pflag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if err := flags.SetAnnotation(name, noSettingAnnotation, []string{"true"}); err != nil {
+			return errors.Wrapf(err, "mark flag %s", name)
+		}
+	}
+	return nil
+}
+
+// Setting documents a setting of the config file.
+type Setting struct {
+	Key string
+	// Type is the type of the value, as named by pflag, e.g. string, uint16 or duration.
+	Type    string
+	Default string
+	Usage   string
+	// Commands are the commands using the setting, empty if all do.
+	Commands []string
+}
+
+// Schema returns the settings of the flags of root and its subcommands and
+// extra, sorted by key. Settings sharing a key are merged.
+func Schema(root *cobra.Command, extra ...Setting) []Setting {
+	settings := make(map[string]*Setting)
+	add := func(s Setting) {
+		if prev, ok := settings[s.Key]; ok {
+			prev.Commands = append(prev.Commands, s.Commands...)
+			return
+		}
+		settings[s.Key] = &s
+	}
+	root.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if isSetting(f) {
+			add(flagSetting(f, nil))
+		}
+	})
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		for _, sub := range cmd.Commands() {
+			// cobra adds these, their flags aren't settings either
+			if sub.Name() == "completion" || sub.Name() == "help" {
+				continue
+			}
+			sub.NonInheritedFlags().VisitAll(func(f *pflag.Flag) {
+				if isSetting(f) {
+					add(flagSetting(f, []string{sub.Name()}))
+				}
+			})
+			walk(sub)
+		}
+	}
+	walk(root)
+	for _, s := range extra {
+		add(s)
+	}
+
+	schema := make([]Setting, 0, len(settings))
+	for _, s := range settings {
+		sort.Strings(s.Commands)
+		s.Commands = lo.Uniq(s.Commands)
+		schema = append(schema, *s)
+	}
+	sort.Slice(schema, func(i, j int) bool { return schema[i].Key < schema[j].Key })
+	return schema
+}
+
+func isSetting(f *pflag.Flag) bool {
+	return f.Name != "help" && f.Annotations[noSettingAnnotation] == nil
+}
+
+func flagSetting(f *pflag.Flag, commands []string) Setting {
+	return Setting{Key: f.Name, Type: f.Value.Type(), Default: f.DefValue, Usage: f.Usage, Commands: commands}
+}
+
+// yamlDefault returns the default of s as YAML value.
+func (s Setting) yamlDefault() string {
+	switch {
+	case s.Type == "string" || s.Type == "ip":
+		return strconv.Quote(s.Default)
+	case strings.HasSuffix(s.Type, "Slice"):
+		return "[]"
+	case strings.HasPrefix(s.Type, "stringTo") || s.Type == "map":
+		return "{}"
+	}
+	return s.Default
+}
+
+// WriteDefault writes a config file documenting every setting of schema. The
+// settings are commented out, so the file doesn't change any defaults.
+func WriteDefault(w io.Writer, schema []Setting) error {
+	if _, err := fmt.Fprintf(w, "# %s config file, uncomment settings to change them.\n", Name); err != nil {
+		return errors.Wrap(err, "write config")
+	}
+	for _, s := range schema {
+		used := "all commands"
+		if len(s.Commands) > 0 {
+			used = strings.Join(s.Commands, ", ")
+		}
+		if _, err := fmt.Fprintf(w, "\n# %s\n# Type: %s, used by: %s\n#%s: %s\n", s.Usage, s.Type, used, s.Key, s.yamlDefault()); err != nil {
+			return errors.Wrap(err, "write config")
+		}
+	}
+	return nil
+}
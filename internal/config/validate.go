@@ -0,0 +1,167 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Rule checks the value of the setting key in v.
+type Rule func(v *viper.Viper, key string) error
+
+// Error is a setting with an invalid value.
+type Error struct {
+	Key string
+	Err error
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors are all invalid settings found, one per line.
+type Errors []Error
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the settings set in v with their rules, sorted by key.
+// It returns Errors, or nil if all settings are valid.
+func Validate(v *viper.Viper, rules map[string]Rule) error {
+	var errs Errors
+	for key, rule := range rules {
+		if !v.IsSet(key) {
+			continue
+		}
+		if err := rule(v, key); err != nil {
+			errs = append(errs, Error{Key: key, Err: err})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Key < errs[j].Key })
+	return errs
+}
+
+// Unknown returns the keys set in v that aren't in schema, e.g. misspelled ones.
+func Unknown(v *viper.Viper, schema []Setting) []string {
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		known := false
+		for _, s := range schema {
+			if key == s.Key || strings.HasPrefix(key, s.Key+".") {
+				known = true
+				break
+			}
+		}
+		if !known {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// IntRange accepts integers from min to max.
+func IntRange(min, max int) Rule {
+	return func(v *viper.Viper, key string) error {
+		i, err := cast.ToIntE(v.Get(key))
+		if err != nil {
+			return errors.Errorf("%v is not a number", v.Get(key))
+		}
+		if i < min || i > max {
+			return errors.Errorf("%d is out of range %d-%d", i, min, max)
+		}
+		return nil
+	}
+}
+
+// Port accepts TCP ports.
+func Port() Rule {
+	return IntRange(1, 65535)
+}
+
+// MinDuration accepts durations of at least min.
+func MinDuration(min time.Duration) Rule {
+	return func(v *viper.Viper, key string) error {
+		d, err := cast.ToDurationE(v.Get(key))
+		if err != nil {
+			return errors.Errorf("%v is not a duration", v.Get(key))
+		}
+		if d < min {
+			return errors.Errorf("%v is shorter than %v", d, min)
+		}
+		return nil
+	}
+}
+
+// File accepts paths of existing files with one of the extensions exts, or
+// any extension if exts is empty. An empty path is accepted.
+func File(exts ...string) Rule {
+	return func(v *viper.Viper, key string) error {
+		path := v.GetString(key)
+		if path == "" {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return errors.Wrap(err, "stat file")
+		}
+		if info.IsDir() {
+			return errors.Errorf("%s is a directory", path)
+		}
+		if len(exts) == 0 {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		for _, e := range exts {
+			if ext == e {
+				return nil
+			}
+		}
+		return errors.Errorf("%s doesn't have one of the extensions %s", path, strings.Join(exts, ", "))
+	}
+}
+
+// Parse accepts strings parse accepts, like the names of an enum.
+func Parse[T any](parse func(string) (T, error)) Rule {
+	return func(v *viper.Viper, key string) error {
+		_, err := parse(v.GetString(key))
+		return err
+	}
+}
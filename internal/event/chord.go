@@ -0,0 +1,111 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/pkg/errors"
+)
+
+// Chord is a key combination like "<Control><Alt>Escape", in the format of gtk.AcceleratorParse.
+type Chord struct {
+	KeyVal uint
+	Mods   gdk.ModifierType
+}
+
+// ParseChord parses an accelerator string into a Chord.
+func ParseChord(accelerator string) (Chord, error) {
+	keyVal, mods, ok := gtk.AcceleratorParse(accelerator)
+	if !ok || keyVal == 0 {
+		return Chord{}, errors.Errorf("invalid key chord %q", accelerator)
+	}
+	return Chord{KeyVal: keyVal, Mods: mods}, nil
+}
+
+// Matches reports whether a key press with the given modifier state is the chord.
+// Modifiers outside gtk.AcceleratorGetDefaultModMask, like Num Lock, are ignored.
+func (c Chord) Matches(keyVal uint, state gdk.ModifierType) bool {
+	return gdk.KeyvalToLower(keyVal) == gdk.KeyvalToLower(c.KeyVal) &&
+		state&gtk.AcceleratorGetDefaultModMask() == c.Mods
+}
+
+// Label returns the chord as shown to the user, e.g. "Ctrl+Alt+Esc".
+func (c Chord) Label() string {
+	return gtk.AcceleratorGetLabel(c.KeyVal, c.Mods)
+}
+
+// chordModifiers are the modifiers a chord can hold, with the key pressed for each.
+var chordModifiers = []struct {
+	mask   gdk.ModifierType
+	keyVal uint
+}{
+	{gdk.ControlMask, gdk.KEY_Control_L},
+	{gdk.ShiftMask, gdk.KEY_Shift_L},
+	{gdk.AltMask, gdk.KEY_Alt_L},
+	{gdk.SuperMask, gdk.KEY_Super_L},
+	{gdk.MetaMask, gdk.KEY_Meta_L},
+}
+
+// KeyEvents returns the key events typing the chord: the modifiers are pressed, the key is pressed and released,
+// then the modifiers are released in reverse order. keycode returns the hardware keycode of a keyval.
+func (c Chord) KeyEvents(keycode func(keyVal uint) uint) []protocol.KeyboardEvent {
+	translator := NewKeyTranslator()
+	var state gdk.ModifierType
+	var events []protocol.KeyboardEvent
+	var held []int
+	for i, modifier := range chordModifiers {
+		if !c.Mods.Has(modifier.mask) {
+			continue
+		}
+		// GDK reports the modifier state from before the event
+		events = append(events, translator.Press(modifier.keyVal, keycode(modifier.keyVal), state))
+		state |= modifier.mask
+		held = append(held, i)
+	}
+	events = append(events,
+		translator.Press(c.KeyVal, keycode(c.KeyVal), state),
+		translator.Release(c.KeyVal, keycode(c.KeyVal), state),
+	)
+	for i := len(held) - 1; i >= 0; i-- {
+		modifier := chordModifiers[held[i]]
+		events = append(events, translator.Release(modifier.keyVal, keycode(modifier.keyVal), state))
+		state &^= modifier.mask
+	}
+	return events
+}
+
+// DisplayKeycode returns a function looking up the hardware keycode of a keyval in the keymap of a display,
+// preferring the key of the first group and level. Unknown keyvals have keycode 0.
+func DisplayKeycode(display *gdk.Display) func(keyVal uint) uint {
+	return func(keyVal uint) uint {
+		keys, ok := display.MapKeyval(keyVal)
+		if !ok || len(keys) == 0 {
+			return 0
+		}
+		best := keys[0]
+		for _, key := range keys[1:] {
+			if key.Group() < best.Group() || key.Group() == best.Group() && key.Level() < best.Level() {
+				best = key
+			}
+		}
+		return best.Keycode()
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+func TestParseChord(t *testing.T) {
+	chord, err := ParseChord("<Control><Alt>Escape")
+	if err != nil {
+		t.Fatalf("ParseChord() error = %v", err)
+	}
+	if chord.KeyVal != gdk.KEY_Escape || chord.Mods != gdk.ControlMask|gdk.AltMask {
+		t.Errorf("ParseChord() = %+v", chord)
+	}
+	for _, invalid := range []string{"", "<Control>", "<Control><Alt>NoSuchKey"} {
+		if _, err := ParseChord(invalid); err == nil {
+			t.Errorf("ParseChord(%q) returned no error", invalid)
+		}
+	}
+}
+
+func TestChord_Matches(t *testing.T) {
+	chord := Chord{KeyVal: gdk.KEY_Escape, Mods: gdk.ControlMask | gdk.AltMask}
+	tests := []struct {
+		name   string
+		keyVal uint
+		state  gdk.ModifierType
+		want   bool
+	}{
+		{"Exact", gdk.KEY_Escape, gdk.ControlMask | gdk.AltMask, true},
+		{"IgnoresLockMask", gdk.KEY_Escape, gdk.ControlMask | gdk.AltMask | gdk.LockMask, true},
+		{"MissingModifier", gdk.KEY_Escape, gdk.ControlMask, false},
+		{"ExtraModifier", gdk.KEY_Escape, gdk.ControlMask | gdk.AltMask | gdk.ShiftMask, false},
+		{"OtherKey", gdk.KEY_Tab, gdk.ControlMask | gdk.AltMask, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chord.Matches(tt.keyVal, tt.state); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	letter := Chord{KeyVal: gdk.KEY_k, Mods: gdk.SuperMask}
+	if !letter.Matches(gdk.KEY_K, gdk.SuperMask) {
+		t.Error("Matches() is case sensitive")
+	}
+}
+
+// usKeycode maps the keyvals used in the tests to their keycodes on a US layout.
+func usKeycode(keyVal uint) uint {
+	return map[uint]uint{
+		gdk.KEY_Control_L: 0x25,
+		gdk.KEY_Shift_L:   0x32,
+		gdk.KEY_Alt_L:     0x40,
+		gdk.KEY_plus:      0x15,
+		gdk.KEY_Left:      0x71,
+	}[keyVal]
+}
+
+func TestChord_KeyEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		chord Chord
+		want  []protocol.KeyboardEvent
+	}{
+		{
+			name:  "Key",
+			chord: Chord{KeyVal: gdk.KEY_Left},
+			want: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "ArrowLeft", Key: "ArrowLeft"},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "ArrowLeft", Key: "ArrowLeft"},
+			},
+		},
+		{
+			name:  "Modifiers",
+			chord: Chord{KeyVal: gdk.KEY_plus, Mods: gdk.ControlMask | gdk.ShiftMask},
+			want: []protocol.KeyboardEvent{
+				{EventType: protocol.KeyboardEventTypeDown, Code: "ControlLeft", Key: "Control", Location: protocol.KeyboardLocationLeft, Ctrl: true},
+				{EventType: protocol.KeyboardEventTypeDown, Code: "ShiftLeft", Key: "Shift", Location: protocol.KeyboardLocationLeft, Ctrl: true, Shift: true},
+				{EventType: protocol.KeyboardEventTypeDown, Code: "Equal", Key: "+", Ctrl: true, Shift: true},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "Equal", Key: "+", Ctrl: true, Shift: true},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "ShiftLeft", Key: "Shift", Location: protocol.KeyboardLocationLeft, Ctrl: true},
+				{EventType: protocol.KeyboardEventTypeUp, Code: "ControlLeft", Key: "Control", Location: protocol.KeyboardLocationLeft},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.chord.KeyEvents(usKeycode)
+			if len(got) != len(tt.want) {
+				t.Fatalf("KeyEvents() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
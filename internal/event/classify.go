@@ -0,0 +1,99 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/pkg/errors"
+)
+
+// DeviceClassifier decides which protocol.PointerType the events of an input device are reported as.
+type DeviceClassifier struct {
+	// Overrides maps device names to the pointer type their events are reported as,
+	// for tablets that misreport themselves.
+	Overrides map[string]protocol.PointerType
+}
+
+// ParseDeviceOverrides parses device name to pointer type pairs as given on the command line.
+func ParseDeviceOverrides(pairs map[string]string) (map[string]protocol.PointerType, error) {
+	overrides := make(map[string]protocol.PointerType, len(pairs))
+	for name, value := range pairs {
+		pointerType, err := protocol.ParsePointerType(value)
+		if err != nil || pointerType == protocol.PointerTypeUnknown {
+			return nil, errors.Errorf("invalid pointer type %q for device %q, expected one of mouse, pen, touch", value, name)
+		}
+		overrides[name] = pointerType
+	}
+	return overrides, nil
+}
+
+// Classify returns the pointer type of a device. An override for the device name takes precedence, then the
+// type of the tool in use, then the input source. toolType is gdk.DeviceToolTypeUnknown if there is no tool.
+func (c DeviceClassifier) Classify(name string, source gdk.InputSource, toolType gdk.DeviceToolType) protocol.PointerType {
+	if pointerType, ok := c.Overrides[name]; ok {
+		return pointerType
+	}
+	switch toolType {
+	case gdk.DeviceToolTypePen, gdk.DeviceToolTypeEraser, gdk.DeviceToolTypeBrush,
+		gdk.DeviceToolTypePencil, gdk.DeviceToolTypeAirbrush:
+		return protocol.PointerTypePen
+	case gdk.DeviceToolTypeMouse, gdk.DeviceToolTypeLens:
+		return protocol.PointerTypeMouse
+	}
+	switch source {
+	case gdk.SourcePen:
+		return protocol.PointerTypePen
+	case gdk.SourceTouchscreen:
+		return protocol.PointerTypeTouch
+	case gdk.SourceMouse, gdk.SourceTouchpad, gdk.SourceTrackpoint:
+		return protocol.PointerTypeMouse
+	}
+	return protocol.PointerTypeUnknown
+}
+
+// ClassifyDevice returns the pointer type of a device and the tool in use, which may be nil.
+func (c DeviceClassifier) ClassifyDevice(device gdk.Devicer, tool *gdk.DeviceTool) protocol.PointerType {
+	toolType := gdk.DeviceToolTypeUnknown
+	if tool != nil {
+		toolType = tool.ToolType()
+	}
+	if device == nil {
+		return c.Classify("", gdk.SourceMouse, toolType)
+	}
+	dev := gdk.BaseDevice(device)
+	return c.Classify(dev.Name(), dev.Source(), toolType)
+}
+
+// ownedByGesture reports whether an event is handled by the Stylus gesture or the Touch controller
+// rather than as mouse input.
+func ownedByGesture(event gdk.Eventer) bool {
+	if event == nil {
+		return false
+	}
+	ev := gdk.BaseEvent(event)
+	if ev.DeviceTool() != nil {
+		return true
+	}
+	switch ev.EventType() {
+	case gdk.TouchBegin, gdk.TouchUpdate, gdk.TouchEnd, gdk.TouchCancel:
+		return true
+	}
+	return ev.PointerEmulated()
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+func TestDeviceClassifier_Classify(t *testing.T) {
+	classifier := DeviceClassifier{Overrides: map[string]protocol.PointerType{
+		"Huion Tablet Mouse": protocol.PointerTypePen,
+		"Wacom Touch":        protocol.PointerTypeMouse,
+	}}
+	tests := []struct {
+		name     string
+		device   string
+		source   gdk.InputSource
+		toolType gdk.DeviceToolType
+		want     protocol.PointerType
+	}{
+		{name: "Mouse", device: "Logitech Mouse", source: gdk.SourceMouse, want: protocol.PointerTypeMouse},
+		{name: "Touchpad", device: "Synaptics", source: gdk.SourceTouchpad, want: protocol.PointerTypeMouse},
+		{name: "TrackPoint", device: "TPPS/2", source: gdk.SourceTrackpoint, want: protocol.PointerTypeMouse},
+		{name: "Touchscreen", device: "ELAN Touchscreen", source: gdk.SourceTouchscreen, want: protocol.PointerTypeTouch},
+		{name: "Pen", device: "Wacom Pen", source: gdk.SourcePen, want: protocol.PointerTypePen},
+		{name: "Keyboard", device: "AT Keyboard", source: gdk.SourceKeyboard, want: protocol.PointerTypeUnknown},
+		{name: "TabletPad", device: "Wacom Pad", source: gdk.SourceTabletPad, want: protocol.PointerTypeUnknown},
+		{name: "EraserTool", device: "Wacom Pen", source: gdk.SourcePen, toolType: gdk.DeviceToolTypeEraser, want: protocol.PointerTypePen},
+		{name: "PencilToolOnMouse", device: "Tablet", source: gdk.SourceMouse, toolType: gdk.DeviceToolTypePencil, want: protocol.PointerTypePen},
+		{name: "LensTool", device: "Wacom Pen", source: gdk.SourcePen, toolType: gdk.DeviceToolTypeLens, want: protocol.PointerTypeMouse},
+		{name: "OverrideSource", device: "Huion Tablet Mouse", source: gdk.SourceMouse, want: protocol.PointerTypePen},
+		{name: "OverrideTool", device: "Wacom Touch", source: gdk.SourceTouchscreen, toolType: gdk.DeviceToolTypePen, want: protocol.PointerTypeMouse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifier.Classify(tt.device, tt.source, tt.toolType); got != tt.want {
+				t.Errorf("Classify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDeviceOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		pairs   map[string]string
+		want    map[string]protocol.PointerType
+		wantErr bool
+	}{
+		{name: "Empty", pairs: nil, want: map[string]protocol.PointerType{}},
+		{
+			name:  "Valid",
+			pairs: map[string]string{"Huion Tablet": "pen", "ELAN Touchscreen": "touch"},
+			want:  map[string]protocol.PointerType{"Huion Tablet": protocol.PointerTypePen, "ELAN Touchscreen": protocol.PointerTypeTouch},
+		},
+		{name: "Invalid", pairs: map[string]string{"Huion Tablet": "stylus"}, wantErr: true},
+		{name: "Empty type", pairs: map[string]string{"Huion Tablet": ""}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDeviceOverrides(tt.pairs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDeviceOverrides() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseDeviceOverrides() = %v, want %v", got, tt.want)
+			}
+			for name, pointerType := range tt.want {
+				if got[name] != pointerType {
+					t.Errorf("ParseDeviceOverrides()[%q] = %q, want %q", name, got[name], pointerType)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// IMComposer resolves dead keys and compose sequences with GTK's built-in input method,
+// which follows the active keyboard layout and the user's compose table.
+type IMComposer struct {
+	controller *gtk.EventControllerKey
+	im         *gtk.IMContextSimple
+	text       string
+}
+
+// NewIMComposer creates an IMComposer filtering the current event of controller.
+func NewIMComposer(controller *gtk.EventControllerKey) *IMComposer {
+	c := &IMComposer{
+		controller: controller,
+		im:         gtk.NewIMContextSimple(),
+	}
+	c.im.ConnectCommit(func(str string) {
+		c.text += str
+	})
+	return c
+}
+
+// Compose implements Composer. It filters the event currently handled by the controller,
+// so it must be called from the controller's key-pressed handler.
+func (c *IMComposer) Compose(_, _ uint, _ gdk.ModifierType) (text string, pending bool) {
+	event := c.controller.CurrentEvent()
+	if event == nil {
+		return "", false
+	}
+	c.text = ""
+	filtered := c.im.FilterKeypress(event)
+	text, c.text = c.text, ""
+	return text, filtered && text == ""
+}
+
+// Reset implements Composer, it aborts a pending sequence and drops its pre-edit text.
+func (c *IMComposer) Reset() {
+	c.im.Reset()
+	c.text = ""
+}
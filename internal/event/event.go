@@ -20,32 +20,61 @@
 package event
 
 import (
-	"math"
-	"strings"
 	"time"
 
 	"github.com/OmegaRogue/weylus-desktop/client"
 	"github.com/OmegaRogue/weylus-desktop/protocol"
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 )
 
+// kineticInterval is the interval at which kinetic scrolling emits wheel events.
+const kineticInterval = 16 * time.Millisecond
+
 type ControllerManager struct {
 	Stylus *gtk.GestureStylus
 	Click  *gtk.GestureClick
-	Drag   *gtk.GestureDrag
 	Motion *gtk.EventControllerMotion
 	Scroll *gtk.EventControllerScroll
 	Key    *gtk.EventControllerKey
+	Touch  *gtk.EventControllerLegacy
+	Zoom   *gtk.GestureZoom
+	Rotate *gtk.GestureRotate
+	Swipe  *gtk.GestureSwipe
 
 	StylusState protocol.PointerEvent
-	TouchState  protocol.PointerEvent
 	MouseState  protocol.PointerEvent
-	KeyState    protocol.KeyboardEvent
 	ScrollState protocol.WheelEvent
 
 	WeylusClient client.WeylusClient
 
+	// Keys translates key presses and releases, KeyEvents queues the resulting events for sending.
+	Keys      *KeyTranslator
+	KeyEvents *Queue[protocol.KeyboardEvent]
+
+	// Mice, Styluses and Touches turn mouse, stylus and touch input into pointer events,
+	// PointerEvents queues them for sending.
+	Mice          *MouseTracker
+	Styluses      *StylusTracker
+	Touches       *TouchTracker
+	PointerEvents *Queue[protocol.PointerEvent]
+
+	// Scrolls and Gestures turn scrolling and touchpad gestures into actions, Actions queues them for sending.
+	Scrolls  *ScrollAccumulator
+	Gestures *GestureTranslator
+	Actions  *Queue[Action]
+
+	kinetic       *KineticScroll
+	kineticSource glib.SourceHandle
+
+	// Classifier decides the pointer type events of a device are reported as.
+	Classifier DeviceClassifier
+	// Profiles tune pressure and tilt of pointer events by device name.
+	Profiles InputProfiles
+	// Mapper converts pointer positions to capturable coordinates.
+	Mapper *CoordinateMapper
+
 	callbacks []func(m *ControllerManager)
 }
 
@@ -61,6 +90,16 @@ func (m *ControllerManager) runCallbacks() {
 
 func NewControllerManager() *ControllerManager {
 	m := new(ControllerManager)
+	m.Keys = NewKeyTranslator()
+	m.KeyEvents = NewQueue[protocol.KeyboardEvent]()
+	m.Mice = new(MouseTracker)
+	m.Styluses = new(StylusTracker)
+	m.Touches = NewTouchTracker()
+	m.PointerEvents = NewQueue[protocol.PointerEvent]()
+	m.Mapper = new(CoordinateMapper)
+	m.Scrolls = new(ScrollAccumulator)
+	m.Gestures = new(GestureTranslator)
+	m.Actions = NewQueue[Action]()
 
 	m.Stylus = gtk.NewGestureStylus()
 	m.Stylus.SetButton(0)
@@ -68,13 +107,14 @@ func NewControllerManager() *ControllerManager {
 	m.Click = gtk.NewGestureClick()
 	m.Click.SetButton(0)
 	m.Click.SetExclusive(false)
-	m.Drag = gtk.NewGestureDrag()
-	m.Drag.SetButton(0)
-	m.Drag.SetExclusive(false)
-	m.Drag.SetTouchOnly(true)
+	m.Touch = gtk.NewEventControllerLegacy()
 	m.Motion = gtk.NewEventControllerMotion()
 	m.Key = gtk.NewEventControllerKey()
-	m.Scroll = gtk.NewEventControllerScroll(gtk.EventControllerScrollBothAxes)
+	m.Keys.Composer = NewIMComposer(m.Key)
+	m.Scroll = gtk.NewEventControllerScroll(gtk.EventControllerScrollBothAxes | gtk.EventControllerScrollKinetic)
+	m.Zoom = gtk.NewGestureZoom()
+	m.Rotate = gtk.NewGestureRotate()
+	m.Swipe = gtk.NewGestureSwipe()
 
 	m.Stylus.ConnectUp(m.StylusUpEventHandler)
 	m.Stylus.ConnectDown(m.StylusDownEventHandler)
@@ -83,307 +123,300 @@ func NewControllerManager() *ControllerManager {
 
 	m.Key.ConnectKeyPressed(m.KeyDownHandler)
 	m.Key.ConnectKeyReleased(m.KeyReleasedHandler)
-	m.Key.ConnectModifiers(m.KeyModHandler)
 
 	m.Scroll.ConnectScroll(m.ScrollHandler)
+	m.Scroll.ConnectScrollBegin(m.stopKinetic)
+	m.Scroll.ConnectDecelerate(m.DecelerateHandler)
+
+	m.Zoom.ConnectBegin(func(*gdk.EventSequence) { m.Gestures.Begin() })
+	m.Zoom.ConnectScaleChanged(m.ZoomHandler)
+	m.Rotate.ConnectBegin(func(*gdk.EventSequence) { m.Gestures.Begin() })
+	m.Rotate.ConnectAngleChanged(m.RotateHandler)
+	m.Swipe.ConnectSwipe(m.SwipeHandler)
 
 	m.Motion.ConnectMotion(m.MotionHandler)
+	m.Motion.ConnectLeave(m.Styluses.Leave)
+	m.Motion.ConnectLeave(m.Mice.Leave)
 
 	m.Click.ConnectPressed(m.PressedHandler)
 	m.Click.ConnectReleased(m.ReleasedHandler)
 	m.Click.ConnectUnpairedRelease(m.UnpairedReleaseHandler)
 
-	m.Drag.ConnectDragBegin(m.DragBeginHandler)
-	m.Drag.ConnectDragEnd(m.DragEndHandler)
-	m.Drag.ConnectDragUpdate(m.DragUpdateHandler)
+	m.Touch.ConnectEvent(m.TouchEventHandler)
 
 	return m
 }
 
+// ScrollHandler turns scroll deltas into high resolution wheel events.
 func (m *ControllerManager) ScrollHandler(dx, dy float64) (ok bool) {
 	ok = false
-	m.ScrollState.Timestamp = uint64(time.Now().UnixMilli())
 	defer m.runCallbacks()
-	m.ScrollState.Dx = int32(math.Round(10 * dx))
-	m.ScrollState.Dy = int32(math.Round(10 * dy))
+	m.scroll(dx, dy)
 	return
 }
 
+func (m *ControllerManager) scroll(dx, dy float64) {
+	if e, whole := m.Scrolls.Scroll(dx, dy, uint64(time.Now().UnixMilli())); whole {
+		m.ScrollState = e
+		m.Actions.Push(wheelAction(e))
+	}
+}
+
+// DecelerateHandler continues a touchpad scroll after the fingers are lifted.
+func (m *ControllerManager) DecelerateHandler(velX, velY float64) {
+	m.stopKinetic()
+	m.kinetic = NewKineticScroll(velX, velY)
+	m.kineticSource = glib.TimeoutAdd(uint(kineticInterval.Milliseconds()), func() bool {
+		dx, dy, done := m.kinetic.Step(kineticInterval)
+		if done {
+			m.kinetic = nil
+			return false
+		}
+		m.scroll(dx, dy)
+		m.runCallbacks()
+		return true
+	})
+}
+
+// stopKinetic stops kinetic scrolling, e.g. when the fingers touch the touchpad again.
+func (m *ControllerManager) stopKinetic() {
+	if m.kinetic != nil {
+		glib.SourceRemove(m.kineticSource)
+		m.kinetic = nil
+	}
+	m.Scrolls.Reset()
+}
+
+// touchpadGesture reports whether the event a gesture handles is a touchpad gesture event of the given type.
+// Zoom, rotate and swipe also recognize touchscreen contacts, which the Touch controller already sends.
+func touchpadGesture(event gdk.Eventer, eventType gdk.EventType) bool {
+	return event != nil && gdk.BaseEvent(event).EventType() == eventType
+}
+
+func (m *ControllerManager) pushActions(actions []Action) {
+	for _, action := range actions {
+		m.Actions.Push(action)
+	}
+}
+
+// ZoomHandler handles pinching on the touchpad.
+func (m *ControllerManager) ZoomHandler(scale float64) {
+	if !touchpadGesture(m.Zoom.CurrentEvent(), gdk.TouchpadPinch) {
+		return
+	}
+	m.pushActions(m.Gestures.Zoom(scale, uint64(time.Now().UnixMilli())))
+}
+
+// RotateHandler handles rotating two fingers on the touchpad.
+func (m *ControllerManager) RotateHandler(_, angleDelta float64) {
+	if !touchpadGesture(m.Rotate.CurrentEvent(), gdk.TouchpadPinch) {
+		return
+	}
+	m.pushActions(m.Gestures.Rotate(angleDelta))
+}
+
+// SwipeHandler handles a swipe on the touchpad.
+func (m *ControllerManager) SwipeHandler(velocityX, velocityY float64) {
+	if !touchpadGesture(m.Swipe.CurrentEvent(), gdk.TouchpadSwipe) {
+		return
+	}
+	m.pushActions(m.Gestures.Swipe(velocityX, velocityY))
+}
+
 func (m *ControllerManager) KeyDownHandler(keyVal, keycode uint, state gdk.ModifierType) (ok bool) {
 	ok = false
 	defer m.runCallbacks()
-	m.KeyState.Alt = state.Has(gdk.AltMask)
-	m.KeyState.Shift = state.Has(gdk.ShiftMask)
-	m.KeyState.Ctrl = state.Has(gdk.ControlMask)
-	m.KeyState.Meta = state.Has(gdk.MetaMask)
-	m.KeyState.EventType = protocol.KeyboardEventTypeDown
-	m.KeyState.Code = protocol.CodeValue[keycode]
-	m.KeyState.Location = protocol.KeyboardLocationStandard
-	var ok2 bool
-	m.KeyState.Key, ok2 = protocol.KeyValue[keyVal]
-	if !ok2 {
-		m.KeyState.Key = string(rune(gdk.KeyvalToUnicode(keyVal)))
-	}
-	m.KeyState.EventType = protocol.KeyboardEventTypeDown
-
+	m.KeyEvents.Push(m.Keys.Press(keyVal, keycode, state))
 	return
 }
 func (m *ControllerManager) KeyReleasedHandler(keyVal, keycode uint, state gdk.ModifierType) {
 	defer m.runCallbacks()
-	m.KeyState.Alt = state.Has(gdk.AltMask)
-	m.KeyState.Shift = state.Has(gdk.ShiftMask)
-	m.KeyState.Ctrl = state.Has(gdk.ControlMask)
-	m.KeyState.Meta = state.Has(gdk.MetaMask)
-	m.KeyState.EventType = protocol.KeyboardEventTypeDown
-	m.KeyState.Code = protocol.CodeValue[keycode]
-	m.KeyState.Location = protocol.KeyboardLocationStandard
-	var ok bool
-	m.KeyState.Key, ok = protocol.KeyValue[keyVal]
-	if !ok {
-		m.KeyState.Key = string(rune(gdk.KeyvalToUnicode(keyVal)))
-	}
-	m.KeyState.EventType = protocol.KeyboardEventTypeUp
+	m.KeyEvents.Push(m.Keys.Release(keyVal, keycode, state))
 }
-func (m *ControllerManager) KeyModHandler(keyVal gdk.ModifierType) (ok bool) {
-	ok = false
-	m.KeyState.Alt = keyVal.Has(gdk.AltMask)
-	m.KeyState.Shift = keyVal.Has(gdk.ShiftMask)
-	m.KeyState.Ctrl = keyVal.Has(gdk.ControlMask)
-	m.KeyState.Meta = keyVal.Has(gdk.MetaMask)
-	return
+
+// FocusOutHandler releases the held keys when the window loses focus, the releases of keys let go meanwhile go to
+// another window, e.g. Alt of Alt+Tab.
+func (m *ControllerManager) FocusOutHandler() {
+	defer m.runCallbacks()
+	for _, e := range m.Keys.Reset() {
+		m.KeyEvents.Push(e)
+	}
 }
 
 func (m *ControllerManager) ConnectControllers(overlay *gtk.Overlay) {
-	overlay.AddController(m.Drag)
+	overlay.AddController(m.Touch)
+	overlay.ConnectUnmap(func() {
+		// GTK sends no touch end for contacts on a widget that goes away
+		for _, e := range m.Touches.CancelAll(uint64(time.Now().UnixMilli())) {
+			if e, ok := m.Mapper.MapEvent(e); ok {
+				m.PointerEvents.Push(e)
+			}
+		}
+	})
 	overlay.AddController(m.Click)
 	overlay.AddController(m.Stylus)
 	overlay.AddController(m.Motion)
+	overlay.AddController(m.Zoom)
+	overlay.AddController(m.Rotate)
+	overlay.AddController(m.Swipe)
+}
+
+// stylusAxes reads the axes of the current stylus event.
+func (m *ControllerManager) stylusAxes() StylusAxes {
+	var axes StylusAxes
+	axes.Pressure, axes.HasPressure = m.Stylus.Axis(gdk.AxisPressure)
+	axes.XTilt, _ = m.Stylus.Axis(gdk.AxisXtilt)
+	axes.YTilt, _ = m.Stylus.Axis(gdk.AxisYtilt)
+	axes.Rotation, _ = m.Stylus.Axis(gdk.AxisRotation)
+	axes.Slider, axes.HasSlider = m.Stylus.Axis(gdk.AxisSlider)
+	return axes
+}
+
+// stylusIsEraser reports whether the current stylus event comes from the eraser end.
+func (m *ControllerManager) stylusIsEraser() bool {
+	tool := m.Stylus.DeviceTool()
+	return tool != nil && tool.ToolType() == gdk.DeviceToolTypeEraser
 }
 
-func (m *ControllerManager) stylusEventHandler(x, y float64) {
-	m.StylusState.X = x
-	m.StylusState.Y = y
-	xTilt, _ := m.Stylus.Axis(gdk.AxisXtilt)
-	yTilt, _ := m.Stylus.Axis(gdk.AxisYtilt)
-	m.StylusState.TiltX = int32(90 * xTilt)
-	m.StylusState.TiltY = int32(90 * yTilt)
-	m.StylusState.Pressure, _ = m.Stylus.Axis(gdk.AxisPressure)
-	m.StylusState.Timestamp = uint64(time.Now().UnixMilli())
+// pushPointer queues a pointer event with the pointer type the classifier assigns to its device,
+// tuned by the input profile of the device and mapped to capturable coordinates.
+// Events outside the video are dropped.
+func (m *ControllerManager) pushPointer(e protocol.PointerEvent, device gdk.Devicer, tool *gdk.DeviceTool) protocol.PointerEvent {
+	if pointerType := m.Classifier.ClassifyDevice(device, tool); pointerType != protocol.PointerTypeUnknown {
+		e.PointerType = pointerType
+	}
+	var name string
+	if device != nil {
+		name = gdk.BaseDevice(device).Name()
+	}
+	e = m.Profiles.For(name).Apply(e)
+	e, ok := m.Mapper.MapEvent(e)
+	if ok {
+		m.PointerEvents.Push(e)
+	}
+	return e
 }
 
+func (m *ControllerManager) pushStylus(e protocol.PointerEvent) {
+	m.StylusState = m.pushPointer(e, m.Stylus.CurrentEventDevice(), m.Stylus.DeviceTool())
+	m.runCallbacks()
+}
+
+func (m *ControllerManager) pushMouse(e protocol.PointerEvent, device gdk.Devicer) {
+	m.MouseState = m.pushPointer(e, device, nil)
+	m.runCallbacks()
+}
+
+// StylusUpEventHandler handles the tip leaving the surface or a barrel button being released.
 func (m *ControllerManager) StylusUpEventHandler(x, y float64) {
-	m.stylusEventHandler(x, y)
-	defer m.runCallbacks()
-	m.StylusState.TiltX = 0
-	m.StylusState.TiltY = 0
-	m.StylusState.Pressure = 0
-	m.StylusState.MovementX = 0
-	m.StylusState.MovementY = 0
-	m.StylusState.Buttons &= ^(protocol.ButtonPrimary | protocol.ButtonEraser)
-	m.StylusState.Button = protocol.ButtonNone
+	m.pushStylus(m.Styluses.Up(x, y, m.stylusAxes(), m.Stylus.CurrentButton(), m.stylusIsEraser(), uint64(time.Now().UnixMilli())))
 }
 
+// StylusDownEventHandler handles the tip touching the surface or a barrel button being pressed.
 func (m *ControllerManager) StylusDownEventHandler(x, y float64) {
-	m.stylusEventHandler(x, y)
-	defer m.runCallbacks()
-	if tool := m.Stylus.DeviceTool(); tool != nil {
-		switch tool.ToolType() {
-		case gdk.DeviceToolTypePen:
-			m.StylusState.Button = protocol.ButtonPrimary
-			m.StylusState.Buttons |= protocol.ButtonPrimary
-			m.StylusState.Buttons &= ^protocol.ButtonEraser
-		case gdk.DeviceToolTypeEraser:
-			m.StylusState.Button = protocol.ButtonEraser
-			m.StylusState.Buttons &= ^protocol.ButtonPrimary
-			m.StylusState.Buttons |= protocol.ButtonEraser
-		}
-	}
+	m.pushStylus(m.Styluses.Down(x, y, m.stylusAxes(), m.Stylus.CurrentButton(), m.stylusIsEraser(), uint64(time.Now().UnixMilli())))
 }
 
+// StylusProximityEventHandler handles the stylus hovering in proximity of the surface.
 func (m *ControllerManager) StylusProximityEventHandler(x, y float64) {
-	m.stylusEventHandler(x, y)
-	defer m.runCallbacks()
-	m.StylusState.Buttons &= ^(protocol.ButtonPrimary | protocol.ButtonEraser)
-	m.StylusState.Button = protocol.ButtonNone
+	m.pushStylus(m.Styluses.Hover(x, y, m.stylusAxes(), uint64(time.Now().UnixMilli())))
 }
 
+// StylusMotionEventHandler handles the stylus moving while it touches the surface.
 func (m *ControllerManager) StylusMotionEventHandler(x, y float64) {
-	m.stylusEventHandler(x, y)
-	m.runCallbacks()
+	m.pushStylus(m.Styluses.Motion(x, y, m.stylusAxes(), uint64(time.Now().UnixMilli())))
+}
+
+// SetRelativeMouse switches mouse events between absolute positions and relative movement.
+func (m *ControllerManager) SetRelativeMouse(relative bool) {
+	m.Mice.Relative = relative
+	m.Mice.Leave()
 }
 
+// PressedHandler handles a button press of a mouse or another device without a tool.
+// Stylus and touch input reaching the Click gesture is left to the Stylus gesture and the Touch controller.
 func (m *ControllerManager) PressedHandler(_ int, x, y float64) {
-	defer m.runCallbacks()
-	name := m.Click.CurrentEventDevice().ObjectProperty("name").(string)
-	source := m.Click.CurrentEventDevice().ObjectProperty("source").(gdk.InputSource)
-	button := m.Click.CurrentButton()
-	var btn protocol.ButtonFlags
-	switch button {
-	case gdk.BUTTON_PRIMARY:
-		btn = protocol.ButtonPrimary
-	case gdk.BUTTON_MIDDLE:
-		btn = protocol.ButtonAuxiliary
-	case gdk.BUTTON_SECONDARY:
-		btn = protocol.ButtonSecondary
-	case 8:
-		btn = protocol.ButtonFourth
-	case 9:
-		btn = protocol.ButtonFifth
-	}
-	switch {
-	case strings.Contains(name, "Stylus"):
-		m.StylusState.Timestamp = uint64(time.Now().UnixMilli())
-		m.StylusState.X = x
-		m.StylusState.Y = y
-		m.StylusState.Button = btn
-		m.StylusState.Buttons |= btn
-	case source == gdk.SourceTouchscreen:
-		m.TouchState.Timestamp = uint64(time.Now().UnixMilli())
-		m.TouchState.X = x
-		m.TouchState.Y = y
-		m.TouchState.Button = btn
-		m.TouchState.Buttons |= btn
-	default:
-		m.MouseState.Timestamp = uint64(time.Now().UnixMilli())
-		m.MouseState.X = x
-		m.MouseState.Y = y
-		m.MouseState.Button = btn
-		m.MouseState.Buttons |= btn
+	if ownedByGesture(m.Click.CurrentEvent()) {
+		return
 	}
+	m.pushMouse(m.Mice.Down(x, y, m.Click.CurrentButton(), uint64(time.Now().UnixMilli())), m.Click.CurrentEventDevice())
 }
 
+// ReleasedHandler handles a button release of a mouse or another device without a tool.
 func (m *ControllerManager) ReleasedHandler(_ int, x, y float64) {
-	defer m.runCallbacks()
-	name := m.Click.CurrentEventDevice().ObjectProperty("name").(string)
-	source := m.Click.CurrentEventDevice().ObjectProperty("source").(gdk.InputSource)
-	button := m.Click.CurrentButton()
-	var btn protocol.ButtonFlags
-	switch button {
-	case gdk.BUTTON_PRIMARY:
-		btn = protocol.ButtonPrimary
-	case gdk.BUTTON_MIDDLE:
-		btn = protocol.ButtonAuxiliary
-	case gdk.BUTTON_SECONDARY:
-		btn = protocol.ButtonSecondary
-	case 8:
-		btn = protocol.ButtonFourth
-	case 9:
-		btn = protocol.ButtonFifth
-	}
-	switch {
-	case strings.Contains(name, "Stylus"):
-		m.StylusState.Timestamp = uint64(time.Now().UnixMilli())
-		m.StylusState.X = x
-		m.StylusState.Y = y
-		m.StylusState.Button = protocol.ButtonNone
-		m.StylusState.Buttons &= ^btn
-	case source == gdk.SourceTouchscreen:
-		m.TouchState.Timestamp = uint64(time.Now().UnixMilli())
-		m.TouchState.X = x
-		m.TouchState.Y = y
-		m.TouchState.Button = protocol.ButtonNone
-		m.TouchState.Buttons &= ^btn
-	default:
-		m.MouseState.Timestamp = uint64(time.Now().UnixMilli())
-		m.MouseState.X = x
-		m.MouseState.Y = y
-		m.MouseState.Button = protocol.ButtonNone
-		m.MouseState.Buttons &= ^btn
+	if ownedByGesture(m.Click.CurrentEvent()) {
+		return
 	}
+	m.pushMouse(m.Mice.Up(x, y, m.Click.CurrentButton(), uint64(time.Now().UnixMilli())), m.Click.CurrentEventDevice())
 }
-func (m *ControllerManager) MotionHandler(x, y float64) {
-	defer m.runCallbacks()
-	var name string
-	var source gdk.InputSource
-	if dev := m.Motion.CurrentEventDevice(); dev != nil {
-		if n := dev.ObjectProperty("name"); n != nil {
-			name = n.(string)
-		}
-		if s := dev.ObjectProperty("source"); s != nil {
-			source = s.(gdk.InputSource)
-		}
-	}
 
-	switch {
-	case strings.Contains(name, "Stylus"):
-		m.StylusState.Timestamp = uint64(time.Now().UnixMilli())
-		m.StylusState.X = x
-		m.StylusState.Y = y
-	case source == gdk.SourceTouchscreen:
-		m.TouchState.Timestamp = uint64(time.Now().UnixMilli())
-		m.TouchState.X = x
-		m.TouchState.Y = y
-	default:
-		m.MouseState.Timestamp = uint64(time.Now().UnixMilli())
-		m.MouseState.X = x
-		m.MouseState.Y = y
+// MotionHandler handles the motion of a mouse or another device without a tool.
+func (m *ControllerManager) MotionHandler(x, y float64) {
+	if ownedByGesture(m.Motion.CurrentEvent()) {
+		return
 	}
+	m.pushMouse(m.Mice.Motion(x, y, uint64(time.Now().UnixMilli())), m.Motion.CurrentEventDevice())
 }
 
+// UnpairedReleaseHandler handles the release of a button that was pressed before the Click gesture saw it.
 func (m *ControllerManager) UnpairedReleaseHandler(x, y float64, button uint, _ *gdk.EventSequence) {
-	defer m.runCallbacks()
-	var name string
-	var source gdk.InputSource
-	if dev := m.Click.CurrentEventDevice(); dev != nil {
-		if n := dev.ObjectProperty("name"); n != nil {
-			name = n.(string)
-		}
-		if s := dev.ObjectProperty("source"); s != nil {
-			source = s.(gdk.InputSource)
-		}
-	}
-	var btn protocol.ButtonFlags
-	switch button {
-	case gdk.BUTTON_PRIMARY:
-		btn = protocol.ButtonPrimary
-	case gdk.BUTTON_MIDDLE:
-		btn = protocol.ButtonAuxiliary
-	case gdk.BUTTON_SECONDARY:
-		btn = protocol.ButtonSecondary
-	case 8:
-		btn = protocol.ButtonFourth
-	case 9:
-		btn = protocol.ButtonFifth
-	}
-	switch {
-	case strings.Contains(name, "Stylus"):
-		m.StylusState.Timestamp = uint64(time.Now().UnixMilli())
-		m.StylusState.Button = protocol.ButtonNone
-		m.StylusState.Buttons &= ^btn
-		m.StylusState.X = x
-		m.StylusState.Y = y
-	case source == 3:
-		m.TouchState.Timestamp = uint64(time.Now().UnixMilli())
-		m.TouchState.Button = protocol.ButtonNone
-		m.TouchState.Buttons &= ^btn
-		m.TouchState.X = x
-		m.TouchState.Y = y
-	default:
-		m.MouseState.Timestamp = uint64(time.Now().UnixMilli())
-		m.MouseState.Button = protocol.ButtonNone
-		m.MouseState.Buttons &= ^btn
-		m.MouseState.X = x
-		m.MouseState.Y = y
+	if ownedByGesture(m.Click.CurrentEvent()) {
+		return
 	}
+	m.pushMouse(m.Mice.Up(x, y, button, uint64(time.Now().UnixMilli())), m.Click.CurrentEventDevice())
 }
 
-func (m *ControllerManager) DragBeginHandler(x, y float64) {
+// TouchEventHandler emits a PointerEvent for every touch event, one stream per contact.
+func (m *ControllerManager) TouchEventHandler(event gdk.Eventer) (ok bool) {
+	ok = false
+	ev := gdk.BaseEvent(event)
+	eventType := ev.EventType()
+	switch eventType {
+	case gdk.TouchBegin, gdk.TouchUpdate, gdk.TouchEnd, gdk.TouchCancel:
+	default:
+		return
+	}
 	defer m.runCallbacks()
-	m.TouchState.X = x
-	m.TouchState.Y = y
-	m.TouchState.Timestamp = uint64(time.Now().UnixMilli())
-}
 
-func (m *ControllerManager) DragEndHandler(x, y float64) {
-	defer m.runCallbacks()
-	m.TouchState.X = x
-	m.TouchState.Y = y
-	m.TouchState.Timestamp = uint64(time.Now().UnixMilli())
+	var c Contact
+	if x, y, hasPosition := ev.Position(); hasPosition {
+		c.X, c.Y = m.widgetCoordinates(x, y)
+	}
+	c.Pressure, _ = ev.Axis(gdk.AxisPressure)
+	sequence := sequenceKey(ev.EventSequence())
+	timestamp := uint64(time.Now().UnixMilli())
+
+	var e protocol.PointerEvent
+	tracked := true
+	switch eventType {
+	case gdk.TouchBegin:
+		e = m.Touches.Begin(sequence, c, timestamp)
+	case gdk.TouchUpdate:
+		e, tracked = m.Touches.Update(sequence, c, timestamp)
+	case gdk.TouchEnd:
+		e, tracked = m.Touches.End(sequence, c, timestamp)
+	case gdk.TouchCancel:
+		e, tracked = m.Touches.Cancel(sequence, c, timestamp)
+	}
+	if tracked {
+		m.pushPointer(e, ev.Device(), nil)
+	}
+	return
 }
 
-func (m *ControllerManager) DragUpdateHandler(x, y float64) {
-	defer m.runCallbacks()
-	m.TouchState.X = x
-	m.TouchState.Y = y
-	m.TouchState.Timestamp = uint64(time.Now().UnixMilli())
+// widgetCoordinates converts surface coordinates of a legacy event to coordinates of the Touch controller's widget.
+func (m *ControllerManager) widgetCoordinates(x, y float64) (float64, float64) {
+	widget := m.Touch.Widget()
+	if widget == nil {
+		return x, y
+	}
+	native := gtk.BaseWidget(widget).Native()
+	if native == nil {
+		return x, y
+	}
+	dx, dy := native.SurfaceTransform()
+	if wx, wy, ok := native.TranslateCoordinates(widget, x-dx, y-dy); ok {
+		return wx, wy
+	}
+	return x, y
 }
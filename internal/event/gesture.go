@@ -0,0 +1,35 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+//go:generate go-enum --marshal --names --values
+package event
+
+// Gesture is a touchpad gesture that can be bound to a key chord.
+/*
+ ENUM(
+ zoom-in // Pinching outwards.
+ zoom-out // Pinching inwards.
+ rotate-left // Rotating two fingers counterclockwise.
+ rotate-right // Rotating two fingers clockwise.
+ swipe-left // Swiping left.
+ swipe-right // Swiping right.
+ swipe-up // Swiping up.
+ swipe-down // Swiping down.
+)
+*/
+type Gesture string
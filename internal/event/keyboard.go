@@ -0,0 +1,206 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+// KeyTranslator turns GDK key presses and releases into discrete protocol.KeyboardEvent values.
+// It remembers which hardware keycodes are held so auto-repeated presses are reported as repeats
+// and releases carry the same key as the press.
+type KeyTranslator struct {
+	// Composer resolves dead keys and compose sequences, nil reports every keyval as is.
+	Composer Composer
+
+	held map[uint]heldKey
+}
+
+// heldKey is a held key, the keyval of its press and the key it was reported as.
+type heldKey struct {
+	keyVal uint
+	key    string
+}
+
+// Composer feeds key presses through an input method to resolve dead keys and compose sequences.
+type Composer interface {
+	// Compose returns the text committed by a press and whether the press is part of a sequence that has not
+	// completed yet.
+	Compose(keyVal, keycode uint, state gdk.ModifierType) (text string, pending bool)
+	// Reset aborts a pending sequence.
+	Reset()
+}
+
+// NewKeyTranslator creates a KeyTranslator with no keys held.
+func NewKeyTranslator() *KeyTranslator {
+	return &KeyTranslator{held: make(map[uint]heldKey)}
+}
+
+// Press translates a key press. A press of a keycode that is already held is reported as a repeat.
+//
+// The key follows the active keyboard layout, since GDK translates keyvals with it. Presses that start or
+// continue a dead key or compose sequence are reported as "Dead" or "Process", the press completing it
+// carries the composed text.
+func (t *KeyTranslator) Press(keyVal, keycode uint, state gdk.ModifierType) protocol.KeyboardEvent {
+	if held, ok := t.held[keycode]; ok {
+		e := translateKey(protocol.KeyboardEventTypeRepeat, keyVal, keycode, state)
+		e.Key = held.key
+		return e
+	}
+	e := translateKey(protocol.KeyboardEventTypeDown, keyVal, keycode, state)
+	if t.Composer != nil {
+		text, pending := t.Composer.Compose(keyVal, keycode, state)
+		switch {
+		case pending && e.Key != "Dead" && e.Key != "Compose":
+			e.Key = "Process"
+		case text != "":
+			e.Key = text
+		}
+	}
+	t.held[keycode] = heldKey{keyVal: keyVal, key: e.Key}
+	return e
+}
+
+// Release translates a key release.
+func (t *KeyTranslator) Release(keyVal, keycode uint, state gdk.ModifierType) protocol.KeyboardEvent {
+	e := translateKey(protocol.KeyboardEventTypeUp, keyVal, keycode, state)
+	if held, ok := t.held[keycode]; ok {
+		e.Key = held.key
+		delete(t.held, keycode)
+	}
+	return e
+}
+
+// Reset releases all held keys and aborts a pending compose sequence, e.g. after the window lost focus and the
+// releases go to another window. It returns the release events of the held keys, ordered by keycode.
+func (t *KeyTranslator) Reset() []protocol.KeyboardEvent {
+	if t.Composer != nil {
+		t.Composer.Reset()
+	}
+	keycodes := make([]uint, 0, len(t.held))
+	for keycode := range t.held {
+		keycodes = append(keycodes, keycode)
+	}
+	sort.Slice(keycodes, func(i, j int) bool { return keycodes[i] < keycodes[j] })
+	events := make([]protocol.KeyboardEvent, 0, len(keycodes))
+	for _, keycode := range keycodes {
+		held := t.held[keycode]
+		delete(t.held, keycode)
+		e := translateKey(protocol.KeyboardEventTypeUp, held.keyVal, keycode, t.modifiers())
+		e.Key = held.key
+		events = append(events, e)
+	}
+	return events
+}
+
+// modifiers returns the modifier state of the held modifier keys.
+func (t *KeyTranslator) modifiers() gdk.ModifierType {
+	var state gdk.ModifierType
+	for _, held := range t.held {
+		switch held.key {
+		case "Alt":
+			state |= gdk.AltMask
+		case "Control":
+			state |= gdk.ControlMask
+		case "Shift":
+			state |= gdk.ShiftMask
+		case "Meta", "Super":
+			state |= gdk.MetaMask
+		}
+	}
+	return state
+}
+
+func translateKey(eventType protocol.KeyboardEventType, keyVal, keycode uint, state gdk.ModifierType) protocol.KeyboardEvent {
+	e := protocol.KeyboardEvent{
+		EventType: eventType,
+		Code:      KeyCode(keycode),
+		Key:       KeyName(keyVal),
+		Location:  KeyLocation(keyVal, keycode),
+		Alt:       state.Has(gdk.AltMask),
+		Ctrl:      state.Has(gdk.ControlMask),
+		Shift:     state.Has(gdk.ShiftMask),
+		Meta:      state.Has(gdk.MetaMask) || state.Has(gdk.SuperMask),
+	}
+	// GDK reports the modifier state from before the event, browsers report it after,
+	// so a modifier key sets its own flag on down and clears it on up.
+	down := eventType != protocol.KeyboardEventTypeUp
+	switch e.Key {
+	case "Alt":
+		e.Alt = down
+	case "Control":
+		e.Ctrl = down
+	case "Shift":
+		e.Shift = down
+	case "Meta", "Super":
+		e.Meta = down
+	}
+	return e
+}
+
+// KeyCode returns the KeyboardEvent.code value for a hardware keycode.
+func KeyCode(keycode uint) string {
+	if code, ok := protocol.CodeValue[keycode]; ok {
+		return code
+	}
+	return "Unidentified"
+}
+
+// KeyName returns the KeyboardEvent.key value for a keyval.
+func KeyName(keyVal uint) string {
+	if key, ok := protocol.KeyValue[keyVal]; ok {
+		return key
+	}
+	if keyVal >= gdk.KEY_dead_grave && keyVal <= gdk.KEY_dead_longsolidusoverlay {
+		return "Dead"
+	}
+	if r := gdk.KeyvalToUnicode(keyVal); r != 0 {
+		return string(rune(r))
+	}
+	return "Unidentified"
+}
+
+// KeyLocation derives the KeyboardEvent.location of a key.
+// The keyval decides where possible, the hardware keycode is used for keys the layout does not tell apart.
+func KeyLocation(keyVal, keycode uint) protocol.KeyboardLocation {
+	switch keyVal {
+	case gdk.KEY_Shift_L, gdk.KEY_Control_L, gdk.KEY_Alt_L, gdk.KEY_Meta_L, gdk.KEY_Super_L, gdk.KEY_Hyper_L:
+		return protocol.KeyboardLocationLeft
+	case gdk.KEY_Shift_R, gdk.KEY_Control_R, gdk.KEY_Alt_R, gdk.KEY_Meta_R, gdk.KEY_Super_R, gdk.KEY_Hyper_R,
+		gdk.KEY_ISO_Level3_Shift, gdk.KEY_Mode_switch:
+		return protocol.KeyboardLocationRight
+	}
+	if keyVal >= gdk.KEY_KP_Space && keyVal <= gdk.KEY_KP_Equal {
+		return protocol.KeyboardLocationNumpad
+	}
+	code := protocol.CodeValue[keycode]
+	switch {
+	case strings.HasPrefix(code, "Numpad"):
+		return protocol.KeyboardLocationNumpad
+	case code == "ShiftLeft", code == "ControlLeft", code == "AltLeft", code == "MetaLeft":
+		return protocol.KeyboardLocationLeft
+	case code == "ShiftRight", code == "ControlRight", code == "AltRight", code == "MetaRight":
+		return protocol.KeyboardLocationRight
+	}
+	return protocol.KeyboardLocationStandard
+}
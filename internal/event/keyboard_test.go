@@ -0,0 +1,285 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+func TestTranslateKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyVal   uint
+		keycode  uint
+		state    gdk.ModifierType
+		up       bool
+		code     string
+		key      string
+		location protocol.KeyboardLocation
+		shift    bool
+		ctrl     bool
+		alt      bool
+	}{
+		{name: "a", keyVal: gdk.KEY_a, keycode: 0x26, code: "KeyA", key: "a", location: protocol.KeyboardLocationStandard},
+		{name: "A", keyVal: gdk.KEY_A, keycode: 0x26, state: gdk.ShiftMask, code: "KeyA", key: "A", location: protocol.KeyboardLocationStandard, shift: true},
+		{name: "Digit1", keyVal: gdk.KEY_1, keycode: 0x0A, code: "Digit1", key: "1", location: protocol.KeyboardLocationStandard},
+		{name: "Enter", keyVal: gdk.KEY_Return, keycode: 0x24, code: "Enter", key: "Enter", location: protocol.KeyboardLocationStandard},
+		{name: "ShiftLeft", keyVal: gdk.KEY_Shift_L, keycode: 0x32, code: "ShiftLeft", key: "Shift", location: protocol.KeyboardLocationLeft, shift: true},
+		{name: "ShiftRight", keyVal: gdk.KEY_Shift_R, keycode: 0x3E, code: "ShiftRight", key: "Shift", location: protocol.KeyboardLocationRight, shift: true},
+		{name: "ShiftLeftUp", keyVal: gdk.KEY_Shift_L, keycode: 0x32, state: gdk.ShiftMask, up: true, code: "ShiftLeft", key: "Shift", location: protocol.KeyboardLocationLeft},
+		{name: "ControlLeft", keyVal: gdk.KEY_Control_L, keycode: 0x25, code: "ControlLeft", key: "Control", location: protocol.KeyboardLocationLeft, ctrl: true},
+		{name: "ControlRight", keyVal: gdk.KEY_Control_R, keycode: 0x69, code: "ControlRight", key: "Control", location: protocol.KeyboardLocationRight, ctrl: true},
+		{name: "AltLeft", keyVal: gdk.KEY_Alt_L, keycode: 0x40, code: "AltLeft", key: "Alt", location: protocol.KeyboardLocationLeft, alt: true},
+		{name: "AltGraph", keyVal: gdk.KEY_ISO_Level3_Shift, keycode: 0x6C, code: "AltRight", key: "AltGraph", location: protocol.KeyboardLocationRight},
+		{name: "Numpad1", keyVal: gdk.KEY_KP_1, keycode: 0x57, code: "Numpad1", key: "1", location: protocol.KeyboardLocationNumpad},
+		{name: "NumpadEnd", keyVal: gdk.KEY_KP_End, keycode: 0x57, code: "Numpad1", key: "End", location: protocol.KeyboardLocationNumpad},
+		{name: "NumpadEnter", keyVal: gdk.KEY_KP_Enter, keycode: 0x68, code: "NumpadEnter", key: "Enter", location: protocol.KeyboardLocationNumpad},
+		{name: "NumpadAdd", keyVal: gdk.KEY_KP_Add, keycode: 0x56, code: "NumpadAdd", key: "+", location: protocol.KeyboardLocationNumpad},
+		{name: "UnknownKeycode", keyVal: gdk.KEY_a, keycode: 0x1000, code: "Unidentified", key: "a", location: protocol.KeyboardLocationStandard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			translator := NewKeyTranslator()
+			var got protocol.KeyboardEvent
+			want := protocol.KeyboardEventTypeDown
+			if tt.up {
+				want = protocol.KeyboardEventTypeUp
+				got = translator.Release(tt.keyVal, tt.keycode, tt.state)
+			} else {
+				got = translator.Press(tt.keyVal, tt.keycode, tt.state)
+			}
+			if got.EventType != want {
+				t.Errorf("EventType = %v, want %v", got.EventType, want)
+			}
+			if got.Code != tt.code {
+				t.Errorf("Code = %q, want %q", got.Code, tt.code)
+			}
+			if got.Key != tt.key {
+				t.Errorf("Key = %q, want %q", got.Key, tt.key)
+			}
+			if got.Location != tt.location {
+				t.Errorf("Location = %v, want %v", got.Location, tt.location)
+			}
+			if got.Shift != tt.shift || got.Ctrl != tt.ctrl || got.Alt != tt.alt {
+				t.Errorf("modifiers = shift %v ctrl %v alt %v, want shift %v ctrl %v alt %v",
+					got.Shift, got.Ctrl, got.Alt, tt.shift, tt.ctrl, tt.alt)
+			}
+		})
+	}
+}
+
+func TestKeyTranslator_Repeat(t *testing.T) {
+	translator := NewKeyTranslator()
+	want := []protocol.KeyboardEventType{
+		protocol.KeyboardEventTypeDown,
+		protocol.KeyboardEventTypeRepeat,
+		protocol.KeyboardEventTypeRepeat,
+		protocol.KeyboardEventTypeUp,
+		protocol.KeyboardEventTypeDown,
+	}
+	got := []protocol.KeyboardEventType{
+		translator.Press(gdk.KEY_a, 0x26, 0).EventType,
+		translator.Press(gdk.KEY_a, 0x26, 0).EventType,
+		translator.Press(gdk.KEY_a, 0x26, 0).EventType,
+		translator.Release(gdk.KEY_a, 0x26, 0).EventType,
+		translator.Press(gdk.KEY_a, 0x26, 0).EventType,
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestKeyTranslator_Interleaved(t *testing.T) {
+	translator := NewKeyTranslator()
+	// a down, s down, a up, s up: the second press must not be taken for a repeat.
+	events := []protocol.KeyboardEvent{
+		translator.Press(gdk.KEY_a, 0x26, 0),
+		translator.Press(gdk.KEY_s, 0x27, 0),
+		translator.Release(gdk.KEY_a, 0x26, 0),
+		translator.Release(gdk.KEY_s, 0x27, 0),
+	}
+	want := []struct {
+		code      string
+		eventType protocol.KeyboardEventType
+	}{
+		{"KeyA", protocol.KeyboardEventTypeDown},
+		{"KeyS", protocol.KeyboardEventTypeDown},
+		{"KeyA", protocol.KeyboardEventTypeUp},
+		{"KeyS", protocol.KeyboardEventTypeUp},
+	}
+	for i, e := range events {
+		if e.Code != want[i].code || e.EventType != want[i].eventType {
+			t.Errorf("event %d = %s %v, want %s %v", i, e.Code, e.EventType, want[i].code, want[i].eventType)
+		}
+	}
+}
+
+// fakeComposer composes a dead acute with the next letter and passes everything else through.
+type fakeComposer struct {
+	dead  bool
+	reset bool
+}
+
+func (c *fakeComposer) Compose(keyVal, _ uint, _ gdk.ModifierType) (string, bool) {
+	switch {
+	case keyVal == gdk.KEY_dead_acute:
+		c.dead = true
+		return "", true
+	case c.dead && keyVal == gdk.KEY_e:
+		c.dead = false
+		return "é", false
+	case keyVal == gdk.KEY_Shift_L:
+		return "", false
+	}
+	return KeyName(keyVal), false
+}
+
+func (c *fakeComposer) Reset() {
+	c.reset = true
+}
+
+func TestKeyTranslator_Compose(t *testing.T) {
+	translator := NewKeyTranslator()
+	translator.Composer = new(fakeComposer)
+	events := []protocol.KeyboardEvent{
+		translator.Press(gdk.KEY_dead_acute, 0x15, 0),
+		translator.Release(gdk.KEY_dead_acute, 0x15, 0),
+		translator.Press(gdk.KEY_e, 0x1A, 0),
+		translator.Release(gdk.KEY_e, 0x1A, 0),
+		translator.Press(gdk.KEY_Shift_L, 0x32, 0),
+		translator.Release(gdk.KEY_Shift_L, 0x32, gdk.ShiftMask),
+	}
+	want := []struct {
+		code string
+		key  string
+	}{
+		{"Equal", "Dead"},
+		{"Equal", "Dead"},
+		{"KeyE", "é"},
+		{"KeyE", "é"},
+		{"ShiftLeft", "Shift"},
+		{"ShiftLeft", "Shift"},
+	}
+	for i, e := range events {
+		if e.Code != want[i].code || e.Key != want[i].key {
+			t.Errorf("event %d = %s %q, want %s %q", i, e.Code, e.Key, want[i].code, want[i].key)
+		}
+	}
+}
+
+func TestKeyTranslator_ReleaseKeepsPressKey(t *testing.T) {
+	translator := NewKeyTranslator()
+	// Shift released before the letter: the release still reports the key the press produced.
+	down := translator.Press(gdk.KEY_A, 0x26, gdk.ShiftMask)
+	up := translator.Release(gdk.KEY_a, 0x26, 0)
+	if down.Key != "A" || up.Key != "A" {
+		t.Errorf("keys = %q, %q, want A, A", down.Key, up.Key)
+	}
+}
+
+func TestKeyTranslator_Reset(t *testing.T) {
+	translator := NewKeyTranslator()
+	composer := new(fakeComposer)
+	translator.Composer = composer
+	translator.Press(gdk.KEY_Alt_L, 0x40, 0)
+	translator.Press(gdk.KEY_Tab, 0x17, gdk.AltMask)
+	translator.Release(gdk.KEY_Tab, 0x17, gdk.AltMask)
+	translator.Press(gdk.KEY_a, 0x26, gdk.AltMask)
+
+	events := translator.Reset()
+	want := []struct {
+		code string
+		key  string
+		alt  bool
+	}{
+		// ordered by keycode, KeyA is released while Alt is still held
+		{"KeyA", "a", true},
+		{"AltLeft", "Alt", false},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Reset() = %v, want %d releases", events, len(want))
+	}
+	for i, e := range events {
+		if e.EventType != protocol.KeyboardEventTypeUp || e.Code != want[i].code || e.Key != want[i].key || e.Alt != want[i].alt {
+			t.Errorf("release %d = %+v, want %s %q alt %v", i, e, want[i].code, want[i].key, want[i].alt)
+		}
+	}
+	if !composer.reset {
+		t.Error("Reset() did not reset the composer")
+	}
+	if e := translator.Press(gdk.KEY_Alt_L, 0x40, 0); e.EventType != protocol.KeyboardEventTypeDown {
+		t.Errorf("press after Reset() = %s, want down", e.EventType)
+	}
+	if events := translator.Reset(); len(events) != 1 {
+		t.Errorf("second Reset() = %v, want only the new press released", events)
+	}
+}
+
+func TestKeyLocation_CodeValue(t *testing.T) {
+	// Every code the table marks as left, right or numpad must map to that location
+	// even if the keyval gives no hint.
+	for keycode, code := range protocol.CodeValue {
+		var want protocol.KeyboardLocation
+		switch code {
+		case "ShiftLeft", "ControlLeft", "AltLeft", "MetaLeft":
+			want = protocol.KeyboardLocationLeft
+		case "ShiftRight", "ControlRight", "AltRight", "MetaRight":
+			want = protocol.KeyboardLocationRight
+		default:
+			if len(code) > 6 && code[:6] == "Numpad" {
+				want = protocol.KeyboardLocationNumpad
+			}
+		}
+		if got := KeyLocation(gdk.KEY_VoidSymbol, keycode); got != want {
+			t.Errorf("KeyLocation(%#x %s) = %v, want %v", keycode, code, got, want)
+		}
+	}
+}
+
+func TestKeyName(t *testing.T) {
+	tests := []struct {
+		keyVal uint
+		want   string
+	}{
+		{gdk.KEY_adiaeresis, "ä"},
+		{gdk.KEY_Cyrillic_ef, "ф"},
+		{gdk.KEY_dead_acute, "Dead"},
+		{gdk.KEY_dead_circumflex, "Dead"},
+		{gdk.KEY_Multi_key, "Compose"},
+		{gdk.KEY_VoidSymbol, "Unidentified"},
+	}
+	for _, tt := range tests {
+		if got := KeyName(tt.keyVal); got != tt.want {
+			t.Errorf("KeyName(%#x) = %q, want %q", tt.keyVal, got, tt.want)
+		}
+	}
+}
+
+func TestKeyName_KeyValue(t *testing.T) {
+	for keyVal, key := range protocol.KeyValue {
+		if got := KeyName(keyVal); got != key {
+			t.Errorf("KeyName(%#x) = %q, want %q", keyVal, got, key)
+		}
+	}
+}
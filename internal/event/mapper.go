@@ -0,0 +1,162 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"math"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+)
+
+// Area is a rectangle. Areas of the video and the capturable are normalized to [0, 1].
+type Area struct {
+	X      float64 `mapstructure:"x"`
+	Y      float64 `mapstructure:"y"`
+	Width  float64 `mapstructure:"width"`
+	Height float64 `mapstructure:"height"`
+}
+
+// FullArea covers the whole video or capturable.
+var FullArea = Area{Width: 1, Height: 1}
+
+// IsZero reports whether the area is unset.
+func (a Area) IsZero() bool {
+	return a == Area{}
+}
+
+// Contains reports whether a point lies in the area, edges included.
+func (a Area) Contains(x, y float64) bool {
+	return x >= a.X && x <= a.X+a.Width && y >= a.Y && y <= a.Y+a.Height
+}
+
+// Validate checks that a normalized area is not empty and lies within [0, 1].
+func (a Area) Validate() error {
+	if a.Width <= 0 || a.Height <= 0 {
+		return errors.Errorf("area %+v is empty", a)
+	}
+	if a.X < 0 || a.Y < 0 || a.X+a.Width > 1 || a.Y+a.Height > 1 {
+		return errors.Errorf("area %+v exceeds [0, 1]", a)
+	}
+	return nil
+}
+
+// ActiveArea maps a region of the video to a region of the capturable, e.g. to use only part of a tablet for
+// one screen. Unset areas cover everything.
+type ActiveArea struct {
+	// Input is the region of the video that receives input.
+	Input Area `mapstructure:"input"`
+	// Output is the region of the capturable the input region is mapped to.
+	Output Area `mapstructure:"output"`
+}
+
+// Validate checks both areas.
+func (a ActiveArea) Validate() error {
+	if !a.Input.IsZero() {
+		if err := a.Input.Validate(); err != nil {
+			return errors.Wrap(err, "input")
+		}
+	}
+	if !a.Output.IsZero() {
+		if err := a.Output.Validate(); err != nil {
+			return errors.Wrap(err, "output")
+		}
+	}
+	return nil
+}
+
+// CoordinateMapper converts widget coordinates to the normalized capturable coordinates the server expects.
+// The video is scaled into the view keeping its aspect ratio and centered, so it may be letterboxed.
+type CoordinateMapper struct {
+	// ActiveArea restricts and redirects input, the zero value maps the whole video to the whole capturable.
+	ActiveArea ActiveArea
+
+	view        Area
+	scale       float64
+	videoWidth  float64
+	videoHeight float64
+}
+
+// SetView sets the bounds of the widget showing the video, in coordinates of the widget receiving input,
+// and the scale factor of its surface.
+func (m *CoordinateMapper) SetView(view Area, scale int) {
+	m.view = view
+	m.scale = float64(scale)
+}
+
+// SetVideoSize sets the size of the video frames in pixels.
+func (m *CoordinateMapper) SetVideoSize(width, height int) {
+	m.videoWidth, m.videoHeight = float64(width), float64(height)
+}
+
+// VideoRect returns where the video is drawn in widget coordinates.
+func (m *CoordinateMapper) VideoRect() Area {
+	if m.videoWidth <= 0 || m.videoHeight <= 0 || m.view.Width <= 0 || m.view.Height <= 0 {
+		return m.view
+	}
+	fit := math.Min(m.view.Width/m.videoWidth, m.view.Height/m.videoHeight)
+	width, height := m.videoWidth*fit, m.videoHeight*fit
+	return Area{
+		X:      m.view.X + (m.view.Width-width)/2,
+		Y:      m.view.Y + (m.view.Height-height)/2,
+		Width:  width,
+		Height: height,
+	}
+}
+
+// Map converts a point in widget coordinates to normalized capturable coordinates.
+// It reports false for points outside the video or its active input area, the result is then clamped to it.
+func (m *CoordinateMapper) Map(x, y float64) (float64, float64, bool) {
+	video := m.VideoRect()
+	if video.Width <= 0 || video.Height <= 0 {
+		return 0, 0, false
+	}
+	x, y = (x-video.X)/video.Width, (y-video.Y)/video.Height
+
+	input, output := m.ActiveArea.Input, m.ActiveArea.Output
+	if input.IsZero() {
+		input = FullArea
+	}
+	if output.IsZero() {
+		output = FullArea
+	}
+	inside := input.Contains(x, y)
+	x = math.Max(0, math.Min(1, (x-input.X)/input.Width))
+	y = math.Max(0, math.Min(1, (y-input.Y)/input.Height))
+	return output.X + x*output.Width, output.Y + y*output.Height, inside
+}
+
+// MapEvent maps the position of a pointer event and converts its contact size and movement to device pixels.
+// It reports false for events outside the video that should be dropped. Ending events are never dropped, they
+// are clamped to the video instead, so the server does not keep buttons or contacts held. Relative events are
+// never dropped either, their position does not matter.
+func (m *CoordinateMapper) MapEvent(e protocol.PointerEvent) (protocol.PointerEvent, bool) {
+	x, y, inside := m.Map(e.X, e.Y)
+	if !inside && !e.Relative && e.EventType != protocol.PointerEventTypeUp && e.EventType != protocol.PointerEventTypeCancel {
+		return e, false
+	}
+	e.X, e.Y = x, y
+	if m.scale > 1 {
+		e.Width *= m.scale
+		e.Height *= m.scale
+		e.MovementX = int64(math.Round(float64(e.MovementX) * m.scale))
+		e.MovementY = int64(math.Round(float64(e.MovementY) * m.scale))
+	}
+	return e, true
+}
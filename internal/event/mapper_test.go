@@ -0,0 +1,163 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"math"
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+)
+
+func TestCoordinateMapper_VideoRect(t *testing.T) {
+	tests := []struct {
+		name          string
+		view          Area
+		width, height int
+		want          Area
+	}{
+		{name: "Exact", view: Area{Width: 160, Height: 90}, width: 1920, height: 1080, want: Area{Width: 160, Height: 90}},
+		{name: "Pillarbox", view: Area{Width: 400, Height: 90}, width: 1920, height: 1080, want: Area{X: 120, Width: 160, Height: 90}},
+		{name: "Letterbox", view: Area{X: 10, Y: 20, Width: 160, Height: 190}, width: 1920, height: 1080, want: Area{X: 10, Y: 70, Width: 160, Height: 90}},
+		{name: "NoVideo", view: Area{Width: 160, Height: 90}, want: Area{Width: 160, Height: 90}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m CoordinateMapper
+			m.SetView(tt.view, 1)
+			m.SetVideoSize(tt.width, tt.height)
+			if got := m.VideoRect(); got != tt.want {
+				t.Errorf("VideoRect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoordinateMapper_Map(t *testing.T) {
+	tests := []struct {
+		name       string
+		activeArea ActiveArea
+		x, y       float64
+		wantX      float64
+		wantY      float64
+		wantInside bool
+	}{
+		{name: "Center", x: 200, y: 45, wantX: 0.5, wantY: 0.5, wantInside: true},
+		{name: "TopLeft", x: 120, y: 0, wantX: 0, wantY: 0, wantInside: true},
+		{name: "BottomRight", x: 280, y: 90, wantX: 1, wantY: 1, wantInside: true},
+		{name: "LeftBar", x: 60, y: 45, wantX: 0, wantY: 0.5},
+		{name: "RightBar", x: 300, y: 45, wantX: 1, wantY: 0.5},
+		{
+			name:       "InputArea",
+			activeArea: ActiveArea{Input: Area{X: 0.5, Y: 0.5, Width: 0.5, Height: 0.5}},
+			x:          240, y: 67.5, wantX: 0.5, wantY: 0.5, wantInside: true,
+		},
+		{
+			name:       "OutsideInputArea",
+			activeArea: ActiveArea{Input: Area{X: 0.5, Y: 0.5, Width: 0.5, Height: 0.5}},
+			x:          160, y: 45, wantX: 0, wantY: 0,
+		},
+		{
+			name:       "OutputArea",
+			activeArea: ActiveArea{Output: Area{X: 0.5, Width: 0.5, Height: 1}},
+			x:          200, y: 45, wantX: 0.75, wantY: 0.5, wantInside: true,
+		},
+		{
+			name: "InputToOutputArea",
+			activeArea: ActiveArea{
+				Input:  Area{Width: 0.5, Height: 0.5},
+				Output: Area{X: 0.25, Y: 0.25, Width: 0.5, Height: 0.5},
+			},
+			x: 160, y: 22.5, wantX: 0.5, wantY: 0.5, wantInside: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := CoordinateMapper{ActiveArea: tt.activeArea}
+			// a 16:9 video pillarboxed to 120..280 in a 400x90 view
+			m.SetView(Area{Width: 400, Height: 90}, 1)
+			m.SetVideoSize(1920, 1080)
+			x, y, inside := m.Map(tt.x, tt.y)
+			if math.Abs(x-tt.wantX) > 1e-9 || math.Abs(y-tt.wantY) > 1e-9 || inside != tt.wantInside {
+				t.Errorf("Map(%v, %v) = %v, %v, %v, want %v, %v, %v", tt.x, tt.y, x, y, inside, tt.wantX, tt.wantY, tt.wantInside)
+			}
+		})
+	}
+}
+
+func TestCoordinateMapper_MapEvent(t *testing.T) {
+	var m CoordinateMapper
+	m.SetView(Area{Width: 400, Height: 90}, 2)
+	m.SetVideoSize(1920, 1080)
+
+	e, ok := m.MapEvent(protocol.PointerEvent{
+		EventType: protocol.PointerEventTypeMove,
+		X:         200, Y: 45,
+		Width: 3, Height: 4,
+		MovementX: 5, MovementY: -6,
+	})
+	if !ok || e.X != 0.5 || e.Y != 0.5 {
+		t.Errorf("MapEvent(inside) = %v, %v, %v, want 0.5, 0.5, true", e.X, e.Y, ok)
+	}
+	if e.Width != 6 || e.Height != 8 || e.MovementX != 10 || e.MovementY != -12 {
+		t.Errorf("MapEvent(inside) size %v x %v movement %d,%d, want 6 x 8 movement 10,-12", e.Width, e.Height, e.MovementX, e.MovementY)
+	}
+
+	for _, eventType := range []protocol.PointerEventType{protocol.PointerEventTypeMove, protocol.PointerEventTypeDown} {
+		if _, ok := m.MapEvent(protocol.PointerEvent{EventType: eventType, X: 60, Y: 45}); ok {
+			t.Errorf("MapEvent(%s outside) not dropped", eventType)
+		}
+	}
+	for _, eventType := range []protocol.PointerEventType{protocol.PointerEventTypeUp, protocol.PointerEventTypeCancel} {
+		e, ok := m.MapEvent(protocol.PointerEvent{EventType: eventType, X: 60, Y: 45})
+		if !ok || e.X != 0 || e.Y != 0.5 {
+			t.Errorf("MapEvent(%s outside) = %v, %v, %v, want clamped to 0, 0.5", eventType, e.X, e.Y, ok)
+		}
+	}
+
+	if _, ok := m.MapEvent(protocol.PointerEvent{EventType: protocol.PointerEventTypeMove, X: 60, Y: 45, Relative: true}); !ok {
+		t.Error("MapEvent(relative outside) dropped")
+	}
+
+	var empty CoordinateMapper
+	if _, ok := empty.MapEvent(protocol.PointerEvent{EventType: protocol.PointerEventTypeMove}); ok {
+		t.Error("MapEvent without view not dropped")
+	}
+}
+
+func TestActiveArea_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		activeArea ActiveArea
+		wantErr    bool
+	}{
+		{name: "Zero"},
+		{name: "Valid", activeArea: ActiveArea{Input: Area{X: 0.5, Width: 0.5, Height: 1}, Output: FullArea}},
+		{name: "EmptyInput", activeArea: ActiveArea{Input: Area{X: 0.5}}, wantErr: true},
+		{name: "OutputTooLarge", activeArea: ActiveArea{Output: Area{X: 0.5, Width: 0.75, Height: 1}}, wantErr: true},
+		{name: "Negative", activeArea: ActiveArea{Output: Area{X: -0.1, Width: 0.5, Height: 1}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.activeArea.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"math"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+// MousePointerID is the PointerID of mouse events.
+const MousePointerID = 1
+
+// MouseButton maps a GDK button number to the protocol button.
+func MouseButton(button uint) protocol.ButtonFlags {
+	switch button {
+	case gdk.BUTTON_PRIMARY:
+		return protocol.ButtonPrimary
+	case gdk.BUTTON_SECONDARY:
+		return protocol.ButtonSecondary
+	case gdk.BUTTON_MIDDLE:
+		return protocol.ButtonAuxiliary
+	case 8:
+		return protocol.ButtonFourth
+	case 9:
+		return protocol.ButtonFifth
+	}
+	return protocol.ButtonNone
+}
+
+// MouseTracker turns button presses and motion of mice, touchpads and other indirect devices into PointerEvents.
+// Movement is reported in whole pixels, the fractions are carried over to the next event.
+type MouseTracker struct {
+	// Relative marks events as relative mouse input, for which the server only uses the movement.
+	Relative bool
+
+	buttons    protocol.ButtonFlags
+	lastX      float64
+	lastY      float64
+	remX, remY float64
+	hasLast    bool
+}
+
+// Down returns the pointerdown event for pressing button.
+func (t *MouseTracker) Down(x, y float64, button uint, timestamp uint64) protocol.PointerEvent {
+	btn := MouseButton(button)
+	t.buttons |= btn
+	e := t.event(protocol.PointerEventTypeDown, x, y, timestamp)
+	e.Button = btn
+	return e
+}
+
+// Up returns the pointerup event for releasing button.
+func (t *MouseTracker) Up(x, y float64, button uint, timestamp uint64) protocol.PointerEvent {
+	btn := MouseButton(button)
+	t.buttons &^= btn
+	e := t.event(protocol.PointerEventTypeUp, x, y, timestamp)
+	e.Button = btn
+	return e
+}
+
+// Motion returns the pointermove event of the pointer moving.
+func (t *MouseTracker) Motion(x, y float64, timestamp uint64) protocol.PointerEvent {
+	return t.event(protocol.PointerEventTypeMove, x, y, timestamp)
+}
+
+// Buttons returns the currently held buttons.
+func (t *MouseTracker) Buttons() protocol.ButtonFlags {
+	return t.buttons
+}
+
+func (t *MouseTracker) event(eventType protocol.PointerEventType, x, y float64, timestamp uint64) protocol.PointerEvent {
+	e := protocol.PointerEvent{
+		EventType:   eventType,
+		PointerType: protocol.PointerTypeMouse,
+		X:           x,
+		Y:           y,
+		Width:       1,
+		Height:      1,
+		PointerID:   MousePointerID,
+		Timestamp:   timestamp,
+		Buttons:     t.buttons,
+		IsPrimary:   true,
+		Relative:    t.Relative,
+	}
+	// pointer events report 0.5 for devices without pressure while a button is held
+	if t.buttons != protocol.ButtonNone {
+		e.Pressure = 0.5
+	}
+	if t.hasLast {
+		dx, dy := x-t.lastX+t.remX, y-t.lastY+t.remY
+		e.MovementX, e.MovementY = int64(math.Round(dx)), int64(math.Round(dy))
+		t.remX, t.remY = dx-float64(e.MovementX), dy-float64(e.MovementY)
+	}
+	t.lastX, t.lastY, t.hasLast = x, y, true
+	return e
+}
+
+// Leave resets the tracker when the pointer leaves the widget, so the next event has no movement.
+func (t *MouseTracker) Leave() {
+	t.hasLast = false
+	t.remX, t.remY = 0, 0
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+func TestMouseButton(t *testing.T) {
+	tests := []struct {
+		button uint
+		want   protocol.ButtonFlags
+	}{
+		{gdk.BUTTON_PRIMARY, protocol.ButtonPrimary},
+		{gdk.BUTTON_MIDDLE, protocol.ButtonAuxiliary},
+		{gdk.BUTTON_SECONDARY, protocol.ButtonSecondary},
+		{8, protocol.ButtonFourth},
+		{9, protocol.ButtonFifth},
+		{0, protocol.ButtonNone},
+		{10, protocol.ButtonNone},
+	}
+	for _, tt := range tests {
+		if got := MouseButton(tt.button); got != tt.want {
+			t.Errorf("MouseButton(%d) = %v, want %v", tt.button, got, tt.want)
+		}
+	}
+}
+
+func TestMouseTracker(t *testing.T) {
+	var mouse MouseTracker
+	events := []protocol.PointerEvent{
+		mouse.Motion(10, 10, 1),
+		mouse.Down(12, 11, gdk.BUTTON_PRIMARY, 2),
+		mouse.Down(12, 11, gdk.BUTTON_SECONDARY, 3),
+		mouse.Motion(20, 15, 4),
+		mouse.Up(20, 15, gdk.BUTTON_PRIMARY, 5),
+		mouse.Up(20, 15, gdk.BUTTON_SECONDARY, 6),
+	}
+	want := []struct {
+		eventType protocol.PointerEventType
+		button    protocol.ButtonFlags
+		buttons   protocol.ButtonFlags
+		pressure  float64
+		movementX int64
+		movementY int64
+	}{
+		{protocol.PointerEventTypeMove, protocol.ButtonNone, protocol.ButtonNone, 0, 0, 0},
+		{protocol.PointerEventTypeDown, protocol.ButtonPrimary, protocol.ButtonPrimary, 0.5, 2, 1},
+		{protocol.PointerEventTypeDown, protocol.ButtonSecondary, protocol.ButtonPrimary | protocol.ButtonSecondary, 0.5, 0, 0},
+		{protocol.PointerEventTypeMove, protocol.ButtonNone, protocol.ButtonPrimary | protocol.ButtonSecondary, 0.5, 8, 4},
+		{protocol.PointerEventTypeUp, protocol.ButtonPrimary, protocol.ButtonSecondary, 0.5, 0, 0},
+		{protocol.PointerEventTypeUp, protocol.ButtonSecondary, protocol.ButtonNone, 0, 0, 0},
+	}
+	for i, e := range events {
+		w := want[i]
+		if e.EventType != w.eventType || e.Button != w.button || e.Buttons != w.buttons {
+			t.Errorf("event %d = %v button %v buttons %v, want %v button %v buttons %v",
+				i, e.EventType, e.Button, e.Buttons, w.eventType, w.button, w.buttons)
+		}
+		if e.Pressure != w.pressure {
+			t.Errorf("event %d pressure = %v, want %v", i, e.Pressure, w.pressure)
+		}
+		if e.MovementX != w.movementX || e.MovementY != w.movementY {
+			t.Errorf("event %d movement = %d,%d, want %d,%d", i, e.MovementX, e.MovementY, w.movementX, w.movementY)
+		}
+		if e.PointerType != protocol.PointerTypeMouse || e.PointerID != MousePointerID || !e.IsPrimary {
+			t.Errorf("event %d = %q id %d primary %v, want mouse id %d primary", i, e.PointerType, e.PointerID, e.IsPrimary, MousePointerID)
+		}
+	}
+}
+
+func TestMouseTracker_Relative(t *testing.T) {
+	mouse := MouseTracker{Relative: true}
+	var x float64
+	var total int64
+	mouse.Motion(x, 0, 0)
+	// sub-pixel steps must add up instead of being rounded away
+	for i := 0; i < 10; i++ {
+		x += 0.3
+		e := mouse.Motion(x, 0, uint64(i))
+		if !e.Relative {
+			t.Fatalf("event %d is not relative", i)
+		}
+		total += e.MovementX
+	}
+	if total != 3 {
+		t.Errorf("total movement = %d, want 3", total)
+	}
+
+	mouse.Leave()
+	if e := mouse.Motion(100, 100, 11); e.MovementX != 0 || e.MovementY != 0 {
+		t.Errorf("movement after Leave = %d,%d, want 0,0", e.MovementX, e.MovementY)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+func TestPressureCurveNames(t *testing.T) {
+	names := PressureCurveNames()
+	for _, name := range names {
+		if i := lo.IndexOf(_PressureCurveNames, name); i < 0 {
+			t.Fatalf("value %v not in list _PressureCurveNames", name)
+		}
+	}
+	for _, name := range _PressureCurveNames {
+		if i := lo.IndexOf(names, name); i < 0 {
+			t.Fatalf("value %v not returned", name)
+		}
+	}
+}
+
+func TestPressureCurveValues(t *testing.T) {
+	values := PressureCurveValues()
+	for _, value := range values {
+		if _, ok := lo.FindKey(_PressureCurveValue, value); !ok {
+			t.Fatalf("value %v not in map _PressureCurveValue", value)
+		}
+	}
+	for _, value := range _PressureCurveValue {
+		if i := lo.IndexOf(values, value); i < 0 {
+			t.Fatalf("value %v not returned", value)
+		}
+	}
+}
+
+func TestPressureCurve_String(t *testing.T) {
+	for s, command := range _PressureCurveValue {
+		if command.String() != s {
+			t.Fatalf("String returned invalid result %s for value %v", command.String(), s)
+		}
+	}
+}
+
+func TestPressureCurve_IsValid(t *testing.T) {
+	for _, command := range _PressureCurveValue {
+		if !command.IsValid() {
+			t.Fatalf("value %v is invalid", command)
+		}
+	}
+}
+
+func TestPressureCurve_MarshalText(t *testing.T) {
+	for s, command := range _PressureCurveValue {
+		if b, _ := command.MarshalText(); string(b) != s {
+			t.Fatalf("Marshal %v returned invalid value %s", command, string(b))
+		}
+	}
+}
+
+func TestPressureCurve_UnmarshalText_Correct(t *testing.T) {
+	var foo PressureCurve
+	for s, command := range _PressureCurveValue {
+		if err := foo.UnmarshalText([]byte(s)); err != nil {
+			if err.Error() != fmt.Errorf("%s is %w", s, ErrInvalidPressureCurve).Error() {
+				t.Fatalf("invalid error on unmarshal %s: %v", s, err)
+			}
+		} else if foo != command {
+			t.Fatalf("Unmarshal %s returned invalid value %s", s, foo)
+		}
+	}
+}
+
+func TestPressureCurve_UnmarshalText_Invalid(t *testing.T) {
+	var foo PressureCurve
+	for _, s := range []string{"0"} {
+		if err := foo.UnmarshalText([]byte(s)); err != nil {
+			if err.Error() != fmt.Errorf("%s is %w", s, ErrInvalidPressureCurve).Error() {
+				t.Fatalf("invalid error on unmarshal %s: %v", s, err)
+			}
+		}
+	}
+}
+
+func FuzzPressureCurve_UnmarshalText(f *testing.F) {
+	for _, seed := range PressureCurveValues() {
+		b, _ := seed.MarshalText()
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		var res PressureCurve
+		err := res.UnmarshalText(in)
+		if err != nil {
+			if err.Error() != fmt.Errorf("%s is %w", string(in), ErrInvalidPressureCurve).Error() {
+				t.Fatalf("invalid error on unmarshal %s: %v", string(in), err)
+			}
+		}
+	})
+}
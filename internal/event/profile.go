@@ -0,0 +1,158 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"math"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+)
+
+// DefaultProfileName is the name of the profile used for devices without a profile of their own.
+const DefaultProfileName = "default"
+
+// InputProfile tunes the feel of a pressure sensitive device.
+type InputProfile struct {
+	// Curve maps the raw pressure, an empty curve is linear.
+	Curve PressureCurve `mapstructure:"curve"`
+	// Gamma is the exponent of the gamma curve, below 1 makes light strokes heavier.
+	Gamma float64 `mapstructure:"gamma"`
+	// Bezier are the control points x1, y1, x2, y2 of the bezier curve from (0, 0) to (1, 1),
+	// like the CSS cubic-bezier() function.
+	Bezier [4]float64 `mapstructure:"bezier"`
+	// MinPressure is the pressure reported for the lightest touch, the curve is scaled to the range above it.
+	MinPressure float64 `mapstructure:"min-pressure"`
+	// TiltDeadZone is the tilt in degrees that is still reported as upright.
+	TiltDeadZone float64 `mapstructure:"tilt-dead-zone"`
+}
+
+// Validate checks that the parameters of the profile are in range.
+func (p InputProfile) Validate() error {
+	switch p.Curve {
+	case "", PressureCurveLinear:
+	case PressureCurveGamma:
+		if p.Gamma <= 0 {
+			return errors.Errorf("gamma %v must be positive", p.Gamma)
+		}
+	case PressureCurveBezier:
+		if p.Bezier[0] < 0 || p.Bezier[0] > 1 || p.Bezier[2] < 0 || p.Bezier[2] > 1 {
+			return errors.Errorf("bezier control points %v must have x in [0, 1]", p.Bezier)
+		}
+	default:
+		return errors.Wrap(ErrInvalidPressureCurve, string(p.Curve))
+	}
+	if p.MinPressure < 0 || p.MinPressure >= 1 {
+		return errors.Errorf("min-pressure %v must be in [0, 1)", p.MinPressure)
+	}
+	if p.TiltDeadZone < 0 || p.TiltDeadZone >= 90 {
+		return errors.Errorf("tilt-dead-zone %v must be in [0, 90)", p.TiltDeadZone)
+	}
+	return nil
+}
+
+// Pressure maps a raw pressure in [0, 1]. No pressure stays 0, so hovering is not turned into touching.
+func (p InputProfile) Pressure(raw float64) float64 {
+	if raw <= 0 {
+		return 0
+	}
+	raw = math.Min(1, raw)
+	var mapped float64
+	switch p.Curve {
+	case PressureCurveGamma:
+		mapped = math.Pow(raw, p.Gamma)
+	case PressureCurveBezier:
+		mapped = cubicBezier(p.Bezier, raw)
+	default:
+		mapped = raw
+	}
+	mapped = math.Max(0, math.Min(1, mapped))
+	return p.MinPressure + mapped*(1-p.MinPressure)
+}
+
+// Tilt maps a tilt in degrees. Tilt inside the dead zone is reported as 0,
+// the rest is stretched so the reported tilt still reaches ±90°.
+func (p InputProfile) Tilt(tilt int32) int32 {
+	if p.TiltDeadZone <= 0 {
+		return tilt
+	}
+	magnitude := math.Abs(float64(tilt))
+	if magnitude <= p.TiltDeadZone {
+		return 0
+	}
+	scaled := math.Round((magnitude - p.TiltDeadZone) * 90 / (90 - p.TiltDeadZone))
+	if tilt < 0 {
+		return -int32(scaled)
+	}
+	return int32(scaled)
+}
+
+// Apply applies the profile to a pen event. Mouse and touch events pass unchanged,
+// their pressure is mostly the 0.5 reported for devices without pressure.
+func (p InputProfile) Apply(e protocol.PointerEvent) protocol.PointerEvent {
+	if e.PointerType != protocol.PointerTypePen {
+		return e
+	}
+	e.Pressure = p.Pressure(e.Pressure)
+	e.TiltX = p.Tilt(e.TiltX)
+	e.TiltY = p.Tilt(e.TiltY)
+	return e
+}
+
+// cubicBezier evaluates the curve from (0, 0) to (1, 1) with control points (x1, y1) and (x2, y2) at x.
+func cubicBezier(points [4]float64, x float64) float64 {
+	x1, y1, x2, y2 := points[0], points[1], points[2], points[3]
+	bezier := func(t, p1, p2 float64) float64 {
+		u := 1 - t
+		return 3*u*u*t*p1 + 3*u*t*t*p2 + t*t*t
+	}
+	// x(t) is monotonic for control points with x in [0, 1], so bisection finds the t for x
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 32; i++ {
+		t := (lo + hi) / 2
+		if bezier(t, x1, x2) < x {
+			lo = t
+		} else {
+			hi = t
+		}
+	}
+	return bezier((lo+hi)/2, y1, y2)
+}
+
+// InputProfiles are input profiles by device name.
+type InputProfiles map[string]InputProfile
+
+// Validate checks every profile.
+func (p InputProfiles) Validate() error {
+	for name, profile := range p {
+		if err := profile.Validate(); err != nil {
+			return errors.Wrapf(err, "input profile %q", name)
+		}
+	}
+	return nil
+}
+
+// For returns the profile of a device, the default profile if it has none,
+// or a linear profile if there is no default either.
+func (p InputProfiles) For(device string) InputProfile {
+	if profile, ok := p[device]; ok {
+		return profile
+	}
+	return p[DefaultProfileName]
+}
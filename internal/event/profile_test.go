@@ -0,0 +1,134 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"math"
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+)
+
+func TestInputProfile_Pressure(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile InputProfile
+		raw     float64
+		want    float64
+	}{
+		{name: "Zero", profile: InputProfile{}, raw: 0.5, want: 0.5},
+		{name: "Linear", profile: InputProfile{Curve: PressureCurveLinear}, raw: 0.3, want: 0.3},
+		{name: "Clamped", profile: InputProfile{Curve: PressureCurveLinear}, raw: 1.5, want: 1},
+		{name: "Hover", profile: InputProfile{Curve: PressureCurveLinear, MinPressure: 0.2}, raw: 0, want: 0},
+		{name: "Gamma", profile: InputProfile{Curve: PressureCurveGamma, Gamma: 2}, raw: 0.5, want: 0.25},
+		{name: "GammaSoft", profile: InputProfile{Curve: PressureCurveGamma, Gamma: 0.5}, raw: 0.25, want: 0.5},
+		{name: "BezierLinear", profile: InputProfile{Curve: PressureCurveBezier, Bezier: [4]float64{0.25, 0.25, 0.75, 0.75}}, raw: 0.4, want: 0.4},
+		{name: "BezierEnd", profile: InputProfile{Curve: PressureCurveBezier, Bezier: [4]float64{0, 1, 0, 1}}, raw: 1, want: 1},
+		{name: "BezierSoft", profile: InputProfile{Curve: PressureCurveBezier, Bezier: [4]float64{0, 1, 0, 1}}, raw: 0.125, want: 0.875},
+		{name: "MinPressure", profile: InputProfile{MinPressure: 0.2}, raw: 0.5, want: 0.6},
+		{name: "MinPressureLightest", profile: InputProfile{MinPressure: 0.2}, raw: 0.001, want: 0.2008},
+		{name: "MinPressureGamma", profile: InputProfile{Curve: PressureCurveGamma, Gamma: 2, MinPressure: 0.5}, raw: 0.5, want: 0.625},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.Pressure(tt.raw); math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("Pressure(%v) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputProfile_Tilt(t *testing.T) {
+	profile := InputProfile{TiltDeadZone: 10}
+	tests := []struct {
+		tilt int32
+		want int32
+	}{
+		{0, 0},
+		{5, 0},
+		{-10, 0},
+		{11, 1},
+		{55, 51},
+		{-55, -51},
+		{90, 90},
+		{-90, -90},
+	}
+	for _, tt := range tests {
+		if got := profile.Tilt(tt.tilt); got != tt.want {
+			t.Errorf("Tilt(%d) = %d, want %d", tt.tilt, got, tt.want)
+		}
+	}
+	if got := (InputProfile{}).Tilt(7); got != 7 {
+		t.Errorf("Tilt(7) without dead zone = %d, want 7", got)
+	}
+}
+
+func TestInputProfile_Apply(t *testing.T) {
+	profile := InputProfile{Curve: PressureCurveGamma, Gamma: 2, TiltDeadZone: 10}
+	pen := protocol.PointerEvent{PointerType: protocol.PointerTypePen, Pressure: 0.5, TiltX: 5, TiltY: -55}
+	if got := profile.Apply(pen); got.Pressure != 0.25 || got.TiltX != 0 || got.TiltY != -51 {
+		t.Errorf("Apply(pen) = pressure %v tilt %d,%d, want 0.25 tilt 0,-51", got.Pressure, got.TiltX, got.TiltY)
+	}
+	for _, pointerType := range []protocol.PointerType{protocol.PointerTypeMouse, protocol.PointerTypeTouch} {
+		e := protocol.PointerEvent{PointerType: pointerType, Pressure: 0.5}
+		if got := profile.Apply(e); got != e {
+			t.Errorf("Apply(%s) = %+v, want unchanged", pointerType, got)
+		}
+	}
+}
+
+func TestInputProfile_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile InputProfile
+		wantErr bool
+	}{
+		{name: "Zero", profile: InputProfile{}},
+		{name: "Gamma", profile: InputProfile{Curve: PressureCurveGamma, Gamma: 0.8}},
+		{name: "GammaZero", profile: InputProfile{Curve: PressureCurveGamma}, wantErr: true},
+		{name: "Bezier", profile: InputProfile{Curve: PressureCurveBezier, Bezier: [4]float64{0.2, 1.5, 0.8, -0.5}}},
+		{name: "BezierX", profile: InputProfile{Curve: PressureCurveBezier, Bezier: [4]float64{1.2, 0, 0.8, 1}}, wantErr: true},
+		{name: "UnknownCurve", profile: InputProfile{Curve: "cubic"}, wantErr: true},
+		{name: "MinPressure", profile: InputProfile{MinPressure: 1}, wantErr: true},
+		{name: "TiltDeadZone", profile: InputProfile{TiltDeadZone: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.profile.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInputProfiles_For(t *testing.T) {
+	profiles := InputProfiles{
+		DefaultProfileName: {Curve: PressureCurveGamma, Gamma: 2},
+		"Wacom Pen":        {MinPressure: 0.1},
+	}
+	if got := profiles.For("Wacom Pen"); got.MinPressure != 0.1 {
+		t.Errorf("For(Wacom Pen) = %+v, want its own profile", got)
+	}
+	if got := profiles.For("Huion Pen"); got.Curve != PressureCurveGamma {
+		t.Errorf("For(Huion Pen) = %+v, want the default profile", got)
+	}
+	if got := InputProfiles(nil).For("Huion Pen"); got != (InputProfile{}) {
+		t.Errorf("For(Huion Pen) without profiles = %+v, want zero profile", got)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+)
+
+func TestQueue(t *testing.T) {
+	q := NewQueue[protocol.KeyboardEvent]()
+	codes := []string{"KeyA", "KeyB", "KeyC"}
+	for _, code := range codes {
+		q.Push(protocol.KeyboardEvent{Code: code})
+	}
+	if q.Len() != len(codes) {
+		t.Fatalf("Len() = %d, want %d", q.Len(), len(codes))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, code := range codes {
+		e, err := q.Pop(ctx)
+		if err != nil {
+			t.Fatalf("Pop() error = %v", err)
+		}
+		if e.Code != code {
+			t.Errorf("Pop() = %s, want %s", e.Code, code)
+		}
+	}
+
+	done := make(chan protocol.KeyboardEvent)
+	go func() {
+		e, _ := q.Pop(ctx)
+		done <- e
+	}()
+	q.Push(protocol.KeyboardEvent{Code: "KeyD"})
+	if e := <-done; e.Code != "KeyD" {
+		t.Errorf("Pop() = %s, want KeyD", e.Code)
+	}
+
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, err := q.Pop(cancelled); err == nil {
+		t.Error("Pop() on empty queue with cancelled context returned no error")
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"math"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+// StylusPointerID is the PointerID of stylus events.
+const StylusPointerID = 2
+
+// StylusAxes are the axis values GDK reports for a stylus event.
+// Pressure is normalized to [0, 1], tilt to [-1, 1] for ±90° and rotation to [0, 1] for a full clockwise turn.
+// PointerEvent has no tangential pressure, so the Slider, the finger wheel of an airbrush in [0, 1], scales the
+// pressure like it opens the airbrush. Whether the stylus touches only follows the tip button, not the axes.
+type StylusAxes struct {
+	Pressure    float64
+	HasPressure bool
+	XTilt       float64
+	YTilt       float64
+	Rotation    float64
+	Slider      float64
+	HasSlider   bool
+}
+
+// pressure returns the pressure of a stylus touching the surface. Without a pressure axis it is 0.5, like the
+// pointer events spec asks for hardware without pressure. A closed or missing airbrush wheel leaves the pressure
+// alone, so a contact never loses its pressure to the wheel.
+func (a StylusAxes) pressure() float64 {
+	if !a.HasPressure {
+		return 0.5
+	}
+	p := a.Pressure
+	if a.HasSlider && a.Slider > 0 {
+		p *= a.Slider
+	}
+	return math.Max(0, math.Min(1, p))
+}
+
+// TiltDegrees converts a normalized GDK tilt axis to a PointerEvent tilt in [-90, 90] degrees.
+func TiltDegrees(axis float64) int32 {
+	return int32(math.Round(math.Max(-90, math.Min(90, 90*axis))))
+}
+
+// TwistDegrees converts a normalized GDK rotation axis to a PointerEvent twist in [0, 359] degrees.
+func TwistDegrees(axis float64) int32 {
+	twist := int32(math.Round(360*axis)) % 360
+	if twist < 0 {
+		twist += 360
+	}
+	return twist
+}
+
+// StylusButton maps a GDK button number of a stylus to the protocol button.
+// The tip is the primary button, or the eraser button for an eraser, the barrel buttons are secondary and
+// auxiliary like in the pointer events spec.
+func StylusButton(button uint, eraser bool) protocol.ButtonFlags {
+	if button == gdk.BUTTON_PRIMARY && eraser {
+		return protocol.ButtonEraser
+	}
+	return MouseButton(button)
+}
+
+// StylusTracker turns stylus contacts, hovering and button presses into PointerEvents.
+type StylusTracker struct {
+	buttons protocol.ButtonFlags
+	lastX   float64
+	lastY   float64
+	hasLast bool
+}
+
+// Down returns the pointerdown event for pressing button, the tip or a barrel button.
+func (s *StylusTracker) Down(x, y float64, axes StylusAxes, button uint, eraser bool, timestamp uint64) protocol.PointerEvent {
+	btn := StylusButton(button, eraser)
+	s.buttons |= btn
+	e := s.event(protocol.PointerEventTypeDown, x, y, axes, timestamp)
+	e.Button = btn
+	return e
+}
+
+// Up returns the pointerup event for releasing button.
+func (s *StylusTracker) Up(x, y float64, axes StylusAxes, button uint, eraser bool, timestamp uint64) protocol.PointerEvent {
+	btn := StylusButton(button, eraser)
+	s.buttons &^= btn
+	e := s.event(protocol.PointerEventTypeUp, x, y, axes, timestamp)
+	e.Button = btn
+	return e
+}
+
+// Motion returns the pointermove event of the stylus moving while it touches.
+func (s *StylusTracker) Motion(x, y float64, axes StylusAxes, timestamp uint64) protocol.PointerEvent {
+	return s.event(protocol.PointerEventTypeMove, x, y, axes, timestamp)
+}
+
+// Hover returns the pointermove event of the stylus moving in proximity without touching.
+// Hover events have no pressure and only the barrel buttons held.
+func (s *StylusTracker) Hover(x, y float64, axes StylusAxes, timestamp uint64) protocol.PointerEvent {
+	s.buttons &^= protocol.ButtonPrimary | protocol.ButtonEraser
+	axes.Pressure = 0
+	return s.event(protocol.PointerEventTypeMove, x, y, axes, timestamp)
+}
+
+// Leave resets the tracker when the stylus leaves proximity, so the next event has no movement.
+func (s *StylusTracker) Leave() {
+	s.buttons = protocol.ButtonNone
+	s.hasLast = false
+}
+
+// Buttons returns the currently held buttons.
+func (s *StylusTracker) Buttons() protocol.ButtonFlags {
+	return s.buttons
+}
+
+func (s *StylusTracker) event(eventType protocol.PointerEventType, x, y float64, axes StylusAxes, timestamp uint64) protocol.PointerEvent {
+	e := protocol.PointerEvent{
+		EventType:   eventType,
+		PointerType: protocol.PointerTypePen,
+		X:           x,
+		Y:           y,
+		Width:       1,
+		Height:      1,
+		PointerID:   StylusPointerID,
+		Timestamp:   timestamp,
+		TiltX:       TiltDegrees(axes.XTilt),
+		TiltY:       TiltDegrees(axes.YTilt),
+		Twist:       TwistDegrees(axes.Rotation),
+		Buttons:     s.buttons,
+		IsPrimary:   true,
+	}
+	if s.buttons&(protocol.ButtonPrimary|protocol.ButtonEraser) != 0 {
+		e.Pressure = axes.pressure()
+	}
+	if s.hasLast {
+		e.MovementX = int64(math.Round(x - s.lastX))
+		e.MovementY = int64(math.Round(y - s.lastY))
+	}
+	s.lastX, s.lastY, s.hasLast = x, y, true
+	return e
+}
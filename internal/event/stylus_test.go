@@ -0,0 +1,169 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+func TestTiltDegrees(t *testing.T) {
+	tests := []struct {
+		axis float64
+		want int32
+	}{
+		{0, 0},
+		{0.5, 45},
+		{-0.5, -45},
+		{1, 90},
+		{-1, -90},
+		{1.2, 90},
+		{-3, -90},
+		{0.333, 30},
+	}
+	for _, tt := range tests {
+		if got := TiltDegrees(tt.axis); got != tt.want {
+			t.Errorf("TiltDegrees(%v) = %d, want %d", tt.axis, got, tt.want)
+		}
+	}
+}
+
+func TestTwistDegrees(t *testing.T) {
+	tests := []struct {
+		axis float64
+		want int32
+	}{
+		{0, 0},
+		{0.25, 90},
+		{0.5, 180},
+		{0.999, 0}, // 359.64° rounds to a full turn
+		{1, 0},
+		{-0.25, 270},
+	}
+	for _, tt := range tests {
+		if got := TwistDegrees(tt.axis); got != tt.want {
+			t.Errorf("TwistDegrees(%v) = %d, want %d", tt.axis, got, tt.want)
+		}
+	}
+}
+
+func TestStylusButton(t *testing.T) {
+	tests := []struct {
+		name   string
+		button uint
+		eraser bool
+		want   protocol.ButtonFlags
+	}{
+		{"Tip", gdk.BUTTON_PRIMARY, false, protocol.ButtonPrimary},
+		{"EraserTip", gdk.BUTTON_PRIMARY, true, protocol.ButtonEraser},
+		{"LowerBarrel", gdk.BUTTON_SECONDARY, false, protocol.ButtonSecondary},
+		{"UpperBarrel", gdk.BUTTON_MIDDLE, false, protocol.ButtonAuxiliary},
+		{"Unknown", 42, false, protocol.ButtonNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StylusButton(tt.button, tt.eraser); got != tt.want {
+				t.Errorf("StylusButton(%d, %v) = %v, want %v", tt.button, tt.eraser, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStylusTracker(t *testing.T) {
+	var s StylusTracker
+	axes := StylusAxes{Pressure: 0.6, HasPressure: true, XTilt: 0.5, YTilt: -0.25, Rotation: 0.25}
+
+	hover := s.Hover(10, 10, axes, 1)
+	if hover.EventType != protocol.PointerEventTypeMove || hover.Pressure != 0 || hover.Buttons != protocol.ButtonNone {
+		t.Errorf("Hover() = %+v, want move without pressure and buttons", hover)
+	}
+	if hover.PointerType != protocol.PointerTypePen || hover.PointerID != StylusPointerID || !hover.IsPrimary {
+		t.Errorf("Hover() = %+v, want primary pen", hover)
+	}
+	if hover.TiltX != 45 || hover.TiltY != -23 || hover.Twist != 90 {
+		t.Errorf("Hover() tilt %d/%d twist %d, want 45/-23 twist 90", hover.TiltX, hover.TiltY, hover.Twist)
+	}
+
+	barrel := s.Down(12, 13, axes, gdk.BUTTON_SECONDARY, false, 2)
+	if barrel.Button != protocol.ButtonSecondary || barrel.Buttons != protocol.ButtonSecondary || barrel.Pressure != 0 {
+		t.Errorf("barrel Down() = %+v, want secondary button without pressure", barrel)
+	}
+	if barrel.MovementX != 2 || barrel.MovementY != 3 {
+		t.Errorf("barrel Down() movement %d/%d, want 2/3", barrel.MovementX, barrel.MovementY)
+	}
+
+	down := s.Down(12, 13, axes, gdk.BUTTON_PRIMARY, false, 3)
+	if down.EventType != protocol.PointerEventTypeDown || down.Button != protocol.ButtonPrimary ||
+		down.Buttons != protocol.ButtonPrimary|protocol.ButtonSecondary || down.Pressure != 0.6 {
+		t.Errorf("Down() = %+v, want primary down with barrel held and pressure", down)
+	}
+
+	move := s.Motion(20, 13, axes, 4)
+	if move.EventType != protocol.PointerEventTypeMove || move.Pressure != 0.6 || move.MovementX != 8 {
+		t.Errorf("Motion() = %+v", move)
+	}
+
+	up := s.Up(20, 13, axes, gdk.BUTTON_PRIMARY, false, 5)
+	if up.EventType != protocol.PointerEventTypeUp || up.Buttons != protocol.ButtonSecondary || up.Pressure != 0 {
+		t.Errorf("Up() = %+v, want up with barrel still held", up)
+	}
+	s.Up(20, 13, axes, gdk.BUTTON_SECONDARY, false, 6)
+
+	s.Leave()
+	if e := s.Hover(50, 50, axes, 7); e.MovementX != 0 || e.MovementY != 0 {
+		t.Errorf("Hover() after Leave() movement %d/%d, want 0/0", e.MovementX, e.MovementY)
+	}
+}
+
+func TestStylusTracker_Eraser(t *testing.T) {
+	var s StylusTracker
+	down := s.Down(0, 0, StylusAxes{Pressure: 1, HasPressure: true}, gdk.BUTTON_PRIMARY, true, 1)
+	if down.Button != protocol.ButtonEraser || down.Buttons != protocol.ButtonEraser || down.Pressure != 1 {
+		t.Errorf("eraser Down() = %+v", down)
+	}
+	up := s.Up(0, 0, StylusAxes{}, gdk.BUTTON_PRIMARY, true, 2)
+	if up.Buttons != protocol.ButtonNone {
+		t.Errorf("eraser Up() buttons = %v, want none", up.Buttons)
+	}
+}
+
+func TestStylusAxes_pressure(t *testing.T) {
+	tests := []struct {
+		name string
+		axes StylusAxes
+		want float64
+	}{
+		{"Pressure", StylusAxes{Pressure: 0.6, HasPressure: true}, 0.6},
+		{"Clamped", StylusAxes{Pressure: 1.5, HasPressure: true}, 1},
+		{"NoPressureAxis", StylusAxes{}, 0.5},
+		{"AirbrushOpen", StylusAxes{Pressure: 0.6, HasPressure: true, Slider: 1, HasSlider: true}, 0.6},
+		{"AirbrushHalfOpen", StylusAxes{Pressure: 0.6, HasPressure: true, Slider: 0.5, HasSlider: true}, 0.3},
+		{"AirbrushClosed", StylusAxes{Pressure: 0.6, HasPressure: true, HasSlider: true}, 0.6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s StylusTracker
+			if got := s.Down(0, 0, tt.axes, gdk.BUTTON_PRIMARY, false, 1).Pressure; got != tt.want {
+				t.Errorf("Down() pressure = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
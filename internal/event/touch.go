@@ -0,0 +1,163 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"unsafe"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/core/gextras"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+)
+
+// firstTouchPointerID is the PointerID of the first touch contact, IDs below are left to the mouse and stylus.
+const firstTouchPointerID = StylusPointerID + 1
+
+// Contact is the state of one touch contact in widget coordinates.
+// A zero Width or Height means the device does not report the contact size.
+type Contact struct {
+	X, Y          float64
+	Width, Height float64
+	Pressure      float64
+}
+
+// TouchTracker turns touch sequences into one PointerEvent stream per contact.
+// Every contact keeps its PointerID until it ends, the first contact to touch while no other is active is the
+// primary one.
+type TouchTracker struct {
+	contacts map[uintptr]int
+	primary  uintptr
+	nextID   int
+}
+
+// NewTouchTracker creates a TouchTracker without contacts.
+func NewTouchTracker() *TouchTracker {
+	return &TouchTracker{
+		contacts: make(map[uintptr]int),
+		nextID:   firstTouchPointerID,
+	}
+}
+
+// Active returns the number of contacts currently touching.
+func (t *TouchTracker) Active() int {
+	return len(t.contacts)
+}
+
+// Begin starts tracking the contact of sequence and returns its pointerdown event.
+func (t *TouchTracker) Begin(sequence uintptr, c Contact, timestamp uint64) protocol.PointerEvent {
+	if _, ok := t.contacts[sequence]; !ok {
+		if len(t.contacts) == 0 {
+			t.primary = sequence
+		}
+		t.contacts[sequence] = t.nextID
+		t.nextID++
+	}
+	e := t.event(protocol.PointerEventTypeDown, sequence, c, timestamp)
+	e.Button = protocol.ButtonPrimary
+	e.Buttons = protocol.ButtonPrimary
+	return e
+}
+
+// Update returns the pointermove event of a tracked contact, ok is false for unknown sequences.
+func (t *TouchTracker) Update(sequence uintptr, c Contact, timestamp uint64) (e protocol.PointerEvent, ok bool) {
+	if _, ok := t.contacts[sequence]; !ok {
+		return e, false
+	}
+	e = t.event(protocol.PointerEventTypeMove, sequence, c, timestamp)
+	e.Buttons = protocol.ButtonPrimary
+	return e, true
+}
+
+// End stops tracking a contact and returns its pointerup event, ok is false for unknown sequences.
+func (t *TouchTracker) End(sequence uintptr, c Contact, timestamp uint64) (e protocol.PointerEvent, ok bool) {
+	if _, ok := t.contacts[sequence]; !ok {
+		return e, false
+	}
+	c.Pressure = 0
+	e = t.event(protocol.PointerEventTypeUp, sequence, c, timestamp)
+	e.Button = protocol.ButtonPrimary
+	t.remove(sequence)
+	return e, true
+}
+
+// Cancel stops tracking a contact and returns its pointercancel event, ok is false for unknown sequences.
+func (t *TouchTracker) Cancel(sequence uintptr, c Contact, timestamp uint64) (e protocol.PointerEvent, ok bool) {
+	if _, ok := t.contacts[sequence]; !ok {
+		return e, false
+	}
+	c.Pressure = 0
+	e = t.event(protocol.PointerEventTypeCancel, sequence, c, timestamp)
+	t.remove(sequence)
+	return e, true
+}
+
+// CancelAll cancels every tracked contact, e.g. when the widget is unmapped mid-touch.
+func (t *TouchTracker) CancelAll(timestamp uint64) []protocol.PointerEvent {
+	events := make([]protocol.PointerEvent, 0, len(t.contacts))
+	for sequence := range t.contacts {
+		e, _ := t.Cancel(sequence, Contact{}, timestamp)
+		events = append(events, e)
+	}
+	return events
+}
+
+func (t *TouchTracker) remove(sequence uintptr) {
+	delete(t.contacts, sequence)
+	if t.primary == sequence {
+		t.primary = 0
+	}
+	if len(t.contacts) == 0 {
+		t.nextID = firstTouchPointerID
+	}
+}
+
+func (t *TouchTracker) event(eventType protocol.PointerEventType, sequence uintptr, c Contact, timestamp uint64) protocol.PointerEvent {
+	// The pointer events spec defaults the contact size to 1 and the pressure of a touching contact without
+	// pressure support to 0.5.
+	if c.Width <= 0 {
+		c.Width = 1
+	}
+	if c.Height <= 0 {
+		c.Height = 1
+	}
+	if c.Pressure <= 0 && eventType != protocol.PointerEventTypeUp && eventType != protocol.PointerEventTypeCancel {
+		c.Pressure = 0.5
+	}
+	return protocol.PointerEvent{
+		EventType:   eventType,
+		PointerType: protocol.PointerTypeTouch,
+		X:           c.X,
+		Y:           c.Y,
+		Pressure:    c.Pressure,
+		Width:       c.Width,
+		Height:      c.Height,
+		PointerID:   t.contacts[sequence],
+		Timestamp:   timestamp,
+		IsPrimary:   sequence == t.primary,
+	}
+}
+
+// sequenceKey returns the identity of a GdkEventSequence. gotk4 creates a new wrapper every time a sequence is
+// passed to Go, the GdkEventSequence pointer inside stays the same for the lifetime of the touch.
+func sequenceKey(sequence *gdk.EventSequence) uintptr {
+	if sequence == nil {
+		return 0
+	}
+	return uintptr(gextras.StructNative(unsafe.Pointer(sequence)))
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+)
+
+func TestTouchTracker(t *testing.T) {
+	tracker := NewTouchTracker()
+	const first, second uintptr = 0x10, 0x20
+
+	down1 := tracker.Begin(first, Contact{X: 1, Y: 2}, 1)
+	down2 := tracker.Begin(second, Contact{X: 3, Y: 4, Width: 8, Height: 6, Pressure: 0.7}, 2)
+	move1, ok1 := tracker.Update(first, Contact{X: 5, Y: 6}, 3)
+	up1, ok2 := tracker.End(first, Contact{X: 5, Y: 6}, 4)
+	move2, ok3 := tracker.Update(second, Contact{X: 7, Y: 8}, 5)
+	cancel2, ok4 := tracker.Cancel(second, Contact{X: 7, Y: 8}, 6)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		t.Fatalf("tracked sequence reported as unknown: %v %v %v %v", ok1, ok2, ok3, ok4)
+	}
+
+	tests := []struct {
+		name      string
+		e         protocol.PointerEvent
+		eventType protocol.PointerEventType
+		id        int
+		primary   bool
+		buttons   protocol.ButtonFlags
+		pressure  float64
+	}{
+		{"down1", down1, protocol.PointerEventTypeDown, firstTouchPointerID, true, protocol.ButtonPrimary, 0.5},
+		{"down2", down2, protocol.PointerEventTypeDown, firstTouchPointerID + 1, false, protocol.ButtonPrimary, 0.7},
+		{"move1", move1, protocol.PointerEventTypeMove, firstTouchPointerID, true, protocol.ButtonPrimary, 0.5},
+		{"up1", up1, protocol.PointerEventTypeUp, firstTouchPointerID, true, protocol.ButtonNone, 0},
+		{"move2", move2, protocol.PointerEventTypeMove, firstTouchPointerID + 1, false, protocol.ButtonPrimary, 0.5},
+		{"cancel2", cancel2, protocol.PointerEventTypeCancel, firstTouchPointerID + 1, false, protocol.ButtonNone, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.e.EventType != tt.eventType {
+				t.Errorf("EventType = %v, want %v", tt.e.EventType, tt.eventType)
+			}
+			if tt.e.PointerType != protocol.PointerTypeTouch {
+				t.Errorf("PointerType = %v, want touch", tt.e.PointerType)
+			}
+			if tt.e.PointerID != tt.id {
+				t.Errorf("PointerID = %d, want %d", tt.e.PointerID, tt.id)
+			}
+			if tt.e.IsPrimary != tt.primary {
+				t.Errorf("IsPrimary = %v, want %v", tt.e.IsPrimary, tt.primary)
+			}
+			if tt.e.Buttons != tt.buttons {
+				t.Errorf("Buttons = %v, want %v", tt.e.Buttons, tt.buttons)
+			}
+			if tt.e.Pressure != tt.pressure {
+				t.Errorf("Pressure = %v, want %v", tt.e.Pressure, tt.pressure)
+			}
+		})
+	}
+
+	if down1.Width != 1 || down1.Height != 1 {
+		t.Errorf("default contact size = %vx%v, want 1x1", down1.Width, down1.Height)
+	}
+	if down2.Width != 8 || down2.Height != 6 {
+		t.Errorf("contact size = %vx%v, want 8x6", down2.Width, down2.Height)
+	}
+	if tracker.Active() != 0 {
+		t.Errorf("Active() = %d after all contacts ended", tracker.Active())
+	}
+}
+
+func TestTouchTracker_PrimaryAfterRelease(t *testing.T) {
+	tracker := NewTouchTracker()
+	tracker.Begin(1, Contact{}, 0)
+	tracker.Begin(2, Contact{}, 0)
+	tracker.End(1, Contact{}, 0)
+	// the primary contact lifted while another one still touches: new contacts are not primary
+	if e := tracker.Begin(3, Contact{}, 0); e.IsPrimary {
+		t.Error("contact starting while others touch is primary")
+	}
+	tracker.End(2, Contact{}, 0)
+	tracker.End(3, Contact{}, 0)
+	if e := tracker.Begin(4, Contact{}, 0); !e.IsPrimary || e.PointerID != firstTouchPointerID {
+		t.Errorf("first contact after release = id %d primary %v, want id %d primary", e.PointerID, e.IsPrimary, firstTouchPointerID)
+	}
+}
+
+func TestTouchTracker_Unknown(t *testing.T) {
+	tracker := NewTouchTracker()
+	if _, ok := tracker.Update(1, Contact{}, 0); ok {
+		t.Error("Update of unknown sequence reported ok")
+	}
+	if _, ok := tracker.End(1, Contact{}, 0); ok {
+		t.Error("End of unknown sequence reported ok")
+	}
+	if _, ok := tracker.Cancel(1, Contact{}, 0); ok {
+		t.Error("Cancel of unknown sequence reported ok")
+	}
+}
+
+func TestTouchTracker_CancelAll(t *testing.T) {
+	tracker := NewTouchTracker()
+	tracker.Begin(1, Contact{}, 0)
+	tracker.Begin(2, Contact{}, 0)
+	events := tracker.CancelAll(5)
+	if len(events) != 2 {
+		t.Fatalf("CancelAll() returned %d events, want 2", len(events))
+	}
+	for _, e := range events {
+		if e.EventType != protocol.PointerEventTypeCancel || e.Timestamp != 5 {
+			t.Errorf("CancelAll() event = %+v", e)
+		}
+	}
+	if tracker.Active() != 0 {
+		t.Errorf("Active() = %d after CancelAll", tracker.Active())
+	}
+}
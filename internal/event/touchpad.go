@@ -0,0 +1,234 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"math"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+const (
+	// WheelStep is the wheel delta of one wheel detent, like the high resolution wheel events of evdev. The server
+	// injects the deltas as REL_WHEEL_HI_RES, which counts 120 per detent, so this is also the server's scale.
+	// Wheel events used to carry ten per detent, which scrolled a twelfth of a detent.
+	WheelStep = 120
+	// ZoomStep is the change of the pinch scale that makes one zoom step.
+	ZoomStep = 1.25
+	// RotateStep is the rotation in radians that makes one rotate step.
+	RotateStep = math.Pi / 12
+	// SwipeMinVelocity is the speed in pixels per second below which a swipe is ignored.
+	SwipeMinVelocity = 300
+
+	// kineticFriction is the rate per second at which kinetic scrolling slows down.
+	kineticFriction = 4
+	// kineticMinVelocity is the speed in scroll units per second at which kinetic scrolling stops.
+	kineticMinVelocity = 0.5
+	// controlLeftKeycode is the hardware keycode of the left Control key.
+	controlLeftKeycode = 0x25
+)
+
+// Action is an input event produced by scrolling or a touchpad gesture. Exactly one of the events is set.
+// Gestures bound to key chords need key and wheel events sent in order, so both share one queue.
+type Action struct {
+	WheelEvent    *protocol.WheelEvent
+	KeyboardEvent *protocol.KeyboardEvent
+}
+
+// ActionSender sends the events of actions, client.WeylusClient implements it.
+type ActionSender interface {
+	SendWheelEvent(e protocol.WheelEvent) error
+	SendKeyboardEvent(e protocol.KeyboardEvent) error
+}
+
+// Send sends the event of the action.
+func (a Action) Send(sender ActionSender) error {
+	switch {
+	case a.WheelEvent != nil:
+		return sender.SendWheelEvent(*a.WheelEvent)
+	case a.KeyboardEvent != nil:
+		return sender.SendKeyboardEvent(*a.KeyboardEvent)
+	}
+	return nil
+}
+
+func wheelAction(e protocol.WheelEvent) Action {
+	return Action{WheelEvent: &e}
+}
+
+func keyActions(events []protocol.KeyboardEvent) []Action {
+	actions := make([]Action, len(events))
+	for i := range events {
+		actions[i] = Action{KeyboardEvent: &events[i]}
+	}
+	return actions
+}
+
+// ScrollAccumulator converts smooth scroll deltas in wheel detents to high resolution wheel events.
+// Fractions that do not make a whole wheel unit are carried over to the next delta instead of being rounded away.
+type ScrollAccumulator struct {
+	remX, remY float64
+}
+
+// Scroll returns the wheel event for a scroll delta, false if the delta did not add up to a wheel unit yet.
+func (s *ScrollAccumulator) Scroll(dx, dy float64, timestamp uint64) (protocol.WheelEvent, bool) {
+	s.remX += dx * WheelStep
+	s.remY += dy * WheelStep
+	e := protocol.WheelEvent{
+		Dx:        int32(math.Trunc(s.remX)),
+		Dy:        int32(math.Trunc(s.remY)),
+		Timestamp: timestamp,
+	}
+	s.remX -= float64(e.Dx)
+	s.remY -= float64(e.Dy)
+	return e, e.Dx != 0 || e.Dy != 0
+}
+
+// Reset drops the carried fractions, e.g. when a new scroll starts.
+func (s *ScrollAccumulator) Reset() {
+	s.remX, s.remY = 0, 0
+}
+
+// KineticScroll continues a touchpad scroll after the fingers are lifted, slowing down from the release velocity.
+type KineticScroll struct {
+	velX, velY float64
+}
+
+// NewKineticScroll starts kinetic scrolling with a velocity in scroll units per second.
+func NewKineticScroll(velX, velY float64) *KineticScroll {
+	return &KineticScroll{velX: velX, velY: velY}
+}
+
+// Step advances the scroll by dt and returns the scroll delta, done is true once the scroll has stopped.
+func (k *KineticScroll) Step(dt time.Duration) (dx, dy float64, done bool) {
+	if math.Hypot(k.velX, k.velY) < kineticMinVelocity {
+		return 0, 0, true
+	}
+	decay := math.Exp(-kineticFriction * dt.Seconds())
+	// distance covered while the velocity decays exponentially over dt
+	travel := (1 - decay) / kineticFriction
+	dx, dy = k.velX*travel, k.velY*travel
+	k.velX *= decay
+	k.velY *= decay
+	return dx, dy, false
+}
+
+// GestureTranslator turns touchpad pinch, rotate and swipe gestures into actions.
+// Gestures with a key chord bound type it, pinching without a binding zooms with Ctrl and the wheel.
+type GestureTranslator struct {
+	// Bindings are the key events typed for a gesture step.
+	Bindings map[Gesture][]protocol.KeyboardEvent
+
+	zoomSteps   int
+	rotateSteps int
+	// keys translates the Ctrl presses of zooming without a binding
+	keys *KeyTranslator
+}
+
+// ParseGestureBindings parses gesture names and key chords in the format of ParseChord into the key events
+// typed for each gesture. keycode returns the hardware keycode of a keyval.
+func ParseGestureBindings(bindings map[string]string, keycode func(keyVal uint) uint) (map[Gesture][]protocol.KeyboardEvent, error) {
+	parsed := make(map[Gesture][]protocol.KeyboardEvent, len(bindings))
+	for name, accelerator := range bindings {
+		gesture, err := ParseGesture(name)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse gesture")
+		}
+		chord, err := ParseChord(accelerator)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse key chord of %s", gesture)
+		}
+		parsed[gesture] = chord.KeyEvents(keycode)
+	}
+	return parsed, nil
+}
+
+// Begin starts a new gesture.
+func (g *GestureTranslator) Begin() {
+	g.zoomSteps = 0
+	g.rotateSteps = 0
+}
+
+// Zoom returns the actions for the pinch scale changing to scale, relative to the start of the gesture.
+func (g *GestureTranslator) Zoom(scale float64, timestamp uint64) []Action {
+	if scale <= 0 {
+		return nil
+	}
+	steps := int(math.Trunc(math.Log(scale) / math.Log(ZoomStep)))
+	var actions []Action
+	for ; g.zoomSteps < steps; g.zoomSteps++ {
+		actions = append(actions, g.zoom(GestureZoomIn, -WheelStep, timestamp)...)
+	}
+	for ; g.zoomSteps > steps; g.zoomSteps-- {
+		actions = append(actions, g.zoom(GestureZoomOut, WheelStep, timestamp)...)
+	}
+	return actions
+}
+
+func (g *GestureTranslator) zoom(gesture Gesture, dy int32, timestamp uint64) []Action {
+	if events, ok := g.Bindings[gesture]; ok {
+		return keyActions(events)
+	}
+	if g.keys == nil {
+		g.keys = NewKeyTranslator()
+	}
+	return []Action{
+		{KeyboardEvent: lo.ToPtr(g.keys.Press(gdk.KEY_Control_L, controlLeftKeycode, 0))},
+		wheelAction(protocol.WheelEvent{Dy: dy, Timestamp: timestamp}),
+		{KeyboardEvent: lo.ToPtr(g.keys.Release(gdk.KEY_Control_L, controlLeftKeycode, gdk.ControlMask))},
+	}
+}
+
+// Rotate returns the actions for the rotation changing to angle radians clockwise, relative to the start of the
+// gesture.
+func (g *GestureTranslator) Rotate(angle float64) []Action {
+	steps := int(math.Trunc(angle / RotateStep))
+	var actions []Action
+	for ; g.rotateSteps < steps; g.rotateSteps++ {
+		actions = append(actions, keyActions(g.Bindings[GestureRotateRight])...)
+	}
+	for ; g.rotateSteps > steps; g.rotateSteps-- {
+		actions = append(actions, keyActions(g.Bindings[GestureRotateLeft])...)
+	}
+	return actions
+}
+
+// Swipe returns the actions for a swipe ending with the given velocity in pixels per second.
+// The swipe goes in the direction of the faster axis.
+func (g *GestureTranslator) Swipe(velocityX, velocityY float64) []Action {
+	if math.Hypot(velocityX, velocityY) < SwipeMinVelocity {
+		return nil
+	}
+	var gesture Gesture
+	switch {
+	case math.Abs(velocityX) >= math.Abs(velocityY) && velocityX < 0:
+		gesture = GestureSwipeLeft
+	case math.Abs(velocityX) >= math.Abs(velocityY):
+		gesture = GestureSwipeRight
+	case velocityY < 0:
+		gesture = GestureSwipeUp
+	default:
+		gesture = GestureSwipeDown
+	}
+	return keyActions(g.Bindings[gesture])
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+)
+
+func TestScrollAccumulator(t *testing.T) {
+	var s ScrollAccumulator
+	tests := []struct {
+		dx, dy float64
+		wantDx int32
+		wantDy int32
+		wantOk bool
+	}{
+		{0, 1, 0, 120, true},
+		{0, -0.5, 0, -60, true},
+		{0.004, 0.004, 0, 0, false},
+		{0.004, 0.004, 0, 0, false},
+		{0.004, 0.004, 1, 1, true},
+		{1.5, 0, 180, 0, true},
+	}
+	for i, tt := range tests {
+		e, ok := s.Scroll(tt.dx, tt.dy, uint64(i))
+		if e.Dx != tt.wantDx || e.Dy != tt.wantDy || ok != tt.wantOk {
+			t.Errorf("Scroll %d = %d, %d, %v, want %d, %d, %v", i, e.Dx, e.Dy, ok, tt.wantDx, tt.wantDy, tt.wantOk)
+		}
+	}
+	s.Scroll(0.004, 0, 0)
+	s.Reset()
+	if _, ok := s.Scroll(0.005, 0, 0); ok {
+		t.Error("Scroll after Reset carried the old fraction")
+	}
+}
+
+func TestKineticScroll(t *testing.T) {
+	k := NewKineticScroll(0, 10)
+	var total float64
+	var steps int
+	for {
+		dx, dy, done := k.Step(16 * time.Millisecond)
+		if done {
+			break
+		}
+		if dx != 0 || dy <= 0 {
+			t.Fatalf("step %d = %v, %v, want positive dy only", steps, dx, dy)
+		}
+		total += dy
+		steps++
+		if steps > 1000 {
+			t.Fatal("kinetic scroll does not stop")
+		}
+	}
+	// the scroll covers the integral of the velocity until it drops below the minimum
+	want := (10 - kineticMinVelocity) / kineticFriction
+	if math.Abs(total-want) > 0.1 {
+		t.Errorf("total distance = %v, want about %v", total, want)
+	}
+	if _, _, done := NewKineticScroll(0.1, 0.1).Step(16 * time.Millisecond); !done {
+		t.Error("slow kinetic scroll did not stop immediately")
+	}
+}
+
+var swipeLeft = []protocol.KeyboardEvent{
+	{EventType: protocol.KeyboardEventTypeDown, Code: "AltLeft", Key: "Alt", Location: protocol.KeyboardLocationLeft, Alt: true},
+	{EventType: protocol.KeyboardEventTypeUp, Code: "AltLeft", Key: "Alt", Location: protocol.KeyboardLocationLeft},
+}
+
+func actionKinds(actions []Action) []string {
+	kinds := make([]string, len(actions))
+	for i, action := range actions {
+		switch {
+		case action.WheelEvent != nil:
+			kinds[i] = "wheel"
+		case action.KeyboardEvent != nil:
+			kinds[i] = action.KeyboardEvent.Code + " " + string(action.KeyboardEvent.EventType)
+		}
+	}
+	return kinds
+}
+
+func TestGestureTranslator_Zoom(t *testing.T) {
+	var g GestureTranslator
+	g.Begin()
+	if actions := g.Zoom(1.1, 0); len(actions) != 0 {
+		t.Errorf("Zoom(1.1) = %v, want no step", actionKinds(actions))
+	}
+	actions := g.Zoom(1.6, 0)
+	want := []string{"ControlLeft down", "wheel", "ControlLeft up", "ControlLeft down", "wheel", "ControlLeft up"}
+	if got := actionKinds(actions); len(got) != len(want) {
+		t.Fatalf("Zoom(1.6) = %v, want %v", got, want)
+	}
+	for i, kind := range actionKinds(actions) {
+		if kind != want[i] {
+			t.Errorf("Zoom(1.6) action %d = %s, want %s", i, kind, want[i])
+		}
+	}
+	if dy := actions[1].WheelEvent.Dy; dy != -WheelStep {
+		t.Errorf("zoom in wheel dy = %d, want %d", dy, -WheelStep)
+	}
+	if !actions[0].KeyboardEvent.Ctrl || actions[2].KeyboardEvent.Ctrl {
+		t.Error("Control key events do not hold Ctrl around the wheel event")
+	}
+	actions = g.Zoom(0.7, 0)
+	if len(actions) != 9 || actions[1].WheelEvent.Dy != WheelStep {
+		t.Errorf("Zoom(0.7) = %v, want three zoom out steps", actionKinds(actions))
+	}
+
+	g.Bindings = map[Gesture][]protocol.KeyboardEvent{GestureZoomIn: swipeLeft}
+	g.Begin()
+	if got := actionKinds(g.Zoom(1.3, 0)); len(got) != 2 || got[0] != "AltLeft down" {
+		t.Errorf("Zoom(1.3) with binding = %v, want the bound keys", got)
+	}
+}
+
+func TestGestureTranslator_Rotate(t *testing.T) {
+	g := GestureTranslator{Bindings: map[Gesture][]protocol.KeyboardEvent{GestureRotateRight: swipeLeft}}
+	g.Begin()
+	if got := g.Rotate(RotateStep / 2); len(got) != 0 {
+		t.Errorf("Rotate(half step) = %v, want none", actionKinds(got))
+	}
+	if got := g.Rotate(2.5 * RotateStep); len(got) != 4 {
+		t.Errorf("Rotate(2.5 steps) = %v, want two bound chords", actionKinds(got))
+	}
+	// rotating left has no binding
+	if got := g.Rotate(-RotateStep); len(got) != 0 {
+		t.Errorf("Rotate(-1 step) = %v, want none", actionKinds(got))
+	}
+}
+
+func TestGestureTranslator_Swipe(t *testing.T) {
+	g := GestureTranslator{Bindings: map[Gesture][]protocol.KeyboardEvent{GestureSwipeLeft: swipeLeft}}
+	tests := []struct {
+		name   string
+		vx, vy float64
+		want   int
+	}{
+		{"Left", -800, 100, 2},
+		{"Right", 800, 100, 0},
+		{"Up", 100, -800, 0},
+		{"Slow", -100, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := g.Swipe(tt.vx, tt.vy); len(got) != tt.want {
+			t.Errorf("Swipe %s = %v, want %d actions", tt.name, actionKinds(got), tt.want)
+		}
+	}
+}
+
+type fakeSender struct {
+	wheel []protocol.WheelEvent
+	keys  []protocol.KeyboardEvent
+}
+
+func (s *fakeSender) SendPointerEvent(protocol.PointerEvent) error { return nil }
+
+func (s *fakeSender) SendWheelEvent(e protocol.WheelEvent) error {
+	s.wheel = append(s.wheel, e)
+	return nil
+}
+
+func (s *fakeSender) SendKeyboardEvent(e protocol.KeyboardEvent) error {
+	s.keys = append(s.keys, e)
+	return nil
+}
+
+func TestAction_Send(t *testing.T) {
+	var sender fakeSender
+	for _, action := range append(keyActions(swipeLeft), wheelAction(protocol.WheelEvent{Dy: 120})) {
+		if err := action.Send(&sender); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if len(sender.keys) != 2 || len(sender.wheel) != 1 || sender.wheel[0].Dy != 120 {
+		t.Errorf("sent keys %v wheel %v", sender.keys, sender.wheel)
+	}
+}
@@ -20,9 +20,11 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 
 	cmd2 "github.com/OmegaRogue/weylus-desktop/cmd"
+	"github.com/OmegaRogue/weylus-desktop/internal/config"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra/doc"
 )
@@ -51,4 +53,17 @@ func main() {
 	if err := doc.GenMarkdownTreeCustom(cmd, mdDir, emptyStr, identity); err != nil {
 		log.Fatal().Err(err).Msg("failed to generate md docs")
 	}
+
+	f, err := os.Create(filepath.Join(mdDir, config.Name+"."+config.Type))
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create example config")
+	}
+	defer func(f *os.File) {
+		if err := f.Close(); err != nil {
+			log.Err(err).Msg("failed closing file")
+		}
+	}(f)
+	if err := config.WriteDefault(f, cmd2.ConfigSchema()); err != nil {
+		log.Fatal().Err(err).Msg("failed to generate example config")
+	}
 }
@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package logger
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Levels are the minimum levels of log messages, by component.
+type Levels struct {
+	// Default applies to messages without a component and to components
+	// without an override.
+	Default zerolog.Level
+	// Components overrides the level of components by name.
+	Components map[string]zerolog.Level
+}
+
+// For returns the minimum level of messages of component.
+func (l Levels) For(component string) zerolog.Level {
+	if level, ok := l.Components[component]; ok {
+		return level
+	}
+	return l.Default
+}
+
+// Min returns the lowest level of all components.
+func (l Levels) Min() zerolog.Level {
+	level := l.Default
+	for _, c := range l.Components {
+		if c < level {
+			level = c
+		}
+	}
+	return level
+}
+
+// ParseLevel parses a level name like debug, case-insensitively.
+func ParseLevel(name string) (zerolog.Level, error) {
+	if name == "" {
+		return zerolog.NoLevel, errors.New("empty log level")
+	}
+	level, err := zerolog.ParseLevel(strings.ToLower(name))
+	return level, errors.Wrapf(err, "parse log level %q", name)
+}
+
+// ParseComponentLevels parses overrides like client:debug into levels by component.
+func ParseComponentLevels(overrides []string) (map[string]zerolog.Level, error) {
+	levels := make(map[string]zerolog.Level, len(overrides))
+	for _, o := range overrides {
+		component, name, ok := strings.Cut(o, ":")
+		if !ok || component == "" {
+			return nil, errors.Errorf("invalid component level %q, want COMPONENT:LEVEL", o)
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "component %s", component)
+		}
+		levels[component] = level
+	}
+	return levels, nil
+}
+
+var levels atomic.Pointer[Levels]
+
+func init() {
+	levels.Store(&Levels{Default: zerolog.InfoLevel})
+}
+
+// SetLevels sets the levels of all loggers, including the ones created before.
+func SetLevels(l Levels) {
+	levels.Store(&l)
+	zerolog.SetGlobalLevel(l.Min())
+}
+
+// CurrentLevels returns the levels set last.
+func CurrentLevels() Levels {
+	return *levels.Load()
+}
+
+// levelHook discards the messages below the level of its component.
+type levelHook struct {
+	component string
+}
+
+func (h levelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if level < levels.Load().For(h.component) {
+		e.Discard()
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestParseComponentLevels(t *testing.T) {
+	var tests = []struct {
+		in      []string
+		want    map[string]zerolog.Level
+		wantErr bool
+	}{
+		{nil, map[string]zerolog.Level{}, false},
+		{[]string{"client:debug", "server:WARN"}, map[string]zerolog.Level{"client": zerolog.DebugLevel, "server": zerolog.WarnLevel}, false},
+		{[]string{"client"}, nil, true},
+		{[]string{":debug"}, nil, true},
+		{[]string{"client:"}, nil, true},
+		{[]string{"client:loud"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseComponentLevels(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseComponentLevels(%v) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("ParseComponentLevels(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+		for c, level := range tt.want {
+			if got[c] != level {
+				t.Errorf("ParseComponentLevels(%v)[%s] = %v, want %v", tt.in, c, got[c], level)
+			}
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	l := Levels{
+		Default:    zerolog.InfoLevel,
+		Components: map[string]zerolog.Level{"client": zerolog.DebugLevel, "glib": zerolog.ErrorLevel},
+	}
+	if got := l.For("client"); got != zerolog.DebugLevel {
+		t.Errorf("For(client) = %v", got)
+	}
+	if got := l.For("server"); got != zerolog.InfoLevel {
+		t.Errorf("For(server) = %v", got)
+	}
+	if got := l.Min(); got != zerolog.DebugLevel {
+		t.Errorf("Min() = %v", got)
+	}
+}
+
+func TestLevelHook(t *testing.T) {
+	defer SetLevels(CurrentLevels())
+	SetLevels(Levels{
+		Default:    zerolog.WarnLevel,
+		Components: map[string]zerolog.Level{"client": zerolog.DebugLevel},
+	})
+	var buf bytes.Buffer
+	client := zerolog.New(&buf).Hook(levelHook{component: "client"})
+	server := zerolog.New(&buf).Hook(levelHook{component: "server"})
+
+	var tests = []struct {
+		logger zerolog.Logger
+		level  zerolog.Level
+		want   bool
+	}{
+		{client, zerolog.DebugLevel, true},
+		{client, zerolog.TraceLevel, false},
+		{server, zerolog.InfoLevel, false},
+		{server, zerolog.WarnLevel, true},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		tt.logger.WithLevel(tt.level).Msg("test")
+		if got := buf.Len() > 0; got != tt.want {
+			t.Errorf("%v message logged = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+
+	SetLevels(Levels{Default: zerolog.ErrorLevel})
+	buf.Reset()
+	client.Debug().Msg("test")
+	if buf.Len() > 0 {
+		t.Error("logger created before SetLevels kept the old level")
+	}
+}
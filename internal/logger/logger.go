@@ -16,54 +16,129 @@
  * along with this program. If not, see <https://www.gnu.org/licenses/>.
  */
 
+// Package logger configures the global zerolog logger and the loggers of
+// components.
 package logger
 
 import (
+	"io"
 	stdlog "log"
 	"os"
+	"sync"
 
 	"github.com/OmegaRogue/weylus-desktop/logger/gliblogger"
 	"github.com/OmegaRogue/weylus-desktop/logger/journald"
-	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/coreos/go-systemd/v22/journal"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/rs/zerolog/pkgerrors"
 )
 
+// ComponentFieldName is the field naming the component that logged a message.
+const ComponentFieldName = "component"
+
+// Options configures where and what is logged.
+type Options struct {
+	Levels Levels
+	Format Format
+	// File is a file to log to in addition to stderr, empty to disable.
+	File string
+	// FileMaxSize is the size in bytes at which File is rotated, 0 disables rotation.
+	FileMaxSize int64
+	// FileMaxBackups is the number of rotated files kept.
+	FileMaxBackups int
+	// Journald also sends the messages to the journal, if its socket exists.
+	Journald bool
+}
+
+// DefaultOptions returns the options used until Setup is called.
+func DefaultOptions() Options {
+	return Options{
+		Levels:   Levels{Default: zerolog.InfoLevel},
+		Format:   FormatConsole,
+		Journald: true,
+	}
+}
+
+var (
+	mu   sync.Mutex
+	base = log.Logger
+	file *RotatingFile
+
+	glibLogger zerolog.Logger
+	glibOnce   sync.Once
+)
+
+// SetupLogger logs to stderr and the journal with the default options, until
+// Setup is called with the configured ones.
 func SetupLogger() {
-	var multi zerolog.LevelWriter
-	journaldWriter := journald.NewBetterJournaldWriter()
-
-	if os.Getenv("WEYLUS_LOG_JSON") == "true" {
-		multi = zerolog.MultiLevelWriter(os.Stderr, journaldWriter)
-	} else {
-		consoleWriter := zerolog.ConsoleWriter{
-			Out:           os.Stderr,
-			FieldsExclude: []string{journald.ThreadFieldName, gliblogger.GlibLevelFieldName},
+	if err := Setup(DefaultOptions()); err != nil {
+		log.Err(err).Msg("setup logger")
+	}
+}
+
+// Setup configures the global logger, the standard library and GLib loggers.
+// Loggers of components created before keep writing to the previous outputs,
+// but follow the new levels.
+func Setup(o Options) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var writers []io.Writer
+	writers = append(writers, formatWriter(o.Format, os.Stderr, false))
+	var newFile *RotatingFile
+	if o.File != "" {
+		var err error
+		newFile, err = OpenRotatingFile(o.File, o.FileMaxSize, o.FileMaxBackups)
+		if err != nil {
+			return err
 		}
-		multi = zerolog.MultiLevelWriter(consoleWriter, journaldWriter)
+		writers = append(writers, formatWriter(o.Format, newFile, true))
 	}
-	log.Logger = log.Output(multi).With().Caller().Logger().Hook(journald.ThreadHook{})
-	stdLogger := log.With().Str("component", "stdlog").Logger()
+	journalMissing := o.Journald && !journal.Enabled()
+	if o.Journald && !journalMissing {
+		writers = append(writers, journald.NewBetterJournaldWriter())
+	}
+
+	base = zerolog.New(zerolog.MultiLevelWriter(writers...)).
+		With().Timestamp().Caller().Logger().
+		Hook(journald.ThreadHook{})
+	log.Logger = base.Hook(levelHook{})
+	SetLevels(o.Levels)
+
+	stdLogger := Component("stdlog")
 	stdlog.SetOutput(stdLogger)
-	if os.Getenv("G_DEBUG") != "" {
-		glibLog := log.With().Str("component", "glib").Logger()
-		glib.LogSetWriter(gliblogger.LoggerHandler(&glibLog))
+	glibLogger = Component("glib")
+	glibOnce.Do(setupGlib)
+
+	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
+
+	if file != nil {
+		if err := file.Close(); err != nil {
+			log.Err(err).Msg("close previous log file")
+		}
 	}
-	switch os.Getenv("WEYLUS_LOG_LEVEL") {
-	case "ERROR":
-		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-	case "WARN":
-		zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	case "INFO":
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	case "DEBUG":
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	case "TRACE":
-		zerolog.SetGlobalLevel(zerolog.TraceLevel)
-	default:
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	file = newFile
+	if journalMissing {
+		log.Debug().Msg("journal socket not found, not logging to the journal")
 	}
+	return nil
+}
 
-	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
+// formatWriter returns a writer formatting messages as f to w.
+func formatWriter(f Format, w io.Writer, noColor bool) io.Writer {
+	if f == FormatJson {
+		return w
+	}
+	return zerolog.ConsoleWriter{
+		Out:           w,
+		NoColor:       noColor,
+		FieldsExclude: []string{journald.ThreadFieldName, gliblogger.GlibLevelFieldName},
+	}
+}
+
+// Component returns a logger for the component name, its level can be
+// overridden by name.
+func Component(name string) zerolog.Logger {
+	return base.With().Str(ComponentFieldName, name).Logger().Hook(levelHook{component: name})
 }
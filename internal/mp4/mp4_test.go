@@ -0,0 +1,73 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// box returns a box of type typ with payload.
+func box(typ string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(data)))
+	return append(append(b, typ...), data...)
+}
+
+func TestFind(t *testing.T) {
+	large := binary.BigEndian.AppendUint32(nil, 1)
+	large = binary.BigEndian.AppendUint64(append(large, "mdat"...), 18)
+	large = append(large, 'a', 'b')
+	toEnd := append(binary.BigEndian.AppendUint32(nil, 0), "mdat"...)
+	data := append(box("moof", box("mfhd", []byte{1}), box("traf", box("tfdt", []byte{2}))), large...)
+
+	var tests = []struct {
+		name   string
+		data   []byte
+		path   []string
+		want   []byte
+		wantOk bool
+	}{
+		{"nested", data, []string{"moof", "traf", "tfdt"}, []byte{2}, true},
+		{"sibling", data, []string{"moof", "mfhd"}, []byte{1}, true},
+		{"large size", data, []string{"mdat"}, []byte("ab"), true},
+		{"size to end", append(toEnd, 'c'), []string{"mdat"}, []byte("c"), true},
+		{"missing", data, []string{"moof", "trun"}, nil, false},
+		{"truncated", data[:20], []string{"moof", "traf"}, nil, false},
+		{"too small", append(binary.BigEndian.AppendUint32(nil, 4), "moof"...), []string{"moof"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Find(tt.data, tt.path...)
+			if ok != tt.wantOk || !bytes.Equal(got, tt.want) {
+				t.Errorf("Find() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func FuzzFind(f *testing.F) {
+	f.Add(box("moof", box("traf", box("tfdt", []byte{1}))))
+	f.Fuzz(func(t *testing.T, in []byte) {
+		if got, ok := Find(in, "moof", "traf"); ok && len(got) > len(in) {
+			t.Errorf("Find() returned %d bytes of %d", len(got), len(in))
+		}
+	})
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package watch calls functions when files change.
+package watch
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// DefaultDelay is how long a file has to be unchanged before its function is
+// called, editors often write a file in several steps.
+const DefaultDelay = 100 * time.Millisecond
+
+// Watcher calls a function per file when the file changes. It watches the
+// directories of the files, so files replaced by renaming another file over
+// them, like many editors save, are noticed too.
+type Watcher struct {
+	watcher *fsnotify.Watcher
+	delay   time.Duration
+
+	mu     sync.Mutex
+	files  map[string]func()
+	dirs   map[string]int
+	timers map[string]*time.Timer
+}
+
+// New creates a Watcher calling functions delay after the last change of their
+// file.
+func New(delay time.Duration) (*Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "create file watcher")
+	}
+	return &Watcher{
+		watcher: watcher,
+		delay:   delay,
+		files:   make(map[string]func()),
+		dirs:    make(map[string]int),
+		timers:  make(map[string]*time.Timer),
+	}, nil
+}
+
+// Watch calls onChange when the file at path is written, created, removed or
+// renamed, replacing the function of a file already watched.
+func (w *Watcher) Watch(path string, onChange func()) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrap(err, "resolve path")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.files[path]; !ok {
+		dir := filepath.Dir(path)
+		if w.dirs[dir] == 0 {
+			if err := w.watcher.Add(dir); err != nil {
+				return errors.Wrapf(err, "watch %s", dir)
+			}
+		}
+		w.dirs[dir]++
+	}
+	w.files[path] = onChange
+	return nil
+}
+
+// Unwatch stops watching the file at path.
+func (w *Watcher) Unwatch(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrap(err, "resolve path")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.files[path]; !ok {
+		return nil
+	}
+	delete(w.files, path)
+	if t, ok := w.timers[path]; ok {
+		t.Stop()
+		delete(w.timers, path)
+	}
+	dir := filepath.Dir(path)
+	w.dirs[dir]--
+	if w.dirs[dir] > 0 {
+		return nil
+	}
+	delete(w.dirs, dir)
+	return errors.Wrapf(w.watcher.Remove(dir), "unwatch %s", dir)
+}
+
+// Run handles file changes until ctx is done or the Watcher is closed.
+func (w *Watcher) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			w.changed(e)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			zerolog.Ctx(ctx).Err(err).Msg("watch files")
+		}
+	}
+}
+
+// changed calls the function of the file of e after the delay, unless it
+// changes again before.
+func (w *Watcher) changed(e fsnotify.Event) {
+	if e.Op == fsnotify.Chmod {
+		return
+	}
+	path := filepath.Clean(e.Name)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.files[path]; !ok {
+		return
+	}
+	if t, ok := w.timers[path]; ok {
+		t.Reset(w.delay)
+		return
+	}
+	w.timers[path] = time.AfterFunc(w.delay, func() {
+		w.mu.Lock()
+		delete(w.timers, path)
+		onChange, ok := w.files[path]
+		w.mu.Unlock()
+		if ok {
+			onChange()
+		}
+	})
+}
+
+// Close stops watching all files.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	for path, t := range w.timers {
+		t.Stop()
+		delete(w.timers, path)
+	}
+	w.mu.Unlock()
+	return errors.Wrap(w.watcher.Close(), "close file watcher")
+}
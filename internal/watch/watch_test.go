@@ -0,0 +1,105 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "style.css")
+	other := filepath.Join(dir, "lib.js")
+	for _, p := range []string{path, other} {
+		if err := os.WriteFile(p, []byte("a"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := New(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := w.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := w.Run(ctx); err != nil && err != context.Canceled {
+			t.Error(err)
+		}
+	}()
+	changed := make(chan struct{}, 10)
+	if err := w.Watch(path, func() { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(name string, want bool) {
+		t.Helper()
+		select {
+		case <-changed:
+			if !want {
+				t.Errorf("%s: unexpected change", name)
+			}
+		case <-time.After(500 * time.Millisecond):
+			if want {
+				t.Errorf("%s: no change", name)
+			}
+		}
+	}
+
+	// several writes in a row are one change
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(path, []byte("b"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect("write", true)
+	expect("write again", false)
+
+	if err := os.WriteFile(other, []byte("b"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	expect("other file", false)
+
+	// editors save by renaming a new file over the old one
+	tmp := filepath.Join(dir, ".style.css.swp")
+	if err := os.WriteFile(tmp, []byte("c"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	expect("rename", true)
+
+	if err := w.Unwatch(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("d"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	expect("unwatched", false)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package macro records input events sent to weylus as JSON Lines and replays them.
+package macro
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+)
+
+// Entry is a single line of a macro file. Exactly one of the events is set.
+type Entry struct {
+	// Offset is the time since the start of the recording.
+	Offset        time.Duration           `json:"offset"`
+	PointerEvent  *protocol.PointerEvent  `json:"PointerEvent,omitempty"`
+	WheelEvent    *protocol.WheelEvent    `json:"WheelEvent,omitempty"`
+	KeyboardEvent *protocol.KeyboardEvent `json:"KeyboardEvent,omitempty"`
+}
+
+// Command returns the command of the event contained in e.
+func (e *Entry) Command() protocol.WeylusCommand {
+	switch {
+	case e.PointerEvent != nil:
+		return protocol.WeylusCommandPointerEvent
+	case e.WheelEvent != nil:
+		return protocol.WeylusCommandWheelEvent
+	case e.KeyboardEvent != nil:
+		return protocol.WeylusCommandKeyboardEvent
+	}
+	return ""
+}
+
+// Event is an input event that can be recorded.
+type Event interface {
+	protocol.PointerEvent | protocol.WheelEvent | protocol.KeyboardEvent
+}
+
+// Writer records events with their offset to the first recorded event. It is safe for concurrent use.
+type Writer struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	started time.Time
+	now     func() time.Time
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{enc: json.NewEncoder(w), now: time.Now}
+}
+
+// Record writes event to the macro.
+func Record[T Event](w *Writer, event T) error {
+	var entry Entry
+	switch e := any(event).(type) {
+	case protocol.PointerEvent:
+		entry.PointerEvent = &e
+	case protocol.WheelEvent:
+		entry.WheelEvent = &e
+	case protocol.KeyboardEvent:
+		entry.KeyboardEvent = &e
+	}
+	return w.write(&entry)
+}
+
+func (w *Writer) write(entry *Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.now()
+	if w.started.IsZero() {
+		w.started = now
+	}
+	entry.Offset = now.Sub(w.started)
+	if err := w.enc.Encode(entry); err != nil {
+		return errors.Wrapf(err, "record %s", entry.Command())
+	}
+	return nil
+}
+
+// Reader reads the entries of a macro.
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{scanner: bufio.NewScanner(r)}
+}
+
+// Next returns the next entry. It returns io.EOF after the last one.
+func (r *Reader) Next() (Entry, error) {
+	var entry Entry
+	for r.scanner.Scan() {
+		r.line++
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(r.scanner.Bytes(), &entry); err != nil {
+			return entry, errors.Wrapf(err, "line %d", r.line)
+		}
+		if entry.Command() == "" {
+			return entry, errors.Errorf("line %d: no event", r.line)
+		}
+		return entry, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return entry, errors.Wrap(err, "read macro")
+	}
+	return entry, io.EOF
+}
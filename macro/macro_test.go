@@ -0,0 +1,110 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package macro
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+)
+
+func TestWriterReader(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	now := time.Unix(100, 0)
+	w.now = func() time.Time { return now }
+
+	pointer := protocol.PointerEvent{EventType: protocol.PointerEventTypeDown, PointerType: protocol.PointerTypePen, X: 0.5, Pressure: 0.7}
+	keyboard := protocol.KeyboardEvent{EventType: protocol.KeyboardEventTypeDown, Code: "KeyA", Key: "a"}
+	wheel := protocol.WheelEvent{Dy: -10}
+	if err := Record(w, pointer); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(150 * time.Millisecond)
+	if err := Record(w, keyboard); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second)
+	if err := Record(w, wheel); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Fatalf("wrote %d lines, want 3:\n%s", lines, buf.String())
+	}
+
+	r := NewReader(&buf)
+	var tests = []struct {
+		offset  time.Duration
+		command protocol.WeylusCommand
+	}{
+		{0, protocol.WeylusCommandPointerEvent},
+		{150 * time.Millisecond, protocol.WeylusCommandKeyboardEvent},
+		{1150 * time.Millisecond, protocol.WeylusCommandWheelEvent},
+	}
+	for _, tt := range tests {
+		entry, err := r.Next()
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if entry.Offset != tt.offset || entry.Command() != tt.command {
+			t.Errorf("got %s at %v, want %s at %v", entry.Command(), entry.Offset, tt.command, tt.offset)
+		}
+		switch tt.command {
+		case protocol.WeylusCommandPointerEvent:
+			if *entry.PointerEvent != pointer {
+				t.Errorf("got %+v, want %+v", *entry.PointerEvent, pointer)
+			}
+		case protocol.WeylusCommandKeyboardEvent:
+			if *entry.KeyboardEvent != keyboard {
+				t.Errorf("got %+v, want %+v", *entry.KeyboardEvent, keyboard)
+			}
+		}
+	}
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestReader_Invalid(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input string
+	}{
+		{"json", "{\"offset\":0,\"PointerEvent\":{}}\n{"},
+		{"empty", "{\"offset\":10}"},
+		{"enum", "{\"offset\":0,\"KeyboardEvent\":{\"event_type\":\"sideways\"}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(strings.NewReader(tt.input))
+			var err error
+			for err == nil {
+				_, err = r.Next()
+			}
+			if errors.Is(err, io.EOF) {
+				t.Error("invalid macro was accepted")
+			}
+		})
+	}
+}
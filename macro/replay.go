@@ -0,0 +1,85 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package macro
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Sender sends input events to a weylus server, e.g. a client.WeylusClient.
+type Sender interface {
+	SendPointerEvent(e protocol.PointerEvent) error
+	SendWheelEvent(e protocol.WheelEvent) error
+	SendKeyboardEvent(e protocol.KeyboardEvent) error
+}
+
+// Replay sends the events of the macro read from r to sender, keeping their relative timing scaled by 1/speed.
+// Timestamps of the events are rewritten to the time they are sent.
+func Replay(ctx context.Context, r io.Reader, sender Sender, speed float64) error {
+	if speed <= 0 {
+		return errors.Errorf("invalid speed %v", speed)
+	}
+	reader := NewReader(r)
+	start := time.Now()
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		due := start.Add(time.Duration(float64(entry.Offset) / speed))
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Wrap(ctx.Err(), "replay")
+			case <-timer.C:
+			}
+		}
+		if err := send(sender, &entry, uint64(due.UnixMilli())); err != nil {
+			return errors.Wrapf(err, "replay %s at %v", entry.Command(), entry.Offset)
+		}
+		log.Trace().Stringer("command", entry.Command()).Dur("offset", entry.Offset).Msg("replayed event")
+	}
+}
+
+func send(sender Sender, entry *Entry, timestamp uint64) error {
+	switch {
+	case entry.PointerEvent != nil:
+		e := *entry.PointerEvent
+		e.Timestamp = timestamp
+		return sender.SendPointerEvent(e)
+	case entry.WheelEvent != nil:
+		e := *entry.WheelEvent
+		e.Timestamp = timestamp
+		return sender.SendWheelEvent(e)
+	case entry.KeyboardEvent != nil:
+		return sender.SendKeyboardEvent(*entry.KeyboardEvent)
+	}
+	return nil
+}
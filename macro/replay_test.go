@@ -0,0 +1,127 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package macro
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+)
+
+type sent struct {
+	at    time.Time
+	event any
+}
+
+type fakeSender struct {
+	sent []sent
+	err  error
+}
+
+func (f *fakeSender) SendPointerEvent(e protocol.PointerEvent) error {
+	f.sent = append(f.sent, sent{time.Now(), e})
+	return f.err
+}
+
+func (f *fakeSender) SendWheelEvent(e protocol.WheelEvent) error {
+	f.sent = append(f.sent, sent{time.Now(), e})
+	return f.err
+}
+
+func (f *fakeSender) SendKeyboardEvent(e protocol.KeyboardEvent) error {
+	f.sent = append(f.sent, sent{time.Now(), e})
+	return f.err
+}
+
+func testMacro(t *testing.T, offsets ...time.Duration) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	start := time.Now()
+	for i, offset := range offsets {
+		w.now = func() time.Time { return start.Add(offset) }
+		var err error
+		if i%2 == 0 {
+			err = Record(w, protocol.PointerEvent{EventType: protocol.PointerEventTypeMove, X: float64(i), Timestamp: 1})
+		} else {
+			err = Record(w, protocol.KeyboardEvent{EventType: protocol.KeyboardEventTypeDown, Key: "a"})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buf
+}
+
+func TestReplay(t *testing.T) {
+	var sender fakeSender
+	start := time.Now()
+	if err := Replay(context.Background(), testMacro(t, 0, 20*time.Millisecond, 100*time.Millisecond), &sender, 1); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(sender.sent) != 3 {
+		t.Fatalf("sent %d events, want 3", len(sender.sent))
+	}
+	if d := sender.sent[2].at.Sub(start); d < 100*time.Millisecond {
+		t.Errorf("last event sent after %v, want at least 100ms", d)
+	}
+	if e, ok := sender.sent[2].event.(protocol.PointerEvent); !ok || e.X != 2 {
+		t.Errorf("unexpected last event %+v", sender.sent[2].event)
+	} else if e.Timestamp < uint64(start.UnixMilli()) {
+		t.Errorf("timestamp %d was not rewritten", e.Timestamp)
+	}
+}
+
+func TestReplay_Speed(t *testing.T) {
+	var sender fakeSender
+	start := time.Now()
+	if err := Replay(context.Background(), testMacro(t, 0, time.Second, 2*time.Second), &sender, 20); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond || d > time.Second {
+		t.Errorf("replay at 20x took %v, want about 100ms", d)
+	}
+}
+
+func TestReplay_Cancel(t *testing.T) {
+	var sender fakeSender
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := Replay(ctx, testMacro(t, 0, time.Hour), &sender, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("sent %d events, want 1", len(sender.sent))
+	}
+}
+
+func TestReplay_Errors(t *testing.T) {
+	sender := fakeSender{err: errors.New("broken")}
+	if err := Replay(context.Background(), testMacro(t, 0), &sender, 1); err == nil {
+		t.Error("send error was not returned")
+	}
+	if err := Replay(context.Background(), testMacro(t, 0), &sender, 0); err == nil {
+		t.Error("invalid speed was accepted")
+	}
+}
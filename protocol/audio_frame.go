@@ -0,0 +1,74 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// audioFrameMagic starts the binary messages of audio frames. Video fragments start with the size of an MP4 box, which
+// is never this large.
+var audioFrameMagic = []byte("WAUD")
+
+// audioFrameHeaderSize is the size of the magic and the timestamp.
+const audioFrameHeaderSize = 4 + 8
+
+// AudioFrame is an Opus packet, sent by the server as a binary message.
+type AudioFrame struct {
+	// Timestamp is the presentation time of the packet since the start of the audio capture.
+	Timestamp time.Duration
+	Data      []byte
+}
+
+// IsAudioFrame reports whether the binary message data is an AudioFrame.
+func IsAudioFrame(data []byte) bool {
+	return bytes.HasPrefix(data, audioFrameMagic)
+}
+
+// MarshalBinary encodes the frame as the magic, the timestamp in microseconds as big endian uint64 and the packet.
+func (f AudioFrame) MarshalBinary() ([]byte, error) {
+	if f.Timestamp < 0 {
+		return nil, errors.Errorf("negative audio timestamp %s", f.Timestamp)
+	}
+	data := make([]byte, audioFrameHeaderSize, audioFrameHeaderSize+len(f.Data))
+	copy(data, audioFrameMagic)
+	binary.BigEndian.PutUint64(data[len(audioFrameMagic):], uint64(f.Timestamp/time.Microsecond))
+	return append(data, f.Data...), nil
+}
+
+// UnmarshalBinary decodes a frame encoded by MarshalBinary.
+func (f *AudioFrame) UnmarshalBinary(data []byte) error {
+	if !IsAudioFrame(data) {
+		return errors.New("not an audio frame")
+	}
+	if len(data) < audioFrameHeaderSize {
+		return errors.New("audio frame too short")
+	}
+	us := binary.BigEndian.Uint64(data[len(audioFrameMagic):])
+	if us > uint64(1<<63-1)/uint64(time.Microsecond) {
+		return errors.Errorf("audio timestamp %d out of range", us)
+	}
+	f.Timestamp = time.Duration(us) * time.Microsecond
+	f.Data = append([]byte(nil), data[audioFrameHeaderSize:]...)
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAudioFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   AudioFrame
+		wantErr bool
+	}{
+		{"Packet", AudioFrame{Timestamp: 1500 * time.Millisecond, Data: []byte{0xfc, 0xff, 0xfe}}, false},
+		{"Empty", AudioFrame{}, false},
+		{"Negative", AudioFrame{Timestamp: -time.Second}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.frame.MarshalBinary()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MarshalBinary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !IsAudioFrame(data) {
+				t.Fatal("IsAudioFrame() = false")
+			}
+			var got AudioFrame
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary() error = %v", err)
+			}
+			if got.Timestamp != tt.frame.Timestamp || !bytes.Equal(got.Data, tt.frame.Data) {
+				t.Errorf("UnmarshalBinary() = %+v, want %+v", got, tt.frame)
+			}
+		})
+	}
+}
+
+func TestAudioFrame_UnmarshalBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"VideoFragment", []byte{0, 0, 0, 8, 'm', 'o', 'o', 'f'}},
+		{"Short", []byte("WAUD\x00\x00")},
+		{"OutOfRange", []byte("WAUD\xff\xff\xff\xff\xff\xff\xff\xff")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f AudioFrame
+			if err := f.UnmarshalBinary(tt.data); err == nil {
+				t.Errorf("UnmarshalBinary(%q) error = nil, want error", tt.data)
+			}
+		})
+	}
+}
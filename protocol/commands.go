@@ -33,6 +33,10 @@ Config
 KeyboardEvent
 PointerEvent
 WheelEvent
+GamepadEvent
+ClipboardOffer
+ClipboardRequest
+ClipboardContent
 )
 */
 type WeylusCommand string
@@ -45,6 +49,14 @@ func CommandFromOutboundContent[T MessageOutboundContent](content T) WeylusComma
 		return WeylusCommandWheelEvent
 	case KeyboardEvent:
 		return WeylusCommandKeyboardEvent
+	case GamepadEvent:
+		return WeylusCommandGamepadEvent
+	case ClipboardOffer:
+		return WeylusCommandClipboardOffer
+	case ClipboardRequest:
+		return WeylusCommandClipboardRequest
+	case ClipboardContent:
+		return WeylusCommandClipboardContent
 	case Config:
 		return WeylusCommandConfig
 	default:
@@ -42,6 +42,14 @@ const (
 	WeylusCommandPointerEvent WeylusCommand = "PointerEvent"
 	// WeylusCommandWheelEvent is a WeylusCommand of type WheelEvent.
 	WeylusCommandWheelEvent WeylusCommand = "WheelEvent"
+	// WeylusCommandGamepadEvent is a WeylusCommand of type GamepadEvent.
+	WeylusCommandGamepadEvent WeylusCommand = "GamepadEvent"
+	// WeylusCommandClipboardOffer is a WeylusCommand of type ClipboardOffer.
+	WeylusCommandClipboardOffer WeylusCommand = "ClipboardOffer"
+	// WeylusCommandClipboardRequest is a WeylusCommand of type ClipboardRequest.
+	WeylusCommandClipboardRequest WeylusCommand = "ClipboardRequest"
+	// WeylusCommandClipboardContent is a WeylusCommand of type ClipboardContent.
+	WeylusCommandClipboardContent WeylusCommand = "ClipboardContent"
 )
 
 var ErrInvalidWeylusCommand = fmt.Errorf("not a valid WeylusCommand, try [%s]", strings.Join(_WeylusCommandNames, ", "))
@@ -53,6 +61,10 @@ var _WeylusCommandNames = []string{
 	string(WeylusCommandKeyboardEvent),
 	string(WeylusCommandPointerEvent),
 	string(WeylusCommandWheelEvent),
+	string(WeylusCommandGamepadEvent),
+	string(WeylusCommandClipboardOffer),
+	string(WeylusCommandClipboardRequest),
+	string(WeylusCommandClipboardContent),
 }
 
 // WeylusCommandNames returns a list of possible string values of WeylusCommand.
@@ -71,6 +83,10 @@ func WeylusCommandValues() []WeylusCommand {
 		WeylusCommandKeyboardEvent,
 		WeylusCommandPointerEvent,
 		WeylusCommandWheelEvent,
+		WeylusCommandGamepadEvent,
+		WeylusCommandClipboardOffer,
+		WeylusCommandClipboardRequest,
+		WeylusCommandClipboardContent,
 	}
 }
 
@@ -92,6 +108,10 @@ var _WeylusCommandValue = map[string]WeylusCommand{
 	"KeyboardEvent":     WeylusCommandKeyboardEvent,
 	"PointerEvent":      WeylusCommandPointerEvent,
 	"WheelEvent":        WeylusCommandWheelEvent,
+	"GamepadEvent":      WeylusCommandGamepadEvent,
+	"ClipboardOffer":    WeylusCommandClipboardOffer,
+	"ClipboardRequest":  WeylusCommandClipboardRequest,
+	"ClipboardContent":  WeylusCommandClipboardContent,
 }
 
 // ParseWeylusCommand attempts to convert a string to a WeylusCommand.
@@ -39,6 +39,10 @@ func TestCommandFromOutboundContent(t *testing.T) {
 		{"KeyboardEvent", KeyboardEvent{}, WeylusCommandKeyboardEvent},
 		{"PointerEvent", PointerEvent{}, WeylusCommandPointerEvent},
 		{"WheelEvent", WheelEvent{}, WeylusCommandWheelEvent},
+		{"ClipboardOffer", ClipboardOffer{}, WeylusCommandClipboardOffer},
+		{"ClipboardRequest", ClipboardRequest{}, WeylusCommandClipboardRequest},
+		{"ClipboardContent", ClipboardContent{}, WeylusCommandClipboardContent},
+		{"GamepadEvent", GamepadEvent{}, WeylusCommandGamepadEvent},
 	}
 	//nolint:dupl
 	for _, tt := range tests {
@@ -54,6 +58,18 @@ func TestCommandFromOutboundContent(t *testing.T) {
 			case KeyboardEvent:
 				res1 := CommandFromOutboundContent(val)
 				res = res1
+			case ClipboardOffer:
+				res1 := CommandFromOutboundContent(val)
+				res = res1
+			case ClipboardRequest:
+				res1 := CommandFromOutboundContent(val)
+				res = res1
+			case ClipboardContent:
+				res1 := CommandFromOutboundContent(val)
+				res = res1
+			case GamepadEvent:
+				res1 := CommandFromOutboundContent(val)
+				res = res1
 			case Config:
 				res1 := CommandFromOutboundContent(val)
 				res = res1
@@ -0,0 +1,57 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package protocol
+
+// GamepadButtons are the buttons of a gamepad in the layout of an Xbox controller.
+type GamepadButtons uint32
+
+const (
+	// GamepadNone is a GamepadButtons of type None.
+	GamepadNone GamepadButtons = 0
+	// GamepadA is a GamepadButtons of type A. The bottom face button
+	GamepadA GamepadButtons = 1 << (iota - 1)
+	// GamepadB is a GamepadButtons of type B. The right face button
+	GamepadB
+	// GamepadX is a GamepadButtons of type X. The left face button
+	GamepadX
+	// GamepadY is a GamepadButtons of type Y. The top face button
+	GamepadY
+	// GamepadLeftBumper is a GamepadButtons of type LeftBumper.
+	GamepadLeftBumper
+	// GamepadRightBumper is a GamepadButtons of type RightBumper.
+	GamepadRightBumper
+	// GamepadBack is a GamepadButtons of type Back. Also called Select or View
+	GamepadBack
+	// GamepadStart is a GamepadButtons of type Start. Also called Menu
+	GamepadStart
+	// GamepadGuide is a GamepadButtons of type Guide. The Xbox or Home button
+	GamepadGuide
+	// GamepadLeftStick is a GamepadButtons of type LeftStick. Pressing the left stick
+	GamepadLeftStick
+	// GamepadRightStick is a GamepadButtons of type RightStick. Pressing the right stick
+	GamepadRightStick
+	// GamepadDPadUp is a GamepadButtons of type DPadUp.
+	GamepadDPadUp
+	// GamepadDPadDown is a GamepadButtons of type DPadDown.
+	GamepadDPadDown
+	// GamepadDPadLeft is a GamepadButtons of type DPadLeft.
+	GamepadDPadLeft
+	// GamepadDPadRight is a GamepadButtons of type DPadRight.
+	GamepadDPadRight
+)
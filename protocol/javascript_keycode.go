@@ -269,6 +269,7 @@ var (
 		gdk.KEY_Scroll_Lock:      "ScrollLock",
 		gdk.KEY_KP_Enter:         "Enter",
 		gdk.KEY_Katakana:         "KanaMode",
+		gdk.KEY_Multi_key:        "Compose",
 		gdk.KEY_3270_PrintScreen: "PrintScreen",
 		gdk.KEY_Home:             "Home",
 		gdk.KEY_Up:               "ArrowUp",
@@ -280,6 +281,17 @@ var (
 		gdk.KEY_Page_Down:        "PageDown",
 		gdk.KEY_Insert:           "Insert",
 		gdk.KEY_Delete:           "Delete",
+		gdk.KEY_KP_Home:          "Home",
+		gdk.KEY_KP_Up:            "ArrowUp",
+		gdk.KEY_KP_Page_Up:       "PageUp",
+		gdk.KEY_KP_Left:          "ArrowLeft",
+		gdk.KEY_KP_Begin:         "Clear",
+		gdk.KEY_KP_Right:         "ArrowRight",
+		gdk.KEY_KP_End:           "End",
+		gdk.KEY_KP_Down:          "ArrowDown",
+		gdk.KEY_KP_Page_Down:     "PageDown",
+		gdk.KEY_KP_Insert:        "Insert",
+		gdk.KEY_KP_Delete:        "Delete",
 		gdk.KEY_AudioMute:        "VolumeMute",
 		gdk.KEY_AudioLowerVolume: "VolumeDown",
 		gdk.KEY_AudioRaiseVolume: "VolumeUp",
@@ -36,13 +36,15 @@ type Config struct {
 	MaxWidth      uint   `json:"max_width"`
 	MaxHeight     uint   `json:"max_height"`
 	ClientName    string `json:"client_name,omitempty"`
+	// Audio asks the server to send audio frames besides the video.
+	Audio bool `json:"audio,omitempty"`
 }
 
 type MessageOutboundContent interface {
-	PointerEvent | WheelEvent | KeyboardEvent | Config | ~string
+	PointerEvent | WheelEvent | KeyboardEvent | GamepadEvent | ClipboardOffer | ClipboardRequest | ClipboardContent | Config | ~string
 }
 type MessageOutbound interface {
-	map[WeylusCommand]PointerEvent | map[WeylusCommand]WheelEvent | map[WeylusCommand]KeyboardEvent | map[WeylusCommand]Config | ~string
+	map[WeylusCommand]PointerEvent | map[WeylusCommand]WheelEvent | map[WeylusCommand]KeyboardEvent | map[WeylusCommand]GamepadEvent | map[WeylusCommand]ClipboardOffer | map[WeylusCommand]ClipboardRequest | map[WeylusCommand]ClipboardContent | map[WeylusCommand]Config | ~string
 }
 
 func WrapMessage[T MessageOutboundContent](a T) any {
@@ -54,6 +56,14 @@ func WrapMessage[T MessageOutboundContent](a T) any {
 		wrapper[WeylusCommandWheelEvent] = a
 	case KeyboardEvent:
 		wrapper[WeylusCommandKeyboardEvent] = a
+	case GamepadEvent:
+		wrapper[WeylusCommandGamepadEvent] = a
+	case ClipboardOffer:
+		wrapper[WeylusCommandClipboardOffer] = a
+	case ClipboardRequest:
+		wrapper[WeylusCommandClipboardRequest] = a
+	case ClipboardContent:
+		wrapper[WeylusCommandClipboardContent] = a
 	case Config:
 		wrapper[WeylusCommandConfig] = a
 	case string, WeylusCommand:
@@ -141,6 +151,8 @@ type PointerEvent struct {
 	Button      ButtonFlags      `json:"button"`
 	Buttons     ButtonFlags      `json:"buttons"`
 	IsPrimary   bool             `json:"is_primary"`
+	// Relative marks relative mouse input, only MovementX, MovementY and the buttons are meaningful.
+	Relative bool `json:"relative,omitempty"`
 }
 
 type WheelEvent struct {
@@ -159,3 +171,48 @@ type KeyboardEvent struct {
 	Shift     bool              `json:"shift"`
 	Meta      bool              `json:"meta"`
 }
+
+// GamepadEvent is the state of a gamepad after it changed.
+// Sticks are in [-1, 1] with positive values to the right and down, triggers in [0, 1].
+type GamepadEvent struct {
+	// Index tells the gamepads of a client apart.
+	Index        int            `json:"index"`
+	Connected    bool           `json:"connected"`
+	Buttons      GamepadButtons `json:"buttons"`
+	LeftX        float64        `json:"left_x"`
+	LeftY        float64        `json:"left_y"`
+	RightX       float64        `json:"right_x"`
+	RightY       float64        `json:"right_y"`
+	LeftTrigger  float64        `json:"left_trigger"`
+	RightTrigger float64        `json:"right_trigger"`
+	Timestamp    uint64         `json:"timestamp"`
+}
+
+// Clipboard content types.
+const (
+	ClipboardMimeText = "text/plain;charset=utf-8"
+	ClipboardMimePNG  = "image/png"
+)
+
+// ClipboardOffer announces new clipboard content, sent by the side whose clipboard changed.
+// The other side asks for the content with a ClipboardRequest.
+type ClipboardOffer struct {
+	// Serial identifies the offer, it increases with every offer of a side.
+	Serial    uint64   `json:"serial"`
+	MimeTypes []string `json:"mime_types"`
+	// Size is the size of the content in bytes.
+	Size int `json:"size"`
+}
+
+// ClipboardRequest asks for the content of a ClipboardOffer.
+type ClipboardRequest struct {
+	Serial   uint64 `json:"serial"`
+	MimeType string `json:"mime_type"`
+}
+
+// ClipboardContent is the content of a ClipboardOffer, answering a ClipboardRequest.
+type ClipboardContent struct {
+	Serial   uint64 `json:"serial"`
+	MimeType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
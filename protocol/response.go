@@ -27,6 +27,9 @@ CapturableList
 ConfigOk
 ConfigError
 Error
+ClipboardOffer
+ClipboardRequest
+ClipboardContent
 )
 */
 type WeylusResponse string
@@ -40,6 +40,12 @@ const (
 	WeylusResponseConfigError WeylusResponse = "ConfigError"
 	// WeylusResponseError is a WeylusResponse of type Error.
 	WeylusResponseError WeylusResponse = "Error"
+	// WeylusResponseClipboardOffer is a WeylusResponse of type ClipboardOffer.
+	WeylusResponseClipboardOffer WeylusResponse = "ClipboardOffer"
+	// WeylusResponseClipboardRequest is a WeylusResponse of type ClipboardRequest.
+	WeylusResponseClipboardRequest WeylusResponse = "ClipboardRequest"
+	// WeylusResponseClipboardContent is a WeylusResponse of type ClipboardContent.
+	WeylusResponseClipboardContent WeylusResponse = "ClipboardContent"
 )
 
 var ErrInvalidWeylusResponse = fmt.Errorf("not a valid WeylusResponse, try [%s]", strings.Join(_WeylusResponseNames, ", "))
@@ -50,6 +56,9 @@ var _WeylusResponseNames = []string{
 	string(WeylusResponseConfigOk),
 	string(WeylusResponseConfigError),
 	string(WeylusResponseError),
+	string(WeylusResponseClipboardOffer),
+	string(WeylusResponseClipboardRequest),
+	string(WeylusResponseClipboardContent),
 }
 
 // WeylusResponseNames returns a list of possible string values of WeylusResponse.
@@ -67,6 +76,9 @@ func WeylusResponseValues() []WeylusResponse {
 		WeylusResponseConfigOk,
 		WeylusResponseConfigError,
 		WeylusResponseError,
+		WeylusResponseClipboardOffer,
+		WeylusResponseClipboardRequest,
+		WeylusResponseClipboardContent,
 	}
 }
 
@@ -82,11 +94,14 @@ func (x WeylusResponse) IsValid() bool {
 }
 
 var _WeylusResponseValue = map[string]WeylusResponse{
-	"NewVideo":       WeylusResponseNewVideo,
-	"CapturableList": WeylusResponseCapturableList,
-	"ConfigOk":       WeylusResponseConfigOk,
-	"ConfigError":    WeylusResponseConfigError,
-	"Error":          WeylusResponseError,
+	"NewVideo":         WeylusResponseNewVideo,
+	"CapturableList":   WeylusResponseCapturableList,
+	"ConfigOk":         WeylusResponseConfigOk,
+	"ConfigError":      WeylusResponseConfigError,
+	"Error":            WeylusResponseError,
+	"ClipboardOffer":   WeylusResponseClipboardOffer,
+	"ClipboardRequest": WeylusResponseClipboardRequest,
+	"ClipboardContent": WeylusResponseClipboardContent,
 }
 
 // ParseWeylusResponse attempts to convert a string to a WeylusResponse.
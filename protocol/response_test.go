@@ -38,6 +38,9 @@ func TestResponseFromOutboundContent(t *testing.T) {
 		{"KeyboardEvent", KeyboardEvent{}, ""},
 		{"PointerEvent", PointerEvent{}, ""},
 		{"WheelEvent", WheelEvent{}, ""},
+		{"ClipboardOffer", ClipboardOffer{}, ""},
+		{"ClipboardRequest", ClipboardRequest{}, ""},
+		{"ClipboardContent", ClipboardContent{}, ""},
 	}
 	//nolint:dupl
 	for _, tt := range tests {
@@ -53,6 +56,15 @@ func TestResponseFromOutboundContent(t *testing.T) {
 			case KeyboardEvent:
 				res1 := ResponseFromOutboundContent(val)
 				res = res1
+			case ClipboardOffer:
+				res1 := ResponseFromOutboundContent(val)
+				res = res1
+			case ClipboardRequest:
+				res1 := ResponseFromOutboundContent(val)
+				res = res1
+			case ClipboardContent:
+				res1 := ResponseFromOutboundContent(val)
+				res = res1
 			case Config:
 				res1 := ResponseFromOutboundContent(val)
 				res = res1
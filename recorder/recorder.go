@@ -0,0 +1,306 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package recorder writes the fMP4 video stream received from weylus to segmented MP4 files.
+package recorder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/internal/mp4"
+	"github.com/pkg/errors"
+)
+
+// Options configure where and how a recording is written.
+type Options struct {
+	// Path is the base path of the recording. Segments are written to Path with an index appended to the file name,
+	// e.g. recording.mp4 becomes recording-001.mp4, recording-002.mp4, ...
+	Path string
+	// MaxSize rotates to a new segment once the current one would exceed MaxSize bytes. 0 disables size based
+	// rotation.
+	MaxSize int64
+	// MaxDuration rotates to a new segment once the current one is older than MaxDuration. 0 disables time based
+	// rotation.
+	MaxDuration time.Duration
+}
+
+// Recorder tees fMP4 fragments into MP4 files. Every file starts with the initialization segment of the current video,
+// so each segment is playable on its own. For the same reason every segment starts with a keyframe fragment: fragments
+// before the first keyframe of a recording or video are dropped, and once rotation is due the current segment grows
+// until the next keyframe, past MaxSize and MaxDuration.
+//
+// The Recorder keeps track of the initialization segment even while not recording, so a recording can be started
+// at any point of the stream.
+type Recorder struct {
+	mu         sync.Mutex
+	opts       Options
+	recording  bool
+	init       []byte
+	collecting bool
+	file       *os.File
+	size       int64
+	started    time.Time
+	index      int
+	now        func() time.Time
+}
+
+// NewRecorder returns a new Recorder that is not recording yet.
+func NewRecorder() *Recorder {
+	return &Recorder{now: time.Now}
+}
+
+// IsInitSegment reports whether fragment starts with the ftyp or moov box of an initialization segment.
+func IsInitSegment(fragment []byte) bool {
+	if len(fragment) < 8 {
+		return false
+	}
+	switch string(fragment[4:8]) {
+	case "ftyp", "moov":
+		return true
+	}
+	return false
+}
+
+// Sample flags of ISO/IEC 14496-12 track fragments.
+const (
+	// sampleNonSync marks samples other than keyframes
+	sampleNonSync = 0x00010000
+	// tfhdDefaultSampleFlags and the flags before it tell which optional fields the tfhd box has
+	tfhdBaseDataOffset     = 0x000001
+	tfhdSampleDescription  = 0x000002
+	tfhdDefaultDuration    = 0x000008
+	tfhdDefaultSize        = 0x000010
+	tfhdDefaultSampleFlags = 0x000020
+	// trunFirstSampleFlags and the flags before it tell which optional fields the trun box has
+	trunDataOffset       = 0x000001
+	trunFirstSampleFlags = 0x000004
+	trunSampleDuration   = 0x000100
+	trunSampleSize       = 0x000200
+	trunSampleFlags      = 0x000400
+)
+
+// IsKeyframe reports whether the media fragment starts with a keyframe, so a segment can start with it. Fragments
+// that don't tell with sample flags in their track fragment are taken as keyframes.
+func IsKeyframe(fragment []byte) bool {
+	traf, ok := mp4.Find(fragment, "moof", "traf")
+	if !ok {
+		return true
+	}
+	if trun, ok := mp4.Find(traf, "trun"); ok && len(trun) >= 8 {
+		flags := binary.BigEndian.Uint32(trun) & 0xffffff
+		offset := 8
+		if flags&trunDataOffset != 0 {
+			offset += 4
+		}
+		if flags&trunFirstSampleFlags != 0 && len(trun) >= offset+4 {
+			return binary.BigEndian.Uint32(trun[offset:])&sampleNonSync == 0
+		}
+		if flags&trunSampleFlags != 0 && binary.BigEndian.Uint32(trun[4:]) > 0 {
+			if flags&trunSampleDuration != 0 {
+				offset += 4
+			}
+			if flags&trunSampleSize != 0 {
+				offset += 4
+			}
+			if len(trun) >= offset+4 {
+				return binary.BigEndian.Uint32(trun[offset:])&sampleNonSync == 0
+			}
+		}
+	}
+	if tfhd, ok := mp4.Find(traf, "tfhd"); ok && len(tfhd) >= 8 {
+		flags := binary.BigEndian.Uint32(tfhd) & 0xffffff
+		offset := 8
+		for _, field := range []struct {
+			flag uint32
+			size int
+		}{
+			{tfhdBaseDataOffset, 8},
+			{tfhdSampleDescription, 4},
+			{tfhdDefaultDuration, 4},
+			{tfhdDefaultSize, 4},
+		} {
+			if flags&field.flag != 0 {
+				offset += field.size
+			}
+		}
+		if flags&tfhdDefaultSampleFlags != 0 && len(tfhd) >= offset+4 {
+			return binary.BigEndian.Uint32(tfhd[offset:])&sampleNonSync == 0
+		}
+	}
+	return true
+}
+
+// Start starts recording to opts.Path.
+func (r *Recorder) Start(opts Options) error {
+	if opts.Path == "" {
+		return errors.New("recording path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0o755); err != nil {
+		return errors.Wrap(err, "create recording directory")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.closeSegment(); err != nil {
+		return err
+	}
+	r.opts = opts
+	r.index = 0
+	r.recording = true
+	return nil
+}
+
+// Stop stops the recording and closes the current segment.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording = false
+	return r.closeSegment()
+}
+
+// Recording reports whether the Recorder is currently recording.
+func (r *Recorder) Recording() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recording
+}
+
+// Segment returns the path of the segment currently written to, or "" if there is none.
+func (r *Recorder) Segment() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return ""
+	}
+	return r.file.Name()
+}
+
+// NewSegment ends the current segment, e.g. because the server announced a NewVideo. The next segment starts with
+// the next initialization segment received.
+func (r *Recorder) NewSegment() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init = nil
+	r.collecting = false
+	return r.closeSegment()
+}
+
+// Write records a single fragment of the video stream. Media fragments received before an initialization segment
+// are dropped, as they can't be played back.
+func (r *Recorder) Write(fragment []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if IsInitSegment(fragment) {
+		if !r.collecting {
+			// a new initialization segment means a new video, which needs a new file
+			r.init = nil
+			r.collecting = true
+			if err := r.closeSegment(); err != nil {
+				return 0, err
+			}
+		}
+		r.init = append(r.init, fragment...)
+		return len(fragment), nil
+	}
+	r.collecting = false
+	if !r.recording || r.init == nil {
+		return len(fragment), nil
+	}
+	switch {
+	case r.file == nil && !IsKeyframe(fragment):
+		// the segment would start mid-GOP, wait for the next keyframe
+		return len(fragment), nil
+	case r.file == nil || r.rotationDue(len(fragment)) && IsKeyframe(fragment):
+		if err := r.openSegment(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(fragment)
+	r.size += int64(n)
+	if err != nil {
+		return n, errors.Wrap(err, "write fragment")
+	}
+	return n, nil
+}
+
+// Close stops the recording.
+func (r *Recorder) Close() error {
+	return r.Stop()
+}
+
+func (r *Recorder) rotationDue(next int) bool {
+	if r.opts.MaxSize > 0 && r.size+int64(next) > r.opts.MaxSize && r.size > int64(len(r.init)) {
+		return true
+	}
+	if r.opts.MaxDuration > 0 && r.now().Sub(r.started) >= r.opts.MaxDuration {
+		return true
+	}
+	return false
+}
+
+// segmentPath returns the path for the segment with index i.
+func (r *Recorder) segmentPath(i int) string {
+	ext := filepath.Ext(r.opts.Path)
+	if ext == "" {
+		ext = ".mp4"
+	}
+	return fmt.Sprintf("%s-%03d%s", strings.TrimSuffix(r.opts.Path, filepath.Ext(r.opts.Path)), i, ext)
+}
+
+func (r *Recorder) openSegment() error {
+	if err := r.closeSegment(); err != nil {
+		return err
+	}
+	for {
+		r.index++
+		f, err := os.OpenFile(r.segmentPath(r.index), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, "create segment")
+		}
+		r.file = f
+		break
+	}
+	r.started = r.now()
+	n, err := r.file.Write(r.init)
+	r.size = int64(n)
+	if err != nil {
+		return errors.Wrap(err, "write initialization segment")
+	}
+	return nil
+}
+
+func (r *Recorder) closeSegment() error {
+	if r.file == nil {
+		return nil
+	}
+	f := r.file
+	r.file = nil
+	r.size = 0
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "close segment %s", f.Name())
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package recorder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/client/weylustest"
+)
+
+func readSegment(t *testing.T, path string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read segment: %v", err)
+	}
+	return b
+}
+
+func write(t *testing.T, r *Recorder, fragments ...[]byte) {
+	t.Helper()
+	for _, f := range fragments {
+		if _, err := r.Write(f); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func TestIsInitSegment(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input []byte
+		want  bool
+	}{
+		{"InitSegment", weylustest.InitSegment(), true},
+		{"moov", weylustest.Box("moov"), true},
+		{"MediaSegment", weylustest.MediaSegment(1), false},
+		{"short", []byte("ftyp"), false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsInitSegment(tt.input); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder()
+	init, m1, m2 := weylustest.InitSegment(), weylustest.MediaSegment(1), weylustest.MediaSegment(2)
+
+	// the initialization segment is kept while not recording
+	write(t, r, init, m1)
+	if err := r.Start(Options{Path: filepath.Join(dir, "rec.mp4")}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	write(t, r, m2)
+	if err := r.Stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	got := readSegment(t, filepath.Join(dir, "rec-001.mp4"))
+	if want := append(append([]byte(nil), init...), m2...); !bytes.Equal(got, want) {
+		t.Errorf("segment contains %x, want %x", got, want)
+	}
+}
+
+func TestRecorder_NewVideo(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder()
+	if err := r.Start(Options{Path: filepath.Join(dir, "rec.mp4")}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	init2 := weylustest.Box("ftyp", []byte("iso6"))
+	write(t, r, weylustest.InitSegment(), weylustest.MediaSegment(1))
+	if err := r.NewSegment(); err != nil {
+		t.Fatalf("new segment: %v", err)
+	}
+	// fragments before the next initialization segment are dropped
+	write(t, r, weylustest.MediaSegment(2), init2, weylustest.MediaSegment(3))
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	got := readSegment(t, filepath.Join(dir, "rec-002.mp4"))
+	if want := append(append([]byte(nil), init2...), weylustest.MediaSegment(3)...); !bytes.Equal(got, want) {
+		t.Errorf("segment contains %x, want %x", got, want)
+	}
+}
+
+func TestRecorder_RotateSize(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder()
+	init, media := weylustest.InitSegment(), weylustest.MediaSegment(1)
+	if err := r.Start(Options{Path: filepath.Join(dir, "rec.mp4"), MaxSize: int64(len(init) + len(media))}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	write(t, r, init, media, media, media)
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	for _, name := range []string{"rec-001.mp4", "rec-002.mp4", "rec-003.mp4"} {
+		if got := readSegment(t, filepath.Join(dir, name)); !bytes.HasPrefix(got, init) || len(got) != len(init)+len(media) {
+			t.Errorf("invalid segment %s: %x", name, got)
+		}
+	}
+}
+
+func TestIsKeyframe(t *testing.T) {
+	box := weylustest.Box
+	traf := func(boxes ...[]byte) []byte { return box("moof", box("traf", boxes...)) }
+	var tests = []struct {
+		name     string
+		fragment []byte
+		want     bool
+	}{
+		{"NoTrackFragment", weylustest.MediaSegment(1), true},
+		{"Keyframe", weylustest.TrackSegment(1, true), true},
+		{"NonSync", weylustest.TrackSegment(1, false), false},
+		// data offset before the first sample flags
+		{"DataOffset", traf(box("trun", []byte{0, 0, 0, 0x05, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0, 0})), false},
+		// flags of every sample after the duration
+		{"SampleFlags", traf(box("trun", []byte{0, 0, 0x05, 0, 0, 0, 0, 1, 0, 0, 0, 1, 0, 1, 0, 0})), false},
+		// default sample flags after the default duration
+		{"DefaultFlags", traf(box("tfhd", []byte{0, 0, 0, 0x28, 0, 0, 0, 1, 0, 0, 0, 1, 0, 1, 0, 0}), box("trun", []byte{0, 0, 0, 0, 0, 0, 0, 1})), false},
+		{"Truncated", traf(box("trun", []byte{0, 0, 0, 0x04, 0, 0, 0, 1, 0, 1})), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsKeyframe(tt.fragment); got != tt.want {
+				t.Errorf("IsKeyframe() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzIsKeyframe(f *testing.F) {
+	f.Add(weylustest.MediaSegment(1))
+	f.Add(weylustest.TrackSegment(1, true))
+	f.Add(weylustest.TrackSegment(1, false))
+	f.Fuzz(func(t *testing.T, in []byte) {
+		IsKeyframe(in)
+	})
+}
+
+func TestRecorder_RotateSizeAtKeyframe(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder()
+	init := weylustest.InitSegment()
+	key, delta := weylustest.TrackSegment(1, true), weylustest.TrackSegment(2, false)
+	if err := r.Start(Options{Path: filepath.Join(dir, "rec.mp4"), MaxSize: int64(len(init) + len(key))}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	write(t, r, init, key, delta, delta, key, delta)
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	want := map[string][][]byte{
+		"rec-001.mp4": {init, key, delta, delta},
+		"rec-002.mp4": {init, key, delta},
+	}
+	for name, fragments := range want {
+		if got := readSegment(t, filepath.Join(dir, name)); !bytes.Equal(got, bytes.Join(fragments, nil)) {
+			t.Errorf("segment %s = %x, want %d fragments starting at the keyframe", name, got, len(fragments))
+		}
+	}
+}
+
+func TestRecorder_StartMidGOP(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder()
+	init := weylustest.InitSegment()
+	key, delta := weylustest.TrackSegment(1, true), weylustest.TrackSegment(2, false)
+	write(t, r, init, key)
+	if err := r.Start(Options{Path: filepath.Join(dir, "rec.mp4")}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	write(t, r, delta, delta, key, delta)
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	got := readSegment(t, filepath.Join(dir, "rec-001.mp4"))
+	if want := bytes.Join([][]byte{init, key, delta}, nil); !bytes.Equal(got, want) {
+		t.Errorf("segment = %x, want it to start at the keyframe", got)
+	}
+}
+
+func TestRecorder_RotateDuration(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder()
+	now := time.Unix(0, 0)
+	r.now = func() time.Time { return now }
+	if err := r.Start(Options{Path: filepath.Join(dir, "rec"), MaxDuration: time.Minute}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	write(t, r, weylustest.InitSegment(), weylustest.MediaSegment(1))
+	now = now.Add(30 * time.Second)
+	write(t, r, weylustest.MediaSegment(2))
+	if r.Segment() != filepath.Join(dir, "rec-001.mp4") {
+		t.Errorf("rotated too early to %s", r.Segment())
+	}
+	now = now.Add(30 * time.Second)
+	write(t, r, weylustest.MediaSegment(3))
+	if r.Segment() != filepath.Join(dir, "rec-002.mp4") {
+		t.Errorf("did not rotate, still writing %s", r.Segment())
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func TestRecorder_NoOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "rec-001.mp4"), []byte("keep"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder()
+	if err := r.Start(Options{Path: filepath.Join(dir, "rec.mp4")}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	write(t, r, weylustest.InitSegment(), weylustest.MediaSegment(1))
+	if r.Segment() != filepath.Join(dir, "rec-002.mp4") {
+		t.Errorf("writing to %s", r.Segment())
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := readSegment(t, filepath.Join(dir, "rec-001.mp4")); string(got) != "keep" {
+		t.Error("existing file was overwritten")
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package screenshot saves frames of the video stream as PNG files.
+package screenshot
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TimeFormat is the format of the timestamp in screenshot file names.
+const TimeFormat = "20060102-150405.000"
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sanitize replaces everything that shouldn't be part of a file name with an underscore.
+func sanitize(s string) string {
+	s = strings.Trim(unsafeChars.ReplaceAllString(s, "_"), "_.")
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// FileName returns the file name of a screenshot of capturable on server taken at t.
+func FileName(server, capturable string, t time.Time) string {
+	return fmt.Sprintf("weylus-%s-%s-%s.png", sanitize(server), sanitize(capturable), t.Format(TimeFormat))
+}
+
+// Save encodes img as PNG into dir, named by FileName, and returns the path of the written file.
+func Save(dir string, img image.Image, server, capturable string, t time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", errors.Wrap(err, "create screenshot directory")
+	}
+	path := filepath.Join(dir, FileName(server, capturable, t))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", errors.Wrap(err, "create screenshot")
+	}
+	if err := png.Encode(f, img); err != nil {
+		_ = f.Close()
+		return "", errors.Wrap(err, "encode screenshot")
+	}
+	if err := f.Close(); err != nil {
+		return "", errors.Wrap(err, "close screenshot")
+	}
+	return path, nil
+}
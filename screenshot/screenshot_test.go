@@ -0,0 +1,86 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package screenshot
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileName(t *testing.T) {
+	ts := time.Date(2023, 5, 17, 13, 4, 5, 6e6, time.UTC)
+	var tests = []struct {
+		name       string
+		server     string
+		capturable string
+		want       string
+	}{
+		{"plain", "localhost", "Desktop", "weylus-localhost-Desktop-20230517-130405.006.png"},
+		{"monitor", "192.168.0.2", "Monitor: DP-4", "weylus-192.168.0.2-Monitor_DP-4-20230517-130405.006.png"},
+		{"path", "host", "../../etc/passwd", "weylus-host-etc_passwd-20230517-130405.006.png"},
+		{"empty", "", "", "weylus-unknown-unknown-20230517-130405.006.png"},
+		{"ipv6", "::1", "Weylus - 0.11.4", "weylus-1-Weylus_-_0.11.4-20230517-130405.006.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FileName(tt.server, tt.capturable, ts); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "screenshots")
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.SetRGBA(2, 1, color.RGBA{R: 255, A: 255})
+	ts := time.Now()
+
+	path, err := Save(dir, img, "localhost", "Desktop", ts)
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if filepath.Dir(path) != dir || filepath.Base(path) != FileName("localhost", "Desktop", ts) {
+		t.Errorf("saved to unexpected path %s", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	decoded, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.Bounds() != img.Bounds() {
+		t.Errorf("bounds %v, want %v", decoded.Bounds(), img.Bounds())
+	}
+	if r, _, _, _ := decoded.At(2, 1).RGBA(); r != 0xffff {
+		t.Errorf("pixel not preserved: %v", decoded.At(2, 1))
+	}
+
+	if _, err := Save(dir, img, "localhost", "Desktop", ts); err == nil {
+		t.Error("existing screenshot was overwritten")
+	}
+}
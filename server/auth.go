@@ -0,0 +1,64 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"nhooyr.io/websocket"
+)
+
+// authTimeout is how long a client has to send the access code after connecting.
+const authTimeout = 10 * time.Second
+
+// errWrongAccessCode is returned for clients not knowing the access code.
+var errWrongAccessCode = errors.New("wrong access code")
+
+// checkAccessCode reports whether code is the access code, or no access code is set.
+func (s *WeylusServer) checkAccessCode(code string) bool {
+	accessCode := *s.accessCode.Load()
+	return accessCode == "" || subtle.ConstantTimeCompare([]byte(code), []byte(accessCode)) == 1
+}
+
+// requiresAccessCode reports whether clients have to know an access code.
+func (s *WeylusServer) requiresAccessCode() bool {
+	return *s.accessCode.Load() != ""
+}
+
+// readAccessCode reads the access code sent as first message, like the web client does, by clients that didn't send
+// it in the query of r. The query is checked before the handshake.
+func (s *WeylusServer) readAccessCode(ctx context.Context, r *http.Request, c *websocket.Conn) error {
+	if !s.requiresAccessCode() || r.URL.Query().Has(accessCodeParam) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, authTimeout)
+	defer cancel()
+	typ, data, err := c.Read(ctx)
+	if err != nil {
+		return errors.Wrap(err, "read access code")
+	}
+	if typ != websocket.MessageText || !s.checkAccessCode(string(data)) {
+		return errWrongAccessCode
+	}
+	return nil
+}
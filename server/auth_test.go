@@ -0,0 +1,135 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+func TestWebsite_AccessCode(t *testing.T) {
+	s := NewWeylusServer(context.Background(), newTestAssets(t), "localhost", 1701, 9001)
+	s.SetAccessCode("secret")
+	var tests = []struct {
+		path      string
+		wantIndex bool
+	}{
+		{"/", false},
+		{"/?access_code=wrong", false},
+		{"/?access_code=secret", true},
+		{"/index.html?access_code=secret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.websiteServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if gotIndex := strings.Contains(w.Body.String(), `run("secret"`); gotIndex != tt.wantIndex {
+				t.Errorf("served index = %v, want %v", gotIndex, tt.wantIndex)
+			}
+			if !tt.wantIndex && !strings.Contains(w.Body.String(), `name="access_code"`) {
+				t.Error("access code page not served")
+			}
+		})
+	}
+
+	s.SetAccessCode("")
+	w := httptest.NewRecorder()
+	s.websiteServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(w.Body.String(), `run("",`) {
+		t.Error("index not served without access code")
+	}
+}
+
+// getCapturableList asks for the capturable list, which only authenticated clients get.
+func getCapturableList(ctx context.Context, c *websocket.Conn) error {
+	if err := wsjson.Write(ctx, c, "GetCapturableList"); err != nil {
+		return err
+	}
+	var list protocol.CapturableList
+	return wsjson.Read(ctx, c, &list)
+}
+
+func TestWebsocket_AccessCode(t *testing.T) {
+	s := NewWeylusServer(context.Background(), newTestAssets(t), "localhost", 1701, 9001)
+	s.SetAccessCode("secret")
+	srv := httptest.NewServer(s.websocketServer.Handler)
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	t.Run("query", func(t *testing.T) {
+		c, _, err := websocket.Dial(ctx, url+"?access_code=secret", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		if err := getCapturableList(ctx, c); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("wrong query", func(t *testing.T) {
+		_, res, err := websocket.Dial(ctx, url+"?access_code=wrong", nil)
+		if err == nil {
+			t.Fatal("handshake with wrong access code succeeded")
+		}
+		if res == nil || res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("response = %v, want 401", res)
+		}
+	})
+	t.Run("first message", func(t *testing.T) {
+		c, _, err := websocket.Dial(ctx, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		if err := c.Write(ctx, websocket.MessageText, []byte("secret")); err != nil {
+			t.Fatal(err)
+		}
+		if err := getCapturableList(ctx, c); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("wrong first message", func(t *testing.T) {
+		c, _, err := websocket.Dial(ctx, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		if err := c.Write(ctx, websocket.MessageText, []byte(`"GetCapturableList"`)); err != nil {
+			t.Fatal(err)
+		}
+		var list protocol.CapturableList
+		err = wsjson.Read(ctx, c, &list)
+		if websocket.CloseStatus(err) != websocket.StatusPolicyViolation {
+			t.Errorf("read = %v, want close with policy violation", err)
+		}
+	})
+}
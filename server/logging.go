@@ -0,0 +1,103 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/rs/zerolog"
+	"nhooyr.io/websocket"
+)
+
+// accessCodeParam is the query parameter carrying the access code.
+const accessCodeParam = "access_code"
+
+// redacted replaces values that must not end up in logs.
+const redacted = "REDACTED"
+
+// redactedCommands carry keystrokes or clipboard contents, they are logged without content unless redaction is
+// disabled.
+var redactedCommands = map[protocol.WeylusCommand]bool{
+	protocol.WeylusCommandKeyboardEvent:    true,
+	protocol.WeylusCommandClipboardContent: true,
+}
+
+// sampledCommands are sent many times a second while the client is used, only some of them are logged.
+var sampledCommands = map[protocol.WeylusCommand]bool{
+	protocol.WeylusCommandPointerEvent: true,
+	protocol.WeylusCommandWheelEvent:   true,
+	protocol.WeylusCommandGamepadEvent: true,
+}
+
+// newEventSampler returns the sampler of sampledCommands, logging bursts of events at the start of a gesture
+// and every 100th event after.
+func newEventSampler() zerolog.Sampler {
+	return &zerolog.BurstSampler{
+		Burst:       5,
+		Period:      time.Second,
+		NextSampler: &zerolog.BasicSampler{N: 100},
+	}
+}
+
+// redactURL returns u with the access code replaced.
+func redactURL(u *url.URL) *url.URL {
+	q := u.Query()
+	if !q.Has(accessCodeParam) {
+		return u
+	}
+	q.Set(accessCodeParam, redacted)
+	r := *u
+	r.RawQuery = q.Encode()
+	return &r
+}
+
+// logMessage logs a message received from the client of sess at debug level.
+func (sess *session) logMessage(typ websocket.MessageType, data []byte) {
+	l := zerolog.Ctx(sess.ctx)
+	if typ != websocket.MessageText {
+		l.Debug().Str("type", typ.String()).Int("size", len(data)).Msg("received data")
+		return
+	}
+	var msg map[protocol.WeylusCommand]json.RawMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		var name string
+		if err := json.Unmarshal(data, &name); err == nil {
+			l.Debug().Str("command", name).Msg("received command")
+			return
+		}
+		l.Debug().Int("size", len(data)).Msg("received invalid data")
+		return
+	}
+	for command, content := range msg {
+		e := l.Debug()
+		if sampledCommands[command] {
+			e = sess.sampled.Debug()
+		}
+		e = e.Stringer("command", command)
+		if sess.unredacted || !redactedCommands[command] {
+			e = e.RawJSON("data", content)
+		} else {
+			e = e.Str("data", redacted).Int("size", len(content))
+		}
+		e.Msg("received command")
+	}
+}
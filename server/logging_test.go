@@ -0,0 +1,101 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"nhooyr.io/websocket"
+)
+
+func TestRedactURL(t *testing.T) {
+	var tests = []struct {
+		in   string
+		want string
+	}{
+		{"/", "/"},
+		{"/?access_code=secret", "/?access_code=REDACTED"},
+		{"/?a=b&access_code=secret", "/?a=b&access_code=REDACTED"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := redactURL(u).String(); got != tt.want {
+			t.Errorf("redactURL(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+		if u.String() != tt.in {
+			t.Errorf("redactURL(%s) modified its argument", tt.in)
+		}
+	}
+}
+
+func TestSession_logMessage(t *testing.T) {
+	var tests = []struct {
+		name       string
+		data       string
+		unredacted bool
+		contains   string
+		excludes   string
+	}{
+		{"keyboard", `{"KeyboardEvent":{"key":"p"}}`, false, `"data":"REDACTED"`, `"key"`},
+		{"keyboard unredacted", `{"KeyboardEvent":{"key":"p"}}`, true, `"key":"p"`, "REDACTED"},
+		{"clipboard", `{"ClipboardContent":{"data":"c2VjcmV0"}}`, false, `"size"`, "c2VjcmV0"},
+		{"config", `{"Config":{"client_name":"tablet"}}`, false, `"client_name":"tablet"`, "REDACTED"},
+		{"plain command", `"GetCapturableList"`, false, `"command":"GetCapturableList"`, ""},
+		{"invalid", `{"KeyboardEvent"`, false, "received invalid data", "KeyboardEvent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := zerolog.New(&buf)
+			sess := &session{ctx: l.WithContext(context.Background()), sampled: l, unredacted: tt.unredacted}
+			sess.logMessage(websocket.MessageText, []byte(tt.data))
+			if !strings.Contains(buf.String(), tt.contains) {
+				t.Errorf("log %s doesn't contain %s", buf.String(), tt.contains)
+			}
+			if tt.excludes != "" && strings.Contains(buf.String(), tt.excludes) {
+				t.Errorf("log %s contains %s", buf.String(), tt.excludes)
+			}
+		})
+	}
+}
+
+func TestSession_logMessage_Sampled(t *testing.T) {
+	var buf bytes.Buffer
+	l := zerolog.New(&buf)
+	sess := &session{ctx: l.WithContext(context.Background()), sampled: l.Sample(newEventSampler())}
+	for i := 0; i < 50; i++ {
+		sess.logMessage(websocket.MessageText, []byte(`{"PointerEvent":{"x":1}}`))
+	}
+	if n := strings.Count(buf.String(), "\n"); n >= 50 || n == 0 {
+		t.Errorf("logged %d of 50 pointer events", n)
+	}
+	buf.Reset()
+	sess.logMessage(websocket.MessageBinary, make([]byte, 10))
+	if !strings.Contains(buf.String(), `"size":10`) {
+		t.Errorf("binary message logged as %s", buf.String())
+	}
+}
@@ -19,26 +19,33 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
 	"encoding/json"
-	"html/template"
 	"net"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/OmegaRogue/weylus-desktop/clipboard"
+	"github.com/OmegaRogue/weylus-desktop/input"
+	"github.com/OmegaRogue/weylus-desktop/internal/logger"
 	"github.com/OmegaRogue/weylus-desktop/protocol"
 	"github.com/OmegaRogue/weylus-desktop/utils"
 	"github.com/OmegaRogue/weylus-desktop/web"
 	"github.com/justinas/alice"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
-	"github.com/rs/zerolog/log"
 	"nhooyr.io/websocket"
 	"nhooyr.io/websocket/wsjson"
 )
 
+// shutdownTimeout is how long Run waits for requests to finish when stopping.
+const shutdownTimeout = 5 * time.Second
+
 type data struct {
 	AccessCode           string
 	WebsocketPort        uint16
@@ -53,34 +60,33 @@ type WeylusServer struct {
 	msgs            chan utils.Msg
 	websiteServer   *http.Server
 	websocketServer *http.Server
+	keyWriter       input.KeyWriter
+	keyInjection    input.KeyboardInjection
+	keyLookup       input.KeyLookup
+	mouse           input.EventWriter
+	pointer         input.EventWriter
+	createGamepad   func(index int) (input.GamepadDevice, error)
+	clipboard       clipboard.Provider
+	clipboardConfig clipboard.Profiles
+	newAudioCapture func() (AudioCapture, error)
+	sessions        atomic.Uint64
+	logUnredacted   bool
+	assets          *web.Assets
+	accessCode      atomic.Pointer[string]
 }
 
-func newWeylusWebsiteServer(ctx context.Context, logger *zerolog.Logger, addr string, websocketPort uint16) *http.Server {
+func newWeylusWebsiteServer(ctx context.Context, logger *zerolog.Logger, addr string, websocketPort uint16, s *WeylusServer) *http.Server {
 	mux := http.NewServeMux()
 	c := middleware(logger)
-	h := c.Then(http.HandlerFunc(handleWebsite(websocketPort)))
-	mux.Handle("/", h)
-	mux.Handle("/style.css", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Content-Type", "text/css")
-		if _, err := w.Write([]byte(web.StyleCSS)); err != nil {
-			hlog.FromRequest(r).Err(err).Msg("error on write style.css")
-			w.WriteHeader(http.StatusInternalServerError)
+	index := s.handleWebsite(websocketPort)
+	mux.Handle("/", c.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/", "/" + web.IndexHTMLName:
+			index(w, r)
+		default:
+			s.assets.ServeHTTP(w, r)
 		}
-	}))
-	mux.Handle("/access_code.html", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Content-Type", "text/html")
-		if _, err := w.Write([]byte(web.AccessHTML)); err != nil {
-			hlog.FromRequest(r).Err(err).Msg("error on write access_code.html")
-			w.WriteHeader(http.StatusInternalServerError)
-		}
-	}))
-	mux.Handle("/lib.js", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Content-Type", "text/javascript")
-		if _, err := w.Write([]byte(web.LibJS)); err != nil {
-			hlog.FromRequest(r).Err(err).Msg("error on write lib.js")
-			w.WriteHeader(http.StatusInternalServerError)
-		}
-	}))
+	})))
 	return &http.Server{
 		Addr:              addr,
 		ReadHeaderTimeout: 10 * time.Second,
@@ -90,51 +96,66 @@ func newWeylusWebsiteServer(ctx context.Context, logger *zerolog.Logger, addr st
 		Handler: mux,
 	}
 }
-func newWeylusWebsocketServer(ctx context.Context, logger *zerolog.Logger, addr string) *http.Server {
+func newWeylusWebsocketServer(ctx context.Context, logger *zerolog.Logger, addr string, s *WeylusServer) *http.Server {
 	mux := http.NewServeMux()
 	c := middleware(logger)
 	h := c.Then(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if q := request.URL.Query(); q.Has(accessCodeParam) && !s.checkAccessCode(q.Get(accessCodeParam)) {
+			http.Error(writer, errWrongAccessCode.Error(), http.StatusUnauthorized)
+			return
+		}
 		c, err := websocket.Accept(writer, request, nil)
 		if err != nil {
 			hlog.FromRequest(request).Err(err).Msg("error on accept websocket")
+			return
 		}
 
-		defer func(c *websocket.Conn, code websocket.StatusCode, reason string) {
-			err := c.Close(code, reason)
-			if err != nil {
-				hlog.FromRequest(request).Err(err).Msg("error on close websocket")
+		status, reason := websocket.StatusNormalClosure, ""
+		defer func() {
+			if err := c.Close(status, reason); err != nil {
+				hlog.FromRequest(request).Debug().Err(err).Msg("error on close websocket")
 			}
-		}(c, websocket.StatusInternalError, "the sky is falling")
-		ctx, cancel := context.WithTimeout(request.Context(), time.Second*100)
+		}()
+		ctx, cancel := context.WithCancel(request.Context())
 		defer cancel()
+		if err := s.readAccessCode(ctx, request, c); err != nil {
+			hlog.FromRequest(request).Warn().Err(err).Msg("client not authenticated")
+			status, reason = websocket.StatusPolicyViolation, errWrongAccessCode.Error()
+			return
+		}
+		sess := s.newSession(ctx, hlog.FromRequest(request), c)
+		defer sess.close()
 		for {
-			select {
-			case <-ctx.Done():
-				writer.WriteHeader(http.StatusInternalServerError)
-				if err := c.Close(websocket.StatusInternalError, "the sky is falling"); err != nil {
-					hlog.FromRequest(request).Err(err).Msg("error on close websocket")
-				}
+			typ, data, err := c.Read(ctx)
+			if err != nil {
+				zerolog.Ctx(sess.ctx).Debug().Err(err).Msg("client disconnected")
 				return
-			default:
-				typ, data, err := c.Read(ctx)
-				if err != nil {
-					hlog.FromRequest(request).Fatal().Err(err).Msg("read")
-				}
+			}
 
-				hlog.FromRequest(request).Info().Str("type", typ.String()).RawJSON("data", data).Msg("received data")
-				if typ == websocket.MessageText {
-					var v any
-					if err := json.Unmarshal(data, &v); err != nil {
-						hlog.FromRequest(request).Fatal().Err(err).Msg("unmarshal json")
-					}
-					if v.(string) == "GetCapturableList" {
-						ret := protocol.CapturableList{CapturableList: []string{"no"}}
+			sess.logMessage(typ, data)
+			if typ != websocket.MessageText {
+				continue
+			}
+			var v any
+			if err := json.Unmarshal(data, &v); err != nil {
+				zerolog.Ctx(sess.ctx).Err(err).Msg("unmarshal json")
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				if v == "GetCapturableList" {
+					ret := protocol.CapturableList{CapturableList: []string{"no"}}
 
-						if err := wsjson.Write(ctx, c, &ret); err != nil {
-							hlog.FromRequest(request).Fatal().Err(err).Msg("write")
-						}
+					if err := wsjson.Write(ctx, c, &ret); err != nil {
+						zerolog.Ctx(sess.ctx).Err(err).Msg("write")
+						status, reason = websocket.StatusInternalError, "write failed"
+						return
 					}
 				}
+			case map[string]any:
+				if err := s.handleCommand(sess, data); err != nil {
+					zerolog.Ctx(sess.ctx).Err(err).Msg("handle command")
+				}
 			}
 		}
 	}))
@@ -149,25 +170,183 @@ func newWeylusWebsocketServer(ctx context.Context, logger *zerolog.Logger, addr
 	}
 }
 
-func NewWeylusServer(ctx context.Context, hostname string, websitePort, websocketPort uint16) *WeylusServer {
+// NewWeylusServer returns a server serving the website with assets, which can be replaced while serving with
+// web.Assets.Load, and the websocket on hostname.
+func NewWeylusServer(ctx context.Context, assets *web.Assets, hostname string, websitePort, websocketPort uint16) *WeylusServer {
 	s := new(WeylusServer)
 	s.msgs = make(chan utils.Msg)
-	logger := log.With().Str("component", "server").Logger()
-	ctx = logger.WithContext(ctx)
+	s.assets = assets
+	s.SetAccessCode("")
+	serverLogger := logger.Component("server")
+	ctx = serverLogger.WithContext(ctx)
 	s.websiteAddr = net.JoinHostPort(hostname, strconv.FormatUint(uint64(websitePort), 10))
 	s.websocketAddr = net.JoinHostPort(hostname, strconv.FormatUint(uint64(websocketPort), 10))
-	s.websiteServer = newWeylusWebsiteServer(ctx, &logger, s.websiteAddr, websocketPort)
-	s.websocketServer = newWeylusWebsocketServer(ctx, &logger, s.websocketAddr)
+	s.websiteServer = newWeylusWebsiteServer(ctx, &serverLogger, s.websiteAddr, websocketPort, s)
+	s.websocketServer = newWeylusWebsocketServer(ctx, &serverLogger, s.websocketAddr, s)
 	return s
 }
 
+// SetKeyboard sets the device keyboard events are written to, nil drops keyboard events.
+// Every session injects through its own input.Keyboard so its held keys are released when it disconnects.
+func (s *WeylusServer) SetKeyboard(w input.KeyWriter, mode input.KeyboardInjection, lookup input.KeyLookup) {
+	s.keyWriter = w
+	s.keyInjection = mode
+	s.keyLookup = lookup
+}
+
+// SetRelativeMouse sets the mouse relative pointer events are injected into, nil drops them. Every client gets its
+// own input.RelativeMouse writing to w.
+func (s *WeylusServer) SetRelativeMouse(w input.EventWriter) {
+	s.mouse = w
+}
+
+// SetAbsolutePointer sets the device with the input.PointerAxes absolute pointer events are injected into, nil drops
+// them. Every client gets its own input.AbsolutePointer writing to w.
+func (s *WeylusServer) SetAbsolutePointer(w input.EventWriter) {
+	s.pointer = w
+}
+
+// SetGamepads sets how the virtual gamepads gamepad events are injected into are created, e.g.
+// input.NewUInputGamepad, nil drops gamepad events. Every client gets its own input.Gamepads, removed when it
+// disconnects.
+func (s *WeylusServer) SetGamepads(create func(index int) (input.GamepadDevice, error)) {
+	s.createGamepad = create
+}
+
+// SetClipboard sets the clipboard synchronized with the clients whose profile in profiles enables it, nil disables
+// clipboard synchronization. Every client synchronizes on its own, p shouldn't be a clipboard.Watcher as only one of
+// them would be notified.
+func (s *WeylusServer) SetClipboard(p clipboard.Provider, profiles clipboard.Profiles) {
+	s.clipboard = p
+	s.clipboardConfig = profiles
+}
+
+// SetLogUnredacted sets whether keystrokes and clipboard contents received from clients are logged, by default they
+// are redacted.
+func (s *WeylusServer) SetLogUnredacted(unredacted bool) {
+	s.logUnredacted = unredacted
+}
+
+// SetAccessCode sets the access code clients have to know, an empty code lets everyone in. It can be changed while
+// serving, it is checked when a client connects, so sessions already authenticated stay open.
+func (s *WeylusServer) SetAccessCode(code string) {
+	s.accessCode.Store(&code)
+}
+
+// SetAudio sets how the audio track is captured for clients asking for it, nil disables audio.
+func (s *WeylusServer) SetAudio(newCapture func() (AudioCapture, error)) {
+	s.newAudioCapture = newCapture
+}
+
+// handleCommand handles a command of sess wrapped by protocol.WrapMessage.
+func (s *WeylusServer) handleCommand(sess *session, data []byte) error {
+	var msg map[protocol.WeylusCommand]json.RawMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return errors.Wrap(err, "unmarshal command")
+	}
+	for command, content := range msg {
+		switch command {
+		case protocol.WeylusCommandKeyboardEvent:
+			var e protocol.KeyboardEvent
+			if err := json.Unmarshal(content, &e); err != nil {
+				return errors.Wrap(err, "unmarshal KeyboardEvent")
+			}
+			if sess.keyboard == nil {
+				continue
+			}
+			if err := sess.keyboard.Inject(e); err != nil {
+				return errors.Wrap(err, "inject KeyboardEvent")
+			}
+		case protocol.WeylusCommandPointerEvent:
+			var e protocol.PointerEvent
+			if err := json.Unmarshal(content, &e); err != nil {
+				return errors.Wrap(err, "unmarshal PointerEvent")
+			}
+			sess.relative = e.Relative
+			switch {
+			case e.Relative && sess.mouse != nil:
+				if err := sess.mouse.Inject(e); err != nil {
+					return errors.Wrap(err, "inject PointerEvent")
+				}
+			case !e.Relative && sess.pointer != nil:
+				if err := sess.pointer.Inject(e); err != nil {
+					return errors.Wrap(err, "inject PointerEvent")
+				}
+			}
+		case protocol.WeylusCommandWheelEvent:
+			var e protocol.WheelEvent
+			if err := json.Unmarshal(content, &e); err != nil {
+				return errors.Wrap(err, "unmarshal WheelEvent")
+			}
+			if err := sess.wheel(e); err != nil {
+				return errors.Wrap(err, "inject WheelEvent")
+			}
+		case protocol.WeylusCommandGamepadEvent:
+			var e protocol.GamepadEvent
+			if err := json.Unmarshal(content, &e); err != nil {
+				return errors.Wrap(err, "unmarshal GamepadEvent")
+			}
+			if sess.gamepads == nil {
+				continue
+			}
+			if err := sess.gamepads.Inject(e); err != nil {
+				return errors.Wrap(err, "inject GamepadEvent")
+			}
+		case protocol.WeylusCommandConfig:
+			var config protocol.Config
+			if err := json.Unmarshal(content, &config); err != nil {
+				return errors.Wrap(err, "unmarshal Config")
+			}
+			sess.setClientName(config.ClientName)
+			s.startClipboard(sess, config.ClientName)
+			if config.Audio {
+				s.startAudio(sess)
+			}
+		case protocol.WeylusCommandClipboardOffer:
+			var o protocol.ClipboardOffer
+			if err := json.Unmarshal(content, &o); err != nil {
+				return errors.Wrap(err, "unmarshal ClipboardOffer")
+			}
+			if sess.clipboard == nil {
+				continue
+			}
+			if err := sess.clipboard.HandleOffer(o); err != nil {
+				return errors.Wrap(err, "handle ClipboardOffer")
+			}
+		case protocol.WeylusCommandClipboardRequest:
+			var r protocol.ClipboardRequest
+			if err := json.Unmarshal(content, &r); err != nil {
+				return errors.Wrap(err, "unmarshal ClipboardRequest")
+			}
+			if sess.clipboard == nil {
+				continue
+			}
+			if err := sess.clipboard.HandleRequest(r); err != nil {
+				return errors.Wrap(err, "handle ClipboardRequest")
+			}
+		case protocol.WeylusCommandClipboardContent:
+			var c protocol.ClipboardContent
+			if err := json.Unmarshal(content, &c); err != nil {
+				return errors.Wrap(err, "unmarshal ClipboardContent")
+			}
+			if sess.clipboard == nil {
+				continue
+			}
+			if err := sess.clipboard.HandleContent(sess.ctx, c); err != nil {
+				return errors.Wrap(err, "handle ClipboardContent")
+			}
+		}
+	}
+	return nil
+}
+
 func middleware(logger *zerolog.Logger) alice.Chain {
 	c := alice.New()
 	c = c.Append(hlog.NewHandler(*logger))
 	c = c.Append(hlog.AccessHandler(func(r *http.Request, status, size int, duration time.Duration) {
 		hlog.FromRequest(r).Info().
 			Str("method", r.Method).
-			Stringer("url", r.URL).
+			Stringer("url", redactURL(r.URL)).
 			Int("status", status).
 			Int("size", size).
 			Dur("duration", duration).
@@ -181,53 +360,65 @@ func middleware(logger *zerolog.Logger) alice.Chain {
 	return c
 }
 
-func (s *WeylusServer) RunWebsite() {
-	if err := s.websiteServer.ListenAndServe(); err != nil {
-		log.Fatal().Err(err).Msg("website failed")
+// Run serves the website and the websocket until ctx is done or serving one of them fails.
+func (s *WeylusServer) Run(ctx context.Context) error {
+	errs := make(chan error, 2)
+	go func() {
+		errs <- errors.Wrap(s.websiteServer.ListenAndServe(), "serve website")
+	}()
+	go func() {
+		errs <- errors.Wrap(s.websocketServer.ListenAndServe(), "serve websocket")
+	}()
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
 	}
-}
-func (s *WeylusServer) RunWebsocket() {
-	if err := s.websocketServer.ListenAndServe(); err != nil {
-		log.Info().Err(err).Msg("websocket failed")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if shutdownErr := s.websiteServer.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+		err = errors.Wrap(shutdownErr, "shut down website")
+	}
+	if shutdownErr := s.websocketServer.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+		err = errors.Wrap(shutdownErr, "shut down websocket")
 	}
+	return err
 }
 
-func handleWebsite(websocketPort uint16) func(w http.ResponseWriter, r *http.Request) {
+func (s *WeylusServer) handleWebsite(websocketPort uint16) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Header().Add("Content-Type", "text/html")
 		d := getBaseConfig()
 		d.WebsocketPort = websocketPort
 
-		// TODO add authentication
-		// authed := false
-		// if accessCode := viper.GetString("access-code"); accessCode != "" {
-		// 	if code := r.URL.Query().Get("access_code"); code != "" {
-		// 		d.AccessCode = code
-		// 		authed = true
-		// 		hlog.FromRequest(r).Debug().Msg("web client authenticated")
-		// 	}
-		// } else {
-		// 	authed = true
-		// }
-		//
-		// if !authed {
-		// 	w.Header().Add("Content-Type", "text/html")
-		// 	if _, err := w.Write([]byte(web.AccessHTML)); err != nil {
-		// 		hlog.FromRequest(r).Err(err).Msg("error on write access_code.html")
-		// 		w.WriteHeader(http.StatusInternalServerError)
-		// 	}
-		// 	return
-		// }
-
-		tmpl, err := template.New("IndexHTML").Parse(web.IndexHTML)
-		if err != nil {
-			hlog.FromRequest(r).Err(err).Msg("error on parse template")
-			w.WriteHeader(http.StatusInternalServerError)
+		if s.requiresAccessCode() {
+			code := r.URL.Query().Get(accessCodeParam)
+			if !s.checkAccessCode(code) {
+				if code != "" {
+					hlog.FromRequest(r).Warn().Msg("wrong access code")
+				}
+				r = r.Clone(r.Context())
+				r.URL.Path = "/" + web.AccessHTMLName
+				s.assets.ServeHTTP(w, r)
+				return
+			}
+			// the web client sends it to the websocket
+			d.AccessCode = code
+			hlog.FromRequest(r).Debug().Msg("web client authenticated")
 		}
-		if err := tmpl.Execute(w, d); err != nil {
+
+		// execute into a buffer first, the status can't be changed once written
+		var buf bytes.Buffer
+		if err := s.assets.Index().Execute(&buf, d); err != nil {
 			hlog.FromRequest(r).Err(err).Msg("error on execute template")
-			w.WriteHeader(http.StatusInternalServerError)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// the page holds the access code
+		w.Header().Set("Cache-Control", "no-store")
+		if _, err := buf.WriteTo(w); err != nil {
+			hlog.FromRequest(r).Err(err).Msg("error on write index.html")
 		}
 	}
 }
@@ -0,0 +1,117 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/web"
+)
+
+// newTestAssets returns the assets of the web package with a stand-in for the web client, which is only compiled by
+// go generate.
+func newTestAssets(t *testing.T) *web.Assets {
+	t.Helper()
+	fsys := fstest.MapFS{"static/" + web.LibJSName: {}}
+	for _, name := range []string{"static/" + web.AccessHTMLName, "static/" + web.StyleCSSName, "templates/index.gohtml"} {
+		data, err := os.ReadFile(filepath.Join("..", "web", filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys[name] = &fstest.MapFile{Data: data}
+	}
+	assets, err := web.NewAssetsFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assets
+}
+
+func TestWebsite(t *testing.T) {
+	s := NewWeylusServer(context.Background(), newTestAssets(t), "localhost", 1701, 9001)
+	var tests = []struct {
+		path       string
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{"/", http.StatusOK, "text/html", `"9001"`},
+		{"/index.html", http.StatusOK, "text/html", `"9001"`},
+		{"/static/style.css", http.StatusOK, "text/css", ""},
+		{"/missing.html", http.StatusNotFound, "text/plain", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.websiteServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("Content-Type = %s, want %s", got, tt.wantType)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body doesn't contain %s", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestWebsite_InvalidTemplate(t *testing.T) {
+	assets := newTestAssets(t)
+	s := NewWeylusServer(context.Background(), assets, "localhost", 1701, 9001)
+	// parses, but fails executing
+	if err := assets.Set(web.IndexHTMLName, "<p>{{.Missing}}</p>"); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	s.websiteServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(w.Body.String(), "<p>") {
+		t.Errorf("body = %q, want no partial page", w.Body.String())
+	}
+}
+
+func TestWeylusServer_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewWeylusServer(ctx, newTestAssets(t), "localhost", 0, 0)
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() = %v, want nil after cancel", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return after cancel")
+	}
+}
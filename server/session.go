@@ -0,0 +1,204 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/OmegaRogue/weylus-desktop/clipboard"
+	"github.com/OmegaRogue/weylus-desktop/input"
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// audioQueueSize is how many audio frames wait to be sent before the capture blocks.
+const audioQueueSize = 16
+
+// clipboardPollInterval is how often clipboards that don't tell about changes are checked.
+const clipboardPollInterval = time.Second
+
+// session is the state of a websocket connection.
+type session struct {
+	ctx       context.Context
+	conn      *websocket.Conn
+	clipboard *clipboard.Sync
+	keyboard  *input.Keyboard
+	mouse     *input.RelativeMouse
+	pointer   *input.AbsolutePointer
+	gamepads  *input.Gamepads
+	// relative is whether the last pointer event was relative, wheel events go to the device of that mode.
+	relative   bool
+	audio      bool
+	clientName string
+	// sampled logs only some of the frequent input events.
+	sampled zerolog.Logger
+	// unredacted logs keystrokes and clipboard contents.
+	unredacted bool
+}
+
+// newSession returns the session of conn, whose messages are logged by l with the session number.
+func (s *WeylusServer) newSession(ctx context.Context, l *zerolog.Logger, conn *websocket.Conn) *session {
+	sessLogger := l.With().Uint64("session", s.sessions.Add(1)).Logger()
+	sess := &session{
+		ctx:        sessLogger.WithContext(ctx),
+		conn:       conn,
+		sampled:    sessLogger.Sample(newEventSampler()),
+		unredacted: s.logUnredacted,
+	}
+	if s.keyWriter != nil {
+		sess.keyboard = input.NewKeyboard(s.keyWriter, s.keyInjection, s.keyLookup)
+	}
+	if s.mouse != nil {
+		sess.mouse = input.NewRelativeMouse(s.mouse)
+	}
+	if s.pointer != nil {
+		sess.pointer = input.NewAbsolutePointer(s.pointer)
+	}
+	if s.createGamepad != nil {
+		sess.gamepads = input.NewGamepads(s.createGamepad)
+	}
+	return sess
+}
+
+// close releases the input of sess, keys and buttons held when the client went away would stay pressed, and removes its
+// gamepads.
+func (sess *session) close() {
+	if sess.keyboard != nil {
+		if err := sess.keyboard.Release(); err != nil {
+			zerolog.Ctx(sess.ctx).Err(err).Msg("release keys")
+		}
+	}
+	if sess.mouse != nil {
+		if err := sess.mouse.Release(); err != nil {
+			zerolog.Ctx(sess.ctx).Err(err).Msg("release mouse buttons")
+		}
+	}
+	if sess.pointer != nil {
+		if err := sess.pointer.Release(); err != nil {
+			zerolog.Ctx(sess.ctx).Err(err).Msg("release pointer buttons")
+		}
+	}
+	if sess.gamepads != nil {
+		if err := sess.gamepads.Close(); err != nil {
+			zerolog.Ctx(sess.ctx).Err(err).Msg("remove gamepads")
+		}
+	}
+}
+
+// wheel injects e into the device of the mode of the last pointer event of sess.
+func (sess *session) wheel(e protocol.WheelEvent) error {
+	switch {
+	case sess.relative && sess.mouse != nil:
+		return sess.mouse.Wheel(e)
+	case !sess.relative && sess.pointer != nil:
+		return sess.pointer.Wheel(e)
+	}
+	return nil
+}
+
+// setClientName adds the name of the client, once known from its Config, to the log messages of sess.
+// The clipboard and audio goroutines read sess.ctx, once one of them is started the name is ignored.
+func (sess *session) setClientName(name string) {
+	if sess.clientName != "" || name == "" || sess.clipboard != nil || sess.audio {
+		return
+	}
+	sess.clientName = name
+	sessLogger := zerolog.Ctx(sess.ctx).With().Str("client", name).Logger()
+	sess.ctx = sessLogger.WithContext(sess.ctx)
+	sess.sampled = sessLogger.Sample(newEventSampler())
+}
+
+// send writes content to the client wrapped in the response.
+func send[T any](sess *session, response protocol.WeylusResponse, content T) error {
+	if err := wsjson.Write(sess.ctx, sess.conn, map[protocol.WeylusResponse]T{response: content}); err != nil {
+		return errors.Wrap(err, string(response))
+	}
+	return nil
+}
+
+// SendClipboardOffer implements clipboard.Sender.
+func (sess *session) SendClipboardOffer(o protocol.ClipboardOffer) error {
+	return send(sess, protocol.WeylusResponseClipboardOffer, o)
+}
+
+// SendClipboardRequest implements clipboard.Sender.
+func (sess *session) SendClipboardRequest(r protocol.ClipboardRequest) error {
+	return send(sess, protocol.WeylusResponseClipboardRequest, r)
+}
+
+// SendClipboardContent implements clipboard.Sender.
+func (sess *session) SendClipboardContent(c protocol.ClipboardContent) error {
+	return send(sess, protocol.WeylusResponseClipboardContent, c)
+}
+
+// startClipboard starts synchronizing the clipboard with the client of sess if its profile enables it.
+func (s *WeylusServer) startClipboard(sess *session, clientName string) {
+	if s.clipboard == nil || sess.clipboard != nil {
+		return
+	}
+	profile := s.clipboardConfig.For(clientName)
+	if !profile.Enabled {
+		zerolog.Ctx(sess.ctx).Debug().Msg("clipboard synchronization disabled")
+		return
+	}
+	sess.clipboard = clipboard.NewSync(s.clipboard, sess, profile)
+	go sess.clipboard.Run(sess.ctx, clipboardPollInterval)
+}
+
+// AudioCapture captures the audio track, gstreamer.AudioCapture implements it.
+type AudioCapture interface {
+	// Run captures until ctx is done and sends the frames to frames.
+	Run(ctx context.Context, frames chan<- protocol.AudioFrame) error
+}
+
+// startAudio starts sending the audio track to the client of sess as binary messages.
+func (s *WeylusServer) startAudio(sess *session) {
+	if s.newAudioCapture == nil || sess.audio {
+		return
+	}
+	capture, err := s.newAudioCapture()
+	if err != nil {
+		zerolog.Ctx(sess.ctx).Err(err).Msg("create audio capture")
+		return
+	}
+	sess.audio = true
+	frames := make(chan protocol.AudioFrame, audioQueueSize)
+	go func() {
+		defer close(frames)
+		if err := capture.Run(sess.ctx, frames); err != nil {
+			zerolog.Ctx(sess.ctx).Err(err).Msg("capture audio")
+		}
+	}()
+	go func() {
+		for frame := range frames {
+			data, err := frame.MarshalBinary()
+			if err != nil {
+				zerolog.Ctx(sess.ctx).Err(err).Msg("encode audio frame")
+				continue
+			}
+			if err := sess.conn.Write(sess.ctx, websocket.MessageBinary, data); err != nil {
+				zerolog.Ctx(sess.ctx).Debug().Err(err).Msg("send audio frame")
+			}
+		}
+	}()
+}
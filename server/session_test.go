@@ -0,0 +1,182 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/OmegaRogue/weylus-desktop/input"
+	"github.com/OmegaRogue/weylus-desktop/protocol"
+	"github.com/holoplot/go-evdev"
+	"github.com/rs/zerolog"
+)
+
+type fakeEventWriter struct {
+	frames [][]evdev.InputEvent
+}
+
+func (w *fakeEventWriter) WriteFrame(events ...evdev.InputEvent) error {
+	w.frames = append(w.frames, events)
+	return nil
+}
+
+type fakeKeyWriter struct {
+	writes []evdev.InputEvent
+}
+
+func (w *fakeKeyWriter) WriteKey(code evdev.EvCode, value int32) error {
+	w.writes = append(w.writes, evdev.InputEvent{Type: evdev.EV_KEY, Code: code, Value: value})
+	return nil
+}
+
+func TestSession_close(t *testing.T) {
+	mouse, pointer := new(fakeEventWriter), new(fakeEventWriter)
+	s := &WeylusServer{mouse: mouse, pointer: pointer}
+	l := zerolog.Nop()
+	a := s.newSession(context.Background(), &l, nil)
+	b := s.newSession(context.Background(), &l, nil)
+
+	down := `{"PointerEvent":{"event_type":"pointerdown","relative":true,"is_primary":true,"buttons":1}}`
+	if err := s.handleCommand(a, []byte(down)); err != nil {
+		t.Fatal(err)
+	}
+	b.close()
+	if len(mouse.frames) != 1 {
+		t.Fatalf("closing another session wrote %d frames, want 0", len(mouse.frames)-1)
+	}
+	a.close()
+	if len(mouse.frames) != 2 {
+		t.Fatalf("closing the session wrote %d frames, want 1", len(mouse.frames)-1)
+	}
+	if got := mouse.frames[1]; len(got) != 1 || got[0].Code != evdev.BTN_LEFT || got[0].Value != 0 {
+		t.Errorf("closing the session wrote %v, want BTN_LEFT released", got)
+	}
+	if len(pointer.frames) != 0 {
+		t.Errorf("relative events wrote %d frames to the absolute pointer", len(pointer.frames))
+	}
+}
+
+func TestSession_closeKeyboard(t *testing.T) {
+	keys := new(fakeKeyWriter)
+	s := new(WeylusServer)
+	s.SetKeyboard(keys, input.KeyboardInjectionCode, nil)
+	l := zerolog.Nop()
+	a := s.newSession(context.Background(), &l, nil)
+	b := s.newSession(context.Background(), &l, nil)
+
+	if err := s.handleCommand(a, []byte(`{"KeyboardEvent":{"event_type":"down","code":"KeyA","key":"a"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	b.close()
+	if len(keys.writes) != 1 {
+		t.Fatalf("closing another session wrote %v", keys.writes[1:])
+	}
+	a.close()
+	if len(keys.writes) != 2 || keys.writes[1].Code != evdev.KEY_A || keys.writes[1].Value != input.KeyReleased {
+		t.Errorf("closing the session wrote %v, want KEY_A released", keys.writes[1:])
+	}
+}
+
+// fakeAudioCapture sends the ctx it runs with to started and captures nothing.
+type fakeAudioCapture struct {
+	started chan context.Context
+}
+
+func (c fakeAudioCapture) Run(ctx context.Context, _ chan<- protocol.AudioFrame) error {
+	c.started <- ctx
+	<-ctx.Done()
+	return nil
+}
+
+func TestSession_setClientNameAfterStart(t *testing.T) {
+	capture := fakeAudioCapture{started: make(chan context.Context, 1)}
+	s := &WeylusServer{newAudioCapture: func() (AudioCapture, error) { return capture, nil }}
+	l := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sess := s.newSession(ctx, &l, nil)
+
+	if err := s.handleCommand(sess, []byte(`{"Config":{"audio":true}}`)); err != nil {
+		t.Fatal(err)
+	}
+	started := <-capture.started
+	if err := s.handleCommand(sess, []byte(`{"Config":{"client_name":"tablet","audio":true}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if sess.ctx != started || sess.clientName != "" {
+		t.Errorf("client name %q replaced the context of the running audio capture", sess.clientName)
+	}
+}
+
+func TestSession_wheel(t *testing.T) {
+	mouse, pointer := new(fakeEventWriter), new(fakeEventWriter)
+	s := &WeylusServer{mouse: mouse, pointer: pointer}
+	l := zerolog.Nop()
+	sess := s.newSession(context.Background(), &l, nil)
+	wheel := protocol.WheelEvent{Dy: 120}
+
+	if err := sess.wheel(wheel); err != nil {
+		t.Fatal(err)
+	}
+	sess.relative = true
+	if err := sess.wheel(wheel); err != nil {
+		t.Fatal(err)
+	}
+	if len(pointer.frames) != 1 || len(mouse.frames) != 1 {
+		t.Errorf("wheel wrote %d frames to the pointer and %d to the mouse, want 1 each",
+			len(pointer.frames), len(mouse.frames))
+	}
+}
+
+type fakeGamepad struct {
+	fakeEventWriter
+	closed bool
+}
+
+func (g *fakeGamepad) Close() error {
+	g.closed = true
+	return nil
+}
+
+func TestSession_closeGamepads(t *testing.T) {
+	var pads []*fakeGamepad
+	s := &WeylusServer{createGamepad: func(int) (input.GamepadDevice, error) {
+		pad := new(fakeGamepad)
+		pads = append(pads, pad)
+		return pad, nil
+	}}
+	l := zerolog.Nop()
+	a := s.newSession(context.Background(), &l, nil)
+	b := s.newSession(context.Background(), &l, nil)
+
+	connect := `{"GamepadEvent":{"index":0,"connected":true,"buttons":1}}`
+	for _, sess := range []*session{a, b} {
+		if err := s.handleCommand(sess, []byte(connect)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pads) != 2 {
+		t.Fatalf("created %d gamepads, want one per session", len(pads))
+	}
+	b.close()
+	if pads[0].closed || !pads[1].closed {
+		t.Errorf("closing a session removed gamepads %v, want only its own", []bool{pads[0].closed, pads[1].closed})
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Names of the assets served by the website.
+const (
+	AccessHTMLName = "access_code.html"
+	IndexHTMLName  = "index.html"
+	LibJSName      = "lib.js"
+	StyleCSSName   = "style.css"
+)
+
+// staticNames are the assets served as they are, unlike the index.html template.
+var staticNames = []string{AccessHTMLName, LibJSName, StyleCSSName}
+
+// Asset is a file served by the website, with its compressed copies.
+type Asset struct {
+	Content     []byte
+	ContentType string
+	// ETag identifies Content, the gzip copy adds its encoding.
+	ETag string
+	// Gzip is nil if compressing doesn't make Content smaller.
+	Gzip []byte
+	// Brotli is only set for embedded files with a copy compressed by go generate.
+	Brotli []byte
+	// BrotliETag identifies Brotli, it is taken from the copy itself as the
+	// copy isn't necessarily made from Content.
+	BrotliETag string
+}
+
+// contentETag returns the ETag identifying content.
+func contentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return strconv.Quote(hex.EncodeToString(sum[:16]))
+}
+
+// newAsset returns the asset name with content, compressing it with gzip.
+func newAsset(name string, content []byte) (*Asset, error) {
+	a := &Asset{
+		Content:     content,
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+		ETag:        contentETag(content),
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, errors.Wrap(err, "create gzip writer")
+	}
+	if _, err := w.Write(content); err != nil {
+		return nil, errors.Wrapf(err, "compress %s", name)
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrapf(err, "compress %s", name)
+	}
+	if buf.Len() < len(content) {
+		a.Gzip = buf.Bytes()
+	}
+	return a, nil
+}
+
+// embeddedAsset returns the asset name of fsys.
+func embeddedAsset(fsys fs.FS, name string) (*Asset, error) {
+	content, err := fs.ReadFile(fsys, path.Join(staticDir, name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "read embedded %s", name)
+	}
+	a, err := newAsset(name, content)
+	if err != nil {
+		return nil, err
+	}
+	a.Brotli, err = fs.ReadFile(fsys, path.Join(staticDir, name+".br"))
+	switch {
+	case err == nil:
+		a.BrotliETag = withEncoding(contentETag(a.Brotli), "br")
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, errors.Wrapf(err, "read embedded %s.br", name)
+	}
+	return a, nil
+}
+
+// Assets are the files served by the website. They start as the embedded files
+// and can be replaced while the website is served, e.g. by custom files.
+type Assets struct {
+	mu        sync.RWMutex
+	fsys      fs.FS
+	files     map[string]*Asset
+	indexHTML string
+	index     *template.Template
+}
+
+// NewAssets returns the embedded assets.
+func NewAssets() (*Assets, error) {
+	return NewAssetsFS(files)
+}
+
+// NewAssetsFS returns the assets of fsys, which is laid out like the embedded
+// files, e.g. to test without the compiled web client.
+func NewAssetsFS(fsys fs.FS) (*Assets, error) {
+	a := &Assets{fsys: fsys, files: make(map[string]*Asset)}
+	for _, name := range staticNames {
+		if err := a.Load(name, ""); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.Load(IndexHTMLName, ""); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Get returns the content of the asset name, for index.html the template.
+func (a *Assets) Get(name string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if name == IndexHTMLName {
+		return a.indexHTML
+	}
+	if asset, ok := a.files[name]; ok {
+		return string(asset.Content)
+	}
+	return ""
+}
+
+// Index returns the parsed template of index.html.
+func (a *Assets) Index() *template.Template {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.index
+}
+
+// Set replaces the asset name with content. An invalid index.html template is
+// rejected, keeping the previous one.
+func (a *Assets) Set(name, content string) error {
+	if name == IndexHTMLName {
+		index, err := template.New(IndexHTMLName).Parse(content)
+		if err != nil {
+			return errors.Wrap(err, "parse index.html template")
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.indexHTML = content
+		a.index = index
+		return nil
+	}
+	if !isStatic(name) {
+		return errors.Errorf("unknown asset %s", name)
+	}
+	asset, err := newAsset(name, []byte(content))
+	if err != nil {
+		return err
+	}
+	a.set(name, asset)
+	return nil
+}
+
+func (a *Assets) set(name string, asset *Asset) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.files[name] = asset
+}
+
+// Load replaces the asset name with the file at path, or the embedded file if
+// path is empty. For assets created by NewAssetsFS the embedded files are the
+// ones of its fs.FS.
+func (a *Assets) Load(name, path string) error {
+	switch {
+	case path != "":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "read %s", name)
+		}
+		return a.Set(name, string(data))
+	case name == IndexHTMLName:
+		data, err := fs.ReadFile(a.fsys, indexHTMLPath)
+		if err != nil {
+			return errors.Wrap(err, "read embedded index.html")
+		}
+		return a.Set(name, string(data))
+	case !isStatic(name):
+		return errors.Errorf("unknown asset %s", name)
+	}
+	asset, err := embeddedAsset(a.fsys, name)
+	if err != nil {
+		return err
+	}
+	a.set(name, asset)
+	return nil
+}
+
+func isStatic(name string) bool {
+	for _, n := range staticNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP serves the static assets at /NAME and /static/NAME like
+// http.FileServer, answering conditional requests by ETag and sending the
+// compressed copy the client accepts.
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + r.URL.Path)
+	dir, name := path.Split(p)
+	a.mu.RLock()
+	asset, ok := a.files[name]
+	a.mu.RUnlock()
+	if !ok || (dir != "/" && dir != "/"+staticDir+"/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, etag := asset.Content, asset.ETag
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	switch accept := r.Header.Get("Accept-Encoding"); {
+	case asset.Brotli != nil && acceptsEncoding(accept, "br"):
+		h.Set("Content-Encoding", "br")
+		content, etag = asset.Brotli, asset.BrotliETag
+	case asset.Gzip != nil && acceptsEncoding(accept, "gzip"):
+		h.Set("Content-Encoding", "gzip")
+		content, etag = asset.Gzip, withEncoding(etag, "gzip")
+	}
+	// the headers have to be set before http.ServeContent writes the status
+	if asset.ContentType != "" {
+		h.Set("Content-Type", asset.ContentType)
+	}
+	h.Set("ETag", etag)
+	// assets can be replaced while serving, so clients have to revalidate them
+	h.Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
+
+// withEncoding returns the ETag of the copy of an asset with etag compressed
+// by encoding.
+func withEncoding(etag, encoding string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header accept accepts
+// encoding, ignoring the preference of the client.
+func acceptsEncoding(accept, encoding string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err != nil || v > 0
+	}
+	return false
+}
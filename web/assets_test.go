@@ -0,0 +1,238 @@
+/*
+ * Copyright © 2023 omegarogue
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package web
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testFiles are laid out like the embedded files, which lack the compiled web client before go generate ran.
+var testFiles = fstest.MapFS{
+	"static/" + AccessHTMLName: {Data: []byte("<form></form>")},
+	"static/" + LibJSName:      {Data: []byte("x")},
+	"static/" + StyleCSSName:   {Data: []byte(strings.Repeat("body { margin: 0; }\n", 100))},
+	"static/style.css.br":      {Data: []byte("brotli")},
+	indexHTMLPath:              {Data: []byte("<p>{{.WebsocketPort}}</p>")},
+}
+
+func newTestAssets(t *testing.T) *Assets {
+	t.Helper()
+	a, err := NewAssetsFS(testFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAssets_Set(t *testing.T) {
+	var tests = []struct {
+		name    string
+		asset   string
+		content string
+		wantErr bool
+	}{
+		{"style", StyleCSSName, "body {}", false},
+		{"index", IndexHTMLName, "<p>{{.WebsocketPort}}</p>", false},
+		{"invalid index", IndexHTMLName, "<p>{{.WebsocketPort</p>", true},
+		{"unknown", "favicon.ico", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAssets(t)
+			index, indexHTML := a.Index(), a.Get(IndexHTMLName)
+			err := a.Set(tt.asset, tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if a.Index() != index || a.Get(IndexHTMLName) != indexHTML {
+					t.Error("Set() replaced the index after an error")
+				}
+				return
+			}
+			if got := a.Get(tt.asset); got != tt.content {
+				t.Errorf("Get() = %q, want %q", got, tt.content)
+			}
+			if tt.asset != IndexHTMLName {
+				return
+			}
+			var b strings.Builder
+			if err := a.Index().Execute(&b, struct{ WebsocketPort int }{9001}); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != "<p>9001</p>" {
+				t.Errorf("Index() executes to %q", b.String())
+			}
+		})
+	}
+}
+
+func TestAssets_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "style.css")
+	if err := os.WriteFile(path, []byte("body {}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAssets(t)
+	embedded := a.Get(StyleCSSName)
+	if err := a.Load(StyleCSSName, path); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Get(StyleCSSName); got != "body {}" {
+		t.Errorf("Get() = %q after Load", got)
+	}
+	if err := a.Load(StyleCSSName, filepath.Join(t.TempDir(), "missing.css")); err == nil {
+		t.Error("Load() of a missing file succeeded")
+	}
+	if err := a.Load(StyleCSSName, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Get(StyleCSSName); got != embedded {
+		t.Errorf("Get() = %q, want the embedded file", got)
+	}
+}
+
+func TestAssets_ServeHTTP(t *testing.T) {
+	a := newTestAssets(t)
+	etag := a.files[StyleCSSName].ETag
+	cssType := mime.TypeByExtension(".css")
+
+	var tests = []struct {
+		name         string
+		path         string
+		header       http.Header
+		wantStatus   int
+		wantType     string
+		wantEncoding string
+		wantETag     string
+	}{
+		{"plain", "/style.css", nil, http.StatusOK, cssType, "", etag},
+		{"static dir", "/static/style.css", nil, http.StatusOK, cssType, "", etag},
+		{"gzip", "/style.css", http.Header{"Accept-Encoding": {"br;q=0, gzip"}}, http.StatusOK, cssType, "gzip", withEncoding(etag, "gzip")},
+		{"gzip refused", "/style.css", http.Header{"Accept-Encoding": {"gzip;q=0"}}, http.StatusOK, cssType, "", etag},
+		{"not smaller", "/lib.js", http.Header{"Accept-Encoding": {"gzip"}}, http.StatusOK, mime.TypeByExtension(".js"), "", a.files[LibJSName].ETag},
+		{"not modified", "/style.css", http.Header{"If-None-Match": {etag}}, http.StatusNotModified, "", "", etag},
+		{"changed", "/style.css", http.Header{"If-None-Match": {`"old"`}}, http.StatusOK, cssType, "", etag},
+		{"index template", "/index.html", nil, http.StatusNotFound, "", "", ""},
+		{"unknown", "/favicon.ico", nil, http.StatusNotFound, "", "", ""},
+		{"other dir", "/templates/style.css", nil, http.StatusNotFound, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, r)
+			res := w.Result()
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNotFound {
+				return
+			}
+			if got := res.Header.Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %s, want %s", got, tt.wantETag)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := res.Header.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %s, want %s", got, tt.wantType)
+			}
+			if got := res.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %s, want %s", got, tt.wantEncoding)
+			}
+			body := io.Reader(res.Body)
+			if tt.wantEncoding == "gzip" {
+				var err error
+				if body, err = gzip.NewReader(res.Body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := a.Get(path.Base(tt.path)); string(got) != want {
+				t.Errorf("body = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAssets_ServeHTTP_Brotli(t *testing.T) {
+	a := newTestAssets(t)
+	r := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if got := w.Header().Get("Content-Encoding"); got != "br" {
+		t.Errorf("Content-Encoding = %s, want br", got)
+	}
+	if w.Body.String() != "brotli" {
+		t.Errorf("body = %q, want the brotli copy", w.Body.String())
+	}
+	// the copy may be older than the file, so it is identified by its own content
+	if got, want := w.Header().Get("ETag"), withEncoding(contentETag([]byte("brotli")), "br"); got != want {
+		t.Errorf("ETag = %s, want %s", got, want)
+	}
+
+	// custom files have no brotli copy
+	if err := a.Set(StyleCSSName, strings.Repeat("p {}\n", 100)); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %s, want gzip", got)
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	var tests = []struct {
+		accept   string
+		encoding string
+		want     bool
+	}{
+		{"", "gzip", false},
+		{"gzip", "gzip", true},
+		{"deflate, GZIP", "gzip", true},
+		{"gzip;q=0.5", "gzip", true},
+		{"gzip; q=0", "gzip", false},
+		{"gzip;q=0.0", "gzip", false},
+		{"x-gzip", "gzip", false},
+		{"*", "gzip", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsEncoding(tt.accept, tt.encoding); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", tt.accept, tt.encoding, got, tt.want)
+		}
+	}
+}
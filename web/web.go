@@ -17,18 +17,21 @@
  */
 
 //go:generate tsc
+//go:generate sh -c "if command -v brotli >/dev/null; then brotli -f -k -q 11 static/*.html static/*.css static/*.js; else rm -f static/*.br; fi"
 package web
 
-import _ "embed"
+import "embed"
 
-//go:embed templates/index.gohtml
-var IndexHTML string
+// files are the embedded assets, the compiled web client in static and the
+// index.html template in templates. static also holds the brotli compressed
+// copies FILE.br created by go generate, if brotli is installed. Without
+// brotli go generate removes them, a copy of an older FILE would be embedded.
+//
+//go:embed static templates
+var files embed.FS
 
-//go:embed static/access_code.html
-var AccessHTML string
-
-//go:embed static/lib.js
-var LibJS string
-
-//go:embed static/style.css
-var StyleCSS string
+// Paths of the embedded assets in Files.
+const (
+	indexHTMLPath = "templates/index.gohtml"
+	staticDir     = "static"
+)